@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arnavsurve/gateway-registry/pkg/client"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// servicesFile is the declarative format read by apply
+type servicesFile struct {
+	Services []types.ServiceRegistrationRequest `json:"services"`
+}
+
+// Apply actions
+const (
+	actionCreate = "create"
+	actionUpdate = "update"
+	actionDelete = "delete"
+)
+
+// step is one change apply makes to converge the registry
+type step struct {
+	action    string
+	serviceID string // Empty for creates
+	request   types.ServiceRegistrationRequest
+}
+
+// runApply diffs the services declared in a file against the registry, keyed
+// by external ID, or by namespace, name and URL for services without one, and
+// creates or updates them to match. Give services an external ID to change
+// their name or URL in place rather than replace them. With -prune,
+// local services in the file's namespaces that it doesn't declare are deleted
+// too, so a namespace can be managed entirely from git.
+func runApply(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	file := flags.String("f", "", "YAML or JSON file declaring the services, - for stdin")
+	prune := flags.Bool("prune", false, "Delete services in the file's namespaces that it doesn't declare")
+	dryRun := flags.Bool("dry-run", false, "Print the changes without making them")
+	flags.Parse(args)
+	if *file == "" {
+		return errors.New("-f is required")
+	}
+
+	desired, err := readServices(*file)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	existing, err := c.List(ctx, url.Values{"state": {"all"}, "origin": {"local"}})
+	if err != nil {
+		return fmt.Errorf("listing services: %w", err)
+	}
+
+	steps, unchanged := plan(desired, existing, *prune)
+	for _, s := range steps {
+		fmt.Printf("%s %s/%s\n", s.action, s.request.Namespace, s.request.Name)
+	}
+	if *dryRun {
+		fmt.Printf("%d to change, %d unchanged (dry run)\n", len(steps), unchanged)
+		return nil
+	}
+
+	failed := 0
+	for _, s := range steps {
+		switch s.action {
+		case actionCreate:
+			_, err = c.Create(ctx, s.request)
+		case actionUpdate:
+			_, err = c.Update(ctx, s.serviceID, s.request)
+		case actionDelete:
+			err = c.Deregister(ctx, s.serviceID)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to %s %s/%s: %v\n", s.action, s.request.Namespace, s.request.Name, err)
+			failed++
+		}
+	}
+
+	fmt.Printf("%d changed, %d unchanged\n", len(steps)-failed, unchanged)
+	if failed > 0 {
+		return fmt.Errorf("%d changes failed", failed)
+	}
+	return nil
+}
+
+// readServices parses a services file, or stdin for "-"
+func readServices(path string) ([]types.ServiceRegistrationRequest, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// YAML is a superset of JSON, go through JSON so the API's field names apply
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if data, err = json.Marshal(v); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	var file servicesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, request := range file.Services {
+		if request.Name == "" {
+			return nil, fmt.Errorf("service %d has no name", i)
+		}
+		if request.Namespace == "" {
+			file.Services[i].Namespace = types.DefaultNamespace
+		}
+		key := requestKey(file.Services[i])
+		if seen[key] {
+			return nil, fmt.Errorf("%s is declared more than once", key)
+		}
+		seen[key] = true
+	}
+	return file.Services, nil
+}
+
+// plan works out the steps that converge existing on desired, and how many
+// services already match
+func plan(desired []types.ServiceRegistrationRequest, existing []types.ServiceResponse, prune bool) ([]step, int) {
+	// Existing services can be matched by their external ID or identity
+	byKey := make(map[string]types.ServiceResponse, len(existing))
+	for _, service := range existing {
+		byKey[identityKey(service.Namespace, service.Name, service.URL)] = service
+		if service.ExternalID != "" {
+			byKey[externalKey(service.Namespace, service.ExternalID)] = service
+		}
+	}
+
+	var steps []step
+	unchanged := 0
+	declared := make(map[string]bool) // By service ID
+	namespaces := make(map[string]bool)
+	for _, request := range desired {
+		namespaces[request.Namespace] = true
+
+		current, ok := byKey[requestKey(request)]
+		if ok {
+			declared[current.ID] = true
+		}
+		switch {
+		case !ok:
+			steps = append(steps, step{action: actionCreate, request: request})
+		case !reflect.DeepEqual(normalize(request), normalize(types.ServiceResponseToRegistration(current))):
+			steps = append(steps, step{action: actionUpdate, serviceID: current.ID, request: request})
+		default:
+			unchanged++
+		}
+	}
+
+	if prune {
+		var deletes []step
+		for _, service := range existing {
+			if namespaces[service.Namespace] && !declared[service.ID] {
+				deletes = append(deletes, step{action: actionDelete, serviceID: service.ID, request: types.ServiceResponseToRegistration(service)})
+			}
+		}
+		sort.Slice(deletes, func(i, j int) bool { return deletes[i].request.Name < deletes[j].request.Name })
+		steps = append(steps, deletes...)
+	}
+	return steps, unchanged
+}
+
+// normalize applies the registry's defaults and drops differences that don't
+// matter, so a declared service compares equal to its registered self
+func normalize(request types.ServiceRegistrationRequest) types.ServiceRegistrationRequest {
+	request.State = ""
+	if request.Weight == 0 {
+		request.Weight = types.DefaultWeight
+	}
+	if len(request.Capabilities) == 0 {
+		request.Capabilities = nil
+	}
+	if len(request.Metadata) == 0 {
+		request.Metadata = nil
+	}
+	request.Categories = sortedOrNil(request.Categories)
+	request.Aliases = sortedOrNil(request.Aliases)
+	if request.SunsetAt != nil {
+		sunset := request.SunsetAt.UTC()
+		request.SunsetAt = &sunset
+	}
+	return request
+}
+
+func sortedOrNil(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// requestKey is what a declared service is matched on: its external ID if
+// it has one, otherwise the registry's identity of namespace, name and URL
+func requestKey(request types.ServiceRegistrationRequest) string {
+	if request.ExternalID != "" {
+		return externalKey(request.Namespace, request.ExternalID)
+	}
+	return identityKey(request.Namespace, request.Name, request.URL)
+}
+
+func identityKey(namespace, name, url string) string {
+	return namespace + "/" + name + " at " + url
+}
+
+func externalKey(namespace, externalID string) string {
+	return namespace + "/" + externalID + " (external ID)"
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/client"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// followWait stays under the default client timeout
+const followWait = 20 * time.Second
+
+// runEvents prints lifecycle events, and with -follow keeps streaming new
+// ones as they happen until interrupted
+func runEvents(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("events", flag.ExitOnError)
+	follow := flags.Bool("follow", false, "Keep printing new events as they arrive")
+	since := flags.String("since", "", "Only events after this ID, defaults to the start or, when following, to now")
+	serviceID := flags.String("service", "", "Only events for this service ID")
+	category := flags.String("category", "", "Only events for services in this category")
+	eventType := flags.String("type", "", "Only events of this type, such as service.deleted")
+	asJSON := flags.Bool("json", false, "Print each event as a line of JSON")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	query := url.Values{}
+	if *serviceID != "" {
+		query.Set("service", *serviceID)
+	}
+	if *eventType != "" {
+		query.Set("type", *eventType)
+	}
+	switch {
+	case *since != "":
+		query.Set("since", *since)
+	case *follow:
+		query.Set("since", "latest")
+	}
+
+	var members *categoryMembers
+	if *category != "" {
+		var err error
+		if members, err = loadCategory(ctx, c, *category); err != nil {
+			return fmt.Errorf("listing category: %w", err)
+		}
+	}
+
+	for {
+		if *follow {
+			query.Set("wait", followWait.String())
+		}
+		page, err := c.Events(ctx, query)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if !*follow {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(2 * time.Second):
+			}
+			continue
+		}
+
+		for _, event := range page.Events {
+			if members != nil && !members.matches(ctx, event) {
+				continue
+			}
+			printEvent(event, *asJSON)
+		}
+		query.Set("since", fmt.Sprint(page.Next))
+
+		if !*follow && len(page.Events) == 0 {
+			return nil
+		}
+	}
+}
+
+func printEvent(event types.EventResponse, asJSON bool) {
+	if asJSON {
+		data, _ := json.Marshal(event)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("%s  %-26s %s  %s\n", event.CreatedAt.Local().Format(time.RFC3339), event.Type, event.ServiceID, event.Data)
+}
+
+// categoryMembers tracks which services are in a category. Deleted services
+// no longer have categories, so membership is remembered from before.
+type categoryMembers struct {
+	client   *client.Client
+	category string
+	ids      map[string]bool
+}
+
+func loadCategory(ctx context.Context, c *client.Client, category string) (*categoryMembers, error) {
+	services, err := c.List(ctx, url.Values{"category": {category}, "state": {"all"}})
+	if err != nil {
+		return nil, err
+	}
+	members := &categoryMembers{client: c, category: category, ids: make(map[string]bool)}
+	for _, service := range services {
+		members.ids[service.ID] = true
+	}
+	return members, nil
+}
+
+// matches reports whether the event's service is in the category, refreshing
+// what's known about it from the registry when it may have changed
+func (m *categoryMembers) matches(ctx context.Context, event types.EventResponse) bool {
+	switch event.Type {
+	case types.EventServiceDeleted:
+		member := m.ids[event.ServiceID]
+		delete(m.ids, event.ServiceID)
+		return member
+	case types.EventServiceCreated, types.EventServiceUpdated:
+		service, err := m.client.Get(ctx, event.ServiceID)
+		if err != nil {
+			// Already gone again, go by what was known
+			return m.ids[event.ServiceID]
+		}
+		member := slices.Contains(service.Categories, m.category)
+		// A service leaving the category still reports the update that moved it
+		wasMember := m.ids[event.ServiceID]
+		m.ids[event.ServiceID] = member
+		return member || wasMember
+	default:
+		return m.ids[event.ServiceID]
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/arnavsurve/gateway-registry/pkg/client"
+)
+
+// runLogin logs in through the registry's identity provider in a browser,
+// receiving the session token on a loopback listener, and prints it
+func runLogin(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("login", flag.ExitOnError)
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	tokens := make(chan string, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			http.Error(w, "No token received", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, "Logged in, you can close this window.")
+		select {
+		case tokens <- token:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	callback := fmt.Sprintf("http://%s/", listener.Addr())
+	fmt.Fprintf(os.Stderr, "Open this URL in a browser to log in:\n\n  %s/auth/login?redirect=%s\n\n",
+		c.BaseURL, url.QueryEscape(callback))
+
+	select {
+	case token := <-tokens:
+		fmt.Fprintln(os.Stderr, "Logged in. Use the session with:")
+		fmt.Printf("export REGISTRY_TOKEN=%s\n", token)
+		return nil
+	case <-ctx.Done():
+		return errors.New("login cancelled")
+	}
+}
@@ -0,0 +1,69 @@
+// Command registryctl manages a registry from the command line.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/arnavsurve/gateway-registry/pkg/client"
+)
+
+const usage = `Usage: registryctl [flags] <command> [command flags]
+
+Commands:
+  apply    Converge the registry on the services declared in a YAML file
+  events   Print lifecycle events, with -follow to stream them live
+  login    Log in through the registry's identity provider and print a session token
+  restore  Load a snapshot from an S3 bucket back into the registry
+
+Flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("registryctl", flag.ExitOnError)
+	registry := flags.String("registry", getEnv("REGISTRY_URL", "http://localhost:8080"), "Registry base URL, or $REGISTRY_URL")
+	token := flags.String("token", os.Getenv("REGISTRY_TOKEN"), "API key or admin token, or $REGISTRY_TOKEN")
+	signingSecret := flags.String("signing-secret", os.Getenv("REGISTRY_SIGNING_SECRET"), "Secret to HMAC sign registrations with, or $REGISTRY_SIGNING_SECRET")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	c := client.New(*registry)
+	c.Token = *token
+	c.SigningSecret = *signingSecret
+
+	var err error
+	switch command, args := flags.Arg(0), flags.Args()[1:]; command {
+	case "apply":
+		err = runApply(c, args)
+	case "events":
+		err = runEvents(c, args)
+	case "login":
+		err = runLogin(c, args)
+	case "restore":
+		err = runRestore(c, args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+
+	"github.com/arnavsurve/gateway-registry/pkg/backup"
+	"github.com/arnavsurve/gateway-registry/pkg/client"
+)
+
+// runRestore downloads a snapshot from S3 and imports it into the registry.
+// The URL may name a snapshot, or a prefix to restore the newest one under.
+// Credentials come from the same variables the registry reads. Like any
+// import through the API, moderation state, verification and ownership
+// aren't taken from the snapshot; POST /admin/restore brings those back from
+// the registry's own backup store.
+func runRestore(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	from := flags.String("from", "", "s3://bucket/prefix/snapshot-....json, or s3://bucket/prefix/ for the newest snapshot")
+	mode := flags.String("mode", "replace", "replace to make the registry match the snapshot, or merge to keep services it doesn't have")
+	endpoint := flags.String("endpoint", getEnv("REGISTRY_BACKUP_S3_ENDPOINT", "https://s3.amazonaws.com"), "S3 endpoint, or $REGISTRY_BACKUP_S3_ENDPOINT")
+	region := flags.String("region", getEnv("REGISTRY_BACKUP_S3_REGION", "us-east-1"), "S3 region, or $REGISTRY_BACKUP_S3_REGION")
+	flags.Parse(args)
+
+	if *from == "" {
+		return errors.New("restore needs -from s3://bucket/key")
+	}
+	if *mode != "replace" && *mode != "merge" {
+		return errors.New("-mode must be replace or merge")
+	}
+	bucket, key, err := backup.ParseURL(*from)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	store := &backup.S3Store{
+		Endpoint:        *endpoint,
+		Bucket:          bucket,
+		Region:          *region,
+		AccessKeyID:     os.Getenv("REGISTRY_BACKUP_S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("REGISTRY_BACKUP_S3_SECRET_ACCESS_KEY"),
+	}
+	name := path.Base(key)
+	if backup.ValidName(name) {
+		store.Prefix = strings.TrimSuffix(key, name)
+	} else {
+		store.Prefix = key
+		if store.Prefix != "" && !strings.HasSuffix(store.Prefix, "/") {
+			store.Prefix += "/"
+		}
+		snapshots, err := store.List(ctx)
+		if err != nil {
+			return fmt.Errorf("listing snapshots: %w", err)
+		}
+		if len(snapshots) == 0 {
+			return fmt.Errorf("no snapshots under %s", *from)
+		}
+		name = snapshots[len(snapshots)-1].Name
+	}
+
+	data, err := store.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("downloading %s: %w", name, err)
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("%s is not a JSON snapshot", name)
+	}
+
+	result, err := c.ImportSnapshot(ctx, data, *mode)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s: %d services imported, %d deleted (%s)\n", name, result.Imported, result.Deleted, result.Mode)
+	return nil
+}
@@ -1,77 +1,533 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
-	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/backup"
+	"github.com/arnavsurve/gateway-registry/pkg/cache"
+	"github.com/arnavsurve/gateway-registry/pkg/catalog"
+	"github.com/arnavsurve/gateway-registry/pkg/config"
+	"github.com/arnavsurve/gateway-registry/pkg/consul"
+	appDB "github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/discovery"
+	"github.com/arnavsurve/gateway-registry/pkg/events"
+	"github.com/arnavsurve/gateway-registry/pkg/extensions"
+	"github.com/arnavsurve/gateway-registry/pkg/federation"
 	appHandlers "github.com/arnavsurve/gateway-registry/pkg/handlers"
-	"github.com/arnavsurve/gateway-registry/pkg/types"
+	"github.com/arnavsurve/gateway-registry/pkg/health"
+	"github.com/arnavsurve/gateway-registry/pkg/kube"
+	"github.com/arnavsurve/gateway-registry/pkg/leader"
+	"github.com/arnavsurve/gateway-registry/pkg/netguard"
+	"github.com/arnavsurve/gateway-registry/pkg/oidc"
+	"github.com/arnavsurve/gateway-registry/pkg/provenance"
+	"github.com/arnavsurve/gateway-registry/pkg/redact"
+	"github.com/arnavsurve/gateway-registry/pkg/secrets"
+	"github.com/arnavsurve/gateway-registry/pkg/telemetry"
+	"github.com/arnavsurve/gateway-registry/pkg/usage"
 )
 
 func main() {
-	db, err := db.InitDB()
+	seed := flag.String("seed", "", "Path to a YAML fixtures file loaded into the registry if it's empty")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := appDB.InitDB(cfg.DatabaseDSN)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
+	if err := appDB.Configure(db, int(cfg.DBMaxOpenConns), int(cfg.DBMaxIdleConns), cfg.DBConnMaxLifetime, cfg.DBQueryTimeout); err != nil {
+		log.Fatalf("Failed to configure database connection: %v", err)
+	}
+	if cfg.JSONBSchema {
+		if err := appDB.UseJSONB(db); err != nil {
+			log.Fatalf("Failed to switch to the JSONB schema: %v", err)
+		}
+	}
+
+	var secretBox *secrets.Box
+	if cfg.SecretKeyFile != "" {
+		if secretBox, err = secrets.Load(cfg.SecretKeyFile); err != nil {
+			log.Fatalf("Failed to load the secret key: %v", err)
+		}
+		appDB.SealMetadata(secretBox, cfg.SensitiveMetadataKeys)
+	}
+
+	if *seed != "" {
+		n, err := appDB.Seed(db, *seed)
+		if err != nil {
+			log.Fatalf("Failed to load seed data: %v", err)
+		}
+		log.Printf("Seeded %d services from %s", n, *seed)
+	}
+
+	var backups backup.Store = &backup.DiskStore{Dir: cfg.BackupDir}
+	if cfg.BackupS3Bucket != "" {
+		backups = &backup.S3Store{
+			Endpoint:        cfg.BackupS3Endpoint,
+			Bucket:          cfg.BackupS3Bucket,
+			Region:          cfg.BackupS3Region,
+			AccessKeyID:     cfg.BackupS3AccessKey,
+			SecretAccessKey: cfg.BackupS3SecretKey,
+			Prefix:          cfg.BackupS3Prefix,
+			SSE:             cfg.BackupS3SSE,
+			KMSKeyID:        cfg.BackupS3KMSKeyID,
+		}
+	}
+
+	// Count lookups per service, flushed to the daily usage rollups
+	counter := usage.NewCounter(db, cfg.UsageFlushInterval)
+	go counter.Run(context.Background())
+
+	// Meter API calls per caller, flushed to their own daily rollups
+	meter := usage.NewMeter(db, cfg.UsageFlushInterval)
+	go meter.Run(context.Background())
+
+	guard, err := netguard.New(cfg.AllowPrivateURLs, cfg.URLAllowlist, cfg.URLDenylist)
+	if err != nil {
+		log.Fatalf("Failed to load URL allow/deny lists: %v", err)
+	}
+
+	adminNetworks, err := netguard.ParseNetworks(cfg.AdminAllowedCIDRs)
+	if err != nil {
+		log.Fatalf("Failed to load admin allowlist: %v", err)
+	}
+	writeNetworks, err := netguard.ParseNetworks(cfg.WriteAllowedCIDRs)
+	if err != nil {
+		log.Fatalf("Failed to load write allowlist: %v", err)
+	}
+
+	var publishers *provenance.Verifier
+	if cfg.PublisherKeysFile != "" {
+		if publishers, err = provenance.Load(cfg.PublisherKeysFile); err != nil {
+			log.Fatalf("Failed to load publisher keys: %v", err)
+		}
+	}
 
-	h := appHandlers.Handler{DB: db}
+	var policy redact.Policy
+	if cfg.ResponsePolicyFile != "" {
+		if policy, err = redact.Load(cfg.ResponsePolicyFile); err != nil {
+			log.Fatalf("Failed to load response policy: %v", err)
+		}
+	}
+
+	var extensionSchemas extensions.Registry
+	if cfg.ExtensionSchemasFile != "" {
+		if extensionSchemas, err = extensions.Load(cfg.ExtensionSchemasFile); err != nil {
+			log.Fatalf("Failed to load extension schemas: %v", err)
+		}
+	}
+
+	var provider *oidc.Provider
+	if cfg.OIDCIssuer != "" {
+		provider = oidc.NewProvider(cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectURL)
+	}
+
+	var readCache *cache.Cache
+	if cfg.RedisAddr != "" {
+		readCache = cache.New(cfg.RedisAddr, cfg.RedisPassword, int(cfg.RedisDB), cfg.CacheTTL)
+		go appDB.WatchChanges(context.Background(), db, cache.InvalidateInterval, readCache.Invalidate)
+	}
+
+	// Discovery reads go to the replica when there is one, writes always go to the primary
+	readDB := db
+	if cfg.ReplicaDSN != "" {
+		if readDB, err = appDB.OpenReplica(cfg.ReplicaDSN); err != nil {
+			log.Fatalf("Failed to connect to read replica: %v", err)
+		}
+		if err := appDB.Configure(readDB, int(cfg.DBMaxOpenConns), int(cfg.DBMaxIdleConns), cfg.DBConnMaxLifetime, cfg.DBQueryTimeout); err != nil {
+			log.Fatalf("Failed to configure read replica connection: %v", err)
+		}
+		if cfg.JSONBSchema {
+			if err := appDB.ReadJSONB(readDB); err != nil {
+				log.Fatalf("Failed to switch the read replica to the JSONB schema: %v", err)
+			}
+		}
+	}
+
+	var snapshot *catalog.Snapshot
+	if cfg.SnapshotReads {
+		snapshot = catalog.NewSnapshot(readDB)
+	}
+
+	// Push request rates, pool stats and background job counts to a collector
+	var metrics *telemetry.Metrics
+	if cfg.OTLPEndpoint != "" {
+		metrics = telemetry.New()
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatalf("Failed to get database handle: %v", err)
+		}
+		metrics.Gauge("registry.db.connections.open", func() int64 { return int64(sqlDB.Stats().OpenConnections) })
+		metrics.Gauge("registry.db.connections.in_use", func() int64 { return int64(sqlDB.Stats().InUse) })
+		metrics.Gauge("registry.db.connections.idle", func() int64 { return int64(sqlDB.Stats().Idle) })
+		metrics.Gauge("registry.db.connections.wait_count", func() int64 { return sqlDB.Stats().WaitCount })
+		exporter := telemetry.NewExporter(metrics, cfg.OTLPEndpoint, cfg.OTLPHeaders, cfg.OTLPInterval)
+		go exporter.Run(context.Background())
+	}
+
+	h := appHandlers.Handler{DB: db, ReadDB: readDB, Config: cfg, Backups: backups, Usage: counter, Meter: meter, Guard: guard, Cache: readCache, Catalog: snapshot, Metrics: metrics, Publishers: publishers, OIDC: provider,
+		Secrets: secretBox, Policy: policy, Extensions: extensionSchemas, AdminNetworks: adminNetworks, WriteNetworks: writeNetworks}
+	h.SetReadOnly(cfg.MirrorUpstream != "")
 	r := mux.NewRouter()
+	r.HandleFunc("/resolve", h.ResolveHandler).Methods(http.MethodGet)
+	r.HandleFunc("/discover", h.DiscoverHandler).Methods(http.MethodGet)
+	r.HandleFunc("/negotiate", h.NegotiateHandler).Methods(http.MethodPost)
+	r.HandleFunc("/import", h.Writable(h.SignedImport(h.ImportHandler))).Methods(http.MethodPost)
+	r.HandleFunc("/export", h.ExportHandler).Methods(http.MethodGet)
+	r.HandleFunc("/stats/top", h.TopServicesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/categories", h.ListCategoriesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/capabilities", h.ListCapabilitiesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/changes", h.ListChangesHandler).Methods(http.MethodGet)
+	r.HandleFunc("/events", h.ListEventsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/dashboard", h.DashboardHandler).Methods(http.MethodGet)
+	r.HandleFunc("/health/summary", h.HealthSummaryHandler).Methods(http.MethodGet)
+	r.HandleFunc("/usage", h.Authenticated(h.UsageHandler)).Methods(http.MethodGet)
 	services := r.PathPrefix("/services").Subrouter()
 	services.HandleFunc("", h.ListServicesHandler).Methods(http.MethodGet)
-	services.HandleFunc("", h.CreateServiceHandler).Methods(http.MethodPost)
+	services.HandleFunc("", h.Writable(h.Signed(h.VerifyManifest(h.CreateServiceHandler)))).Methods(http.MethodPost)
+	services.HandleFunc("", h.Writable(h.Signed(h.VerifyManifest(h.UpsertServiceHandler)))).Methods(http.MethodPut)
 	services.HandleFunc("/search", h.SearchServicesHandler).Methods(http.MethodGet)
+	services.HandleFunc("/suggest", h.SuggestHandler).Methods(http.MethodGet)
+	services.HandleFunc("/batch-get", h.BatchGetServicesHandler).Methods(http.MethodPost)
+	services.HandleFunc("/trending", h.TrendingServicesHandler).Methods(http.MethodGet)
+	services.HandleFunc("/recent", h.RecentServicesHandler).Methods(http.MethodGet)
 	services.HandleFunc("/{id}", h.GetServiceHandler).Methods(http.MethodGet)
-	services.HandleFunc("/{id}", h.UpdateServiceHandler).Methods(http.MethodPut)
-	services.HandleFunc("/{id}", h.DeleteServiceHandler).Methods(http.MethodDelete)
-	services.HandleFunc("/{id}/heartbeat", h.HeartbeatHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}", h.HeadServiceHandler).Methods(http.MethodHead)
+	services.HandleFunc("/{id}", h.Writable(h.Signed(h.VerifyManifest(h.UpdateServiceHandler)))).Methods(http.MethodPut)
+	services.HandleFunc("/{id}", h.Writable(h.DeleteServiceHandler)).Methods(http.MethodDelete)
+	services.HandleFunc("/{id}/heartbeat", h.Renewal(h.HeartbeatHandler)).Methods(http.MethodGet, http.MethodPost)
+	services.HandleFunc("/{id}/heartbeat/stream", h.Renewal(h.HeartbeatStreamHandler)).Methods(http.MethodGet)
+	if cfg.HeartbeatTokens {
+		services.HandleFunc("/{id}/heartbeat-token", h.Authenticated(h.Writable(h.ResetHeartbeatTokenHandler))).Methods(http.MethodPost)
+	}
+	services.HandleFunc("/{id}/uptime", h.UptimeHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/stats", h.ServiceStatsHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/metrics", h.ServiceMetricsHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/export", h.ExportServiceHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/openapi.json", h.ServiceOpenAPIHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/revisions", h.ListRevisionsHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/rollback/{rev}", h.Writable(h.SignedService(h.RollbackHandler))).Methods(http.MethodPost)
+	services.HandleFunc("/{id}/import-openapi", h.Writable(h.SignedService(h.ImportOpenAPIHandler))).Methods(http.MethodPost)
+	services.HandleFunc("/{id}/reviews", h.ListReviewsHandler).Methods(http.MethodGet)
+	services.HandleFunc("/{id}/reviews", h.Authenticated(h.Writable(h.CreateReviewHandler))).Methods(http.MethodPost)
+	services.HandleFunc("/{id}/transfer", h.Authenticated(h.Writable(h.TransferServiceHandler))).Methods(http.MethodPost)
+
+	transfers := r.PathPrefix("/transfers").Subrouter()
+	transfers.HandleFunc("", h.Authenticated(h.ListTransfersHandler)).Methods(http.MethodGet)
+	transfers.HandleFunc("/{id}/accept", h.Authenticated(h.Writable(h.AcceptTransferHandler))).Methods(http.MethodPost)
+	transfers.HandleFunc("/{id}/decline", h.Authenticated(h.Writable(h.DeclineTransferHandler))).Methods(http.MethodPost)
+	transfers.HandleFunc("/{id}/cancel", h.Authenticated(h.Writable(h.CancelTransferHandler))).Methods(http.MethodPost)
+
+	groups := r.PathPrefix("/groups").Subrouter()
+	groups.HandleFunc("", h.ListGroupsHandler).Methods(http.MethodGet)
+	groups.HandleFunc("", h.Writable(h.CreateGroupHandler)).Methods(http.MethodPost)
+	groups.HandleFunc("/{id}", h.GetGroupHandler).Methods(http.MethodGet)
+	groups.HandleFunc("/{id}", h.Writable(h.UpdateGroupHandler)).Methods(http.MethodPut)
+	groups.HandleFunc("/{id}", h.Writable(h.DeleteGroupHandler)).Methods(http.MethodDelete)
+
+	keys := r.PathPrefix("/keys").Subrouter()
+	keys.HandleFunc("", h.Admin(h.ListAPIKeysHandler)).Methods(http.MethodGet)
+	keys.HandleFunc("", h.Admin(h.Writable(h.CreateAPIKeyHandler))).Methods(http.MethodPost)
+	keys.HandleFunc("/self/rotate", h.Authenticated(h.RotateOwnKeyHandler)).Methods(http.MethodPost)
+	keys.HandleFunc("/{id}", h.Admin(h.GetAPIKeyHandler)).Methods(http.MethodGet)
+	keys.HandleFunc("/{id}", h.Admin(h.Writable(h.RevokeAPIKeyHandler))).Methods(http.MethodDelete)
+	keys.HandleFunc("/{id}/rotate", h.Admin(h.Writable(h.RotateAPIKeyHandler))).Methods(http.MethodPost)
+
+	orgs := r.PathPrefix("/orgs").Subrouter()
+	orgs.HandleFunc("", h.Authenticated(h.ListOrganizationsHandler)).Methods(http.MethodGet)
+	orgs.HandleFunc("", h.Admin(h.Writable(h.CreateOrganizationHandler))).Methods(http.MethodPost)
+	orgs.HandleFunc("/{id}", h.Authenticated(h.GetOrganizationHandler)).Methods(http.MethodGet)
+	orgs.HandleFunc("/{id}", h.Admin(h.Writable(h.DeleteOrganizationHandler))).Methods(http.MethodDelete)
+	orgs.HandleFunc("/{id}/teams", h.Admin(h.Writable(h.CreateTeamHandler))).Methods(http.MethodPost)
+
+	teams := r.PathPrefix("/teams").Subrouter()
+	teams.HandleFunc("/{id}", h.Authenticated(h.GetTeamHandler)).Methods(http.MethodGet)
+	teams.HandleFunc("/{id}", h.Admin(h.Writable(h.DeleteTeamHandler))).Methods(http.MethodDelete)
+	teams.HandleFunc("/{id}/members", h.Authenticated(h.Writable(h.SetTeamMemberHandler))).Methods(http.MethodPut)
+	teams.HandleFunc("/{id}/members/{principal}", h.Authenticated(h.Writable(h.RemoveTeamMemberHandler))).Methods(http.MethodDelete)
+
+	// Sessions for people logging in through the identity provider
+	if cfg.OIDCIssuer != "" {
+		login := r.PathPrefix("/auth").Subrouter()
+		login.HandleFunc("/login", h.LoginHandler).Methods(http.MethodGet)
+		login.HandleFunc("/callback", h.LoginCallbackHandler).Methods(http.MethodGet)
+		login.HandleFunc("/logout", h.LogoutHandler).Methods(http.MethodPost)
+		login.HandleFunc("/session", h.SessionHandler).Methods(http.MethodGet)
+	}
+
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/snapshots", h.Admin(h.ListSnapshotsHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/snapshots", h.Admin(h.CreateSnapshotHandler)).Methods(http.MethodPost)
+	admin.HandleFunc("/restore", h.Admin(h.Writable(h.RestoreHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/duplicates", h.Admin(h.DuplicatesHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/duplicates/report", h.Admin(h.DuplicateReportHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/services/{id}/approve", h.Admin(h.Writable(h.ApproveServiceHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/services/{id}/reject", h.Admin(h.Writable(h.RejectServiceHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/services/{id}/verify", h.Admin(h.Writable(h.VerifyServiceHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/audit", h.Admin(h.AuditLogHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/categories", h.Admin(h.Writable(h.CreateCategoryHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/categories/{name}", h.Admin(h.Writable(h.UpdateCategoryHandler))).Methods(http.MethodPut)
+	admin.HandleFunc("/categories/{name}", h.Admin(h.Writable(h.DeleteCategoryHandler))).Methods(http.MethodDelete)
+	admin.HandleFunc("/prune", h.Admin(h.Writable(h.PruneHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/stale", h.Admin(h.StaleServicesHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/archive", h.Admin(h.ListArchiveHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/archive/purge", h.Admin(h.Writable(h.PurgeArchiveHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/archive/{id}/restore", h.Admin(h.Writable(h.RestoreArchivedHandler))).Methods(http.MethodPost)
+	admin.HandleFunc("/config", h.Admin(h.ConfigHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/quotas", h.Admin(h.QuotasHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/usage/export", h.Admin(h.ExportUsageHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/read-only", h.Admin(h.ReadOnlyHandler)).Methods(http.MethodGet)
+	admin.HandleFunc("/read-only", h.Admin(h.SetReadOnlyHandler)).Methods(http.MethodPut)
+
+	// Runtime diagnostics, admin only like the rest of the admin API
+	r.HandleFunc("/debug/vars", h.Admin(h.DebugVarsHandler)).Methods(http.MethodGet)
+	h.RegisterPprof(r)
+
+	if cfg.EurekaEnabled {
+		eureka := r.PathPrefix("/eureka/apps").Subrouter()
+		eureka.HandleFunc("", h.EurekaAppsHandler).Methods(http.MethodGet)
+		eureka.HandleFunc("/{app}", h.EurekaAppHandler).Methods(http.MethodGet)
+		eureka.HandleFunc("/{app}", h.Writable(h.Signed(h.EurekaRegisterHandler))).Methods(http.MethodPost)
+		eureka.HandleFunc("/{app}/{instance}", h.EurekaInstanceHandler).Methods(http.MethodGet)
+		eureka.HandleFunc("/{app}/{instance}", h.Renewal(h.EurekaRenewHandler)).Methods(http.MethodPut)
+		eureka.HandleFunc("/{app}/{instance}", h.Writable(h.EurekaCancelHandler)).Methods(http.MethodDelete)
+		eureka.HandleFunc("/{app}/{instance}/status", h.Writable(h.EurekaStatusHandler)).Methods(http.MethodPut)
+	}
+
+	if cfg.ProxyEnabled {
+		r.PathPrefix("/proxy/{id}").HandlerFunc(h.ProxyHandler)
+	}
 
 	corsMiddleware := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Content-Type", "Authorization"}),
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"}),
+		handlers.AllowedHeaders([]string{"Content-Type", "Authorization", appHandlers.RequestIDHeader,
+			auth.SignatureHeader, auth.SignatureTimestampHeader, auth.SignatureNonceHeader, appHandlers.ManifestSignatureHeader}),
+		handlers.ExposedHeaders([]string{appHandlers.RequestIDHeader, appHandlers.IndexHeader, "X-Next-Cursor", "Retry-After"}),
 	)
 
-	// Add middleware for logging
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			log.Printf("%s %s", r.Method, r.URL.Path)
-			next.ServeHTTP(w, r)
-			log.Printf("Completed %s %s in %v", r.Method, r.URL.Path, time.Since(start))
-		})
-	})
+	// With several instances on one database, only the lease holder runs the
+	// prune loop, health probes and webhook delivery
+	var elector *leader.Elector
+	if cfg.LeaderElection {
+		elector = leader.NewElector(db, "background-jobs", cfg.LeaderLeaseTTL)
+		go elector.Run(context.Background())
+	}
+
+	// Deliver queued events to the webhook
+	if cfg.WebhookURL != "" {
+		dispatcher := &events.Dispatcher{DB: db, Webhook: events.NewWebhook(cfg.WebhookURL), Interval: cfg.WebhookInterval,
+			MaxAttempts: int(cfg.WebhookMaxAttempts), Leader: elector, Metrics: metrics}
+		go dispatcher.Run(context.Background())
+	}
+
+	r.Use(appHandlers.RequestID, h.Shed, h.LimitBody, metrics.Middleware, h.RateLimit, h.MeterUsage)
+	if cfg.DBCheckInterval > 0 {
+		go h.MonitorDatabase(context.Background())
+	}
 
 	// Prune inactive services
 	go func() {
 		for {
-			// Prune every 30 sec
-			time.Sleep(30 * time.Second)
+			time.Sleep(cfg.PruneInterval)
+			if !elector.IsLeader() {
+				continue
+			}
 
-			// Remove services that haven't sent a heartbeat in the last prune cycle
-			cutoff := time.Now().Add(-30 * time.Second)
-			var inactiveServices []types.MCPService
-			db.Where("last_seen < ?", cutoff).Find(&inactiveServices)
+			// Remove services that haven't sent a heartbeat within the TTL,
+			// unless heartbeats were being refused for a database outage
+			if time.Since(h.RecoveringSince()) >= cfg.ServiceTTL {
+				cutoff := time.Now().Add(-cfg.ServiceTTL)
+				pruned, err := appDB.PruneInactive(db, cutoff, cfg.ArchiveGracePeriod > 0)
+				if err != nil {
+					log.Printf("Failed to prune inactive services: %v", err)
+				}
+				metrics.Add(telemetry.PrunedServices, int64(len(pruned)))
+				for _, service := range pruned {
+					log.Printf("Pruned inactive service: %s (%s)", service.Name, service.ID)
+				}
+			}
 
-			// TODO: rather than hard deleting just use a deleted flag in case the service comes back.
-			// or maybe it's not expensive for a hard delete and registration. look into it
-			for _, service := range inactiveServices {
-				// Delete related records
-				db.Where("service_id = ?", service.ID).Delete(&types.Capability{})
-				db.Where("service_id = ?", service.ID).Delete(&types.Category{})
-				db.Where("service_id = ?", service.ID).Delete(&types.MetadataItem{})
+			// Older nonces belong to signatures that have expired anyway
+			if _, err := appDB.PurgeNonces(db, time.Now().Add(-2*cfg.SignatureTolerance)); err != nil {
+				log.Printf("Failed to purge signature nonces: %v", err)
+			}
 
-				// Delete the service
-				db.Delete(&service)
-				log.Printf("Pruned inactive service: %s (%s)", service.Name, service.ID)
+			if _, err := appDB.PurgeSessions(db, time.Now(), time.Now().Add(-time.Hour)); err != nil {
+				log.Printf("Failed to purge expired sessions: %v", err)
+			}
+
+			// Metrics only need to cover the window
+			if _, err := appDB.PurgeMetrics(db, time.Now().Add(-cfg.MetricsWindow)); err != nil {
+				log.Printf("Failed to purge metrics: %v", err)
+			}
+
+			// Announce deprecated services whose sunset date has passed
+			sunsets, err := appDB.EmitSunsets(db, time.Now())
+			if err != nil {
+				log.Printf("Failed to emit sunset events: %v", err)
+			}
+			for _, event := range sunsets {
+				log.Printf("Service %s reached its sunset date", event.ServiceID)
+			}
+
+			// Warn before API keys expire so they can be rotated in time
+			expiring, err := appDB.EmitKeyExpiries(db, time.Now(), cfg.KeyExpiryWarning)
+			if err != nil {
+				log.Printf("Failed to emit key expiry events: %v", err)
+			}
+			if len(expiring) > 0 {
+				log.Printf("%d API keys expire within %s", len(expiring), cfg.KeyExpiryWarning)
+			}
+		}
+	}()
+
+	// Delete history older than its retention window
+	go func() {
+		retention := appDB.Retention{Audit: cfg.AuditRetention, Archive: cfg.ArchiveRetention,
+			Availability: cfg.AvailabilityRetention, Changes: cfg.ChangeRetention, Events: cfg.EventRetention,
+			EventAttempts: int(cfg.WebhookMaxAttempts)}
+		for {
+			time.Sleep(cfg.RetentionInterval)
+			if !elector.IsLeader() {
+				continue
+			}
+			result, err := appDB.EnforceRetention(db, retention, time.Now())
+			if err != nil {
+				log.Printf("Failed to enforce retention: %v", err)
+			}
+			if result != (appDB.RetentionResult{}) {
+				log.Printf("Retention deleted %d audit entries, %d archived services, %d availability buckets, %d changes and %d events",
+					result.Audit, result.Archive, result.Availability, result.Changes, result.Events)
 			}
 		}
 	}()
 
-	log.Println("MCP Registry Service running at :42069")
-	http.ListenAndServe(":42069", corsMiddleware(r))
+	// Probe service health and latency
+	if cfg.ProbeInterval > 0 {
+		prober := health.NewProber(db, cfg.ProbeInterval, cfg.ProbeTimeout, guard.Transport())
+		prober.Leader = elector
+		prober.Policy = h.StreakPolicy()
+		go prober.Run(context.Background())
+	}
+
+	// Answer SRV and TXT queries for services
+	if cfg.DNSAddr != "" {
+		dnsServer := &discovery.DNSServer{DB: db, Addr: cfg.DNSAddr, Zone: cfg.DNSZone, ServiceTTL: cfg.ServiceTTL, RecordTTL: 10}
+		go func() {
+			if err := dnsServer.Run(context.Background()); err != nil {
+				log.Printf("DNS server stopped: %v", err)
+			}
+		}()
+	}
+
+	// Take scheduled snapshots on the leader, pruning those past retention
+	if cfg.BackupInterval > 0 || cfg.BackupSchedule != "" {
+		scheduler := &backup.Scheduler{
+			DB:        db,
+			Store:     backups,
+			Interval:  cfg.BackupInterval,
+			Retention: backup.Retention{Keep: int(cfg.BackupKeep), MaxAge: cfg.BackupMaxAge},
+			Leader:    elector,
+		}
+		if cfg.BackupSchedule != "" {
+			if scheduler.Schedule, err = backup.ParseSchedule(cfg.BackupSchedule); err != nil {
+				log.Fatalf("Invalid REGISTRY_BACKUP_SCHEDULE: %v", err)
+			}
+		}
+		go scheduler.Run(context.Background())
+	}
+
+	// Replicate the upstream registry, or pull services from peer registries
+	if cfg.MirrorUpstream != "" {
+		syncer := federation.NewSyncer(db, []string{cfg.MirrorUpstream}, cfg.PeerSyncInterval)
+		syncer.Mirror = true
+		go syncer.Run(context.Background())
+	} else if len(cfg.Peers) > 0 {
+		syncer := federation.NewSyncer(db, cfg.Peers, cfg.PeerSyncInterval)
+		go syncer.Run(context.Background())
+	}
+
+	// Mirror services into Consul, and optionally import Consul's MCP services
+	if cfg.ConsulAddr != "" {
+		consulSyncer := consul.NewSyncer(db, cfg.ConsulAddr, cfg.ConsulToken, cfg.ConsulSyncInterval)
+		consulSyncer.Import = cfg.ConsulImport
+		go consulSyncer.Run(context.Background())
+	}
+
+	// Register services declared as MCPService resources or labeled Services in the cluster
+	if cfg.KubernetesCRD || cfg.KubernetesServices {
+		client, err := kube.InClusterClient()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		if cfg.KubernetesCRD {
+			controller := &kube.Controller{DB: db, Client: client, Namespace: cfg.KubernetesNamespace, Interval: cfg.KubernetesSyncInterval}
+			go controller.Run(context.Background())
+		}
+		if cfg.KubernetesServices {
+			watcher := &kube.ServiceWatcher{
+				DB:            db,
+				Client:        client,
+				Namespace:     cfg.KubernetesNamespace,
+				ClusterDomain: cfg.KubernetesDomain,
+				Interval:      cfg.KubernetesSyncInterval,
+			}
+			go watcher.Run(context.Background())
+		}
+	}
+
+	var handler http.Handler = corsMiddleware(r)
+	switch cfg.AccessLog {
+	case "off":
+	case "stdout":
+		handler = appHandlers.AccessLog(os.Stdout, cfg.AccessLogFormat)(handler)
+	case "stderr":
+		handler = appHandlers.AccessLog(os.Stderr, cfg.AccessLogFormat)(handler)
+	default:
+		accessLog, err := os.OpenFile(cfg.AccessLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatalf("Failed to open access log: %v", err)
+		}
+		defer accessLog.Close()
+		handler = appHandlers.AccessLog(accessLog, cfg.AccessLogFormat)(handler)
+	}
+
+	server := &http.Server{Addr: cfg.Addr, Handler: handler}
+	if cfg.TLSClientCAFile != "" {
+		// Certificates are optional at the handshake so reads stay open,
+		// write routes insist on one when REGISTRY_REQUIRE_CLIENT_CERT is set
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			log.Fatalf("Failed to read client CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("No certificates found in %s", cfg.TLSClientCAFile)
+		}
+		server.TLSConfig = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	}
+
+	log.Printf("MCP Registry Service running at %s", cfg.Addr)
+	if cfg.TLSCertFile != "" {
+		err = server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	log.Fatalf("Server stopped: %v", err)
 }
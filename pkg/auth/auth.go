@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Name  string
+	Admin bool
+	KeyID string // Database API key the caller authenticated with, if any
+
+	// What an API key from the database was granted, nil for callers that
+	// aren't limited by scopes
+	Scopes []string
+}
+
+// API key scopes. Keys without any may only read.
+const (
+	ScopeWrite   = "write"   // Registrations, heartbeats and other changes
+	ScopeAdmin   = "admin"   // Everything the admin token may do
+	ScopeSecrets = "secrets" // Reading sensitive metadata in the clear
+)
+
+// Scopes lists every scope a key may be granted
+var Scopes = []string{ScopeWrite, ScopeAdmin, ScopeSecrets}
+
+// Can reports whether the principal was granted scope, which the admin
+// scope implies
+func (p Principal) Can(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the authenticated caller, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(Principal)
+	return principal, ok
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header
+func BearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token, ok && token != ""
+}
+
+// NewToken generates a random token and the hash it should be stored as
+func NewToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex SHA-256 of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchesHash reports whether token hashes to hash
+func MatchesHash(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hash)) == 1
+}
+
+// CanReadSecrets reports whether the principal may see sensitive metadata.
+// Unlike other scopes, callers without scopes don't have it: only admins and
+// keys granted it explicitly.
+func (p Principal) CanReadSecrets() bool {
+	return p.Admin || slices.Contains(p.Scopes, ScopeSecrets)
+}
+
+// ClientIdentity returns the identity in the request's verified client
+// certificate: its SPIFFE ID if it has one, otherwise its first DNS name
+func ClientIdentity(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String(), true
+		}
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0], true
+	}
+	return "", false
+}
@@ -0,0 +1,169 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/leader"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// SnapshotInfo describes a stored snapshot
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store persists snapshot files
+type Store interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	List(ctx context.Context) ([]SnapshotInfo, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// snapshotName is the file name a snapshot taken at t is stored under. Names
+// sort chronologically.
+func snapshotName(t time.Time) string {
+	return "snapshot-" + t.UTC().Format("20060102T150405Z") + ".json"
+}
+
+// ValidName reports whether name looks like a snapshot written by TakeSnapshot,
+// which also keeps callers from escaping the store with paths
+func ValidName(name string) bool {
+	return strings.HasPrefix(name, "snapshot-") && strings.HasSuffix(name, ".json") &&
+		!strings.ContainsAny(name, `/\`) && !strings.Contains(name, "..")
+}
+
+// TakeSnapshot exports the registry inside a repeatable read transaction, so
+// services and their associations are consistent, and writes it to the store
+func TakeSnapshot(ctx context.Context, gdb *gorm.DB, store Store) (SnapshotInfo, error) {
+	var snapshot types.Snapshot
+	err := gdb.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		snapshot, err = db.ExportSnapshot(tx)
+		return err
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return SnapshotInfo{}, fmt.Errorf("exporting snapshot: %w", err)
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	info := SnapshotInfo{
+		Name:      snapshotName(snapshot.ExportedAt),
+		Size:      int64(len(data)),
+		CreatedAt: snapshot.ExportedAt,
+	}
+	if err := store.Put(ctx, info.Name, data); err != nil {
+		return SnapshotInfo{}, fmt.Errorf("storing snapshot: %w", err)
+	}
+	return info, nil
+}
+
+// Restore loads a stored snapshot into the registry using the given import mode
+func Restore(ctx context.Context, gdb *gorm.DB, store Store, name, mode string) (types.SnapshotImportResult, error) {
+	data, err := store.Get(ctx, name)
+	if err != nil {
+		return types.SnapshotImportResult{}, fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	var snapshot types.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return types.SnapshotImportResult{}, fmt.Errorf("decoding snapshot: %w", err)
+	}
+	return db.ImportSnapshot(gdb.WithContext(ctx), snapshot, mode)
+}
+
+// Retention says which stored snapshots to keep. A snapshot is deleted once
+// it falls outside either limit, but the newest one is always kept.
+type Retention struct {
+	Keep   int           // Newest snapshots to keep, 0 for no limit
+	MaxAge time.Duration // Delete snapshots older than this, 0 for no limit
+}
+
+// Prune deletes the snapshots retention no longer covers and returns their names
+func Prune(ctx context.Context, store Store, retention Retention, now time.Time) ([]string, error) {
+	if retention.Keep <= 0 && retention.MaxAge <= 0 {
+		return nil, nil
+	}
+	snapshots, err := store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots: %w", err)
+	}
+
+	var deleted []string
+	// List sorts oldest first, so count back from the newest
+	for i := len(snapshots) - 2; i >= 0; i-- {
+		snapshot := snapshots[i]
+		tooMany := retention.Keep > 0 && len(snapshots)-i > retention.Keep
+		tooOld := retention.MaxAge > 0 && now.Sub(snapshot.CreatedAt) > retention.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := store.Delete(ctx, snapshot.Name); err != nil {
+			return deleted, fmt.Errorf("deleting %s: %w", snapshot.Name, err)
+		}
+		deleted = append(deleted, snapshot.Name)
+	}
+	return deleted, nil
+}
+
+// Scheduler takes a snapshot on every interval, or at the times a cron
+// schedule gives when one is set, then prunes snapshots retention no longer
+// covers
+type Scheduler struct {
+	DB        *gorm.DB
+	Store     Store
+	Interval  time.Duration
+	Schedule  *Schedule // Takes precedence over Interval
+	Retention Retention
+	Leader    *leader.Elector // Only take snapshots while this instance is the leader, if set
+}
+
+// Run takes snapshots until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		wait := s.Interval
+		if s.Schedule != nil {
+			wait = time.Until(s.Schedule.Next(time.Now()))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.Leader.IsLeader() {
+			continue
+		}
+		info, err := TakeSnapshot(ctx, s.DB, s.Store)
+		if err != nil {
+			log.Printf("Scheduled snapshot failed: %v", err)
+			continue
+		}
+		log.Printf("Wrote snapshot %s (%d bytes)", info.Name, info.Size)
+
+		deleted, err := Prune(ctx, s.Store, s.Retention, time.Now())
+		if err != nil {
+			log.Printf("Pruning snapshots failed: %v", err)
+		}
+		if len(deleted) > 0 {
+			log.Printf("Deleted %d snapshots past retention", len(deleted))
+		}
+	}
+}
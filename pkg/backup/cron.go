@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed five field cron expression: minute, hour, day of
+// month, month and day of week. Times are matched in UTC.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // Bit i is set when value i matches
+	domAny, dowAny                bool
+}
+
+// cronFields are the bounds of each field, in order
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses a cron expression such as "0 3 * * *". Each field takes
+// *, a value, a range a-b, a step */n or a-b/n, or a comma separated list of
+// those. Sunday is 0 or 7 in the day of week field.
+func ParseSchedule(spec string) (*Schedule, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", spec, len(cronFields))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		field := cronFields[i]
+		for _, item := range strings.Split(part, ",") {
+			set, err := parseCronItem(item, field.min, field.max)
+			if err != nil {
+				return nil, fmt.Errorf("cron %s %q: %w", field.name, item, err)
+			}
+			bits[i] |= set
+		}
+	}
+	// Sunday may be written as 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseCronItem(item string, min, max int) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(item, "/")
+	step := 1
+	if hasStep {
+		var err error
+		if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+			return 0, fmt.Errorf("step must be a positive number")
+		}
+	}
+
+	low, high := min, max
+	if rangePart != "*" {
+		from, to, isRange := strings.Cut(rangePart, "-")
+		var err error
+		if low, err = strconv.Atoi(from); err != nil {
+			return 0, fmt.Errorf("expected a number")
+		}
+		high = low
+		if isRange {
+			if high, err = strconv.Atoi(to); err != nil {
+				return 0, fmt.Errorf("expected a number")
+			}
+		} else if hasStep {
+			high = max
+		}
+	}
+	if low < min || high > max || low > high {
+		return 0, fmt.Errorf("must be between %d and %d", min, max)
+	}
+
+	var set uint64
+	for v := low; v <= high; v += step {
+		set |= 1 << v
+	}
+	return set, nil
+}
+
+// Next returns the first time after t the schedule matches, to the minute
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Every schedule matches at least once within four years, so this ends
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit
+}
+
+// dayMatches follows cron: when both day fields are restricted, matching
+// either one is enough
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
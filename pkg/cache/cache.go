@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+)
+
+// keyPrefix namespaces the cache's keys in a shared Redis
+const keyPrefix = "registry:cache:"
+
+// generationKey holds a counter bumped on every catalog write. Entries are
+// stored under the generation they were read at, so one INCR invalidates
+// everything at once, across every registry instance sharing the Redis,
+// without tracking which keys a write affected.
+const generationKey = keyPrefix + "generation"
+
+// InvalidateInterval is how often the registry checks for committed writes to
+// invalidate the cache for
+const InvalidateInterval = time.Second
+
+// Cache keeps rendered read results in Redis in front of Postgres. Redis
+// errors are logged and treated as misses so the registry keeps serving from
+// the database if Redis goes away. A nil Cache always loads.
+//
+// Invalidation follows the change index, after writes commit, so a read
+// racing a write caches the old value under the old generation and the next
+// INCR drops it. Until then, for up to InvalidateInterval, reads can still be
+// served the old value.
+type Cache struct {
+	TTL time.Duration
+
+	client *redisClient
+}
+
+// New creates a Cache backed by the Redis server at addr
+func New(addr, password string, db int, ttl time.Duration) *Cache {
+	return &Cache{
+		TTL: ttl,
+		client: &redisClient{
+			addr:     addr,
+			password: password,
+			db:       db,
+			timeout:  time.Second,
+		},
+	}
+}
+
+// Fetch fills v from the cache, or calls load to fill it and caches the
+// result. Errors from load are returned and nothing is cached.
+func (c *Cache) Fetch(key string, v any, load func() error) error {
+	if c == nil {
+		return load()
+	}
+
+	generation, err := c.generation()
+	if err != nil {
+		log.Printf("Cache unavailable: %v", err)
+		return load()
+	}
+	key = keyPrefix + generation + ":" + key
+
+	cached, err := c.client.do("GET", key)
+	if err == nil {
+		if err := json.Unmarshal([]byte(cached.(string)), v); err == nil {
+			return nil
+		}
+	} else if err != errNil {
+		log.Printf("Failed to read cache: %v", err)
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	ttl := strconv.FormatInt(int64(c.TTL/time.Millisecond), 10)
+	if _, err := c.client.do("SET", key, string(data), "PX", ttl); err != nil {
+		log.Printf("Failed to write cache: %v", err)
+	}
+	return nil
+}
+
+// Invalidate drops every cached entry. A nil Cache ignores it.
+func (c *Cache) Invalidate() {
+	if c == nil {
+		return
+	}
+	if _, err := c.client.do("INCR", generationKey); err != nil {
+		log.Printf("Failed to invalidate cache: %v", err)
+	}
+}
+
+func (c *Cache) generation() (string, error) {
+	reply, err := c.client.do("GET", generationKey)
+	if err == errNil {
+		return "0", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return reply.(string), nil
+}
@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// leaseRefresh is how stale the snapshot's leases may get. Heartbeats and
+// probes don't move the catalog version, so the last_seen and latencies they
+// write are refreshed on their own, with one narrow query, rather than by
+// reloading the whole catalog.
+const leaseRefresh = 5 * time.Second
+
+// Snapshot keeps every service and its associations in memory so list and
+// search reads don't each load them from Postgres. It's versioned by the
+// registry_state timestamp, which every catalog write but a lease renewal
+// bumps in its own transaction, so writes from any registry instance are picked up on the
+// next read after they commit.
+type Snapshot struct {
+	DB *gorm.DB
+
+	mu       sync.Mutex
+	version  time.Time
+	leased   time.Time // When leases were last read
+	services []types.MCPService
+}
+
+// NewSnapshot creates a Snapshot loaded lazily on first use
+func NewSnapshot(db *gorm.DB) *Snapshot {
+	return &Snapshot{DB: db}
+}
+
+// Services returns every service as of the catalog version modified,
+// reloading them first if the snapshot is older. It reports false if the
+// snapshot couldn't be loaded, or the Snapshot is nil, in which case callers
+// should query the database. The returned slice is shared and must not be
+// modified.
+func (s *Snapshot) Services(modified time.Time) ([]types.MCPService, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.services != nil && !s.version.Before(modified) {
+		if time.Since(s.leased) >= leaseRefresh {
+			s.refreshLeases()
+		}
+		return s.services, true
+	}
+
+	// Read the version before the services so a write landing in between
+	// leaves the snapshot looking older than it is, never newer
+	leased := time.Now()
+	version, err := db.LastModified(s.DB)
+	if err != nil {
+		log.Printf("Failed to load service snapshot: %v", err)
+		return nil, false
+	}
+	services := []types.MCPService{}
+	if err := db.Preload(s.DB).Find(&services).Error; err != nil {
+		log.Printf("Failed to load service snapshot: %v", err)
+		return nil, false
+	}
+
+	s.version, s.leased, s.services = version, leased, services
+	return services, true
+}
+
+// refreshLeases copies the current leases onto a new copy of the services,
+// leaving the slice earlier callers hold untouched. Failures keep the old
+// leases.
+func (s *Snapshot) refreshLeases() {
+	leased := time.Now()
+	var leases []types.MCPService
+	if err := s.DB.Select("id", "last_seen", "latency_p50_ms", "latency_p95_ms").Find(&leases).Error; err != nil {
+		log.Printf("Failed to refresh service snapshot leases: %v", err)
+		return
+	}
+	byID := make(map[string]types.MCPService, len(leases))
+	for _, lease := range leases {
+		byID[lease.ID] = lease
+	}
+
+	services := slices.Clone(s.services)
+	for i := range services {
+		if lease, ok := byID[services[i].ID]; ok {
+			services[i].LastSeen = lease.LastSeen
+			services[i].LatencyP50Ms, services[i].LatencyP95Ms = lease.LatencyP50Ms, lease.LatencyP95Ms
+		}
+	}
+	s.leased, s.services = leased, services
+}
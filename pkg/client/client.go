@@ -0,0 +1,255 @@
+// Package client is a Go client for the registry API, for MCP servers that
+// register themselves and for tools that manage the registry.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Client talks to a single registry
+type Client struct {
+	BaseURL string
+	Token   string // API key or admin token sent as a bearer token, optional
+	HTTP    *http.Client
+
+	// Signs request bodies for registries that require signed registrations
+	// in the namespace, optional
+	SigningSecret string
+}
+
+// New creates a Client for the registry at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error is an error response from the registry
+type Error struct {
+	StatusCode int
+	RetryAfter time.Duration // How long the registry asked callers to back off, 0 if it didn't
+	types.ErrorBody
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("registry returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 from the registry
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// RetryAfter returns how long the registry asked to wait before retrying
+// when it refused a request for load or an outage, and 0 otherwise
+func RetryAfter(err error) time.Duration {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// Create registers a new service, failing with a 409 if it already exists
+func (c *Client) Create(ctx context.Context, request types.ServiceRegistrationRequest) (types.ServiceResponse, error) {
+	var service types.ServiceResponse
+	err := c.do(ctx, http.MethodPost, "/services", "", request, &service)
+	return service, err
+}
+
+// Register creates a service, or updates the one already registered with the
+// same URL in its namespace. Newly created services may come back with a
+// heartbeat token.
+func (c *Client) Register(ctx context.Context, request types.ServiceRegistrationRequest) (types.ServiceResponse, error) {
+	var service types.ServiceResponse
+	err := c.do(ctx, http.MethodPut, "/services", "", request, &service)
+	return service, err
+}
+
+// Get fetches a service by ID
+func (c *Client) Get(ctx context.Context, serviceID string) (types.ServiceResponse, error) {
+	var service types.ServiceResponse
+	err := c.do(ctx, http.MethodGet, "/services/"+url.PathEscape(serviceID), "", nil, &service)
+	return service, err
+}
+
+// List returns the services matching the query, such as category=search
+func (c *Client) List(ctx context.Context, query url.Values) ([]types.ServiceResponse, error) {
+	path := "/services"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var services []types.ServiceResponse
+	err := c.do(ctx, http.MethodGet, path, "", nil, &services)
+	return services, err
+}
+
+// indexHeader carries the registry's change index on read responses
+const indexHeader = "X-Registry-Index"
+
+// Watch is a blocking List. It returns once the registry's change index has
+// passed index or wait has run out, along with the index the services were
+// read at. wait must be shorter than the HTTP client's timeout.
+func (c *Client) Watch(ctx context.Context, query url.Values, index int64, wait time.Duration) ([]types.ServiceResponse, int64, error) {
+	blocking := url.Values{}
+	for key, values := range query {
+		blocking[key] = values
+	}
+	blocking.Set("index", strconv.FormatInt(index, 10))
+	blocking.Set("wait", wait.String())
+
+	var services []types.ServiceResponse
+	header, err := c.send(ctx, http.MethodGet, "/services?"+blocking.Encode(), "", nil, &services)
+	if err != nil {
+		return nil, 0, err
+	}
+	next, err := strconv.ParseInt(header.Get(indexHeader), 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid %s header: %w", indexHeader, err)
+	}
+	return services, next, nil
+}
+
+// Events returns a page of lifecycle events matching the query, such as
+// since=42&service=<id>. With wait set the call blocks until an event arrives.
+func (c *Client) Events(ctx context.Context, query url.Values) (types.EventsResponse, error) {
+	path := "/events"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var events types.EventsResponse
+	err := c.do(ctx, http.MethodGet, path, "", nil, &events)
+	return events, err
+}
+
+// Update replaces a service's registration
+func (c *Client) Update(ctx context.Context, serviceID string, request types.ServiceRegistrationRequest) (types.ServiceResponse, error) {
+	var service types.ServiceResponse
+	err := c.do(ctx, http.MethodPut, "/services/"+url.PathEscape(serviceID), "", request, &service)
+	return service, err
+}
+
+// Deregister removes a service
+func (c *Client) Deregister(ctx context.Context, serviceID string) error {
+	return c.do(ctx, http.MethodDelete, "/services/"+url.PathEscape(serviceID), "", nil, nil)
+}
+
+// Heartbeat renews a service's lease. token is the service's heartbeat token,
+// the client's own Token is used when it is empty.
+func (c *Client) Heartbeat(ctx context.Context, serviceID, token string, request types.HeartbeatRequest) error {
+	return c.do(ctx, http.MethodPost, "/services/"+url.PathEscape(serviceID)+"/heartbeat", token, request, nil)
+}
+
+// RotateKey replaces the API key the client authenticates with and switches
+// the client over to the new one. The old key keeps working for the
+// registry's grace period, or for grace if it is positive.
+func (c *Client) RotateKey(ctx context.Context, grace time.Duration) (types.RotateKeyResponse, error) {
+	var response types.RotateKeyResponse
+	request := types.RotateKeyRequest{GraceSeconds: int(grace / time.Second)}
+	if err := c.do(ctx, http.MethodPost, "/keys/self/rotate", "", request, &response); err != nil {
+		return response, err
+	}
+	c.Token = response.Key.Token
+	return response, nil
+}
+
+// ImportSnapshot loads a snapshot taken by the registry, merging it into the
+// services already registered or, with mode replace, replacing them
+func (c *Client) ImportSnapshot(ctx context.Context, snapshot json.RawMessage, mode string) (types.SnapshotImportResult, error) {
+	var result types.SnapshotImportResult
+	err := c.do(ctx, http.MethodPost, "/import?mode="+url.QueryEscape(mode), "", snapshot, &result)
+	return result, err
+}
+
+// do sends a JSON request and decodes the JSON response into out, if given
+func (c *Client) do(ctx context.Context, method, path, token string, body, out any) error {
+	_, err := c.send(ctx, method, path, token, body, out)
+	return err
+}
+
+// send is do but also returns the response headers
+func (c *Client) send(ctx context.Context, method, path, token string, body, out any) (http.Header, error) {
+	var data []byte
+	var reader io.Reader
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if c.SigningSecret != "" {
+			if err := auth.SignRequest(req, c.SigningSecret, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if token == "" {
+		token = c.Token
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &Error{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+		var envelope types.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&envelope) == nil {
+			apiErr.ErrorBody = envelope.Error
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = resp.Status
+		}
+		return resp.Header, apiErr
+	}
+
+	if out == nil {
+		return resp.Header, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.Header, fmt.Errorf("decoding response: %w", err)
+	}
+	return resp.Header, nil
+}
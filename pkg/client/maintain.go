@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// defaultHeartbeatInterval is used when the registry doesn't report a TTL
+const defaultHeartbeatInterval = 10 * time.Second
+
+// Registration is a service kept registered by RegisterAndMaintain
+type Registration struct {
+	mu      sync.Mutex
+	service types.ServiceResponse
+	token   string
+	done    chan struct{}
+}
+
+// Service returns the service as it was last registered
+func (r *Registration) Service() types.ServiceResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.service
+}
+
+// Done is closed once the service has been deregistered after the context
+// passed to RegisterAndMaintain was cancelled
+func (r *Registration) Done() <-chan struct{} {
+	return r.done
+}
+
+// RegisterAndMaintain registers a service and keeps it alive in the
+// background, sending heartbeats at a third of the registry's TTL with some
+// jitter so restarted fleets don't heartbeat in lockstep. If the registry has
+// forgotten the service, for instance because it was pruned while unreachable,
+// it is registered again. A registry refusing heartbeats with a Retry-After
+// isn't retried any sooner. When ctx is cancelled the service is deregistered.
+func (c *Client) RegisterAndMaintain(ctx context.Context, request types.ServiceRegistrationRequest) (*Registration, error) {
+	service, err := c.Register(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	registration := &Registration{service: service, token: service.HeartbeatToken, done: make(chan struct{})}
+	go c.maintain(ctx, request, registration)
+	return registration, nil
+}
+
+func (c *Client) maintain(ctx context.Context, request types.ServiceRegistrationRequest, registration *Registration) {
+	defer close(registration.done)
+
+	var retryAfter time.Duration
+	for {
+		service := registration.Service()
+		wait := heartbeatInterval(service)
+		if retryAfter > 0 {
+			// The registry is shedding load, so wait as long as it asked
+			wait, retryAfter = retryAfter, 0
+		}
+		select {
+		case <-ctx.Done():
+			deregisterCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := c.Deregister(deregisterCtx, service.ID); err != nil && !IsNotFound(err) {
+				log.Printf("Failed to deregister %s: %v", service.ID, err)
+			}
+			cancel()
+			return
+		case <-time.After(wait):
+		}
+
+		registration.mu.Lock()
+		token := registration.token
+		registration.mu.Unlock()
+
+		err := c.Heartbeat(ctx, service.ID, token, types.HeartbeatRequest{})
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		if !IsNotFound(err) {
+			log.Printf("Heartbeat for %s failed: %v", service.ID, err)
+			retryAfter = RetryAfter(err)
+			continue
+		}
+
+		registered, err := c.Register(ctx, request)
+		if err != nil {
+			log.Printf("Failed to re-register %s: %v", service.ID, err)
+			retryAfter = RetryAfter(err)
+			continue
+		}
+		registration.mu.Lock()
+		registration.service = registered
+		// Updating an existing registration doesn't hand out a new token
+		if registered.HeartbeatToken != "" || registered.ID != service.ID {
+			registration.token = registered.HeartbeatToken
+		}
+		registration.mu.Unlock()
+	}
+}
+
+// heartbeatInterval is a third of the service's TTL, give or take 10%
+func heartbeatInterval(service types.ServiceResponse) time.Duration {
+	interval := defaultHeartbeatInterval
+	if service.TTLSeconds > 0 {
+		interval = time.Duration(service.TTLSeconds) * time.Second / 3
+	}
+	jitter := time.Duration((rand.Float64()*0.2 - 0.1) * float64(interval))
+	return interval + jitter
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// defaultWatchWait stays under the default client timeout
+const defaultWatchWait = 20 * time.Second
+
+// Watcher keeps an in-memory copy of the services matching a filter, for
+// building gateway routing tables. It long-polls the service list and calls
+// the callbacks, from the goroutine running Run, as services come and go.
+//
+// Heartbeats alone aren't reported as updates, only changes to the
+// registration or its status.
+type Watcher struct {
+	Client *Client
+	Query  url.Values    // Filter as accepted by GET /services, such as category=search
+	Wait   time.Duration // How long each poll is held open, shorter than the client timeout
+
+	OnAdd    func(service types.ServiceResponse)
+	OnUpdate func(previous, service types.ServiceResponse)
+	OnRemove func(service types.ServiceResponse)
+
+	mu       sync.RWMutex
+	services map[string]types.ServiceResponse
+}
+
+// NewWatcher creates a Watcher for the services matching query
+func NewWatcher(client *Client, query url.Values) *Watcher {
+	return &Watcher{
+		Client:   client,
+		Query:    query,
+		Wait:     defaultWatchWait,
+		services: make(map[string]types.ServiceResponse),
+	}
+}
+
+// Run polls until the context is cancelled. Errors are logged and retried
+// with backoff, or after the registry's Retry-After if it's longer, and the
+// cached services are kept meanwhile.
+func (w *Watcher) Run(ctx context.Context) {
+	var index int64
+	backoff := time.Second
+	for {
+		services, next, err := w.Client.Watch(ctx, w.Query, index, w.Wait)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to watch services: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(max(backoff, RetryAfter(err))):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+			continue
+		}
+		backoff = time.Second
+
+		// The index going backwards means the registry state was reset
+		if next < index {
+			next = 0
+		}
+		index = next
+		w.apply(services)
+	}
+}
+
+// Services returns the cached services ordered by ID
+func (w *Watcher) Services() []types.ServiceResponse {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	services := make([]types.ServiceResponse, 0, len(w.services))
+	for _, service := range w.services {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ID < services[j].ID })
+	return services
+}
+
+// Get returns a cached service by ID
+func (w *Watcher) Get(serviceID string) (types.ServiceResponse, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	service, ok := w.services[serviceID]
+	return service, ok
+}
+
+// apply replaces the cache with a fresh listing and reports the differences
+func (w *Watcher) apply(services []types.ServiceResponse) {
+	current := make(map[string]types.ServiceResponse, len(services))
+	for _, service := range services {
+		current[service.ID] = service
+	}
+
+	w.mu.Lock()
+	previous := w.services
+	w.services = current
+	w.mu.Unlock()
+
+	for _, service := range services {
+		old, ok := previous[service.ID]
+		switch {
+		case !ok:
+			if w.OnAdd != nil {
+				w.OnAdd(service)
+			}
+		case changed(old, service):
+			if w.OnUpdate != nil {
+				w.OnUpdate(old, service)
+			}
+		}
+	}
+	for id, service := range previous {
+		if _, ok := current[id]; !ok && w.OnRemove != nil {
+			w.OnRemove(service)
+		}
+	}
+}
+
+// changed compares two versions of a service ignoring what every heartbeat moves
+func changed(a, b types.ServiceResponse) bool {
+	a.LastSeen, b.LastSeen = time.Time{}, time.Time{}
+	a.UpdatedAt, b.UpdatedAt = time.Time{}, time.Time{}
+	a.ExpiresAt, b.ExpiresAt = nil, nil
+	return !reflect.DeepEqual(a, b)
+}
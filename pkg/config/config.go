@@ -0,0 +1,667 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route classes that can be given their own rate limit. Routes outside the
+// other classes fall under RateLimitDefault.
+const (
+	RateLimitDefault   = "default"
+	RateLimitHeartbeat = "heartbeat"
+	RateLimitRegister  = "register"
+	RateLimitSearch    = "search"
+)
+
+// QuotaDefault is the quota entry applying to namespaces without their own
+const QuotaDefault = "*"
+
+// Config holds the registry's runtime settings, read from the environment
+type Config struct {
+	Addr        string
+	DatabaseDSN string
+
+	// Serve HTTPS with this certificate, plain HTTP when empty
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// CA bundle client certificates are verified against. Clients may then
+	// present one, and services registered with it are owned by its identity.
+	TLSClientCAFile string
+
+	// Refuse writes and heartbeats without a verified client certificate,
+	// except from admins
+	RequireClientCert bool
+
+	AccessLog       string // Where access logs go: stdout, stderr, a file path, or off
+	AccessLogFormat string // json, or combined for the Apache combined log format
+	ReplicaDSN      string // Read replica for list, search and get, which may lag behind writes
+
+	// Connection pool limits, applied to the primary and the replica. Zero
+	// keeps database/sql's defaults.
+	DBMaxOpenConns    int64
+	DBMaxIdleConns    int64
+	DBConnMaxLifetime time.Duration
+	DBQueryTimeout    time.Duration // Longest any single query may run, 0 for no limit
+
+	// How often the database is pinged. While it's unreachable requests are
+	// refused with a 503 and a Retry-After instead of failing one by one. 0
+	// disables the check.
+	DBCheckInterval time.Duration
+
+	// Requests served at once before further ones are refused with a 503,
+	// 0 for no limit. Blocking queries, long-polls and heartbeat streams
+	// don't count while they wait.
+	MaxInFlight int64
+
+	// Read service associations from a JSONB column on mcp_services rather
+	// than the child tables, which are still kept up to date
+	JSONBSchema   bool
+	MaxBodyBytes  int64         // Largest accepted request body outside of imports
+	ServiceTTL    time.Duration // How long a service stays healthy without a heartbeat
+	PruneInterval time.Duration
+	MetricsWindow time.Duration // How long metrics reported with heartbeats are kept
+
+	// Requests each caller may make per minute, by route class. Callers are
+	// told apart by API key, or by client IP when anonymous. Classes without
+	// an entry are unlimited.
+	RateLimits map[string]int64
+
+	// Quotas on each namespace's services and the bytes of metadata they
+	// carry, by namespace. The QuotaDefault entry covers namespaces without
+	// their own; namespaces covered by neither are unlimited.
+	QuotaMaxServices      map[string]int64
+	QuotaMaxMetadataBytes map[string]int64
+
+	// Issue each new service a token that can only renew its lease, and
+	// require it on that service's heartbeats
+	HeartbeatTokens bool
+
+	// How long pruned services are archived, during which re-registering
+	// restores their ID. 0 disables archiving.
+	ArchiveGracePeriod time.Duration
+
+	// How long history is kept before the janitor deletes it, 0 keeping it
+	// forever. Archived services are kept for the grace period by default and
+	// can still be restored by an admin after it.
+	AuditRetention        time.Duration
+	ArchiveRetention      time.Duration
+	AvailabilityRetention time.Duration
+	ChangeRetention       time.Duration // Followers further behind get a 410 and must resync
+	EventRetention        time.Duration // Only once delivered or given up on
+	RetentionInterval     time.Duration // How often the janitor runs
+
+	ProbeInterval time.Duration // How often services are health probed, 0 disables probing
+	ProbeTimeout  time.Duration
+
+	// Consecutive failed probes or heartbeat streams before a healthy service
+	// is marked degraded, and successes before a degraded one recovers
+	FailureThreshold  int64
+	RecoveryThreshold int64
+
+	// A service changing status FlapThreshold times within FlapWindow is
+	// flagged as flapping until it has been stable for a whole window
+	FlapThreshold int64
+	FlapWindow    time.Duration
+
+	RegistrationProbe bool // Refuse registrations whose URL doesn't answer a probe
+
+	// Limit which hosts the registry connects to when probing, proxying or
+	// importing. Entries are host names, IPs or CIDRs. Private and loopback
+	// addresses are refused unless allowed explicitly or AllowPrivateURLs is set.
+	URLAllowlist     []string
+	URLDenylist      []string
+	AllowPrivateURLs bool
+
+	ProxyEnabled bool          // Serve /proxy/{id}/ as a minimal gateway
+	ProxyTimeout time.Duration // Default upstream timeout for services without their own
+
+	Peers            []string // Base URLs of peer registries to federate from
+	PeerSyncInterval time.Duration
+
+	UsageFlushInterval time.Duration // How often usage counts are written to the daily rollups
+
+	SnapshotReads bool // Serve list and search from an in-memory copy of the services
+
+	RedisAddr     string // Redis server caching hot reads, disabled when empty
+	RedisPassword string
+	RedisDB       int64
+	CacheTTL      time.Duration
+
+	EurekaEnabled bool // Serve a Eureka-compatible API under /eureka
+
+	DNSAddr string // UDP address of the embedded DNS server, disabled when empty
+	DNSZone string // Zone SRV and TXT records are served under
+
+	WebhookURL         string        // Receives service events such as sunsets as JSON POSTs
+	WebhookInterval    time.Duration // How often queued events are delivered
+	WebhookMaxAttempts int64         // Failed deliveries, backing off between them, before an event is given up on
+
+	OTLPEndpoint string            // OpenTelemetry collector metrics are pushed to over OTLP/HTTP, disabled when empty
+	OTLPHeaders  map[string]string // Extra headers for the collector, e.g. for auth
+	OTLPInterval time.Duration
+
+	ConsulAddr         string // Consul agent HTTP API to mirror services into, disabled when empty
+	ConsulToken        string
+	ConsulSyncInterval time.Duration
+	ConsulImport       bool // Also import Consul services tagged "mcp"
+
+	KubernetesCRD          bool   // Register services declared as MCPService resources
+	KubernetesServices     bool   // Register Services labeled mcp.enabled=true
+	KubernetesNamespace    string // Only watch this namespace, all when empty
+	KubernetesDomain       string // Cluster DNS domain used in Service URLs
+	KubernetesSyncInterval time.Duration
+
+	LeaderElection bool          // Elect one instance to run background jobs, for multi-instance deployments
+	LeaderLeaseTTL time.Duration // How long the leader's lease lasts without renewal
+
+	MirrorUpstream string // When set, refuse writes and replicate this registry instead
+
+	AdminToken string            // Bearer token for /admin routes, which are disabled when empty unless admins log in with OIDC
+	APIKeys    map[string]string // Bearer tokens for API users, mapped to user names
+
+	// How long a rotated API key keeps working alongside its replacement by
+	// default, and how long before a key expires an event warns about it
+	KeyRotationGrace time.Duration
+	KeyExpiryWarning time.Duration
+
+	// OpenID provider people log in with, disabled when OIDCIssuer is empty.
+	// OIDCRedirectURL is the registry's public /auth/callback URL.
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+
+	// ID token claim listing the user's roles or groups. Users with one of
+	// OIDCAdminRoles are admins. When OIDCAllowedRoles is set, users need an
+	// admin role or one of those to log in at all.
+	OIDCRoleClaim    string
+	OIDCAdminRoles   []string
+	OIDCAllowedRoles []string
+	SessionTTL       time.Duration // How long a login lasts
+
+	// Client networks (CIDRs or IPs) allowed to reach the admin API, and the
+	// routes that modify the registry. Either is unrestricted when empty.
+	AdminAllowedCIDRs []string
+	WriteAllowedCIDRs []string
+
+	// YAML file mapping publisher names to the cosign or minisign public keys
+	// manifest signatures are verified against
+	PublisherKeysFile string
+
+	// Metadata keys whose values are encrypted at rest and redacted from
+	// responses, except to admins and keys with the secrets scope. The
+	// master key file holds a base64 encoded 32 byte AES key.
+	SensitiveMetadataKeys []string
+	SecretKeyFile         string
+
+	// YAML file with the fields stripped and masked from service responses
+	// for anonymous and read-only callers, see package redact
+	ResponsePolicyFile string
+
+	// YAML or JSON file mapping extension names to the JSON Schemas their
+	// data must match, see package extensions. Without it registrations
+	// can't carry extensions.
+	ExtensionSchemasFile string
+
+	// Secrets registrations into a namespace must be HMAC signed with, by
+	// namespace, and how far a signature's timestamp may be from the clock
+	SigningSecrets     map[string]string
+	SignatureTolerance time.Duration
+
+	RequireApproval bool // New registrations wait in pending_review until an admin approves them
+
+	// Only accept categories from the admin-managed canonical list, once it has any entries
+	EnforceCategories bool
+
+	BackupInterval    time.Duration // How often to take snapshots, 0 disables scheduled backups
+	BackupSchedule    string        // Cron expression, in UTC, to take snapshots on instead of BackupInterval
+	BackupKeep        int64         // Newest snapshots to keep, 0 keeps them all
+	BackupMaxAge      time.Duration // Delete snapshots older than this, 0 keeps them all
+	BackupDir         string
+	BackupS3Endpoint  string
+	BackupS3Bucket    string // Store snapshots in this bucket instead of BackupDir
+	BackupS3Region    string
+	BackupS3Prefix    string
+	BackupS3AccessKey string
+	BackupS3SecretKey string
+	BackupS3SSE       string // Server-side encryption for uploads: AES256 or aws:kms
+	BackupS3KMSKeyID  string
+}
+
+// Load reads the configuration from environment variables, falling back to defaults
+func Load() (*Config, error) {
+	cfg := &Config{
+		Addr:        getEnv("REGISTRY_ADDR", ":42069"),
+		DatabaseDSN: getEnv("REGISTRY_DATABASE_DSN", "host=localhost user=postgres password=postgres dbname=gateway port=5432 sslmode=disable"),
+	}
+
+	cfg.ReplicaDSN = getEnv("REGISTRY_DATABASE_REPLICA_DSN", "")
+
+	cfg.TLSCertFile = getEnv("REGISTRY_TLS_CERT_FILE", "")
+	cfg.TLSKeyFile = getEnv("REGISTRY_TLS_KEY_FILE", "")
+	cfg.TLSClientCAFile = getEnv("REGISTRY_TLS_CLIENT_CA_FILE", "")
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("REGISTRY_TLS_CERT_FILE and REGISTRY_TLS_KEY_FILE must be set together")
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("REGISTRY_TLS_CLIENT_CA_FILE requires REGISTRY_TLS_CERT_FILE")
+	}
+
+	cfg.AccessLog = getEnv("REGISTRY_ACCESS_LOG", "stdout")
+	cfg.AccessLogFormat = getEnv("REGISTRY_ACCESS_LOG_FORMAT", "combined")
+	if cfg.AccessLogFormat != "json" && cfg.AccessLogFormat != "combined" {
+		return nil, fmt.Errorf("invalid REGISTRY_ACCESS_LOG_FORMAT: must be json or combined")
+	}
+
+	var err error
+	if cfg.RequireClientCert, err = getBool("REGISTRY_REQUIRE_CLIENT_CERT", false); err != nil {
+		return nil, err
+	}
+	if cfg.RequireClientCert && cfg.TLSClientCAFile == "" {
+		return nil, fmt.Errorf("REGISTRY_REQUIRE_CLIENT_CERT requires REGISTRY_TLS_CLIENT_CA_FILE")
+	}
+	if cfg.DBMaxOpenConns, err = getInt64("REGISTRY_DB_MAX_OPEN_CONNS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBMaxIdleConns, err = getInt64("REGISTRY_DB_MAX_IDLE_CONNS", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBConnMaxLifetime, err = getDuration("REGISTRY_DB_CONN_MAX_LIFETIME", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBQueryTimeout, err = getDuration("REGISTRY_DB_QUERY_TIMEOUT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.DBCheckInterval, err = getDuration("REGISTRY_DB_CHECK_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MaxInFlight, err = getInt64("REGISTRY_MAX_IN_FLIGHT", 0); err != nil {
+		return nil, err
+	}
+	if cfg.JSONBSchema, err = getBool("REGISTRY_JSONB_SCHEMA", false); err != nil {
+		return nil, err
+	}
+	if cfg.MaxBodyBytes, err = getInt64("REGISTRY_MAX_BODY_BYTES", 1<<20); err != nil {
+		return nil, err
+	}
+	if cfg.ServiceTTL, err = getDuration("REGISTRY_SERVICE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.PruneInterval, err = getDuration("REGISTRY_PRUNE_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.MetricsWindow, err = getDuration("REGISTRY_METRICS_WINDOW", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimits, err = getRateLimits("REGISTRY_RATE_LIMITS"); err != nil {
+		return nil, err
+	}
+	if cfg.QuotaMaxServices, err = getQuotas("REGISTRY_QUOTA_MAX_SERVICES"); err != nil {
+		return nil, err
+	}
+	if cfg.QuotaMaxMetadataBytes, err = getQuotas("REGISTRY_QUOTA_MAX_METADATA_BYTES"); err != nil {
+		return nil, err
+	}
+	if cfg.HeartbeatTokens, err = getBool("REGISTRY_HEARTBEAT_TOKENS", false); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveGracePeriod, err = getDuration("REGISTRY_ARCHIVE_GRACE_PERIOD", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.AuditRetention, err = getDuration("REGISTRY_AUDIT_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveRetention, err = getDuration("REGISTRY_ARCHIVE_RETENTION", cfg.ArchiveGracePeriod); err != nil {
+		return nil, err
+	}
+	if cfg.ArchiveRetention > 0 && cfg.ArchiveRetention < cfg.ArchiveGracePeriod {
+		return nil, fmt.Errorf("REGISTRY_ARCHIVE_RETENTION must be at least REGISTRY_ARCHIVE_GRACE_PERIOD")
+	}
+	if cfg.AvailabilityRetention, err = getDuration("REGISTRY_AVAILABILITY_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ChangeRetention, err = getDuration("REGISTRY_CHANGE_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.EventRetention, err = getDuration("REGISTRY_EVENT_RETENTION", 0); err != nil {
+		return nil, err
+	}
+	if cfg.RetentionInterval, err = getDuration("REGISTRY_RETENTION_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.RetentionInterval <= 0 {
+		return nil, fmt.Errorf("REGISTRY_RETENTION_INTERVAL must be positive")
+	}
+	if cfg.ProbeInterval, err = getDuration("REGISTRY_PROBE_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.ProbeTimeout, err = getDuration("REGISTRY_PROBE_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.RegistrationProbe, err = getBool("REGISTRY_REGISTRATION_PROBE", false); err != nil {
+		return nil, err
+	}
+	if cfg.FailureThreshold, err = getInt64("REGISTRY_FAILURE_THRESHOLD", 3); err != nil {
+		return nil, err
+	}
+	if cfg.RecoveryThreshold, err = getInt64("REGISTRY_RECOVERY_THRESHOLD", 1); err != nil {
+		return nil, err
+	}
+	if cfg.FailureThreshold < 1 || cfg.RecoveryThreshold < 1 {
+		return nil, fmt.Errorf("REGISTRY_FAILURE_THRESHOLD and REGISTRY_RECOVERY_THRESHOLD must be at least 1")
+	}
+	if cfg.FlapThreshold, err = getInt64("REGISTRY_FLAP_THRESHOLD", 4); err != nil {
+		return nil, err
+	}
+	if cfg.FlapWindow, err = getDuration("REGISTRY_FLAP_WINDOW", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	cfg.URLAllowlist = getList("REGISTRY_URL_ALLOWLIST")
+	cfg.URLDenylist = getList("REGISTRY_URL_DENYLIST")
+	if cfg.AllowPrivateURLs, err = getBool("REGISTRY_ALLOW_PRIVATE_URLS", false); err != nil {
+		return nil, err
+	}
+	if cfg.ProxyEnabled, err = getBool("REGISTRY_PROXY_ENABLED", false); err != nil {
+		return nil, err
+	}
+	if cfg.ProxyTimeout, err = getDuration("REGISTRY_PROXY_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.UsageFlushInterval, err = getDuration("REGISTRY_USAGE_FLUSH_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.SnapshotReads, err = getBool("REGISTRY_SNAPSHOT_READS", true); err != nil {
+		return nil, err
+	}
+	cfg.RedisAddr = getEnv("REGISTRY_REDIS_ADDR", "")
+	cfg.RedisPassword = getEnv("REGISTRY_REDIS_PASSWORD", "")
+	if cfg.RedisDB, err = getInt64("REGISTRY_REDIS_DB", 0); err != nil {
+		return nil, err
+	}
+	if cfg.CacheTTL, err = getDuration("REGISTRY_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.EurekaEnabled, err = getBool("REGISTRY_EUREKA_ENABLED", false); err != nil {
+		return nil, err
+	}
+	cfg.DNSAddr = getEnv("REGISTRY_DNS_ADDR", "")
+	cfg.DNSZone = getEnv("REGISTRY_DNS_ZONE", "registry.local")
+	cfg.WebhookURL = getEnv("REGISTRY_WEBHOOK_URL", "")
+	if cfg.WebhookInterval, err = getDuration("REGISTRY_WEBHOOK_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookMaxAttempts, err = getInt64("REGISTRY_WEBHOOK_MAX_ATTEMPTS", 10); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookMaxAttempts <= 0 {
+		return nil, fmt.Errorf("REGISTRY_WEBHOOK_MAX_ATTEMPTS must be positive")
+	}
+	cfg.OTLPEndpoint = getEnv("REGISTRY_OTLP_ENDPOINT", "")
+	if cfg.OTLPHeaders, err = getPairs("REGISTRY_OTLP_HEADERS"); err != nil {
+		return nil, err
+	}
+	if cfg.OTLPInterval, err = getDuration("REGISTRY_OTLP_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	cfg.ConsulAddr = getEnv("REGISTRY_CONSUL_ADDR", "")
+	cfg.ConsulToken = getEnv("REGISTRY_CONSUL_TOKEN", "")
+	if cfg.ConsulSyncInterval, err = getDuration("REGISTRY_CONSUL_SYNC_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ConsulImport, err = getBool("REGISTRY_CONSUL_IMPORT", false); err != nil {
+		return nil, err
+	}
+	if cfg.KubernetesCRD, err = getBool("REGISTRY_KUBERNETES_CRD", false); err != nil {
+		return nil, err
+	}
+	if cfg.KubernetesServices, err = getBool("REGISTRY_KUBERNETES_SERVICES", false); err != nil {
+		return nil, err
+	}
+	cfg.KubernetesNamespace = getEnv("REGISTRY_KUBERNETES_NAMESPACE", "")
+	cfg.KubernetesDomain = getEnv("REGISTRY_KUBERNETES_CLUSTER_DOMAIN", "cluster.local")
+	if cfg.KubernetesSyncInterval, err = getDuration("REGISTRY_KUBERNETES_SYNC_INTERVAL", 15*time.Second); err != nil {
+		return nil, err
+	}
+	cfg.Peers = getList("REGISTRY_PEERS")
+	if cfg.LeaderElection, err = getBool("REGISTRY_LEADER_ELECTION", false); err != nil {
+		return nil, err
+	}
+	if cfg.LeaderLeaseTTL, err = getDuration("REGISTRY_LEADER_LEASE_TTL", 15*time.Second); err != nil {
+		return nil, err
+	}
+	cfg.MirrorUpstream = getEnv("REGISTRY_MIRROR_UPSTREAM", "")
+	cfg.AdminToken = getEnv("REGISTRY_ADMIN_TOKEN", "")
+	if cfg.APIKeys, err = getKeyMap("REGISTRY_API_KEYS"); err != nil {
+		return nil, err
+	}
+	if cfg.KeyRotationGrace, err = getDuration("REGISTRY_KEY_ROTATION_GRACE", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.KeyExpiryWarning, err = getDuration("REGISTRY_KEY_EXPIRY_WARNING", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.OIDCIssuer = getEnv("REGISTRY_OIDC_ISSUER", "")
+	cfg.OIDCClientID = getEnv("REGISTRY_OIDC_CLIENT_ID", "")
+	cfg.OIDCClientSecret = getEnv("REGISTRY_OIDC_CLIENT_SECRET", "")
+	cfg.OIDCRedirectURL = getEnv("REGISTRY_OIDC_REDIRECT_URL", "")
+	if cfg.OIDCIssuer != "" && (cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "") {
+		return nil, fmt.Errorf("REGISTRY_OIDC_ISSUER requires REGISTRY_OIDC_CLIENT_ID and REGISTRY_OIDC_REDIRECT_URL")
+	}
+	cfg.OIDCRoleClaim = getEnv("REGISTRY_OIDC_ROLE_CLAIM", "groups")
+	cfg.OIDCAdminRoles = getList("REGISTRY_OIDC_ADMIN_ROLES")
+	cfg.OIDCAllowedRoles = getList("REGISTRY_OIDC_ALLOWED_ROLES")
+	if cfg.SessionTTL, err = getDuration("REGISTRY_SESSION_TTL", 12*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.AdminAllowedCIDRs = getList("REGISTRY_ADMIN_ALLOWED_CIDRS")
+	cfg.WriteAllowedCIDRs = getList("REGISTRY_WRITE_ALLOWED_CIDRS")
+	cfg.PublisherKeysFile = getEnv("REGISTRY_PUBLISHER_KEYS_FILE", "")
+	cfg.SensitiveMetadataKeys = getList("REGISTRY_SENSITIVE_METADATA_KEYS")
+	cfg.SecretKeyFile = getEnv("REGISTRY_SECRET_KEY_FILE", "")
+	cfg.ResponsePolicyFile = getEnv("REGISTRY_RESPONSE_POLICY_FILE", "")
+	cfg.ExtensionSchemasFile = getEnv("REGISTRY_EXTENSION_SCHEMAS_FILE", "")
+	if len(cfg.SensitiveMetadataKeys) > 0 && cfg.SecretKeyFile == "" {
+		return nil, fmt.Errorf("REGISTRY_SENSITIVE_METADATA_KEYS requires REGISTRY_SECRET_KEY_FILE")
+	}
+	if cfg.SigningSecrets, err = getPairs("REGISTRY_SIGNING_SECRETS"); err != nil {
+		return nil, err
+	}
+	if cfg.SignatureTolerance, err = getDuration("REGISTRY_SIGNATURE_TOLERANCE", 5*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.RequireApproval, err = getBool("REGISTRY_REQUIRE_APPROVAL", false); err != nil {
+		return nil, err
+	}
+	if cfg.EnforceCategories, err = getBool("REGISTRY_ENFORCE_CATEGORIES", false); err != nil {
+		return nil, err
+	}
+
+	if cfg.BackupInterval, err = getDuration("REGISTRY_BACKUP_INTERVAL", 0); err != nil {
+		return nil, err
+	}
+	cfg.BackupSchedule = getEnv("REGISTRY_BACKUP_SCHEDULE", "")
+	if cfg.BackupSchedule != "" && cfg.BackupInterval > 0 {
+		return nil, fmt.Errorf("REGISTRY_BACKUP_SCHEDULE and REGISTRY_BACKUP_INTERVAL must not be set together")
+	}
+	if cfg.BackupKeep, err = getInt64("REGISTRY_BACKUP_KEEP", 0); err != nil {
+		return nil, err
+	}
+	if cfg.BackupMaxAge, err = getDuration("REGISTRY_BACKUP_MAX_AGE", 0); err != nil {
+		return nil, err
+	}
+	cfg.BackupDir = getEnv("REGISTRY_BACKUP_DIR", "backups")
+	cfg.BackupS3Endpoint = getEnv("REGISTRY_BACKUP_S3_ENDPOINT", "https://s3.amazonaws.com")
+	cfg.BackupS3Bucket = getEnv("REGISTRY_BACKUP_S3_BUCKET", "")
+	cfg.BackupS3Region = getEnv("REGISTRY_BACKUP_S3_REGION", "us-east-1")
+	cfg.BackupS3Prefix = getEnv("REGISTRY_BACKUP_S3_PREFIX", "")
+	cfg.BackupS3AccessKey = getEnv("REGISTRY_BACKUP_S3_ACCESS_KEY_ID", "")
+	cfg.BackupS3SecretKey = getEnv("REGISTRY_BACKUP_S3_SECRET_ACCESS_KEY", "")
+	cfg.BackupS3SSE = getEnv("REGISTRY_BACKUP_S3_SSE", "")
+	if cfg.BackupS3SSE != "" && cfg.BackupS3SSE != "AES256" && cfg.BackupS3SSE != "aws:kms" {
+		return nil, fmt.Errorf("invalid REGISTRY_BACKUP_S3_SSE: must be AES256 or aws:kms")
+	}
+	cfg.BackupS3KMSKeyID = getEnv("REGISTRY_BACKUP_S3_KMS_KEY_ID", "")
+	if cfg.BackupS3KMSKeyID != "" && cfg.BackupS3SSE != "aws:kms" {
+		return nil, fmt.Errorf("REGISTRY_BACKUP_S3_KMS_KEY_ID requires REGISTRY_BACKUP_S3_SSE=aws:kms")
+	}
+	if cfg.PeerSyncInterval, err = getDuration("REGISTRY_PEER_SYNC_INTERVAL", 15*time.Second); err != nil {
+		return nil, err
+	}
+
+	// The loops these pace panic or spin unless they're positive. Leases are
+	// renewed at a third of their TTL, so those need at least a second.
+	for _, setting := range []struct {
+		key      string
+		value    time.Duration
+		minimum  time.Duration
+		disabled bool // Whether 0 turns the loop off
+	}{
+		{"REGISTRY_DB_CHECK_INTERVAL", cfg.DBCheckInterval, 0, true},
+		{"REGISTRY_SERVICE_TTL", cfg.ServiceTTL, time.Second, false},
+		{"REGISTRY_PRUNE_INTERVAL", cfg.PruneInterval, 0, false},
+		{"REGISTRY_PROBE_INTERVAL", cfg.ProbeInterval, 0, true},
+		{"REGISTRY_USAGE_FLUSH_INTERVAL", cfg.UsageFlushInterval, 0, false},
+		{"REGISTRY_CACHE_TTL", cfg.CacheTTL, 0, false},
+		{"REGISTRY_WEBHOOK_INTERVAL", cfg.WebhookInterval, 0, false},
+		{"REGISTRY_OTLP_INTERVAL", cfg.OTLPInterval, 0, false},
+		{"REGISTRY_CONSUL_SYNC_INTERVAL", cfg.ConsulSyncInterval, 0, false},
+		{"REGISTRY_KUBERNETES_SYNC_INTERVAL", cfg.KubernetesSyncInterval, 0, false},
+		{"REGISTRY_LEADER_LEASE_TTL", cfg.LeaderLeaseTTL, time.Second, false},
+		{"REGISTRY_BACKUP_INTERVAL", cfg.BackupInterval, 0, true},
+		{"REGISTRY_PEER_SYNC_INTERVAL", cfg.PeerSyncInterval, 0, false},
+	} {
+		switch {
+		case setting.disabled && setting.value == 0:
+		case setting.value <= 0:
+			return nil, fmt.Errorf("%s must be positive", setting.key)
+		case setting.value < setting.minimum:
+			return nil, fmt.Errorf("%s must be at least %s", setting.key, setting.minimum)
+		}
+	}
+	return cfg, nil
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return d, nil
+}
+
+func getInt64(key string, fallback int64) (int64, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return n, nil
+}
+
+func getBool(key string, fallback bool) (bool, error) {
+	value := getEnv(key, "")
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// getList splits a comma separated variable, dropping empty entries
+func getList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getPairs parses "key=value,key=value" into a map
+func getPairs(key string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, pair := range getList(key) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid %s: entries must look like key=value", key)
+		}
+		pairs[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return pairs, nil
+}
+
+// getRateLimits parses "class=limit,class=limit" into per minute limits by
+// route class
+func getRateLimits(key string) (map[string]int64, error) {
+	pairs, err := getPairs(key)
+	if err != nil {
+		return nil, err
+	}
+	limits := make(map[string]int64, len(pairs))
+	for class, value := range pairs {
+		switch class {
+		case RateLimitDefault, RateLimitHeartbeat, RateLimitRegister, RateLimitSearch:
+		default:
+			return nil, fmt.Errorf("invalid %s: unknown route class %q", key, class)
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid %s: limit for %s must be a positive number of requests per minute", key, class)
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// getQuotas parses "namespace=limit,*=limit" into a map from namespace to limit
+func getQuotas(key string) (map[string]int64, error) {
+	pairs, err := getPairs(key)
+	if err != nil {
+		return nil, err
+	}
+	quotas := make(map[string]int64, len(pairs))
+	for namespace, value := range pairs {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid %s: quota for %s must be a non-negative number", key, namespace)
+		}
+		quotas[namespace] = limit
+	}
+	return quotas, nil
+}
+
+// getKeyMap parses "user:token,user:token" into a map from token to user
+func getKeyMap(key string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range getList(key) {
+		name, token, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("invalid %s: entries must look like user:token", key)
+		}
+		keys[token] = name
+	}
+	return keys, nil
+}
@@ -0,0 +1,303 @@
+package consul
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+const (
+	// mcpTag marks Consul services that are MCP servers
+	mcpTag = "mcp"
+	// managedMeta marks Consul services registered by this registry, holding the registry ID
+	managedMeta = "mcp_registry_id"
+	// idPrefix prefixes the Consul service ID of exported services
+	idPrefix = "mcp-registry-"
+)
+
+// consulNamespace derives stable registry IDs for services imported from Consul
+var consulNamespace = uuid.MustParse("4b5e2c1a-8f3d-4e7b-9a6c-2d1f0e8b7c35")
+
+// Syncer mirrors the registry's local published services into a Consul
+// agent and, with Import set, pulls healthy Consul services tagged "mcp" into
+// the registry as read-only entries with origin "consul". Imported entries
+// that disappear from Consul are pruned here once their last_seen falls
+// outside the TTL, like federated ones.
+type Syncer struct {
+	DB       *gorm.DB
+	Addr     string // Base URL of the Consul agent's HTTP API
+	Token    string
+	Interval time.Duration
+	Import   bool
+	Client   *http.Client
+}
+
+// NewSyncer creates a Syncer for the Consul agent at addr
+func NewSyncer(db *gorm.DB, addr, token string, interval time.Duration) *Syncer {
+	return &Syncer{
+		DB:       db,
+		Addr:     strings.TrimSuffix(addr, "/"),
+		Token:    token,
+		Interval: interval,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Run syncs on each interval until the context is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Export(ctx); err != nil {
+			log.Printf("Failed to export services to Consul: %v", err)
+		}
+		if s.Import {
+			if err := s.ImportServices(ctx); err != nil {
+				log.Printf("Failed to import services from Consul: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// agentService is a service as registered with or listed by the Consul agent
+type agentService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service,omitempty"`
+	Name    string            `json:"Name,omitempty"`
+	Tags    []string          `json:"Tags"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+// Export registers every local published service with the Consul agent and
+// deregisters the ones this registry registered that no longer exist
+func (s *Syncer) Export(ctx context.Context) error {
+	var services []types.MCPService
+	if err := db.Preload(s.DB).Where("origin = '' AND state = ?", types.StatePublished).Find(&services).Error; err != nil {
+		return err
+	}
+
+	current := make(map[string]bool, len(services))
+	for _, service := range services {
+		registration, ok := toConsul(service)
+		if !ok {
+			continue
+		}
+		if err := s.do(ctx, http.MethodPut, "/v1/agent/service/register", registration, nil); err != nil {
+			return fmt.Errorf("registering %s: %w", service.ID, err)
+		}
+		current[registration.ID] = true
+	}
+
+	var registered map[string]agentService
+	if err := s.do(ctx, http.MethodGet, "/v1/agent/services", nil, &registered); err != nil {
+		return err
+	}
+	for id, service := range registered {
+		if service.Meta[managedMeta] == "" || current[id] {
+			continue
+		}
+		if err := s.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(id), nil, nil); err != nil {
+			return fmt.Errorf("deregistering %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// toConsul maps a service onto a Consul agent registration
+func toConsul(service types.MCPService) (agentService, bool) {
+	parsed, err := url.Parse(service.URL)
+	if err != nil || parsed.Hostname() == "" {
+		return agentService{}, false
+	}
+	port := 80
+	if parsed.Scheme == "https" {
+		port = 443
+	}
+	if p := parsed.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return agentService{}, false
+		}
+	}
+
+	tags := []string{mcpTag}
+	for _, category := range service.Categories {
+		tags = append(tags, category.Name)
+	}
+	return agentService{
+		ID:      idPrefix + service.ID,
+		Name:    service.Name,
+		Tags:    tags,
+		Address: parsed.Hostname(),
+		Port:    port,
+		Meta: map[string]string{
+			managedMeta: service.ID,
+			"namespace": service.Namespace,
+			"url":       service.URL,
+		},
+	}, true
+}
+
+// healthEntry is one instance returned by the Consul health API
+type healthEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service agentService `json:"Service"`
+}
+
+// ImportServices pulls the passing instances of every Consul service tagged
+// "mcp" that this registry didn't export itself
+func (s *Syncer) ImportServices(ctx context.Context) error {
+	var catalog map[string][]string
+	if err := s.do(ctx, http.MethodGet, "/v1/catalog/services", nil, &catalog); err != nil {
+		return err
+	}
+
+	for name, tags := range catalog {
+		if !hasTag(tags, mcpTag) {
+			continue
+		}
+
+		var entries []healthEntry
+		path := "/v1/health/service/" + url.PathEscape(name) + "?passing=true&tag=" + mcpTag
+		if err := s.do(ctx, http.MethodGet, path, nil, &entries); err != nil {
+			return fmt.Errorf("listing %s: %w", name, err)
+		}
+
+		for _, entry := range entries {
+			if entry.Service.Meta[managedMeta] != "" {
+				continue
+			}
+			if err := s.save(fromConsul(entry)); err != nil {
+				log.Printf("Failed to import Consul service %s: %v", entry.Service.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fromConsul maps a Consul service instance onto a registry service
+func fromConsul(entry healthEntry) types.MCPService {
+	address := entry.Service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+	serviceURL := entry.Service.Meta["url"]
+	if serviceURL == "" {
+		serviceURL = "http://" + net.JoinHostPort(address, strconv.Itoa(entry.Service.Port))
+	}
+	namespace := entry.Service.Meta["namespace"]
+	if namespace == "" {
+		namespace = types.DefaultNamespace
+	}
+
+	id := uuid.NewSHA1(consulNamespace, []byte(entry.Service.ID)).String()
+	service := types.MCPService{
+		ID:        id,
+		Namespace: namespace,
+		Name:      entry.Service.Service,
+		URL:       serviceURL,
+		LastSeen:  time.Now(),
+		Weight:    types.DefaultWeight,
+		Status:    types.StatusHealthy,
+		State:     types.StatePublished,
+		Origin:    "consul",
+	}
+	for _, tag := range entry.Service.Tags {
+		if tag != mcpTag {
+			service.Categories = append(service.Categories, types.Category{ServiceID: id, Name: tag})
+		}
+	}
+	for key, value := range entry.Service.Meta {
+		service.Metadata = append(service.Metadata, types.MetadataItem{ServiceID: id, Key: key, Value: value})
+	}
+	return service
+}
+
+// save upserts an imported service, keeping its original creation time
+func (s *Syncer) save(service types.MCPService) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var existing types.MCPService
+		err := tx.Select("id", "origin", "created_at").Limit(1).Find(&existing, "id = ?", service.ID).Error
+		if err != nil {
+			return err
+		}
+		if existing.ID != "" {
+			if existing.Origin != service.Origin {
+				return fmt.Errorf("service already exists with origin %q", existing.Origin)
+			}
+			service.CreatedAt = existing.CreatedAt
+		}
+		return db.SaveService(tx, service)
+	})
+}
+
+// do sends a request to the Consul HTTP API, encoding body and decoding the response into out when set
+func (s *Syncer) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.Addr+path, reader)
+	if err != nil {
+		return err
+	}
+	if s.Token != "" {
+		req.Header.Set("X-Consul-Token", s.Token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
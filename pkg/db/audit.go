@@ -0,0 +1,17 @@
+package db
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordAudit appends an entry to the audit log
+func RecordAudit(tx *gorm.DB, serviceID, action, actor, details string) error {
+	return tx.Create(&types.AuditEntry{
+		ServiceID: serviceID,
+		Action:    action,
+		Actor:     actor,
+		Details:   details,
+	}).Error
+}
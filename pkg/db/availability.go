@@ -0,0 +1,38 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordAvailability counts a successful or failed heartbeat/probe in the bucket containing at
+func RecordAvailability(db *gorm.DB, serviceID string, success bool, at time.Time) error {
+	bucket := types.AvailabilityBucket{
+		ServiceID:   serviceID,
+		BucketStart: at.UTC().Truncate(types.AvailabilityBucketSize),
+	}
+	column := "failures"
+	if success {
+		column = "successes"
+		bucket.Successes = 1
+	} else {
+		bucket.Failures = 1
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "service_id"}, {Name: "bucket_start"}},
+		DoUpdates: clause.Set{{Column: clause.Column{Name: column}, Value: gorm.Expr("availability." + column + " + 1")}},
+	}).Create(&bucket).Error
+}
+
+// AvailabilitySince returns a service's buckets starting at or after from, oldest first
+func AvailabilitySince(db *gorm.DB, serviceID string, from time.Time) ([]types.AvailabilityBucket, error) {
+	var buckets []types.AvailabilityBucket
+	err := db.Where("service_id = ? AND bucket_start >= ?", serviceID, from.UTC().Truncate(types.AvailabilityBucketSize)).
+		Order("bucket_start").Find(&buckets).Error
+	return buckets, err
+}
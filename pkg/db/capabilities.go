@@ -0,0 +1,35 @@
+package db
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// CapabilitySummaries lists every enabled capability of published services
+// with the services exposing it, ordered by name. scope narrows the services
+// considered, such as to those a caller may see.
+func CapabilitySummaries(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) ([]types.CapabilitySummary, error) {
+	var rows []struct {
+		Name      string
+		ServiceID string
+	}
+	if err := db.Model(&types.Capability{}).
+		Select("DISTINCT capabilities.name, capabilities.service_id").
+		Joins("JOIN mcp_services ON mcp_services.id = capabilities.service_id").
+		Where("capabilities.enabled = ? AND mcp_services.state = ?", true, types.StatePublished).Scopes(scope).
+		Order("capabilities.name, capabilities.service_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := []types.CapabilitySummary{}
+	for _, row := range rows {
+		if n := len(summaries); n == 0 || summaries[n-1].Name != row.Name {
+			summaries = append(summaries, types.CapabilitySummary{Name: row.Name})
+		}
+		summary := &summaries[len(summaries)-1]
+		summary.ServiceIDs = append(summary.ServiceIDs, row.ServiceID)
+		summary.Count++
+	}
+	return summaries, nil
+}
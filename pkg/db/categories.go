@@ -0,0 +1,80 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// CategorySummaries lists every category in use or in the canonical list,
+// with the number of services in each
+func CategorySummaries(db *gorm.DB) ([]types.CategorySummary, error) {
+	var used []types.CategorySummary
+	if err := db.Model(&types.Category{}).Select("name, COUNT(DISTINCT service_id) AS count").
+		Group("name").Scan(&used).Error; err != nil {
+		return nil, err
+	}
+
+	var canonical []types.CanonicalCategory
+	if err := db.Find(&canonical).Error; err != nil {
+		return nil, err
+	}
+
+	// Indexes rather than pointers, which appending would leave pointing
+	// into the old array
+	byName := make(map[string]int, len(used)+len(canonical))
+	for i := range used {
+		byName[used[i].Name] = i
+	}
+	for _, category := range canonical {
+		i, ok := byName[category.Name]
+		if !ok {
+			i = len(used)
+			used = append(used, types.CategorySummary{Name: category.Name})
+		}
+		summary := &used[i]
+		summary.Canonical = true
+		summary.Description = category.Description
+		summary.MetadataSchema = category.MetadataSchema
+	}
+
+	sort.Slice(used, func(i, j int) bool { return used[i].Name < used[j].Name })
+	return used, nil
+}
+
+// CategorySchemas returns the metadata schemas of the canonical categories
+// among names, matched regardless of case, by canonical name
+func CategorySchemas(db *gorm.DB, names []string) (map[string]types.JSONDocument, error) {
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(name)
+	}
+	var categories []types.CanonicalCategory
+	if err := db.Where("LOWER(name) IN ? AND metadata_schema IS NOT NULL", lowered).Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	schemas := make(map[string]types.JSONDocument, len(categories))
+	for _, category := range categories {
+		schemas[category.Name] = category.MetadataSchema
+	}
+	return schemas, nil
+}
+
+// CanonicalCategories maps the lowercased names of canonical categories to
+// their canonical spelling
+func CanonicalCategories(db *gorm.DB) (map[string]string, error) {
+	var names []string
+	if err := db.Model(&types.CanonicalCategory{}).Pluck("name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	canonical := make(map[string]string, len(names))
+	for _, name := range names {
+		canonical[strings.ToLower(name)] = name
+	}
+	return canonical, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordChange appends a registration change to the change feed. Heartbeats
+// and health updates aren't recorded. The caller owns the transaction.
+func RecordChange(tx *gorm.DB, changeType, serviceID string) error {
+	return RecordChanges(tx, changeType, []string{serviceID})
+}
+
+// RecordChanges appends the same kind of change for several services at once
+func RecordChanges(tx *gorm.DB, changeType string, serviceIDs []string) error {
+	if len(serviceIDs) == 0 {
+		return nil
+	}
+
+	// Hold the registry_state row, which every catalog write also updates, so
+	// sequence numbers are handed out and committed in the same order
+	if err := tx.Exec("SELECT id FROM registry_state WHERE id = ? FOR UPDATE", registryStateID).Error; err != nil {
+		return err
+	}
+	changes := make([]types.Change, len(serviceIDs))
+	for i, id := range serviceIDs {
+		changes[i] = types.Change{Type: changeType, ServiceID: id}
+	}
+	if err := tx.CreateInBatches(changes, associationBatchSize).Error; err != nil {
+		return err
+	}
+
+	// Queue the matching lifecycle events in the same transaction
+	events := make([]types.Event, len(changes))
+	for i, change := range changes {
+		data, err := json.Marshal(map[string]any{"seq": change.Seq})
+		if err != nil {
+			return err
+		}
+		events[i] = types.Event{Type: "service." + changeType, ServiceID: change.ServiceID, Data: string(data)}
+	}
+	return tx.CreateInBatches(events, associationBatchSize).Error
+}
+
+// Changes returns up to limit changes with sequence numbers after since, oldest first
+func Changes(db *gorm.DB, since uint64, limit int) ([]types.Change, error) {
+	changes := []types.Change{}
+	err := db.Where("seq > ?", since).Order("seq").Limit(limit).Find(&changes).Error
+	return changes, err
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// countRow is one group of a GROUP BY count
+type countRow struct {
+	Key   string
+	Count int64
+}
+
+// CountServicesBy counts services grouped by a column such as status or state
+func CountServicesBy(db *gorm.DB, column string) (map[string]int64, error) {
+	var rows []countRow
+	if err := db.Model(&types.MCPService{}).Select(column + " AS key, COUNT(*) AS count").
+		Group(column).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return countMap(rows), nil
+}
+
+// CountEventsSince counts the events of each type created at or after since.
+// Events about API keys are only counted with keys.
+func CountEventsSince(db *gorm.DB, since time.Time, keys bool) (map[string]int64, error) {
+	var rows []countRow
+	query := db.Model(&types.Event{}).Select("type AS key, COUNT(*) AS count").Where("created_at >= ?", since.UTC())
+	if err := withoutKeyEvents(query, keys).Group("type").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return countMap(rows), nil
+}
+
+// RecentEvents returns the newest limit events, newest first. Events about
+// API keys are only included with keys.
+func RecentEvents(db *gorm.DB, limit int, keys bool) ([]types.Event, error) {
+	events := []types.Event{}
+	err := withoutKeyEvents(db, keys).Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func countMap(rows []countRow) map[string]int64 {
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Key] = row.Count
+	}
+	return counts
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"strings"
+
 	"github.com/arnavsurve/gateway-registry/pkg/types"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -9,15 +11,66 @@ import (
 var db *gorm.DB
 
 // InitDB initializes a database connection and runs migrations
-func InitDB() (*gorm.DB, error) {
-	dsn := "host=localhost user=postgres password=postgres dbname=gateway port=5432 sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func InitDB(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
 
-	if err = db.AutoMigrate(&types.MCPService{}, &types.Capability{}, &types.Category{}, &types.MetadataItem{}); err != nil {
+	if err := dedupeIdentities(db); err != nil {
+		return nil, err
+	}
+	migrator := db.Migrator()
+	backfill := migrator.HasTable(&types.MCPService{}) && !migrator.HasColumn(&types.MCPService{}, "sunset_notified_at")
+	if err = db.AutoMigrate(&types.MCPService{}, &types.Capability{}, &types.Category{}, &types.MetadataItem{}, &types.ServiceAlias{}, &types.ArchivedService{}, &types.AvailabilityBucket{}, &types.UsageDay{}, &types.APIUsageDay{}, &types.AuditEntry{}, &types.Review{}, &types.RegistryState{}, &types.CanonicalCategory{}, &types.ServiceRevision{}, &types.Event{}, &types.Change{}, &types.LeaderLease{}, &types.MetricSample{}, &types.SignatureNonce{}, &types.Session{}, &types.PendingLogin{},
+		&types.ServiceGroup{}, &types.ServiceGroupMember{}, &types.GroupMetadataItem{}, &types.Organization{}, &types.Team{}, &types.TeamMember{}, &types.ServiceTransfer{}, &types.APIKey{}); err != nil {
+		return nil, err
+	}
+	if backfill {
+		if err := backfillSunsetNotices(db); err != nil {
+			return nil, err
+		}
+	}
+	if err := trackChanges(db); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
+
+// dedupeIdentities renames services that share a namespace, name, URL and
+// origin, all but the oldest getting the start of their ID appended to their
+// name, so AutoMigrate can add the unique idx_service_identity index to
+// databases from before it. Renamed services keep their IDs, and the
+// duplicates report can help merge them by hand.
+func dedupeIdentities(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&types.MCPService{}) || migrator.HasIndex(&types.MCPService{}, "idx_service_identity") {
+		return nil
+	}
+	// Columns added since are the same default for every existing row
+	identity := []string{"name", "url"}
+	for _, column := range []string{"namespace", "origin"} {
+		if migrator.HasColumn(&types.MCPService{}, column) {
+			identity = append(identity, column)
+		}
+	}
+	return db.Exec(`UPDATE mcp_services SET name = mcp_services.name || '-' || LEFT(mcp_services.id, 8)
+		FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY ` + strings.Join(identity, ", ") + ` ORDER BY created_at, id) AS n
+			FROM mcp_services) ranked
+		WHERE mcp_services.id = ranked.id AND ranked.n > 1`).Error
+}
+
+// backfillSunsetNotices marks the services whose sunset was announced before
+// sunset_notified_at existed, which was recorded only by the event, so they
+// aren't announced again
+func backfillSunsetNotices(db *gorm.DB) error {
+	return db.Exec(`UPDATE mcp_services SET sunset_notified_at = announced.at
+		FROM (SELECT service_id, MIN(created_at) AS at FROM events WHERE type = ? GROUP BY service_id) announced
+		WHERE mcp_services.id = announced.service_id`, types.EventServiceSunset).Error
+}
+
+// OpenReplica connects to a read replica. Migrations aren't run since the
+// replica follows the primary's schema.
+func OpenReplica(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
+}
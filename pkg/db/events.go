@@ -0,0 +1,111 @@
+package db
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordEvent appends an event with JSON encoded data and returns it
+func RecordEvent(tx *gorm.DB, eventType, serviceID string, data any) (types.Event, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return types.Event{}, err
+	}
+
+	event := types.Event{Type: eventType, ServiceID: serviceID, Data: string(encoded)}
+	return event, tx.Create(&event).Error
+}
+
+// keyEvents matches the types of events about API keys, which only admins
+// may read
+const keyEvents = "api_key.%"
+
+// withoutKeyEvents leaves events about API keys out of a query unless keys
+// is set
+func withoutKeyEvents(query *gorm.DB, keys bool) *gorm.DB {
+	if keys {
+		return query
+	}
+	return query.Where("type NOT LIKE ?", keyEvents)
+}
+
+// ListEvents returns up to limit events with IDs after since, oldest first,
+// optionally only those for one service or of one type. Events about API
+// keys are only included with keys.
+func ListEvents(db *gorm.DB, since uint, serviceID, eventType string, limit int, keys bool) ([]types.Event, error) {
+	query := withoutKeyEvents(db.Where("id > ?", since), keys)
+	if serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+	if eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	events := []types.Event{}
+	err := query.Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// LatestEventID returns the ID of the newest event, 0 if there are none
+func LatestEventID(db *gorm.DB) (uint, error) {
+	var id uint
+	err := db.Model(&types.Event{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// PendingEvents returns up to limit undelivered events, oldest first,
+// leaving out those that have failed maxAttempts times
+func PendingEvents(db *gorm.DB, limit, maxAttempts int) ([]types.Event, error) {
+	var events []types.Event
+	err := db.Where("delivered_at IS NULL AND attempts < ?", maxAttempts).Order("id").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// MarkDelivered records that an event reached its receiver
+func MarkDelivered(db *gorm.DB, eventID uint, now time.Time) error {
+	return db.Model(&types.Event{}).Where("id = ?", eventID).Update("delivered_at", now).Error
+}
+
+// RecordAttempt counts a failed delivery of an event, holding it back until
+// next
+func RecordAttempt(db *gorm.DB, eventID uint, next time.Time) error {
+	return db.Model(&types.Event{}).Where("id = ?", eventID).
+		UpdateColumns(map[string]any{"attempts": gorm.Expr("attempts + 1"), "next_attempt_at": next}).Error
+}
+
+// EmitSunsets records a sunset event for every deprecated service whose sunset
+// date has passed and that hasn't been announced yet, returning the new events
+func EmitSunsets(db *gorm.DB, now time.Time) ([]types.Event, error) {
+	var services []types.MCPService
+	if err := db.Where("deprecated = ? AND sunset_at <= ? AND sunset_notified_at IS NULL", true, now).
+		Find(&services).Error; err != nil {
+		return nil, err
+	}
+
+	var events []types.Event
+	for _, service := range services {
+		var event types.Event
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// UpdateColumn so updated_at stays the time of the last real change
+			if err := tx.Model(&types.MCPService{}).Where("id = ?", service.ID).
+				UpdateColumn("sunset_notified_at", now.UTC()).Error; err != nil {
+				return err
+			}
+			var err error
+			event, err = RecordEvent(tx, types.EventServiceSunset, service.ID, map[string]any{
+				"name":                   service.Name,
+				"sunset_at":              service.SunsetAt,
+				"replacement_service_id": service.Replacement,
+			})
+			return err
+		})
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// FleetRow counts the services of one health class in one group
+type FleetRow struct {
+	Key   string
+	Class string
+	Count int64
+}
+
+// fleetClass classifies a published service as stale, degraded or healthy
+const fleetClass = "CASE WHEN mcp_services.last_seen < @stale THEN '" + types.FleetStale +
+	"' WHEN mcp_services.status = '" + types.StatusDegraded + "' THEN '" + types.FleetDegraded +
+	"' ELSE '" + types.FleetHealthy + "' END"
+
+// FleetByNamespace counts published services by namespace and health class,
+// those last seen before staleBefore being stale
+func FleetByNamespace(db *gorm.DB, staleBefore time.Time) ([]FleetRow, error) {
+	var rows []FleetRow
+	err := db.Raw("SELECT namespace AS key, "+fleetClass+" AS class, COUNT(*) AS count FROM mcp_services "+
+		"WHERE state = @state GROUP BY 1, 2",
+		map[string]any{"stale": staleBefore, "state": types.StatePublished}).Scan(&rows).Error
+	return rows, err
+}
+
+// FleetByCategory counts published services by category and health class
+func FleetByCategory(db *gorm.DB, staleBefore time.Time) ([]FleetRow, error) {
+	var rows []FleetRow
+	err := db.Raw("SELECT categories.name AS key, "+fleetClass+" AS class, COUNT(DISTINCT mcp_services.id) AS count "+
+		"FROM categories JOIN mcp_services ON mcp_services.id = categories.service_id "+
+		"WHERE mcp_services.state = @state GROUP BY 1, 2",
+		map[string]any{"stale": staleBefore, "state": types.StatePublished}).Scan(&rows).Error
+	return rows, err
+}
+
+// ArchivedByNamespace counts archived services by namespace
+func ArchivedByNamespace(db *gorm.DB) (map[string]int64, error) {
+	var rows []countRow
+	if err := db.Model(&types.ArchivedService{}).Select("namespace AS key, COUNT(*) AS count").
+		Group("namespace").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return countMap(rows), nil
+}
+
+// ArchivedByCategory counts archived services by the categories they had
+// when they were pruned
+func ArchivedByCategory(db *gorm.DB) (map[string]int64, error) {
+	var rows []countRow
+	if err := db.Raw("SELECT category AS key, COUNT(*) AS count FROM archived_services, " +
+		"jsonb_array_elements_text(COALESCE(data->'categories', '[]'::jsonb)) AS category GROUP BY 1").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return countMap(rows), nil
+}
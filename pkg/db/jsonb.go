@@ -0,0 +1,129 @@
+package db
+
+import (
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// jsonbSchema is set once UseJSONB has switched reads to the associations column
+var jsonbSchema bool
+
+// UseJSONB switches service reads from the capability, category, metadata and
+// alias tables to the JSONB associations column on mcp_services, so loading
+// services takes one query instead of five. Services written before the
+// column existed are backfilled first.
+//
+// The child tables are still written alongside the column and still back the
+// category, capability and alias lookups, so turning the option off again
+// needs no migration.
+func UseJSONB(db *gorm.DB) error {
+	var services []types.MCPService
+	err := Preload(db).Where("associations IS NULL").
+		FindInBatches(&services, associationBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, service := range services {
+				if err := writeAssociations(db, service); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+	return ReadJSONB(db)
+}
+
+// ReadJSONB switches reads on a connection to the associations column without
+// backfilling it, for read replicas of a primary that UseJSONB migrated
+func ReadJSONB(db *gorm.DB) error {
+	if err := db.Callback().Query().After("gorm:after_query").Register("registry:expand_associations", expandAssociations); err != nil {
+		return err
+	}
+	jsonbSchema = true
+	return nil
+}
+
+// writeAssociations stores the JSONB copy of a service's associations
+func writeAssociations(tx *gorm.DB, service types.MCPService) error {
+	doc := types.ServiceAssociations{
+		Capabilities: make(map[string]bool, len(service.Capabilities)),
+		Categories:   make([]string, 0, len(service.Categories)),
+		Metadata:     make(map[string]string, len(service.Metadata)),
+		Aliases:      make([]string, 0, len(service.Aliases)),
+	}
+	for _, capability := range service.Capabilities {
+		doc.Capabilities[capability.Name] = capability.Enabled
+	}
+	for _, category := range service.Categories {
+		doc.Categories = append(doc.Categories, category.Name)
+	}
+	for _, item := range service.Metadata {
+		doc.Metadata[item.Key] = item.Value
+	}
+	for _, alias := range service.Aliases {
+		doc.Aliases = append(doc.Aliases, alias.Name)
+	}
+
+	// UpdateColumn so the copy doesn't count as a change to the service itself
+	return tx.Model(&types.MCPService{}).Where("id = ?", service.ID).UpdateColumn("associations", doc).Error
+}
+
+// expandAssociations fills the association slices of loaded services from
+// their JSONB copy
+func expandAssociations(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.Schema.Table != "mcp_services" {
+		return
+	}
+
+	value := reflect.Indirect(tx.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			expandService(value.Index(i))
+		}
+	case reflect.Struct:
+		expandService(value)
+	}
+}
+
+func expandService(value reflect.Value) {
+	value = reflect.Indirect(value)
+	if !value.CanAddr() {
+		return
+	}
+	service, ok := value.Addr().Interface().(*types.MCPService)
+	if !ok || service.Associations == nil {
+		return
+	}
+	doc := service.Associations
+
+	service.Capabilities = []types.Capability{}
+	for _, name := range sortedKeys(doc.Capabilities) {
+		service.Capabilities = append(service.Capabilities, types.Capability{ServiceID: service.ID, Name: name, Enabled: doc.Capabilities[name]})
+	}
+	service.Categories = []types.Category{}
+	for _, name := range doc.Categories {
+		service.Categories = append(service.Categories, types.Category{ServiceID: service.ID, Name: name})
+	}
+	service.Metadata = []types.MetadataItem{}
+	for _, key := range sortedKeys(doc.Metadata) {
+		service.Metadata = append(service.Metadata, types.MetadataItem{ServiceID: service.ID, Key: key, Value: doc.Metadata[key]})
+	}
+	service.Aliases = []types.ServiceAlias{}
+	for _, name := range doc.Aliases {
+		service.Aliases = append(service.Aliases, types.ServiceAlias{ServiceID: service.ID, Name: name})
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// keyTouchInterval is how stale an API key's last_used_at may get, so busy
+// keys don't cost a write on every request
+const keyTouchInterval = time.Minute
+
+// ErrKeyRotated is returned when rotating a key that was already replaced
+var ErrKeyRotated = errors.New("API key already rotated")
+
+// FindAPIKey returns the unexpired, unrevoked API key whose token hashes to
+// tokenHash, recording that it was used
+func FindAPIKey(db *gorm.DB, tokenHash string, now time.Time) (types.APIKey, error) {
+	var key types.APIKey
+	err := db.Where("token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", tokenHash, now.UTC()).
+		First(&key).Error
+	if err != nil {
+		return key, err
+	}
+
+	// A failed touch shouldn't lock the caller out, so its error is dropped
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > keyTouchInterval {
+		db.Model(&types.APIKey{}).Where("id = ?", key.ID).Update("last_used_at", now.UTC())
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+// HasAPIKeys reports whether any unexpired, unrevoked API key is issued
+func HasAPIKeys(db *gorm.DB, now time.Time) (bool, error) {
+	var ids []string
+	err := db.Model(&types.APIKey{}).Where("revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now.UTC()).
+		Limit(1).Pluck("id", &ids).Error
+	return len(ids) > 0, err
+}
+
+// RevokeAPIKey stops a key from working, reporting false if it was already
+// revoked
+func RevokeAPIKey(db *gorm.DB, id string, now time.Time) (bool, error) {
+	result := db.Model(&types.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", now.UTC())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RotateAPIKey stores replacement and has the old key expire at graceUntil,
+// or sooner if it was going to anyway. Only one rotation of a key can win;
+// the others get ErrKeyRotated. The caller owns the transaction.
+func RotateAPIKey(tx *gorm.DB, old *types.APIKey, replacement *types.APIKey, graceUntil time.Time) error {
+	if err := tx.Create(replacement).Error; err != nil {
+		return err
+	}
+	expiresAt := old.ExpiresAt
+	if expiresAt == nil || graceUntil.Before(*expiresAt) {
+		expiresAt = &graceUntil
+	}
+	result := tx.Model(&types.APIKey{}).Where("id = ? AND replaced_by = ''", old.ID).
+		Updates(map[string]any{"expires_at": expiresAt, "replaced_by": replacement.ID})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrKeyRotated
+	}
+	old.ExpiresAt = expiresAt
+	old.ReplacedBy = replacement.ID
+	_, err := RecordEvent(tx, types.EventAPIKeyRotated, "", map[string]any{
+		"id":          old.ID,
+		"name":        old.Name,
+		"replaced_by": replacement.ID,
+		"expires_at":  old.ExpiresAt,
+	})
+	return err
+}
+
+// EmitKeyExpiries records an expiring event for every active key that
+// expires within warning and hasn't been announced yet, returning the new
+// events. Rotated keys are left out since they already have a replacement.
+func EmitKeyExpiries(db *gorm.DB, now time.Time, warning time.Duration) ([]types.Event, error) {
+	var keys []types.APIKey
+	if err := db.Where("revoked_at IS NULL AND replaced_by = '' AND expiry_notified_at IS NULL AND expires_at > ? AND expires_at <= ?",
+		now.UTC(), now.Add(warning).UTC()).Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	var events []types.Event
+	for _, key := range keys {
+		var event types.Event
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&key).Update("expiry_notified_at", now.UTC()).Error; err != nil {
+				return err
+			}
+			var err error
+			event, err = RecordEvent(tx, types.EventAPIKeyExpiring, "", map[string]any{
+				"id":         key.ID,
+				"name":       key.Name,
+				"prefix":     key.Prefix,
+				"expires_at": key.ExpiresAt,
+			})
+			return err
+		})
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
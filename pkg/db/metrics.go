@@ -0,0 +1,29 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordMetrics stores the metrics a service reported with a heartbeat at at
+func RecordMetrics(db *gorm.DB, serviceID string, metrics types.HeartbeatMetrics, at time.Time) error {
+	sample := types.MetricSample{ServiceID: serviceID, RecordedAt: at.UTC(), HeartbeatMetrics: metrics}
+	return db.Create(&sample).Error
+}
+
+// MetricsSince returns a service's samples recorded at or after from, oldest first
+func MetricsSince(db *gorm.DB, serviceID string, from time.Time) ([]types.MetricSample, error) {
+	var samples []types.MetricSample
+	err := db.Where("service_id = ? AND recorded_at >= ?", serviceID, from.UTC()).
+		Order("recorded_at, id").Find(&samples).Error
+	return samples, err
+}
+
+// PurgeMetrics deletes samples recorded before cutoff, keeping the window rolling
+func PurgeMetrics(db *gorm.DB, cutoff time.Time) (int64, error) {
+	result := db.Where("recorded_at < ?", cutoff.UTC()).Delete(&types.MetricSample{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ClaimNonce records a signed request's nonce, failing with
+// gorm.ErrDuplicatedKey if it was already used
+func ClaimNonce(db *gorm.DB, nonce string, at time.Time) error {
+	return db.Create(&types.SignatureNonce{Nonce: nonce, SeenAt: at.UTC()}).Error
+}
+
+// PurgeNonces deletes nonces seen before cutoff, whose requests would be
+// refused as too old anyway
+func PurgeNonces(db *gorm.DB, cutoff time.Time) (int64, error) {
+	result := db.Where("seen_at < ?", cutoff.UTC()).Delete(&types.SignatureNonce{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// cancelQueryKey stores a statement's timeout cancel func between callbacks
+const cancelQueryKey = "registry:cancel_query"
+
+// Configure sizes a connection's pool and bounds how long its queries may
+// run. Zero values keep database/sql's defaults: unlimited open connections,
+// two idle ones, no lifetime limit and no query timeout.
+func Configure(db *gorm.DB, maxOpen, maxIdle int, maxLifetime, queryTimeout time.Duration) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if maxOpen > 0 {
+		sqlDB.SetMaxOpenConns(maxOpen)
+	}
+	if maxIdle > 0 {
+		sqlDB.SetMaxIdleConns(maxIdle)
+	}
+	if maxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(maxLifetime)
+	}
+	return setQueryTimeout(db, queryTimeout)
+}
+
+// setQueryTimeout bounds how long each statement may run, on top of any
+// deadline already on its context. Row scans are left alone since their
+// results are read after the callbacks return. A timeout of 0 is a no-op.
+func setQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+
+	start := func(tx *gorm.DB) {
+		ctx, cancel := context.WithTimeout(tx.Statement.Context, timeout)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(cancelQueryKey, cancel)
+	}
+	finish := func(tx *gorm.DB) {
+		if cancel, ok := tx.InstanceGet(cancelQueryKey); ok {
+			cancel.(context.CancelFunc)()
+		}
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:begin_transaction").Register("registry:timeout_create", start); err != nil {
+		return err
+	}
+	if err := callbacks.Create().After("gorm:commit_or_rollback_transaction").Register("registry:timeout_create_done", finish); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:begin_transaction").Register("registry:timeout_update", start); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:commit_or_rollback_transaction").Register("registry:timeout_update_done", finish); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:begin_transaction").Register("registry:timeout_delete", start); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().After("gorm:commit_or_rollback_transaction").Register("registry:timeout_delete_done", finish); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("registry:timeout_query", start); err != nil {
+		return err
+	}
+	if err := callbacks.Query().After("gorm:after_query").Register("registry:timeout_query_done", finish); err != nil {
+		return err
+	}
+	if err := callbacks.Raw().Before("gorm:raw").Register("registry:timeout_raw", start); err != nil {
+		return err
+	}
+	return callbacks.Raw().After("gorm:raw").Register("registry:timeout_raw_done", finish)
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// pruneBatchSize is how many services each prune transaction removes
+const pruneBatchSize = 500
+
+// PruneInactive deletes services that haven't been seen since cutoff and
+// returns them. When archive is set, local services are copied into the
+// archive first so they can be resurrected if they re-register.
+//
+// Services are removed in chunks, each in its own transaction with one
+// DELETE per table, so a large backlog doesn't hold locks for long.
+func PruneInactive(db *gorm.DB, cutoff time.Time, archive bool) ([]types.MCPService, error) {
+	var pruned []types.MCPService
+	for {
+		var batch []types.MCPService
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Lock the chunk so a heartbeat arriving now waits and then finds
+			// nothing to renew, rather than being lost. Services locked by
+			// another pruner are left to it.
+			err := Preload(tx).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+				Where("last_seen < ?", cutoff).Order("id").Limit(pruneBatchSize).Find(&batch).Error
+			if err != nil || len(batch) == 0 {
+				return err
+			}
+
+			ids := make([]string, len(batch))
+			var archived []types.ArchivedService
+			for i, service := range batch {
+				ids[i] = service.ID
+				// Federated copies belong to their origin, there's nothing to resurrect locally
+				if archive && service.Origin == "" {
+					entry, err := archiveEntry(service)
+					if err != nil {
+						return err
+					}
+					archived = append(archived, entry)
+				}
+			}
+			if len(archived) > 0 {
+				if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&archived).Error; err != nil {
+					return err
+				}
+			}
+
+			for _, model := range serviceAssociations {
+				if err := tx.Where("service_id IN ?", ids).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&types.MCPService{}).Error; err != nil {
+				return err
+			}
+			return RecordChanges(tx, types.ChangeDeleted, ids)
+		})
+		if err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, batch...)
+		if len(batch) < pruneBatchSize {
+			return pruned, nil
+		}
+	}
+}
+
+// PurgeArchive permanently deletes services archived before cutoff
+func PurgeArchive(db *gorm.DB, cutoff time.Time) (int64, error) {
+	result := db.Where("archived_at < ?", cutoff).Delete(&types.ArchivedService{})
+	return result.RowsAffected, result.Error
+}
+
+// ListArchived returns every archived service, most recently archived first
+func ListArchived(db *gorm.DB) ([]types.ArchivedService, error) {
+	var archived []types.ArchivedService
+	err := db.Order("archived_at DESC, id").Find(&archived).Error
+	return archived, err
+}
+
+// FindArchived returns the most recently archived service with the given
+// identity that was archived after since, or nil if there is none
+func FindArchived(tx *gorm.DB, namespace, name, url string, since time.Time) (*types.ArchivedService, error) {
+	var archived types.ArchivedService
+	err := tx.Where("namespace = ? AND name = ? AND url = ? AND archived_at >= ?", namespace, name, url, since).
+		Order("archived_at DESC").First(&archived).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &archived, nil
+}
+
+// RestoreArchived registers a service under its archived ID and creation
+// time, keeping the archived metadata unless the request overrides a key.
+// The archive entry is removed. The caller owns the transaction.
+func RestoreArchived(tx *gorm.DB, archived types.ArchivedService, request types.ServiceRegistrationRequest, now time.Time) error {
+	var previous types.ServiceResponse
+	if err := json.Unmarshal([]byte(archived.Data), &previous); err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string, len(previous.Metadata)+len(request.Metadata))
+	for key, value := range previous.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range request.Metadata {
+		metadata[key] = value
+	}
+	request.Metadata = metadata
+	if request.ExternalID == "" {
+		request.ExternalID = previous.ExternalID
+	}
+	// A team's private service mustn't come back public
+	if request.TeamID == "" {
+		request.TeamID = previous.TeamID
+	}
+	if request.Visibility == "" {
+		request.Visibility = previous.Visibility
+	}
+
+	if err := CreateService(tx, archived.ID, request, now); err != nil {
+		return err
+	}
+	if err := tx.Model(&types.MCPService{}).Where("id = ?", archived.ID).
+		UpdateColumn("created_at", archived.CreatedAt).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&archived).Error
+}
+
+// archiveEntry builds the archive row for a service, keeping it as a ServiceResponse
+func archiveEntry(service types.MCPService) (types.ArchivedService, error) {
+	data, err := json.Marshal(types.ServiceModelToResponse(service))
+	if err != nil {
+		return types.ArchivedService{}, err
+	}
+
+	return types.ArchivedService{
+		ID:         service.ID,
+		Namespace:  service.Namespace,
+		Name:       service.Name,
+		URL:        service.URL,
+		CreatedAt:  service.CreatedAt,
+		ArchivedAt: time.Now(),
+		Data:       string(data),
+	}, nil
+}
+
+// purgedModels are the rows kept about a service beyond its registration,
+// which purging removes along with it
+var purgedModels = []any{&types.AvailabilityBucket{}, &types.UsageDay{}, &types.MetricSample{}, &types.Review{},
+	&types.ServiceGroupMember{}, &types.ServiceTransfer{}, &types.Change{}}
+
+// PurgeService irreversibly removes everything the registry keeps about a
+// service: the service itself, its archives, revisions, audit entries and
+// events, and the history and usage recorded for it. Followers of the change
+// feed are still told it was deleted. The caller owns the transaction.
+func PurgeService(tx *gorm.DB, serviceID string) (types.PurgeResult, error) {
+	result := types.PurgeResult{ServiceID: serviceID}
+	deleted := tx.Where("id = ?", serviceID).Delete(&types.MCPService{})
+	if deleted.Error != nil {
+		return result, deleted.Error
+	}
+	result.Service = deleted.RowsAffected > 0
+	if err := DeleteAssociations(tx, serviceID); err != nil {
+		return result, err
+	}
+
+	counts := []struct {
+		model any
+		where string
+		count *int64
+	}{
+		{&types.ArchivedService{}, "id = ?", &result.Archives},
+		{&types.ServiceRevision{}, "service_id = ?", &result.Revisions},
+		{&types.AuditEntry{}, "service_id = ?", &result.Audit},
+		{&types.Event{}, "service_id = ?", &result.Events},
+	}
+	for _, c := range counts {
+		deleted := tx.Where(c.where, serviceID).Delete(c.model)
+		if deleted.Error != nil {
+			return result, deleted.Error
+		}
+		*c.count = deleted.RowsAffected
+	}
+	for _, model := range purgedModels {
+		if err := tx.Where("service_id = ?", serviceID).Delete(model).Error; err != nil {
+			return result, err
+		}
+	}
+
+	if !result.Service {
+		return result, nil
+	}
+	return result, RecordChange(tx, types.ChangeDeleted, serviceID)
+}
+
+// DeleteService removes a service and its associations. The caller owns the transaction.
+func DeleteService(tx *gorm.DB, serviceID string) error {
+	if err := DeleteAssociations(tx, serviceID); err != nil {
+		return err
+	}
+	if err := tx.Where("id = ?", serviceID).Delete(&types.MCPService{}).Error; err != nil {
+		return err
+	}
+	return RecordChange(tx, types.ChangeDeleted, serviceID)
+}
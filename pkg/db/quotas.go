@@ -0,0 +1,47 @@
+package db
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// NamespaceUsage is what a namespace's services count against its quotas
+type NamespaceUsage struct {
+	Namespace     string
+	Services      int64
+	MetadataBytes int64
+}
+
+// LockNamespace serializes quota checks on a namespace until the transaction
+// ends, so concurrent registrations can't both squeeze under a quota
+func LockNamespace(tx *gorm.DB, namespace string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "quota:"+namespace).Error
+}
+
+// UsageOf returns a namespace's usage, leaving out the service excludeID so
+// an update can be checked as if it replaced it
+func UsageOf(db *gorm.DB, namespace, excludeID string) (NamespaceUsage, error) {
+	usage := NamespaceUsage{Namespace: namespace}
+	if err := db.Model(&types.MCPService{}).Where("namespace = ? AND id <> ?", namespace, excludeID).
+		Count(&usage.Services).Error; err != nil {
+		return usage, err
+	}
+	err := db.Model(&types.MetadataItem{}).
+		Select("COALESCE(SUM(LENGTH(metadata_items.key) + LENGTH(metadata_items.value)), 0)").
+		Joins("JOIN mcp_services ON mcp_services.id = metadata_items.service_id").
+		Where("mcp_services.namespace = ? AND mcp_services.id <> ?", namespace, excludeID).
+		Scan(&usage.MetadataBytes).Error
+	return usage, err
+}
+
+// NamespaceUsages returns the usage of every namespace with services, ordered
+// by namespace
+func NamespaceUsages(db *gorm.DB) ([]NamespaceUsage, error) {
+	var usages []NamespaceUsage
+	err := db.Raw("SELECT mcp_services.namespace, COUNT(DISTINCT mcp_services.id) AS services, " +
+		"COALESCE(SUM(LENGTH(metadata_items.key) + LENGTH(metadata_items.value)), 0) AS metadata_bytes " +
+		"FROM mcp_services LEFT JOIN metadata_items ON metadata_items.service_id = mcp_services.id " +
+		"GROUP BY mcp_services.namespace ORDER BY mcp_services.namespace").Scan(&usages).Error
+	return usages, err
+}
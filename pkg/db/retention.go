@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// retentionBatchSize bounds the rows each DELETE of old history removes, so
+// a large backlog doesn't hold locks for long
+const retentionBatchSize = 5000
+
+// Retention says how long each kind of history is kept, 0 keeping it forever
+type Retention struct {
+	Audit        time.Duration
+	Archive      time.Duration
+	Availability time.Duration
+	Changes      time.Duration
+	Events       time.Duration
+
+	// Failed deliveries after which an event is given up on. Events still
+	// being retried are kept however old they are.
+	EventAttempts int
+}
+
+// RetentionResult counts the rows a janitor run deleted
+type RetentionResult struct {
+	Audit        int64
+	Archive      int64
+	Availability int64
+	Changes      int64
+	Events       int64
+}
+
+// EnforceRetention deletes history older than its retention window
+func EnforceRetention(db *gorm.DB, retention Retention, now time.Time) (RetentionResult, error) {
+	var result RetentionResult
+	purges := []struct {
+		window time.Duration
+		table  string
+		column string
+		done   string // Further condition a row must meet to be deleted, if any
+		count  *int64
+	}{
+		{retention.Audit, "audit_entries", "created_at", "", &result.Audit},
+		{retention.Archive, "archived_services", "archived_at", "", &result.Archive},
+		{retention.Availability, types.AvailabilityBucket{}.TableName(), "bucket_start", "", &result.Availability},
+		{retention.Changes, "changes", "created_at", "", &result.Changes},
+		{retention.Events, "events", "created_at",
+			fmt.Sprintf("(delivered_at IS NOT NULL OR attempts >= %d)", retention.EventAttempts), &result.Events},
+	}
+	for _, purge := range purges {
+		if purge.window <= 0 {
+			continue
+		}
+		n, err := purgeBefore(db, purge.table, purge.column, purge.done, now.Add(-purge.window))
+		*purge.count = n
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// purgeBefore deletes the rows of table whose column is before cutoff and
+// that meet done, if given, in batches
+func purgeBefore(db *gorm.DB, table, column, done string, cutoff time.Time) (int64, error) {
+	where := column + " < ?"
+	if done != "" {
+		where += " AND " + done
+	}
+	var total int64
+	for {
+		result := db.Exec("DELETE FROM "+table+" WHERE ctid IN (SELECT ctid FROM "+table+" WHERE "+where+" LIMIT ?)",
+			cutoff.UTC(), retentionBatchSize)
+		total += result.RowsAffected
+		if result.Error != nil || result.RowsAffected < retentionBatchSize {
+			return total, result.Error
+		}
+	}
+}
+
+// OldestChange returns the sequence number of the oldest change still kept,
+// or 0 if there are none
+func OldestChange(db *gorm.DB) (uint64, error) {
+	var seq uint64
+	err := db.Model(&types.Change{}).Select("COALESCE(MIN(seq), 0)").Scan(&seq).Error
+	return seq, err
+}
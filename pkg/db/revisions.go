@@ -0,0 +1,67 @@
+package db
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordRevision stores the current state of a service as its next revision.
+// The caller owns the transaction.
+func RecordRevision(tx *gorm.DB, serviceID string) error {
+	var service types.MCPService
+	if err := Preload(tx).First(&service, "id = ?", serviceID).Error; err != nil {
+		return err
+	}
+	data, err := json.Marshal(types.ServiceModelToResponse(service))
+	if err != nil {
+		return err
+	}
+
+	var latest int
+	if err := tx.Model(&types.ServiceRevision{}).Where("service_id = ?", serviceID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(&types.ServiceRevision{
+		ServiceID: serviceID,
+		Revision:  latest + 1,
+		Data:      string(data),
+	}).Error
+}
+
+// Revisions returns a service's revisions, newest first
+func Revisions(db *gorm.DB, serviceID string) ([]types.RevisionResponse, error) {
+	var revisions []types.ServiceRevision
+	if err := db.Where("service_id = ?", serviceID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	responses := make([]types.RevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		response, err := decodeRevision(revision)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// FindRevision returns one revision of a service
+func FindRevision(db *gorm.DB, serviceID string, revision int) (types.RevisionResponse, error) {
+	var stored types.ServiceRevision
+	if err := db.First(&stored, "service_id = ? AND revision = ?", serviceID, revision).Error; err != nil {
+		return types.RevisionResponse{}, err
+	}
+	return decodeRevision(stored)
+}
+
+func decodeRevision(revision types.ServiceRevision) (types.RevisionResponse, error) {
+	response := types.RevisionResponse{Revision: revision.Revision, CreatedAt: revision.CreatedAt}
+	err := json.Unmarshal([]byte(revision.Data), &response.Service)
+	return response, err
+}
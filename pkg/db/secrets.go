@@ -0,0 +1,99 @@
+package db
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/secrets"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// sensitiveKeys and sealBox are set once SealMetadata has turned on
+// encryption of sensitive metadata
+var (
+	sensitiveKeys []string
+	sealBox       *secrets.Box
+)
+
+// SealMetadata has the values of the given metadata keys encrypted with box
+// whenever service metadata is written, whichever way the service arrives.
+// Values written before stay as they were until the service is next saved.
+func SealMetadata(box *secrets.Box, keys []string) {
+	sealBox = box
+	sensitiveKeys = keys
+}
+
+// Sensitive reports whether a metadata key's values are sealed
+func Sensitive(key string) bool {
+	return slices.Contains(sensitiveKeys, key)
+}
+
+// sealItems returns items with the values of sensitive keys sealed, leaving
+// the caller's slice untouched
+func sealItems(items []types.MetadataItem) ([]types.MetadataItem, error) {
+	if sealBox == nil {
+		return items, nil
+	}
+	sealed := slices.Clone(items)
+	for i, item := range sealed {
+		if !Sensitive(item.Key) || item.Value == types.RedactedValue {
+			continue
+		}
+		value, err := sealBox.Seal(item.Value)
+		if err != nil {
+			return nil, err
+		}
+		sealed[i].Value = value
+	}
+	return sealed, nil
+}
+
+// openItems returns items with sealed values opened where the key allows it,
+// for comparing what's stored with what's about to be, leaving the caller's
+// slice untouched
+func openItems(items []types.MetadataItem) []types.MetadataItem {
+	if sealBox == nil {
+		return items
+	}
+	opened := slices.Clone(items)
+	for i, item := range opened {
+		if !secrets.Sealed(item.Value) {
+			continue
+		}
+		if value, err := sealBox.Open(item.Value); err == nil {
+			opened[i].Value = value
+		}
+	}
+	return opened
+}
+
+// keepRedacted replaces metadata values sent back redacted, as a client
+// that read a service before changing it would, with the stored values.
+// Redacted values with nothing stored behind them are dropped.
+func keepRedacted(tx *gorm.DB, serviceID string, metadata map[string]string) (map[string]string, error) {
+	var redacted []string
+	for key, value := range metadata {
+		if value == types.RedactedValue {
+			redacted = append(redacted, key)
+		}
+	}
+	if len(redacted) == 0 {
+		return metadata, nil
+	}
+
+	var stored []types.MetadataItem
+	if err := tx.Where("service_id = ? AND key IN ?", serviceID, redacted).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	kept := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		if value != types.RedactedValue {
+			kept[key] = value
+		}
+	}
+	for _, item := range stored {
+		kept[item.Key] = item.Value
+	}
+	return kept, nil
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// SeedFixtures is the layout of a seed file, using the same field names as
+// the registration API
+type SeedFixtures struct {
+	Services []types.ServiceRegistrationRequest `json:"services"`
+}
+
+// Seed registers the services in a YAML fixtures file, but only when the
+// registry is empty so restarts don't duplicate them. It returns the number of
+// services created.
+func Seed(db *gorm.DB, path string) (int, error) {
+	var count int64
+	if err := db.Model(&types.MCPService{}).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Decode through JSON so fixtures use the API's field names
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if data, err = json.Marshal(raw); err != nil {
+		return 0, err
+	}
+	var fixtures SeedFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return 0, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i, request := range fixtures.Services {
+			if request.Name == "" || request.URL == "" {
+				return fmt.Errorf("service %d is missing a name or url", i)
+			}
+			if request.Namespace == "" {
+				request.Namespace = types.DefaultNamespace
+			}
+			if request.Weight == 0 {
+				request.Weight = types.DefaultWeight
+			}
+			if err := CreateService(tx, uuid.New().String(), request, now); err != nil {
+				return fmt.Errorf("seeding %s: %w", request.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(fixtures.Services), nil
+}
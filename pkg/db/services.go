@@ -0,0 +1,407 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Preload loads every association needed to build a ServiceResponse. With
+// the JSONB schema they come from the service row itself instead.
+func Preload(tx *gorm.DB) *gorm.DB {
+	if jsonbSchema {
+		return tx
+	}
+	return tx.Preload("Capabilities").Preload("Categories").Preload("Metadata").Preload("Aliases")
+}
+
+// serviceAssociations are the models of rows owned by a service, which have
+// to be deleted before the service itself
+var serviceAssociations = []any{&types.Capability{}, &types.Category{}, &types.MetadataItem{}, &types.ServiceAlias{}}
+
+// DeleteAssociations removes every row owned by a service
+func DeleteAssociations(tx *gorm.DB, serviceID string) error {
+	for _, model := range serviceAssociations {
+		if err := tx.Where("service_id = ?", serviceID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateService inserts a new service along with its capabilities,
+// categories and metadata. The caller owns the transaction.
+func CreateService(tx *gorm.DB, serviceID string, request types.ServiceRegistrationRequest, now time.Time) error {
+	service := types.MCPService{
+		ID:           serviceID,
+		Namespace:    request.Namespace,
+		Name:         request.Name,
+		Description:  request.Description,
+		URL:          request.URL,
+		LastSeen:     now,
+		ApiDocs:      request.ApiDocs,
+		Weight:       request.Weight,
+		Priority:     request.Priority,
+		Region:       request.Region,
+		Status:       types.StatusHealthy,
+		State:        request.State,
+		Deprecated:   request.Deprecated,
+		SunsetAt:     request.SunsetAt,
+		Replacement:  request.Replacement,
+		ProxyTimeout: request.ProxyTimeout,
+		ExternalID:   request.ExternalID,
+		Tools:        request.Tools,
+		TeamID:       request.TeamID,
+		Visibility:   request.Visibility,
+		Extensions:   request.Extensions,
+
+		ProtocolVersions: request.ProtocolVersions,
+	}
+	if service.State == "" {
+		service.State = types.StatePublished
+	}
+	if service.Visibility == "" {
+		service.Visibility = types.VisibilityPublic
+	}
+
+	if err := tx.Create(&service).Error; err != nil {
+		return err
+	}
+	if err := createAssociations(tx, serviceID, request); err != nil {
+		return err
+	}
+	if err := RecordChange(tx, types.ChangeCreated, serviceID); err != nil {
+		return err
+	}
+	return RecordRevision(tx, serviceID)
+}
+
+// UpdateService overwrites a service's fields and associations with a
+// registration request and refreshes its last_seen. Metadata sent back as
+// types.RedactedValue keeps its stored value. The caller owns the
+// transaction.
+func UpdateService(tx *gorm.DB, service *types.MCPService, request types.ServiceRegistrationRequest, now time.Time) error {
+	service.Namespace = request.Namespace
+	service.Name = request.Name
+	service.Description = request.Description
+	service.URL = request.URL
+	service.LastSeen = now
+	service.ApiDocs = request.ApiDocs
+	service.Weight = request.Weight
+	service.Priority = request.Priority
+	service.Region = request.Region
+	service.ProxyTimeout = request.ProxyTimeout
+	service.Deprecated = request.Deprecated
+	service.SunsetAt = request.SunsetAt
+	service.Replacement = request.Replacement
+	service.Tools = request.Tools
+	service.ProtocolVersions = request.ProtocolVersions
+	if request.State != "" {
+		service.State = request.State
+	}
+	// Clients unaware of external IDs mustn't drop them by leaving them out
+	if request.ExternalID != "" {
+		service.ExternalID = request.ExternalID
+	}
+	// Nor extensions; sending an empty object clears them
+	if request.Extensions != nil {
+		service.Extensions = request.Extensions
+	}
+	// Likewise for ownership, so an older client can't expose a team's service
+	if request.TeamID != "" {
+		service.TeamID = request.TeamID
+	}
+	if request.Visibility != "" {
+		service.Visibility = request.Visibility
+	}
+
+	if err := tx.Omit(clause.Associations).Save(service).Error; err != nil {
+		return err
+	}
+
+	var err error
+	if request.Metadata, err = keepRedacted(tx, service.ID, request.Metadata); err != nil {
+		return err
+	}
+	if err := DeleteAssociations(tx, service.ID); err != nil {
+		return err
+	}
+	if err := createAssociations(tx, service.ID, request); err != nil {
+		return err
+	}
+	if err := RecordChange(tx, types.ChangeUpdated, service.ID); err != nil {
+		return err
+	}
+	return RecordRevision(tx, service.ID)
+}
+
+// associationBatchSize bounds the rows in each association INSERT, keeping
+// statements well under Postgres's limit of 65535 parameters
+const associationBatchSize = 500
+
+// createAssociations inserts the capabilities, categories and metadata of a registration request
+func createAssociations(tx *gorm.DB, serviceID string, request types.ServiceRegistrationRequest) error {
+	service := types.MCPService{ID: serviceID}
+	for name, enabled := range request.Capabilities {
+		service.Capabilities = append(service.Capabilities, types.Capability{ServiceID: serviceID, Name: name, Enabled: enabled})
+	}
+	for _, name := range request.Categories {
+		service.Categories = append(service.Categories, types.Category{ServiceID: serviceID, Name: name})
+	}
+	for key, value := range request.Metadata {
+		service.Metadata = append(service.Metadata, types.MetadataItem{ServiceID: serviceID, Key: key, Value: value})
+	}
+	for _, name := range request.Aliases {
+		service.Aliases = append(service.Aliases, types.ServiceAlias{ServiceID: serviceID, Name: name})
+	}
+	return insertAssociations(tx, service)
+}
+
+// insertAssociations writes a service's associations with one batched
+// INSERT per table rather than one per row, and refreshes their JSONB copy
+func insertAssociations(tx *gorm.DB, service types.MCPService) error {
+	var err error
+	if service.Metadata, err = sealItems(service.Metadata); err != nil {
+		return err
+	}
+	if err := writeAssociations(tx, service); err != nil {
+		return err
+	}
+	if err := insertBatch(tx, service.Capabilities); err != nil {
+		return err
+	}
+	if err := insertBatch(tx, service.Categories); err != nil {
+		return err
+	}
+	if err := insertBatch(tx, service.Metadata); err != nil {
+		return err
+	}
+	return insertBatch(tx, service.Aliases)
+}
+
+func insertBatch[T any](tx *gorm.DB, rows []T) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.CreateInBatches(rows, associationBatchSize).Error
+}
+
+// FindByURL returns the oldest local service registered with a URL in a namespace
+func FindByURL(tx *gorm.DB, namespace, url string) (types.MCPService, error) {
+	var service types.MCPService
+	err := tx.Where("namespace = ? AND url = ? AND origin = ''", namespace, url).
+		Order("created_at, id").First(&service).Error
+	return service, err
+}
+
+// FindByExternalID returns the local service given an external ID in a namespace
+func FindByExternalID(tx *gorm.DB, namespace, externalID string) (types.MCPService, error) {
+	var service types.MCPService
+	err := tx.Where("namespace = ? AND external_id = ? AND origin = ''", namespace, externalID).
+		First(&service).Error
+	return service, err
+}
+
+// FindDuplicate returns the ID of the local service already registered with
+// the same namespace, name and URL, or an empty string if there is none
+func FindDuplicate(tx *gorm.DB, namespace, name, url string) (string, error) {
+	var ids []string
+	err := tx.Model(&types.MCPService{}).
+		Where("namespace = ? AND name = ? AND url = ? AND origin = ''", namespace, name, url).
+		Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// TakenNames returns which of names a service in the namespace other than
+// excludeID, and not itself called name, is already named or aliased. Those
+// names would resolve to both services.
+func TakenNames(tx *gorm.DB, namespace, name string, names []string, excludeID string) (map[string]bool, error) {
+	taken := map[string]bool{}
+	if len(names) == 0 {
+		return taken, nil
+	}
+	others := tx.Model(&types.MCPService{}).Select("id").Where("namespace = ? AND name <> ? AND id <> ?", namespace, name, excludeID)
+
+	var named, aliased []string
+	if err := tx.Model(&types.MCPService{}).Where("namespace = ? AND name <> ? AND id <> ? AND name IN ?", namespace, name, excludeID, names).
+		Pluck("name", &named).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&types.ServiceAlias{}).Where("name IN ? AND service_id IN (?)", names, others).
+		Pluck("name", &aliased).Error; err != nil {
+		return nil, err
+	}
+	for _, n := range append(named, aliased...) {
+		taken[n] = true
+	}
+	return taken, nil
+}
+
+// RecordBuild stores the version and git SHA a service reported with a
+// heartbeat. Empty values weren't reported and leave the stored ones alone. If
+// either differs from before, a change and a version event are recorded and
+// true is returned.
+func RecordBuild(tx *gorm.DB, serviceID, version, gitSHA string) (bool, error) {
+	var current types.MCPService
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "version", "git_sha").
+		First(&current, "id = ?", serviceID).Error; err != nil {
+		return false, err
+	}
+	if version == "" {
+		version = current.Version
+	}
+	if gitSHA == "" {
+		gitSHA = current.GitSHA
+	}
+	if version == current.Version && gitSHA == current.GitSHA {
+		return false, nil
+	}
+
+	if err := tx.Model(&types.MCPService{}).Where("id = ?", serviceID).
+		Updates(map[string]any{"version": version, "git_sha": gitSHA}).Error; err != nil {
+		return false, err
+	}
+	// Recording the change first takes the registry_state lock, keeping event
+	// IDs in commit order for followers of /events
+	if err := RecordChange(tx, types.ChangeUpdated, serviceID); err != nil {
+		return false, err
+	}
+	_, err := RecordEvent(tx, types.EventServiceVersionChanged, serviceID, map[string]string{
+		"previous_version": current.Version,
+		"previous_git_sha": current.GitSHA,
+		"version":          version,
+		"git_sha":          gitSHA,
+	})
+	return err == nil, err
+}
+
+// SaveService inserts or overwrites a service by ID, replacing its
+// associations. Syncs save each of their services on every pass, so a
+// service whose registration is unchanged only has its lease, health and
+// ratings refreshed, and no change is recorded for it.
+func SaveService(tx *gorm.DB, service types.MCPService) error {
+	var stored []types.MCPService
+	if err := Preload(tx).Limit(1).Find(&stored, "id = ?", service.ID).Error; err != nil {
+		return err
+	}
+	if len(stored) > 0 && sameRegistration(stored[0], service) {
+		// UpdateColumns so updated_at stays the time of the last real change.
+		// Health and ratings go in only when they moved, so an unchanged
+		// service is a lease-only update that leaves the change index alone.
+		columns := map[string]any{
+			"last_seen":      service.LastSeen,
+			"latency_p50_ms": service.LatencyP50Ms,
+			"latency_p95_ms": service.LatencyP95Ms,
+		}
+		if service.Status != stored[0].Status || service.Flapping != stored[0].Flapping {
+			columns["status"] = service.Status
+			columns["flapping"] = service.Flapping
+		}
+		if !reflect.DeepEqual(service.RatingAvg, stored[0].RatingAvg) || service.RatingCount != stored[0].RatingCount {
+			columns["rating_avg"] = service.RatingAvg
+			columns["rating_count"] = service.RatingCount
+		}
+		return tx.Model(&types.MCPService{}).Where("id = ?", service.ID).UpdateColumns(columns).Error
+	}
+	// Snapshots and external sources don't carry the heartbeat token or
+	// sunset notice, so keep whatever was recorded locally
+	if err := tx.Omit(clause.Associations, "HeartbeatTokenHash", "SunsetNotifiedAt").Save(&service).Error; err != nil {
+		return err
+	}
+	if err := ReplaceAssociations(tx, service); err != nil {
+		return err
+	}
+
+	change := types.ChangeUpdated
+	if len(stored) == 0 {
+		change = types.ChangeCreated
+	}
+	return RecordChange(tx, change, service.ID)
+}
+
+// sameRegistration reports whether saving incoming over stored would change
+// anything but its timestamps, lease, health and ratings
+func sameRegistration(stored, incoming types.MCPService) bool {
+	stored.Metadata = openItems(stored.Metadata)
+	incoming.Metadata = openItems(incoming.Metadata)
+	a, errA := registrationView(stored)
+	b, errB := registrationView(incoming)
+	return errA == nil && errB == nil && reflect.DeepEqual(a, b)
+}
+
+// registrationView is a service without the fields SaveService refreshes
+// quietly, in a form that compares equal however it was loaded
+func registrationView(service types.MCPService) (any, error) {
+	response := types.ServiceModelToResponse(service)
+	response.CreatedAt, response.UpdatedAt, response.LastSeen = time.Time{}, time.Time{}, time.Time{}
+	response.Status, response.Flapping = "", false
+	response.LatencyP50Ms, response.LatencyP95Ms = nil, nil
+	response.RatingAvg, response.RatingCount = nil, 0
+	response.SunsetAt = storedTime(response.SunsetAt)
+	if response.Provenance != nil {
+		provenance := *response.Provenance
+		provenance.VerifiedAt = storedTime(provenance.VerifiedAt)
+		response.Provenance = &provenance
+	}
+	// Associations come back in no particular order
+	slices.Sort(response.Categories)
+	slices.Sort(response.Aliases)
+
+	// Through JSON so JSONB columns compare however Postgres reformatted them
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var view any
+	err = json.Unmarshal(data, &view)
+	return view, err
+}
+
+// storedTime is t as Postgres keeps it, in UTC to the microsecond
+func storedTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	stored := t.UTC().Truncate(time.Microsecond)
+	return &stored
+}
+
+// ReplaceAssociations deletes every row owned by a service and recreates them from the model
+func ReplaceAssociations(tx *gorm.DB, service types.MCPService) error {
+	if err := DeleteAssociations(tx, service.ID); err != nil {
+		return err
+	}
+	return insertAssociations(tx, service)
+}
+
+// DeleteAllServices removes every service and its associations, returning how many services were deleted
+func DeleteAllServices(tx *gorm.DB) (int64, error) {
+	var ids []string
+	if err := tx.Model(&types.MCPService{}).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	for _, model := range serviceAssociations {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; err != nil {
+			return 0, err
+		}
+	}
+	result := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&types.MCPService{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	for _, id := range ids {
+		if err := RecordChange(tx, types.ChangeDeleted, id); err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// CreateLogin remembers a login started with the provider
+func CreateLogin(db *gorm.DB, login types.PendingLogin) error {
+	return db.Create(&login).Error
+}
+
+// TakeLogin removes and returns the pending login with a state, so each can
+// only be completed once. gorm.ErrRecordNotFound means it doesn't exist.
+func TakeLogin(db *gorm.DB, state string) (types.PendingLogin, error) {
+	var logins []types.PendingLogin
+	if err := db.Clauses(clause.Returning{}).Where("state = ?", state).Delete(&logins).Error; err != nil {
+		return types.PendingLogin{}, err
+	}
+	if len(logins) == 0 {
+		return types.PendingLogin{}, gorm.ErrRecordNotFound
+	}
+	return logins[0], nil
+}
+
+// CreateSession stores a new session
+func CreateSession(db *gorm.DB, session types.Session) error {
+	return db.Create(&session).Error
+}
+
+// FindSession returns the unexpired session whose token hashes to tokenHash
+func FindSession(db *gorm.DB, tokenHash string, now time.Time) (types.Session, error) {
+	var session types.Session
+	err := db.Where("token_hash = ? AND expires_at > ?", tokenHash, now.UTC()).First(&session).Error
+	return session, err
+}
+
+// DeleteSession ends a session
+func DeleteSession(db *gorm.DB, tokenHash string) error {
+	return db.Where("token_hash = ?", tokenHash).Delete(&types.Session{}).Error
+}
+
+// PurgeSessions deletes expired sessions and logins started before loginCutoff
+// that were never completed
+func PurgeSessions(db *gorm.DB, now, loginCutoff time.Time) (int64, error) {
+	result := db.Where("expires_at <= ?", now.UTC()).Delete(&types.Session{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	logins := db.Where("created_at < ?", loginCutoff.UTC()).Delete(&types.PendingLogin{})
+	return result.RowsAffected + logins.RowsAffected, logins.Error
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Snapshot import modes
+const (
+	ImportMerge   = "merge"   // Upsert snapshot services by ID, leaving others untouched
+	ImportReplace = "replace" // Delete every service before loading the snapshot
+)
+
+// ExportSnapshot reads every service and its associations into a snapshot
+func ExportSnapshot(db *gorm.DB) (types.Snapshot, error) {
+	var services []types.MCPService
+	if err := Preload(db).
+		Order("created_at, id").Find(&services).Error; err != nil {
+		return types.Snapshot{}, err
+	}
+
+	snapshot := types.Snapshot{
+		Version:    types.SnapshotVersion,
+		ExportedAt: time.Now().UTC(),
+		Services:   make([]types.ServiceResponse, 0, len(services)),
+	}
+	for _, service := range services {
+		snapshot.Services = append(snapshot.Services, types.ServiceModelToResponse(service))
+	}
+	return snapshot, nil
+}
+
+// ImportSnapshot loads a snapshot in a single transaction. Imported services
+// get a fresh last_seen so they aren't pruned before they resume heartbeats.
+func ImportSnapshot(db *gorm.DB, snapshot types.Snapshot, mode string) (types.SnapshotImportResult, error) {
+	result := types.SnapshotImportResult{Mode: mode}
+
+	if mode != ImportMerge && mode != ImportReplace {
+		return result, fmt.Errorf("unknown import mode %q", mode)
+	}
+	if snapshot.Version > types.SnapshotVersion {
+		return result, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+	for _, service := range snapshot.Services {
+		if service.ID == "" || service.Name == "" || service.URL == "" {
+			return result, errors.New("snapshot contains a service without an id, name or url")
+		}
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if mode == ImportReplace {
+			deleted, err := DeleteAllServices(tx)
+			if err != nil {
+				return err
+			}
+			result.Deleted = deleted
+		}
+
+		for _, response := range snapshot.Services {
+			service := types.ServiceResponseToModel(response)
+			service.LastSeen = now
+			if service.Namespace == "" {
+				service.Namespace = types.DefaultNamespace
+			}
+			if service.Weight == 0 {
+				service.Weight = types.DefaultWeight
+			}
+			if service.State == "" {
+				service.State = types.StatePublished
+			}
+			if service.Status == "" {
+				service.Status = types.StatusHealthy
+			}
+			if err := SaveService(tx, service); err != nil {
+				return fmt.Errorf("importing service %s: %w", service.ID, err)
+			}
+			result.Imported++
+		}
+		return nil
+	})
+	return result, err
+}
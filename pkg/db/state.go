@@ -0,0 +1,144 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// registryStateID is the primary key of the only registry_state row
+const registryStateID = 1
+
+// catalogTables are the tables whose changes alter what the service endpoints return
+var catalogTables = map[string]bool{
+	"mcp_services":    true,
+	"capabilities":    true,
+	"categories":      true,
+	"metadata_items":  true,
+	"service_aliases": true,
+
+	"canonical_categories": true,
+}
+
+// leaseColumns are the mcp_services columns heartbeats and probes write on
+// every beat. Updates that only set these don't count as catalog changes, or
+// a heartbeating fleet would move the change index every second.
+var leaseColumns = map[string]bool{
+	"last_seen":         true,
+	"failure_streak":    true,
+	"success_streak":    true,
+	"status_changes":    true,
+	"flap_window_start": true,
+	"latency_p50_ms":    true,
+	"latency_p95_ms":    true,
+}
+
+// trackChanges creates the registry_state row and registers callbacks that
+// bump it whenever a catalog table is written, in the same transaction
+func trackChanges(db *gorm.DB) error {
+	state := types.RegistryState{ID: registryStateID, UpdatedAt: time.Now()}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error; err != nil {
+		return err
+	}
+
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("registry:touch_create", touch); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("registry:touch_update", touch); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("registry:touch_delete", touch)
+}
+
+func changedCatalog(tx *gorm.DB) bool {
+	return tx.Error == nil && tx.Statement.RowsAffected > 0 && catalogTables[tx.Statement.Table] && !leaseOnly(tx.Statement)
+}
+
+// leaseOnly reports whether stmt is an UpdateColumn or UpdateColumns of
+// lease columns alone. Update also sets updated_at, so it always counts.
+func leaseOnly(stmt *gorm.Statement) bool {
+	columns, ok := stmt.Dest.(map[string]any)
+	if stmt.Table != "mcp_services" || !stmt.SkipHooks || !ok || len(columns) == 0 {
+		return false
+	}
+	for column := range columns {
+		if !leaseColumns[column] {
+			return false
+		}
+	}
+	return true
+}
+
+func touch(tx *gorm.DB) {
+	if !changedCatalog(tx) {
+		return
+	}
+	tx.Session(&gorm.Session{NewDB: true}).
+		Exec("UPDATE registry_state SET updated_at = ?, change_index = change_index + 1 WHERE id = ?", time.Now(), registryStateID)
+}
+
+// LastModified returns when the catalog last changed
+func LastModified(db *gorm.DB) (time.Time, error) {
+	state, err := State(db)
+	return state.UpdatedAt, err
+}
+
+// State returns when the catalog last changed and its change index
+func State(db *gorm.DB) (types.RegistryState, error) {
+	var state types.RegistryState
+	err := db.First(&state, "id = ?", registryStateID).Error
+	return state, err
+}
+
+// WatchChanges calls fn each time the change index moves, checking every
+// interval until the context is cancelled. The index only moves when a
+// catalog write commits, so unlike a callback fn never runs inside the
+// writing transaction, for changes later rolled back or for lease renewals.
+// Failed checks are retried on the next tick.
+func WatchChanges(ctx context.Context, db *gorm.DB, interval time.Duration, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	index := int64(-1)
+	for {
+		if state, err := State(db.WithContext(ctx)); err == nil {
+			if index >= 0 && state.Index != index {
+				fn()
+			}
+			index = state.Index
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForChange polls until the change index moves past index, the wait
+// elapses or the context is cancelled, and returns the latest state
+func WaitForChange(ctx context.Context, db *gorm.DB, index int64, wait, interval time.Duration) (types.RegistryState, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		state, err := State(db.WithContext(ctx))
+		if err != nil || state.Index > index {
+			return state, err
+		}
+		select {
+		case <-ctx.Done():
+			return state, ctx.Err()
+		case <-deadline.C:
+			return state, nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// StreakPolicy decides when probe and heartbeat outcomes change a service's
+// status and when its status changes count as flapping
+type StreakPolicy struct {
+	FailureThreshold  int // Consecutive failures before a healthy service is degraded
+	RecoveryThreshold int // Consecutive successes before a degraded service is healthy
+	FlapThreshold     int // Status changes within FlapWindow that make a service flapping, 0 disables
+	FlapWindow        time.Duration
+}
+
+// RecordOutcome counts a successful or failed probe or heartbeat towards a
+// service's streaks, changing its status once a streak reaches the policy's
+// threshold, and returns whether it did. The caller owns the transaction.
+func RecordOutcome(tx *gorm.DB, serviceID string, success bool, policy StreakPolicy, now time.Time) (bool, error) {
+	var service types.MCPService
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "status", "failure_streak", "success_streak", "status_changes", "flap_window_start", "flapping").
+		First(&service, "id = ?", serviceID).Error; err != nil {
+		return false, err
+	}
+	previous := service.Status
+
+	if success {
+		service.SuccessStreak++
+		service.FailureStreak = 0
+		if service.Status != types.StatusHealthy && service.SuccessStreak >= policy.RecoveryThreshold {
+			service.Status = types.StatusHealthy
+		}
+	} else {
+		service.FailureStreak++
+		service.SuccessStreak = 0
+		if service.Status == types.StatusHealthy && service.FailureStreak >= policy.FailureThreshold {
+			service.Status = types.StatusDegraded
+		}
+	}
+
+	changed := service.Status != previous
+	windowOver := service.FlapWindowStart == nil || now.Sub(*service.FlapWindowStart) > policy.FlapWindow
+	switch {
+	case changed && windowOver:
+		service.FlapWindowStart = &now
+		service.StatusChanges = 1
+	case changed:
+		service.StatusChanges++
+	case windowOver:
+		// A whole window without reaching the threshold clears the flag
+		service.FlapWindowStart = nil
+		service.StatusChanges = 0
+	}
+	wasFlapping := service.Flapping
+	if policy.FlapThreshold > 0 && service.StatusChanges >= policy.FlapThreshold {
+		service.Flapping = true
+	} else if service.StatusChanges == 0 {
+		service.Flapping = false
+	}
+
+	// UpdateColumns so streak bookkeeping doesn't count as editing the
+	// service, and status and flapping only when they change so the rest
+	// stays a lease-only update
+	columns := map[string]any{
+		"failure_streak":    service.FailureStreak,
+		"success_streak":    service.SuccessStreak,
+		"status_changes":    service.StatusChanges,
+		"flap_window_start": service.FlapWindowStart,
+	}
+	if changed || service.Flapping != wasFlapping {
+		columns["status"] = service.Status
+		columns["flapping"] = service.Flapping
+	}
+	if err := tx.Model(&types.MCPService{}).Where("id = ?", serviceID).UpdateColumns(columns).Error; err != nil {
+		return false, err
+	}
+
+	if !changed && service.Flapping == wasFlapping {
+		return false, nil
+	}
+	if err := RecordChange(tx, types.ChangeUpdated, serviceID); err != nil {
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+	_, err := RecordEvent(tx, types.EventServiceStatusChanged, serviceID, map[string]any{
+		"previous_status": previous,
+		"status":          service.Status,
+		"flapping":        service.Flapping,
+	})
+	return true, err
+}
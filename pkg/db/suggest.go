@@ -0,0 +1,50 @@
+package db
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// likeEscaper escapes LIKE wildcards so a prefix matches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards in s, for patterns built from user
+// input that should match it literally
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// SuggestNames returns up to limit public, published service names starting
+// with prefix, ignoring case, most used first
+func SuggestNames(db *gorm.DB, prefix string, limit int) ([]types.Suggestion, error) {
+	var suggestions []types.Suggestion
+	err := db.Model(&types.MCPService{}).
+		Select("name AS text, COUNT(*) AS count").
+		Where("state = ? AND visibility <> ? AND name ILIKE ?", types.StatePublished, types.VisibilityTeam, likeEscaper.Replace(prefix)+"%").
+		Group("name").Order("count DESC, LENGTH(name), name").Limit(limit).
+		Scan(&suggestions).Error
+	for i := range suggestions {
+		suggestions[i].Type = types.SuggestionName
+	}
+	return suggestions, err
+}
+
+// SuggestCategories returns up to limit categories of public, published
+// services starting with prefix, ignoring case, most used first
+func SuggestCategories(db *gorm.DB, prefix string, limit int) ([]types.Suggestion, error) {
+	var suggestions []types.Suggestion
+	err := db.Model(&types.Category{}).
+		Select("categories.name AS text, COUNT(DISTINCT categories.service_id) AS count").
+		Joins("JOIN mcp_services ON mcp_services.id = categories.service_id").
+		Where("mcp_services.state = ? AND mcp_services.visibility <> ? AND categories.name ILIKE ?",
+			types.StatePublished, types.VisibilityTeam, likeEscaper.Replace(prefix)+"%").
+		Group("categories.name").Order("count DESC, LENGTH(categories.name), categories.name").Limit(limit).
+		Scan(&suggestions).Error
+	for i := range suggestions {
+		suggestions[i].Type = types.SuggestionCategory
+	}
+	return suggestions, err
+}
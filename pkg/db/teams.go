@@ -0,0 +1,34 @@
+package db
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// TeamsOf returns the IDs of the teams principal is a member of
+func TeamsOf(db *gorm.DB, principal string) ([]string, error) {
+	var teams []string
+	err := db.Model(&types.TeamMember{}).Where("principal = ?", principal).Pluck("team_id", &teams).Error
+	return teams, err
+}
+
+// TeamRole returns principal's role in a team, or an empty string if they
+// aren't a member
+func TeamRole(db *gorm.DB, teamID, principal string) (string, error) {
+	var member types.TeamMember
+	err := db.First(&member, "team_id = ? AND principal = ?", teamID, principal).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return member.Role, err
+}
+
+// TeamServiceCount returns how many services a team owns
+func TeamServiceCount(db *gorm.DB, teamID string) (int64, error) {
+	var count int64
+	err := db.Model(&types.MCPService{}).Where("team_id = ?", teamID).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// LockTransfer loads a transfer and locks it until the transaction ends, so
+// it can only be resolved once
+func LockTransfer(tx *gorm.DB, id string) (types.ServiceTransfer, error) {
+	var transfer types.ServiceTransfer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, "id = ?", id).Error
+	return transfer, err
+}
+
+// CompleteTransfer moves the transfer's service to its new namespace and team
+// and marks the transfer accepted. The caller owns the transaction.
+func CompleteTransfer(tx *gorm.DB, transfer *types.ServiceTransfer, resolvedBy string, now time.Time) error {
+	if err := tx.Model(&types.MCPService{}).Where("id = ?", transfer.ServiceID).
+		Updates(map[string]any{"namespace": transfer.ToNamespace, "team_id": transfer.ToTeamID}).Error; err != nil {
+		return err
+	}
+	if err := RecordChange(tx, types.ChangeUpdated, transfer.ServiceID); err != nil {
+		return err
+	}
+	if err := RecordRevision(tx, transfer.ServiceID); err != nil {
+		return err
+	}
+	return ResolveTransfer(tx, transfer, types.TransferAccepted, resolvedBy, now)
+}
+
+// ResolveTransfer records how a pending transfer ended
+func ResolveTransfer(tx *gorm.DB, transfer *types.ServiceTransfer, state, resolvedBy string, now time.Time) error {
+	transfer.State = state
+	transfer.ResolvedBy = resolvedBy
+	transfer.ResolvedAt = &now
+	return tx.Model(transfer).Updates(map[string]any{"state": state, "resolved_by": resolvedBy, "resolved_at": now}).Error
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// RecordUsage adds the counts to each service's rollup for the day containing at
+func RecordUsage(db *gorm.DB, counts map[string]int64, at time.Time) error {
+	day := at.UTC().Truncate(24 * time.Hour)
+	rows := make([]types.UsageDay, 0, len(counts))
+	for serviceID, count := range counts {
+		rows = append(rows, types.UsageDay{ServiceID: serviceID, Day: day, Count: count})
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "service_id"}, {Name: "day"}},
+		DoUpdates: clause.Set{{Column: clause.Column{Name: "count"}, Value: gorm.Expr("usage_daily.count + excluded.count")}},
+	}).Create(&rows).Error
+}
+
+// UsageSince returns a service's daily rollups starting on or after from's day, oldest first
+func UsageSince(db *gorm.DB, serviceID string, from time.Time) ([]types.UsageDay, error) {
+	var days []types.UsageDay
+	err := db.Where("service_id = ? AND day >= ?", serviceID, from.UTC().Truncate(24*time.Hour)).
+		Order("day").Find(&days).Error
+	return days, err
+}
+
+// TopServices returns the most used existing services since from's day
+func TopServices(db *gorm.DB, from time.Time, limit int) ([]types.ServiceUsage, error) {
+	var top []types.ServiceUsage
+	err := db.Table("usage_daily").
+		Select("usage_daily.service_id, mcp_services.name, SUM(usage_daily.count) AS count").
+		Joins("JOIN mcp_services ON mcp_services.id = usage_daily.service_id").
+		Where("usage_daily.day >= ?", from.UTC().Truncate(24*time.Hour)).
+		Group("usage_daily.service_id, mcp_services.name").
+		Order("count DESC, usage_daily.service_id").
+		Limit(limit).Scan(&top).Error
+	return top, err
+}
+
+// TrendingServices returns the IDs of published services whose usage grew the
+// most since from, compared to the window of the same length before it
+func TrendingServices(db *gorm.DB, from time.Time, window time.Duration, limit int) ([]string, error) {
+	from = from.UTC().Truncate(24 * time.Hour)
+	previous := from.Add(-window).Truncate(24 * time.Hour)
+
+	recent := gorm.Expr("SUM(CASE WHEN usage_daily.day >= ? THEN usage_daily.count ELSE 0 END)", from)
+	before := gorm.Expr("SUM(CASE WHEN usage_daily.day < ? THEN usage_daily.count ELSE 0 END)", from)
+
+	var ids []string
+	err := db.Table("usage_daily").
+		Select("usage_daily.service_id").
+		Joins("JOIN mcp_services ON mcp_services.id = usage_daily.service_id").
+		Where("usage_daily.day >= ? AND mcp_services.state = ?", previous, types.StatePublished).
+		Group("usage_daily.service_id").
+		Having("? > 0", recent).
+		Order(clause.OrderBy{Expression: gorm.Expr("? - ? DESC, ? DESC, usage_daily.service_id", recent, before, recent)}).
+		Limit(limit).Pluck("usage_daily.service_id", &ids).Error
+	return ids, err
+}
+
+// RecordAPIUsage adds the counts, keyed by principal and class, to the
+// rollups for the day containing at
+func RecordAPIUsage(db *gorm.DB, counts map[[2]string]int64, at time.Time) error {
+	day := at.UTC().Truncate(24 * time.Hour)
+	rows := make([]types.APIUsageDay, 0, len(counts))
+	for key, count := range counts {
+		rows = append(rows, types.APIUsageDay{Principal: key[0], Class: key[1], Day: day, Count: count})
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "principal"}, {Name: "day"}, {Name: "class"}},
+		DoUpdates: clause.Set{{Column: clause.Column{Name: "count"}, Value: gorm.Expr("api_usage_daily.count + excluded.count")}},
+	}).Create(&rows).Error
+}
+
+// APIUsageSince returns the API usage rollups starting on or after from's
+// day, only principal's if it isn't empty, ordered by day and principal
+func APIUsageSince(db *gorm.DB, principal string, from time.Time) ([]types.APIUsageDay, error) {
+	query := db.Where("day >= ?", from.UTC().Truncate(24*time.Hour))
+	if principal != "" {
+		query = query.Where("principal = ?", principal)
+	}
+	days := []types.APIUsageDay{}
+	err := query.Order("day, principal, class").Find(&days).Error
+	return days, err
+}
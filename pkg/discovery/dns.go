@@ -0,0 +1,284 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// DNS record types, classes and response codes used by the server
+const (
+	typeTXT   = 16
+	typeSRV   = 33
+	typeANY   = 255
+	classIN   = 1
+	rcodeOK   = 0
+	rcodeErr  = 1 // Format error
+	rcodeFail = 2 // Server failure
+	rcodeNX   = 3 // Name error
+	rcodeImp  = 4 // Not implemented
+
+	// maxUDPSize is the largest response sent without EDNS
+	maxUDPSize = 512
+)
+
+// DNSServer answers SRV and TXT queries for healthy published services over
+// UDP, so tools that only speak DNS can discover MCP servers. A query for
+// _mcp._tcp.<name>.<zone> looks the name up in the default namespace and
+// _mcp._tcp.<name>.<namespace>.<zone> in the given one.
+//
+// SRV records carry each replica's priority, weight and URL host and port,
+// TXT records its ID and full URL.
+type DNSServer struct {
+	DB         *gorm.DB
+	Addr       string
+	Zone       string
+	ServiceTTL time.Duration // Services not seen within it are left out
+	RecordTTL  uint32        // TTL in seconds handed to resolvers
+}
+
+// Run serves queries until the context is cancelled
+func (s *DNSServer) Run(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		response := s.answer(buf[:n])
+		if response == nil {
+			continue
+		}
+		if _, err := conn.WriteTo(response, addr); err != nil {
+			log.Printf("Failed to answer DNS query from %s: %v", addr, err)
+		}
+	}
+}
+
+// question is the single question of a query
+type question struct {
+	labels []string
+	qtype  uint16
+	class  uint16
+	end    int // Offset just past the question in the query
+}
+
+// answer builds the response to a query, or returns nil if it can't be answered at all
+func (s *DNSServer) answer(query []byte) []byte {
+	if len(query) < 12 || query[2]&0x80 != 0 {
+		return nil // Too short, or a response
+	}
+
+	q, err := parseQuestion(query)
+	if err != nil {
+		response := header(query, rcodeErr, 0)
+		binary.BigEndian.PutUint16(response[4:6], 0)
+		return response
+	}
+	if binary.BigEndian.Uint16(query[4:6]) != 1 || query[2]&0x78 != 0 {
+		return append(header(query, rcodeImp, 0), query[12:q.end]...)
+	}
+
+	name, namespace, ok := s.serviceName(q.labels)
+	if !ok || q.class != classIN {
+		return append(header(query, rcodeNX, 0), query[12:q.end]...)
+	}
+	if q.qtype != typeSRV && q.qtype != typeTXT && q.qtype != typeANY {
+		// The name may exist, there just aren't records of this type
+		return append(header(query, rcodeOK, 0), query[12:q.end]...)
+	}
+
+	services, err := s.lookup(name, namespace)
+	if err != nil {
+		log.Printf("Failed to look up %s for DNS: %v", name, err)
+		return append(header(query, rcodeFail, 0), query[12:q.end]...)
+	}
+	if len(services) == 0 {
+		return append(header(query, rcodeNX, 0), query[12:q.end]...)
+	}
+
+	var records [][]byte
+	for _, service := range services {
+		if q.qtype != typeTXT {
+			if record, ok := s.srvRecord(service); ok {
+				records = append(records, record)
+			}
+		}
+		if q.qtype != typeSRV {
+			records = append(records, s.txtRecord(service))
+		}
+	}
+
+	response := append(header(query, rcodeOK, len(records)), query[12:q.end]...)
+	for i, record := range records {
+		if len(response)+len(record) > maxUDPSize {
+			// Count only the records that fit and tell the resolver the
+			// answer was cut short
+			binary.BigEndian.PutUint16(response[6:8], uint16(i))
+			response[2] |= 0x02
+			break
+		}
+		response = append(response, record...)
+	}
+	return response
+}
+
+// serviceName extracts the service name and namespace from _mcp._tcp.<name>[.<namespace>].<zone>
+func (s *DNSServer) serviceName(labels []string) (string, string, bool) {
+	zone := strings.Split(strings.Trim(strings.ToLower(s.Zone), "."), ".")
+	if len(labels) < 3+len(zone) || labels[0] != "_mcp" || labels[1] != "_tcp" {
+		return "", "", false
+	}
+	for i, label := range zone {
+		if labels[len(labels)-len(zone)+i] != label {
+			return "", "", false
+		}
+	}
+
+	rest := labels[2 : len(labels)-len(zone)]
+	switch len(rest) {
+	case 1:
+		return rest[0], types.DefaultNamespace, true
+	case 2:
+		return rest[0], rest[1], true
+	default:
+		return "", "", false
+	}
+}
+
+// lookup finds the healthy published services answering to a name. DNS
+// queries are anonymous, so team services are never answered.
+func (s *DNSServer) lookup(name, namespace string) ([]types.MCPService, error) {
+	var services []types.MCPService
+	err := s.DB.Where("LOWER(name) = ? AND LOWER(namespace) = ? AND state = ? AND status = ? AND last_seen >= ? AND visibility <> ?",
+		name, namespace, types.StatePublished, types.StatusHealthy, time.Now().Add(-s.ServiceTTL), types.VisibilityTeam).
+		Order("priority, id").Find(&services).Error
+	return services, err
+}
+
+// srvRecord encodes a SRV answer pointing at the service's URL host and port
+func (s *DNSServer) srvRecord(service types.MCPService) ([]byte, bool) {
+	parsed, err := url.Parse(service.URL)
+	if err != nil || parsed.Hostname() == "" {
+		return nil, false
+	}
+	port := 80
+	if parsed.Scheme == "https" {
+		port = 443
+	}
+	if p := parsed.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil, false
+		}
+	}
+
+	target := encodeName(parsed.Hostname())
+	if target == nil {
+		return nil, false
+	}
+	data := make([]byte, 6, 6+len(target))
+	binary.BigEndian.PutUint16(data[0:2], uint16(service.Priority))
+	binary.BigEndian.PutUint16(data[2:4], uint16(service.Weight))
+	binary.BigEndian.PutUint16(data[4:6], uint16(port))
+	return s.record(typeSRV, append(data, target...)), true
+}
+
+// txtRecord encodes a TXT answer with the service's ID and URL
+func (s *DNSServer) txtRecord(service types.MCPService) []byte {
+	var data []byte
+	for _, value := range []string{"id=" + service.ID, "url=" + service.URL} {
+		if len(value) > 255 {
+			value = value[:255]
+		}
+		data = append(data, byte(len(value)))
+		data = append(data, value...)
+	}
+	return s.record(typeTXT, data)
+}
+
+// record encodes an answer for the question's name
+func (s *DNSServer) record(rtype uint16, data []byte) []byte {
+	record := make([]byte, 12, 12+len(data))
+	// Pointer to the name in the question, which always starts at offset 12
+	binary.BigEndian.PutUint16(record[0:2], 0xC00C)
+	binary.BigEndian.PutUint16(record[2:4], rtype)
+	binary.BigEndian.PutUint16(record[4:6], classIN)
+	binary.BigEndian.PutUint32(record[6:10], s.RecordTTL)
+	binary.BigEndian.PutUint16(record[10:12], uint16(len(data)))
+	return append(record, data...)
+}
+
+// header builds a response header for the query with one question and n answers
+func header(query []byte, rcode byte, answers int) []byte {
+	h := make([]byte, 12)
+	copy(h[0:2], query[0:2])
+	// QR and AA set, opcode and RD copied from the query
+	h[2] = 0x80 | 0x04 | query[2]&0x79
+	h[3] = rcode
+	binary.BigEndian.PutUint16(h[4:6], 1)
+	binary.BigEndian.PutUint16(h[6:8], uint16(answers))
+	return h
+}
+
+func parseQuestion(query []byte) (question, error) {
+	var q question
+	offset := 12
+	for {
+		if offset >= len(query) {
+			return q, errors.New("truncated name")
+		}
+		length := int(query[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length > 63 || offset+length > len(query) {
+			return q, errors.New("invalid label")
+		}
+		q.labels = append(q.labels, strings.ToLower(string(query[offset:offset+length])))
+		offset += length
+	}
+	if offset+4 > len(query) {
+		return q, errors.New("truncated question")
+	}
+	q.qtype = binary.BigEndian.Uint16(query[offset : offset+2])
+	q.class = binary.BigEndian.Uint16(query[offset+2 : offset+4])
+	q.end = offset + 4
+	return q, nil
+}
+
+// encodeName encodes a host name in DNS wire format, or returns nil if it isn't valid
+func encodeName(host string) []byte {
+	var encoded []byte
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil
+		}
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+	return append(encoded, 0)
+}
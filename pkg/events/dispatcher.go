@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/leader"
+	"github.com/arnavsurve/gateway-registry/pkg/telemetry"
+)
+
+// dispatchBatchSize is how many pending events are read per delivery round
+const dispatchBatchSize = 100
+
+// maxBackoff caps how long a failing event is held back between attempts
+const maxBackoff = time.Hour
+
+// Dispatcher delivers events from the outbox to a webhook. Events are
+// committed with the change that caused them, so one only goes missing if
+// the receiver doesn't accept it within MaxAttempts. Delivery is at least
+// once and in order: a failure stops the round, and the same event is
+// retried after a backoff that doubles with each attempt. An event that has
+// failed MaxAttempts times is given up on so it can't hold up the rest.
+type Dispatcher struct {
+	DB          *gorm.DB
+	Webhook     *Webhook
+	Interval    time.Duration
+	MaxAttempts int
+	Leader      *leader.Elector // Only deliver while this instance is the leader, if set
+	Metrics     *telemetry.Metrics
+}
+
+// Run delivers pending events every interval until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if d.Leader.IsLeader() {
+				d.dispatch(ctx)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context) {
+	for {
+		pending, err := db.PendingEvents(d.DB, dispatchBatchSize, d.MaxAttempts)
+		if err != nil {
+			log.Printf("Failed to read pending events: %v", err)
+			return
+		}
+
+		for _, event := range pending {
+			if event.NextAttemptAt != nil && time.Now().Before(*event.NextAttemptAt) {
+				return
+			}
+			if err := d.Webhook.Send(ctx, event); err != nil {
+				log.Printf("Failed to deliver event %d: %v", event.ID, err)
+				d.Metrics.Add(telemetry.WebhookFailures, 1)
+				if event.Attempts+1 >= d.MaxAttempts {
+					log.Printf("Giving up on event %d after %d attempts", event.ID, event.Attempts+1)
+				}
+				if err := db.RecordAttempt(d.DB, event.ID, time.Now().Add(d.backoff(event.Attempts))); err != nil {
+					log.Printf("Failed to record delivery attempt for event %d: %v", event.ID, err)
+				}
+				return
+			}
+			d.Metrics.Add(telemetry.WebhookDelivered, 1)
+			if err := db.MarkDelivered(d.DB, event.ID, time.Now()); err != nil {
+				log.Printf("Failed to mark event %d delivered: %v", event.ID, err)
+				return
+			}
+		}
+
+		if len(pending) < dispatchBatchSize {
+			return
+		}
+	}
+}
+
+// backoff is how long to hold an event back after its attempts+1th failure
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.Interval
+	for i := 0; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
@@ -0,0 +1,98 @@
+// Package extensions checks the custom data services carry under
+// "extensions" against schemas the operator registers by extension name, so
+// organizations can attach structured fields of their own, such as a cost
+// center or a data classification, without changing the registry's types.
+package extensions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arnavsurve/gateway-registry/pkg/jsonschema"
+)
+
+// Registry holds the schema for each extension name. Services may only carry
+// the extensions it has.
+type Registry map[string]*jsonschema.Schema
+
+// Violation is a way one extension doesn't match its schema
+type Violation struct {
+	Extension string
+	jsonschema.Violation
+}
+
+// Load reads a YAML or JSON file mapping extension names to JSON Schemas,
+// such as
+//
+//	cost_center:
+//	  type: object
+//	  required: [code]
+//	  properties:
+//	    code: {type: string, pattern: "^CC-[0-9]{4}$"}
+//	data_classification:
+//	  enum: [public, internal, confidential]
+func Load(path string) (Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schemas map[string]any
+	if err := yaml.Unmarshal(data, &schemas); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	registry := make(Registry, len(schemas))
+	for name, schema := range schemas {
+		// Schemas go through JSON so they read the same however they were written
+		encoded, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if registry[name], err = jsonschema.Compile(encoded); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return registry, nil
+}
+
+// Names lists the registered extensions
+func (r Registry) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks each extension against its schema. Extensions without one
+// are reported as unknown.
+func (r Registry) Validate(extensions map[string]json.RawMessage) []Violation {
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var violations []Violation
+	for _, name := range names {
+		schema, ok := r[name]
+		if !ok {
+			violations = append(violations, Violation{Extension: name, Violation: jsonschema.Violation{Message: "is not a registered extension"}})
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(extensions[name], &value); err != nil {
+			violations = append(violations, Violation{Extension: name, Violation: jsonschema.Violation{Message: "is not valid JSON"}})
+			continue
+		}
+		for _, violation := range schema.Validate(value) {
+			violations = append(violations, Violation{Extension: name, Violation: violation})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,141 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Syncer periodically pulls the locally registered services of peer
+// registries and merges them in as read-only entries tagged with their origin.
+//
+// Only changes are fetched after the first pass, using the delta token each
+// peer hands back. Services removed on a peer stop being refreshed and are
+// pruned here once their last_seen falls outside the TTL.
+//
+// In mirror mode the full catalog of a single upstream is replicated as-is,
+// keeping each service's original origin, so the local copy is identical.
+type Syncer struct {
+	DB       *gorm.DB
+	Peers    []string
+	Interval time.Duration
+	Client   *http.Client
+	Mirror   bool
+
+	tokens map[string]string
+}
+
+// NewSyncer creates a Syncer for the given peer base URLs
+func NewSyncer(db *gorm.DB, peers []string, interval time.Duration) *Syncer {
+	return &Syncer{
+		DB:       db,
+		Peers:    peers,
+		Interval: interval,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		tokens:   make(map[string]string),
+	}
+}
+
+// Run syncs every peer on each interval until the context is cancelled
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		for _, peer := range s.Peers {
+			if err := s.SyncPeer(ctx, peer); err != nil {
+				log.Printf("Failed to sync peer %s: %v", peer, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncPeer pulls the services changed on a peer since the last sync and merges them
+func (s *Syncer) SyncPeer(ctx context.Context, peer string) error {
+	peer = strings.TrimSuffix(peer, "/")
+
+	// Only pull what the peer owns, otherwise peers would federate each other's copies back and forth
+	// Mirrors copy every lifecycle state, peers only see what's published.
+	query := url.Values{}
+	if s.Mirror {
+		query.Set("state", "all")
+	} else {
+		query.Set("origin", "local")
+	}
+	if token := s.tokens[peer]; token != "" {
+		query.Set("delta_token", token)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+"/services?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var services []types.ServiceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		return fmt.Errorf("decoding services: %w", err)
+	}
+
+	for _, service := range services {
+		if err := s.merge(peer, service); err != nil {
+			log.Printf("Failed to merge service %s from %s: %v", service.ID, peer, err)
+		}
+	}
+
+	if token := resp.Header.Get("X-Delta-Token"); token != "" {
+		s.tokens[peer] = token
+	}
+	return nil
+}
+
+// merge upserts a peer's service and replaces its associations
+func (s *Syncer) merge(origin string, remote types.ServiceResponse) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		service := types.ServiceResponseToModel(remote)
+		if s.Mirror {
+			return s.save(tx, service)
+		}
+
+		var existing types.MCPService
+		err := tx.Select("id", "origin").First(&existing, "id = ?", remote.ID).Error
+		if err == nil && existing.Origin != origin {
+			return fmt.Errorf("service already exists with origin %q", existing.Origin)
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		service.Origin = origin
+		return s.save(tx, service)
+	})
+}
+
+func (s *Syncer) save(tx *gorm.DB, service types.MCPService) error {
+	return db.SaveService(tx, service)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Access log formats
+const (
+	AccessLogJSON     = "json"
+	AccessLogCombined = "combined" // Apache combined log format
+)
+
+// accessLogEntry is a request as written by the JSON access log
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMs float64   `json:"latency_ms"`
+	ClientIP  string    `json:"client_ip"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// AccessLog is middleware writing a line to out for every request once it
+// completes, as JSON or in Apache combined format. It belongs outside the
+// router so unmatched routes and CORS preflights are logged too.
+func AccessLog(out io.Writer, format string) func(http.Handler) http.Handler {
+	// log.Logger serializes writes from concurrent requests
+	logger := log.New(out, "", 0)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			entry := accessLogEntry{
+				Time:      start,
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Proto:     r.Proto,
+				Status:    recorder.statusCode(),
+				Bytes:     recorder.bytes,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				ClientIP:  clientIP(r),
+				UserAgent: r.UserAgent(),
+				Referer:   r.Referer(),
+				RequestID: w.Header().Get(RequestIDHeader),
+			}
+
+			if format == AccessLogJSON {
+				line, err := json.Marshal(entry)
+				if err == nil {
+					logger.Print(string(line))
+				}
+				return
+			}
+			logger.Printf(`%s - - [%s] "%s %s %s" %d %s "%s" "%s"`,
+				entry.ClientIP, entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+				entry.Method, entry.Path, entry.Proto, entry.Status, combinedBytes(entry.Bytes),
+				orDash(entry.Referer), orDash(entry.UserAgent))
+		})
+	}
+}
+
+// clientIP is the address the request came from, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func combinedBytes(n int64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprint(n)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// responseRecorder remembers the status and size of a response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// statusCode is what was sent, 200 if the handler never wrote anything
+func (r *responseRecorder) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// Flush keeps streamed responses working through the recorder
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack keeps WebSocket upgrades working through the recorder
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
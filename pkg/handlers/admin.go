@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/backup"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// adminActor is recorded in the audit log for actions taken with the admin token
+const adminActor = "admin"
+
+// RestoreRequest selects a stored snapshot to restore
+type RestoreRequest struct {
+	Name string `json:"name"`
+	Mode string `json:"mode"` // merge or replace, defaults to replace
+}
+
+func (h *Handler) ListSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := h.Backups.List(r.Context())
+	if err != nil {
+		errorResponse(w, "Error listing snapshots", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, snapshots, http.StatusOK)
+}
+
+func (h *Handler) CreateSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := backup.TakeSnapshot(r.Context(), h.conn(r), h.Backups)
+	if err != nil {
+		errorResponse(w, "Failed to take snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, info, http.StatusCreated)
+}
+
+func (h *Handler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	var request RestoreRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	if !backup.ValidName(request.Name) {
+		errorResponse(w, "Invalid snapshot name", http.StatusBadRequest)
+		return
+	}
+	if request.Mode == "" {
+		request.Mode = db.ImportReplace
+	}
+	if request.Mode != db.ImportMerge && request.Mode != db.ImportReplace {
+		errorResponse(w, "Mode must be merge or replace", http.StatusBadRequest)
+		return
+	}
+
+	result, err := backup.Restore(r.Context(), h.conn(r), h.Backups, request.Name, request.Mode)
+	if err != nil {
+		errorResponse(w, "Failed to restore snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, result, http.StatusOK)
+}
+
+// DuplicatesHandler reports groups of services that are probably the same
+// server registered more than once: matching normalized names within a
+// namespace, or matching normalized URLs anywhere
+func (h *Handler) DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	var services []types.MCPService
+	if err := db.Preload(h.conn(r)).
+		Order("created_at, id").Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	byName := make(map[string][]types.MCPService)
+	byURL := make(map[string][]types.MCPService)
+	var nameKeys, urlKeys []string
+	for _, service := range services {
+		nameKey := service.Namespace + "/" + normalizeName(service.Name)
+		if _, ok := byName[nameKey]; !ok {
+			nameKeys = append(nameKeys, nameKey)
+		}
+		byName[nameKey] = append(byName[nameKey], service)
+
+		urlKey := normalizeURL(service.URL)
+		if _, ok := byURL[urlKey]; !ok {
+			urlKeys = append(urlKeys, urlKey)
+		}
+		byURL[urlKey] = append(byURL[urlKey], service)
+	}
+
+	groups := []types.DuplicateGroup{}
+	addGroups := func(keys []string, groupsByKey map[string][]types.MCPService, reason string) {
+		for _, key := range keys {
+			if len(groupsByKey[key]) < 2 {
+				continue
+			}
+			group := types.DuplicateGroup{Reason: reason}
+			for _, service := range groupsByKey[key] {
+				group.Services = append(group.Services, h.toResponse(service))
+			}
+			groups = append(groups, group)
+		}
+	}
+	addGroups(nameKeys, byName, "same_name")
+	addGroups(urlKeys, byURL, "same_url")
+
+	jsonResponse(w, groups, http.StatusOK)
+}
+
+// normalizeName lowercases a name and drops everything but letters and digits,
+// so "Slack MCP", "slack-mcp" and "slack_mcp" compare equal
+func normalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeURL ignores scheme and host case, default ports and trailing slashes
+func normalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return strings.ToLower(strings.TrimSuffix(raw, "/"))
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	return host + strings.TrimSuffix(u.EscapedPath(), "/")
+}
+
+// ApproveServiceHandler publishes a service that is awaiting review
+func (h *Handler) ApproveServiceHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewService(w, r, types.StatePublished)
+}
+
+// RejectServiceHandler rejects a service so it isn't published
+func (h *Handler) RejectServiceHandler(w http.ResponseWriter, r *http.Request) {
+	h.reviewService(w, r, types.StateRejected)
+}
+
+func (h *Handler) reviewService(w http.ResponseWriter, r *http.Request, state string) {
+	principal, _ := auth.FromContext(r.Context())
+
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var request types.ReviewRequest
+	if r.ContentLength != 0 {
+		if !decodeJSON(w, r, &request) {
+			return
+		}
+	}
+
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) {
+		return
+	}
+	if service.State == state {
+		errorResponse(w, "Service is already "+state, http.StatusConflict)
+		return
+	}
+
+	action := types.AuditApproved
+	if state == types.StateRejected {
+		action = types.AuditRejected
+	}
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&service).Updates(map[string]any{"state": state, "review_note": request.Note}).Error; err != nil {
+			return err
+		}
+		if err := db.RecordChange(tx, types.ChangeUpdated, serviceID); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, serviceID, action, principal.Name, request.Note)
+	})
+	if err != nil {
+		errorResponse(w, "Failed to update service state", http.StatusInternalServerError)
+		return
+	}
+
+	var reviewed types.MCPService
+	if err := db.Preload(h.conn(r)).First(&reviewed, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Service reviewed but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, h.toResponse(reviewed), http.StatusOK)
+}
+
+// VerifyServiceHandler sets or clears a service's verified badge, recording
+// the verification method in the audit log
+func (h *Handler) VerifyServiceHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var request types.VerifyRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Verified && request.Method == "" {
+		errorResponse(w, "A verification method is required", http.StatusBadRequest)
+		return
+	}
+
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) {
+		return
+	}
+
+	action := types.AuditVerified
+	if !request.Verified {
+		action = types.AuditUnverified
+	}
+	details, _ := json.Marshal(map[string]string{"method": request.Method})
+
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&service).Update("verified", request.Verified).Error; err != nil {
+			return err
+		}
+		if err := db.RecordChange(tx, types.ChangeUpdated, serviceID); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, serviceID, action, principal.Name, string(details))
+	})
+	if err != nil {
+		errorResponse(w, "Failed to update verification", http.StatusInternalServerError)
+		return
+	}
+
+	var verified types.MCPService
+	if err := db.Preload(h.conn(r)).First(&verified, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Service updated but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, h.toResponse(verified), http.StatusOK)
+}
+
+// AuditLogHandler lists audit entries, newest first, optionally for one ?service_id
+func (h *Handler) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := h.conn(r).Order("id DESC").Limit(500)
+	if serviceID := r.URL.Query().Get("service_id"); serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+
+	entries := []types.AuditEntry{}
+	if err := query.Find(&entries).Error; err != nil {
+		errorResponse(w, "Error reading audit log", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, entries, http.StatusOK)
+}
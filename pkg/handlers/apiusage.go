@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// defaultUsageWindow is how far back usage is reported without ?window
+const defaultUsageWindow = "30d"
+
+// MeterUsage is middleware counting each authenticated caller's API calls as
+// reads, writes or searches, for chargeback. Anonymous calls aren't metered.
+// It needs the matched route, so it's installed with Router.Use.
+func (h *Handler) MeterUsage(next http.Handler) http.Handler {
+	if h.Meter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			if principal, ok := h.authenticate(r); ok {
+				h.Meter.Record(principal.Name, usageClass(r))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// usageClass sorts a call into the class it's metered as
+func usageClass(r *http.Request) string {
+	var template string
+	if route := mux.CurrentRoute(r); route != nil {
+		template, _ = route.GetPathTemplate()
+	}
+	switch template {
+	case "/services/search", "/services/suggest", "/discover", "/resolve", "/negotiate":
+		return types.APIUsageSearch
+	case "/services/batch-get":
+		return types.APIUsageRead
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return types.APIUsageRead
+	}
+	return types.APIUsageWrite
+}
+
+// UsageHandler returns the caller's metered API calls by day over ?window
+// (default 30d). Admins may look at anyone's with ?principal.
+func (h *Handler) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	name := principal.Name
+	if other := r.URL.Query().Get("principal"); other != "" && other != name {
+		if !principal.Admin {
+			errorResponse(w, "Only admins may view other callers' usage", http.StatusForbidden)
+			return
+		}
+		name = other
+	}
+
+	windowParam, from, ok := usageWindow(w, r)
+	if !ok {
+		return
+	}
+	days, err := db.APIUsageSince(h.reader(r), name, from)
+	if err != nil {
+		errorResponse(w, "Error loading usage", http.StatusInternalServerError)
+		return
+	}
+
+	response := types.APIUsageResponse{
+		Principal: name,
+		Window:    windowParam,
+		Totals:    map[string]int64{types.APIUsageRead: 0, types.APIUsageWrite: 0, types.APIUsageSearch: 0},
+		Days:      days,
+	}
+	for _, day := range days {
+		response.Totals[day.Class] += day.Count
+	}
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// ExportUsageHandler streams every caller's metered API calls over ?window
+// (default 30d) as CSV, one row per caller, day and class
+func (h *Handler) ExportUsageHandler(w http.ResponseWriter, r *http.Request) {
+	_, from, ok := usageWindow(w, r)
+	if !ok {
+		return
+	}
+	days, err := db.APIUsageSince(h.reader(r), "", from)
+	if err != nil {
+		errorResponse(w, "Error loading usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="api-usage.csv"`)
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	out.Write([]string{"day", "principal", "class", "count"})
+	for _, day := range days {
+		out.Write([]string{day.Day.Format(time.DateOnly), day.Principal, day.Class, strconv.FormatInt(day.Count, 10)})
+	}
+	out.Flush()
+}
+
+// usageWindow reads ?window, writing a 400 and returning false if it's invalid
+func usageWindow(w http.ResponseWriter, r *http.Request) (string, time.Time, bool) {
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = defaultUsageWindow
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return "", time.Time{}, false
+	}
+	return windowParam, time.Now().Add(-window), true
+}
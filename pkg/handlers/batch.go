@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// maxBatchSize bounds how many services a single batch get may ask for
+const maxBatchSize = 100
+
+// BatchGetServicesHandler returns several services by ID in one query, so
+// gateways resolving their configured services don't need a request each
+func (h *Handler) BatchGetServicesHandler(w http.ResponseWriter, r *http.Request) {
+	var request types.BatchGetRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if len(request.IDs) == 0 || len(request.IDs) > maxBatchSize {
+		validationResponse(w, []types.FieldError{{Field: "ids", Code: codeInvalid,
+			Message: fmt.Sprintf("Between 1 and %d IDs are required", maxBatchSize)}})
+		return
+	}
+
+	// Services the caller may not see are reported missing
+	filter := serviceFilter{ids: request.IDs}
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+	var services []types.MCPService
+	if err := filter.apply(db.Preload(h.conn(r)), h.conn(r)).Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	byID := make(map[string]types.MCPService, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+
+	response := types.BatchGetResponse{Services: []types.ServiceResponse{}, Missing: []string{}}
+	for _, id := range request.IDs {
+		service, ok := byID[id]
+		if !ok {
+			response.Missing = append(response.Missing, id)
+			continue
+		}
+		response.Services = append(response.Services, h.toResponse(service))
+		h.Usage.Record(id)
+	}
+	if err := h.presentServices(r, response.Services); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// IndexHeader carries the registry's change index on read responses
+const IndexHeader = "X-Registry-Index"
+
+// Blocking query limits, following Consul's defaults
+const (
+	defaultBlockingWait  = 5 * time.Minute
+	maxBlockingWait      = 10 * time.Minute
+	blockingPollInterval = time.Second
+)
+
+// registryState returns the catalog state a read is served at and sets
+// X-Registry-Index. With ?index=N it's a blocking query: the request is held
+// until the change index passes N or ?wait (default 5m, at most 10m) runs
+// out, so caches can long-poll instead of re-listing on a timer. It writes an
+// error response and returns false if it fails.
+func (h *Handler) registryState(w http.ResponseWriter, r *http.Request) (types.RegistryState, bool) {
+	query := r.URL.Query()
+	var state types.RegistryState
+	var err error
+
+	if param := query.Get("index"); param != "" {
+		index, parseErr := strconv.ParseInt(param, 10, 64)
+		if parseErr != nil || index < 0 {
+			errorResponse(w, "Invalid index", http.StatusBadRequest)
+			return state, false
+		}
+		wait := defaultBlockingWait
+		if param := query.Get("wait"); param != "" {
+			if wait, parseErr = time.ParseDuration(param); parseErr != nil || wait <= 0 {
+				errorResponse(w, "Invalid wait", http.StatusBadRequest)
+				return state, false
+			}
+			wait = min(wait, maxBlockingWait)
+		}
+		resume := h.holdOpen()
+		state, err = db.WaitForChange(r.Context(), h.reader(r), index, wait, blockingPollInterval)
+		resume()
+	} else {
+		state, err = db.State(h.reader(r))
+	}
+	if err != nil {
+		errorResponse(w, "Error reading registry state", http.StatusInternalServerError)
+		return state, false
+	}
+
+	w.Header().Set(IndexHeader, strconv.FormatInt(state.Index, 10))
+	return state, true
+}
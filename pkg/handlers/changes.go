@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ListChangesHandler returns the registration changes after ?since, oldest
+// first, for mirrors and caches that sync incrementally. Callers pass the
+// returned next value as ?since to continue from where they left off. Once
+// older changes are deleted, callers that fell behind get a 410.
+func (h *Handler) ListChangesHandler(w http.ResponseWriter, r *http.Request) {
+	var since uint64
+	if param := r.URL.Query().Get("since"); param != "" {
+		var err error
+		if since, err = strconv.ParseUint(param, 10, 64); err != nil {
+			errorResponse(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := defaultPageSize
+	if param := r.URL.Query().Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n <= 0 || n > maxPageSize {
+			errorResponse(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// Followers behind the retention window have missed changes for good
+	if since > 0 && h.Config.ChangeRetention > 0 {
+		oldest, err := db.OldestChange(h.reader(r))
+		if err != nil {
+			errorResponse(w, "Error finding changes", http.StatusInternalServerError)
+			return
+		}
+		if oldest > since+1 {
+			errorCodeResponse(w, CodeChangesExpired, "Changes after since are no longer kept, resync from a full list", http.StatusGone,
+				map[string]uint64{"oldest": oldest})
+			return
+		}
+	}
+
+	changes, err := db.Changes(h.reader(r), since, limit)
+	if err != nil {
+		errorResponse(w, "Error finding changes", http.StatusInternalServerError)
+		return
+	}
+
+	next := since
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Seq
+	}
+	jsonResponse(w, types.ChangesResponse{Changes: changes, Next: next}, http.StatusOK)
+}
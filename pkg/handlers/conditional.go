@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// notModified sets Last-Modified and, if the request's If-Modified-Since is
+// at or after it, writes a 304 and returns true
+func notModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	// HTTP dates only have second precision
+	modified = modified.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// DashboardHandler returns what a UI home page needs in one call: service
+// counts by status and state, the newest registrations, the services that
+// most recently went stale, the top categories and a summary of events over
+// ?window (default 24h). ?limit caps each list. The newest and stale lists
+// only hold services the caller may see.
+func (h *Handler) DashboardHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(r)
+	if !ok {
+		errorResponse(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "24h"
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var filter serviceFilter
+	err = h.scopeFilter(r, &filter)
+	var dashboard types.DashboardResponse
+	if err == nil {
+		key := "dashboard:" + strconv.Itoa(limit) + ":" + windowParam + ":" + filter.scopeKey()
+		err = h.Cache.Fetch(key, &dashboard, func() (err error) {
+			dashboard, err = h.dashboard(h.reader(r), filter, limit, window, windowParam)
+			return err
+		})
+	}
+	if err == nil {
+		err = h.presentServices(r, dashboard.Recent)
+	}
+	if err == nil {
+		err = h.presentServices(r, dashboard.Stale)
+	}
+	if err != nil {
+		errorResponse(w, "Error building dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, dashboard, http.StatusOK)
+}
+
+func (h *Handler) dashboard(conn *gorm.DB, filter serviceFilter, limit int, window time.Duration, windowParam string) (types.DashboardResponse, error) {
+	now := time.Now()
+	dashboard := types.DashboardResponse{
+		Recent: []types.ServiceResponse{},
+		Stale:  []types.ServiceResponse{},
+		Events: types.DashboardEvents{Window: windowParam, Recent: []types.EventResponse{}},
+	}
+
+	var err error
+	if dashboard.ByStatus, err = db.CountServicesBy(conn, "status"); err != nil {
+		return dashboard, err
+	}
+	if dashboard.ByState, err = db.CountServicesBy(conn, "state"); err != nil {
+		return dashboard, err
+	}
+	for _, count := range dashboard.ByState {
+		dashboard.Total += count
+	}
+
+	var recent []types.MCPService
+	if err := db.Preload(conn).Scopes(filter.scope).Where("state = ?", types.StatePublished).
+		Order("created_at DESC, id").Limit(limit).Find(&recent).Error; err != nil {
+		return dashboard, err
+	}
+	for _, service := range recent {
+		dashboard.Recent = append(dashboard.Recent, h.toResponse(service))
+	}
+
+	var stale []types.MCPService
+	if err := db.Preload(conn).Scopes(filter.scope).Where("last_seen < ?", now.Add(-h.Config.ServiceTTL)).
+		Order("last_seen DESC, id").Limit(limit).Find(&stale).Error; err != nil {
+		return dashboard, err
+	}
+	for _, service := range stale {
+		dashboard.Stale = append(dashboard.Stale, h.toResponse(service))
+	}
+
+	categories, err := db.CategorySummaries(conn)
+	if err != nil {
+		return dashboard, err
+	}
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Count > categories[j].Count })
+	dashboard.TopCategories = []types.CategorySummary{}
+	for _, category := range categories {
+		if category.Count == 0 || len(dashboard.TopCategories) == limit {
+			break
+		}
+		dashboard.TopCategories = append(dashboard.TopCategories, category)
+	}
+
+	// Only admins, who see every team, see events about API keys
+	if dashboard.Events.ByType, err = db.CountEventsSince(conn, now.Add(-window), filter.allTeams); err != nil {
+		return dashboard, err
+	}
+	events, err := db.RecentEvents(conn, limit, filter.allTeams)
+	if err != nil {
+		return dashboard, err
+	}
+	for _, event := range events {
+		dashboard.Events.Recent = append(dashboard.Events.Recent, toEventResponse(event))
+	}
+	return dashboard, nil
+}
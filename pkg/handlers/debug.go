@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/arnavsurve/gateway-registry/pkg/config"
+)
+
+// startTime is when the process started, for uptime in /debug/vars
+var startTime = time.Now()
+
+// DebugVars is a snapshot of the process's runtime state
+type DebugVars struct {
+	Uptime     string         `json:"uptime"`
+	GoVersion  string         `json:"go_version"`
+	Goroutines int            `json:"goroutines"`
+	CPUs       int            `json:"cpus"`
+	Memory     DebugMemory    `json:"memory"`
+	GC         DebugGC        `json:"gc"`
+	DBPool     *DebugDBPool   `json:"db_pool,omitempty"`
+	Config     *config.Config `json:"config"`
+	ReadOnly   bool           `json:"read_only"`
+}
+
+// DebugMemory reports heap usage in bytes
+type DebugMemory struct {
+	Alloc      uint64 `json:"alloc"`
+	TotalAlloc uint64 `json:"total_alloc"`
+	Sys        uint64 `json:"sys"`
+	HeapInuse  uint64 `json:"heap_inuse"`
+	HeapIdle   uint64 `json:"heap_idle"`
+	HeapObjs   uint64 `json:"heap_objects"`
+}
+
+// DebugGC reports garbage collector activity
+type DebugGC struct {
+	NumGC        uint32    `json:"num_gc"`
+	PauseTotalMs float64   `json:"pause_total_ms"`
+	LastPauseMs  float64   `json:"last_pause_ms"`
+	LastGC       time.Time `json:"last_gc"`
+	NextGCBytes  uint64    `json:"next_gc_bytes"`
+}
+
+// DebugDBPool reports the primary database connection pool
+type DebugDBPool struct {
+	Open         int    `json:"open"`
+	InUse        int    `json:"in_use"`
+	Idle         int    `json:"idle"`
+	WaitCount    int64  `json:"wait_count"`
+	WaitDuration string `json:"wait_duration"`
+}
+
+// DebugVarsHandler reports goroutine, memory, GC and connection pool stats
+// along with the redacted configuration, for diagnosing a running registry
+func (h *Handler) DebugVarsHandler(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	vars := DebugVars{
+		Uptime:     time.Since(startTime).Round(time.Second).String(),
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		CPUs:       runtime.NumCPU(),
+		Memory: DebugMemory{
+			Alloc:      mem.Alloc,
+			TotalAlloc: mem.TotalAlloc,
+			Sys:        mem.Sys,
+			HeapInuse:  mem.HeapInuse,
+			HeapIdle:   mem.HeapIdle,
+			HeapObjs:   mem.HeapObjects,
+		},
+		GC: DebugGC{
+			NumGC:        mem.NumGC,
+			PauseTotalMs: float64(mem.PauseTotalNs) / 1e6,
+			LastPauseMs:  float64(mem.PauseNs[(mem.NumGC+255)%256]) / 1e6,
+			LastGC:       time.Unix(0, int64(mem.LastGC)),
+			NextGCBytes:  mem.NextGC,
+		},
+		Config:   h.redactedConfig(),
+		ReadOnly: h.readOnly.Load(),
+	}
+	if sqlDB, err := h.DB.DB(); err == nil {
+		stats := sqlDB.Stats()
+		vars.DBPool = &DebugDBPool{
+			Open:         stats.OpenConnections,
+			InUse:        stats.InUse,
+			Idle:         stats.Idle,
+			WaitCount:    stats.WaitCount,
+			WaitDuration: stats.WaitDuration.String(),
+		}
+	}
+
+	jsonResponse(w, vars, http.StatusOK)
+}
+
+// RegisterPprof serves the net/http/pprof profiles under /debug/pprof,
+// wrapped so only admins can reach them
+func (h *Handler) RegisterPprof(router *mux.Router) {
+	debug := router.PathPrefix("/debug/pprof").Subrouter()
+	debug.HandleFunc("/cmdline", h.Admin(pprof.Cmdline))
+	debug.HandleFunc("/profile", h.Admin(pprof.Profile))
+	debug.HandleFunc("/symbol", h.Admin(pprof.Symbol))
+	debug.HandleFunc("/trace", h.Admin(pprof.Trace))
+	// Index also serves the named profiles, such as /debug/pprof/heap
+	debug.PathPrefix("/").HandlerFunc(h.Admin(pprof.Index))
+}
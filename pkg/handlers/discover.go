@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Discovery response formats
+const (
+	DiscoverFormatJSON = "json"
+	DiscoverFormatLLM  = "llm" // Plain text meant to be pasted into a model's context
+)
+
+// DiscoverHandler finds published services matching ?q= and returns them
+// with their tools' names, descriptions and input schemas. With
+// ?format=llm they're rendered as compact plain text an agent framework can
+// hand straight to a model.
+func (h *Handler) DiscoverHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		errorResponse(w, "Query parameter 'q' is required", http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = DiscoverFormatJSON
+	}
+	if format != DiscoverFormatJSON && format != DiscoverFormatLLM {
+		errorResponse(w, "Format must be json or llm", http.StatusBadRequest)
+		return
+	}
+	limit, ok := parseLimit(r)
+	if !ok {
+		errorResponse(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	state, ok := h.registryState(w, r)
+	if !ok {
+		return
+	}
+	if notModified(w, r, state.UpdatedAt) {
+		return
+	}
+
+	// Deprecated services are left out so agents don't start depending on them
+	filter := serviceFilter{search: query, state: types.StatePublished, excludeDeprecated: true}
+	services, err := h.findServices(r, filter, state.UpdatedAt)
+	if err != nil {
+		errorResponse(w, "Error searching for services", http.StatusInternalServerError)
+		return
+	}
+	if len(services) > limit {
+		services = services[:limit]
+	}
+
+	audience := h.audience(r)
+	discovered := make([]types.DiscoveredService, 0, len(services))
+	for _, service := range services {
+		discovered = append(discovered, types.DiscoveredService{
+			ID:          service.ID,
+			Name:        service.Name,
+			Description: h.Policy.Description(audience, service.Description),
+			URL:         service.URL,
+			Tools:       serviceTools(service),
+		})
+		h.Usage.Record(service.ID)
+	}
+
+	if format == DiscoverFormatLLM {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(renderDiscovery(query, discovered)))
+		return
+	}
+	jsonResponse(w, map[string]any{"query": query, "services": discovered}, http.StatusOK)
+}
+
+// serviceTools returns a service's declared tool definitions, or just the
+// names of its enabled capabilities if it didn't declare any
+func serviceTools(service types.MCPService) []types.Tool {
+	if len(service.Tools) > 0 {
+		return service.Tools
+	}
+	tools := []types.Tool{}
+	for _, capability := range service.Capabilities {
+		if capability.Enabled {
+			tools = append(tools, types.Tool{Name: capability.Name})
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// renderDiscovery writes discovered services as terse Markdown, one section
+// per service with a line per tool and its input schema inline
+func renderDiscovery(query string, services []types.DiscoveredService) string {
+	var b strings.Builder
+	if len(services) == 0 {
+		fmt.Fprintf(&b, "No MCP services match %q.\n", query)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "MCP services matching %q:\n", query)
+	for _, service := range services {
+		fmt.Fprintf(&b, "\n## %s\n", service.Name)
+		if service.Description != "" {
+			fmt.Fprintf(&b, "%s\n", oneLine(service.Description))
+		}
+		fmt.Fprintf(&b, "id: %s\nurl: %s\n", service.ID, service.URL)
+		if len(service.Tools) == 0 {
+			b.WriteString("tools: none declared\n")
+			continue
+		}
+		b.WriteString("tools:\n")
+		for _, tool := range service.Tools {
+			fmt.Fprintf(&b, "- %s", tool.Name)
+			if tool.Description != "" {
+				fmt.Fprintf(&b, ": %s", oneLine(tool.Description))
+			}
+			b.WriteString("\n")
+			if len(tool.InputSchema) > 0 {
+				fmt.Fprintf(&b, "  input: %s\n", compactJSON(tool.InputSchema))
+			}
+		}
+	}
+	return b.String()
+}
+
+// oneLine collapses whitespace so a description can't break the layout
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// compactJSON strips insignificant whitespace from a schema to save tokens
+func compactJSON(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// How much each signal counts towards a match's score, and the similarity a
+// signal needs before it counts at all
+const (
+	hostWeight        = 0.35
+	nameWeight        = 0.35
+	toolWeight        = 0.30
+	minNameSimilarity = 0.75
+	minToolOverlap    = 0.5
+
+	defaultMinDuplicateScore = 0.5
+)
+
+// nameNoise are words that say nothing about which server a name refers to,
+// so "Slack MCP Server" and "slack" compare equal
+var nameNoise = map[string]bool{"mcp": true, "server": true, "service": true, "api": true}
+
+// DuplicateReportHandler looks for services that are probably the same server
+// registered more than once, even when their names and URLs differ. Each pair
+// is scored on sharing a URL host, having similar names and declaring
+// overlapping tools; pairs scoring at least ?min_score (0.5 by default) are
+// grouped, and each group comes with a suggestion of which service to keep.
+func (h *Handler) DuplicateReportHandler(w http.ResponseWriter, r *http.Request) {
+	minScore := defaultMinDuplicateScore
+	if param := r.URL.Query().Get("min_score"); param != "" {
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil || value <= 0 || value > 1 {
+			errorResponse(w, "Invalid min_score, must be above 0 and at most 1", http.StatusBadRequest)
+			return
+		}
+		minScore = value
+	}
+
+	var services []types.MCPService
+	if err := db.Preload(h.conn(r)).Where("state <> ?", types.StateRejected).
+		Order("created_at, id").Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	fingerprints := make([]duplicateFingerprint, len(services))
+	for i, service := range services {
+		fingerprints[i] = fingerprint(service)
+	}
+
+	// Link matching pairs, then group services by the links between them
+	parent := make([]int, len(services))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+	type pair struct {
+		a, b  int
+		match types.DuplicateMatch
+	}
+	var pairs []pair
+	for i := range fingerprints {
+		for j := i + 1; j < len(fingerprints); j++ {
+			match, ok := compareServices(fingerprints[i], fingerprints[j], minScore)
+			if !ok {
+				continue
+			}
+			match.ServiceIDs = [2]string{services[i].ID, services[j].ID}
+			pairs = append(pairs, pair{i, j, match})
+			parent[root(i)] = root(j)
+		}
+	}
+
+	members := map[int][]int{}
+	matches := map[int][]types.DuplicateMatch{}
+	for _, p := range pairs {
+		matches[root(p.a)] = append(matches[root(p.a)], p.match)
+	}
+	for i := range services {
+		if group := root(i); len(matches[group]) > 0 {
+			members[group] = append(members[group], i)
+		}
+	}
+
+	report := types.DuplicateReport{GeneratedAt: time.Now().UTC(), MinScore: minScore, Groups: []types.NearDuplicateGroup{}}
+	for group, indexes := range members {
+		sort.SliceStable(indexes, func(a, b int) bool {
+			return keepRank(services[indexes[a]], services[indexes[b]])
+		})
+		sort.Slice(matches[group], func(a, b int) bool { return matches[group][a].Score > matches[group][b].Score })
+
+		result := types.NearDuplicateGroup{Matches: matches[group]}
+		for _, i := range indexes {
+			result.Services = append(result.Services, h.toResponse(services[i]))
+		}
+		result.Suggestion = suggestMerge(services, fingerprints, indexes)
+		report.Groups = append(report.Groups, result)
+	}
+	// Most certain groups first
+	sort.Slice(report.Groups, func(a, b int) bool {
+		sa, sb := report.Groups[a].Matches[0].Score, report.Groups[b].Matches[0].Score
+		if sa != sb {
+			return sa > sb
+		}
+		return report.Groups[a].Suggestion.Keep < report.Groups[b].Suggestion.Keep
+	})
+
+	jsonResponse(w, report, http.StatusOK)
+}
+
+// duplicateFingerprint is what services are compared on
+type duplicateFingerprint struct {
+	host  string
+	name  []rune
+	tools map[string]bool
+}
+
+func fingerprint(service types.MCPService) duplicateFingerprint {
+	f := duplicateFingerprint{name: []rune(nameStem(service.Name)), tools: map[string]bool{}}
+	if u, err := url.Parse(strings.TrimSpace(service.URL)); err == nil {
+		f.host = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	}
+	for _, tool := range service.Tools {
+		f.tools[strings.ToLower(tool.Name)] = true
+	}
+	for _, capability := range service.Capabilities {
+		if capability.Enabled {
+			f.tools[strings.ToLower(capability.Name)] = true
+		}
+	}
+	return f
+}
+
+// nameStem reduces a name to its distinguishing words, lowercased and joined
+// without separators
+func nameStem(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if !nameNoise[word] {
+			b.WriteString(word)
+		}
+	}
+	// A name made only of noise words is still a name
+	if b.Len() == 0 {
+		return normalizeName(name)
+	}
+	return b.String()
+}
+
+// compareServices scores a pair of services, reporting whether the score
+// reaches minScore
+func compareServices(a, b duplicateFingerprint, minScore float64) (types.DuplicateMatch, bool) {
+	var match types.DuplicateMatch
+	if a.host != "" && a.host == b.host {
+		match.Score += hostWeight
+		match.Signals = append(match.Signals, "same_host")
+	}
+	match.NameSimilarity = round(similarity(a.name, b.name))
+	if match.NameSimilarity >= minNameSimilarity {
+		match.Score += nameWeight * match.NameSimilarity
+		match.Signals = append(match.Signals, "similar_name")
+	}
+	match.ToolOverlap = round(overlap(a.tools, b.tools))
+	if match.ToolOverlap >= minToolOverlap {
+		match.Score += toolWeight * match.ToolOverlap
+		match.Signals = append(match.Signals, "overlapping_tools")
+	}
+	match.Score = round(match.Score)
+	return match, match.Score >= minScore
+}
+
+// similarity is one minus the edit distance between two names over the
+// length of the longer one
+func similarity(a, b []rune) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 0
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// overlap is the Jaccard index of two tool sets, 0 when either is empty
+func overlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for tool := range a {
+		if b[tool] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func round(value float64) float64 {
+	return float64(int(value*100+0.5)) / 100
+}
+
+// keepRank orders the services of a group by how good a candidate each is to
+// keep: local before federated, published, verified, healthy, with more
+// tools and reviews, then registered first
+func keepRank(a, b types.MCPService) bool {
+	for _, preferred := range [][2]bool{
+		{a.Origin == "", b.Origin == ""},
+		{a.State == types.StatePublished, b.State == types.StatePublished},
+		{a.Verified, b.Verified},
+		{a.Status == types.StatusHealthy, b.Status == types.StatusHealthy},
+	} {
+		if preferred[0] != preferred[1] {
+			return preferred[0]
+		}
+	}
+	if len(a.Tools) != len(b.Tools) {
+		return len(a.Tools) > len(b.Tools)
+	}
+	if a.RatingCount != b.RatingCount {
+		return a.RatingCount > b.RatingCount
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+// suggestMerge proposes keeping the best ranked service of a group, given in
+// rank order, and carrying the others' names and tools over to it
+func suggestMerge(services []types.MCPService, fingerprints []duplicateFingerprint, indexes []int) types.MergeSuggestion {
+	keep := services[indexes[0]]
+	suggestion := types.MergeSuggestion{Keep: keep.ID, Merge: []string{}}
+
+	var reasons []string
+	if keep.State == types.StatePublished {
+		reasons = append(reasons, "published")
+	}
+	if keep.Verified {
+		reasons = append(reasons, "verified")
+	}
+	if keep.Status == types.StatusHealthy {
+		reasons = append(reasons, "healthy")
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "registered first")
+	}
+	suggestion.Reason = "Keep the " + strings.Join(reasons, ", ") + " service and deprecate the others with it as their replacement"
+
+	names := map[string]bool{strings.ToLower(keep.Name): true}
+	for _, alias := range keep.Aliases {
+		names[strings.ToLower(alias.Name)] = true
+	}
+	keepTools := fingerprints[indexes[0]].tools
+	for _, i := range indexes[1:] {
+		merged := services[i]
+		suggestion.Merge = append(suggestion.Merge, merged.ID)
+		if !names[strings.ToLower(merged.Name)] {
+			names[strings.ToLower(merged.Name)] = true
+			suggestion.AddAliases = append(suggestion.AddAliases, merged.Name)
+		}
+		for _, tool := range merged.Tools {
+			if !keepTools[strings.ToLower(tool.Name)] && !slices.Contains(suggestion.AddTools, tool.Name) {
+				suggestion.AddTools = append(suggestion.AddTools, tool.Name)
+			}
+		}
+	}
+	return suggestion
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Machine-readable error codes, so clients can branch on the kind of failure
+// instead of matching messages
+const (
+	CodeBadRequest           = "BAD_REQUEST"
+	CodeValidationFailed     = "VALIDATION_FAILED"
+	CodeInvalidServiceID     = "INVALID_SERVICE_ID"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeInvalidSignature     = "INVALID_SIGNATURE"
+	CodeForbidden            = "FORBIDDEN"
+	CodeReadOnly             = "REGISTRY_READ_ONLY"
+	CodeServiceReadOnly      = "SERVICE_READ_ONLY"
+	CodeNotFound             = "NOT_FOUND"
+	CodeServiceNotFound      = "SERVICE_NOT_FOUND"
+	CodeGroupNotFound        = "GROUP_NOT_FOUND"
+	CodeOrganizationNotFound = "ORGANIZATION_NOT_FOUND"
+	CodeTeamNotFound         = "TEAM_NOT_FOUND"
+	CodeRevisionNotFound     = "REVISION_NOT_FOUND"
+	CodeChangesExpired       = "CHANGES_EXPIRED"
+	CodeNoOpenAPIDocument    = "NO_OPENAPI_DOCUMENT"
+	CodeNoHealthyEndpoint    = "NO_HEALTHY_ENDPOINT"
+	CodeConflict             = "CONFLICT"
+	CodeDuplicateService     = "DUPLICATE_SERVICE"
+	CodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeQuotaExceeded        = "QUOTA_EXCEEDED"
+	CodeInternal             = "INTERNAL_ERROR"
+	CodeUpstreamUnavailable  = "UPSTREAM_UNAVAILABLE"
+	CodeUpstreamTimeout      = "UPSTREAM_TIMEOUT"
+	CodeServiceUnavailable   = "SERVICE_UNAVAILABLE"
+	CodeDatabaseUnavailable  = "DATABASE_UNAVAILABLE"
+	CodeOverloaded           = "OVERLOADED"
+)
+
+// RequestIDHeader carries the ID that errors report as request_id
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID keeps caller supplied IDs short and free of anything that
+// could forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestID is middleware tagging every request with the caller's request ID,
+// or a generated one if it didn't send a usable one. The ID is echoed on the
+// response, put on the request context for logs and error envelopes, and
+// kept on the request headers so proxied calls pass it on.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+		r.Header.Set(RequestIDHeader, id)
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// RequestIDFromContext returns the ID RequestID assigned to a request, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// logf logs a message about a request, tagged with its request ID
+func logf(r *http.Request, format string, args ...any) {
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		format = "[" + id + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// errorResponse writes an error whose code is derived from the HTTP status
+func errorResponse(w http.ResponseWriter, message string, status int) {
+	errorCodeResponse(w, statusCode(status), message, status, nil)
+}
+
+// errorCodeResponse writes the standard error envelope
+func errorCodeResponse(w http.ResponseWriter, code, message string, status int, details any) {
+	jsonResponse(w, types.ErrorResponse{Error: types.ErrorBody{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: w.Header().Get(RequestIDHeader),
+	}}, status)
+}
+
+// statusCode is the generic error code for an HTTP status
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusBadGateway:
+		return CodeUpstreamUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeUpstreamTimeout
+	case http.StatusServiceUnavailable:
+		return CodeServiceUnavailable
+	default:
+		return CodeInternal
+	}
+}
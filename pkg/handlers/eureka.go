@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// The Eureka shim maps Eureka applications onto service names and instances
+// onto services in the default namespace, so Eureka clients can register,
+// renew and query during a migration. Only the JSON representation is
+// supported. Instance IDs map to stable registry IDs and are kept in the
+// eureka_instance_id metadata key.
+
+// eurekaNamespace derives registry IDs from Eureka app and instance IDs
+var eurekaNamespace = uuid.MustParse("6f1c9e2a-4d3b-4a7e-b5c8-0e9f8d7a6b54")
+
+// eurekaInstanceKey is the metadata key holding the Eureka instance ID
+const eurekaInstanceKey = "eureka_instance_id"
+
+// Eureka instance statuses
+const (
+	eurekaUp           = "UP"
+	eurekaDown         = "DOWN"
+	eurekaOutOfService = "OUT_OF_SERVICE"
+)
+
+// eurekaValue accepts the strings, numbers and booleans Eureka clients use interchangeably
+type eurekaValue string
+
+func (v *eurekaValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = eurekaValue(s)
+		return nil
+	}
+	*v = eurekaValue(strings.Trim(string(data), `"`))
+	return nil
+}
+
+type eurekaPort struct {
+	Port    eurekaValue `json:"$"`
+	Enabled eurekaValue `json:"@enabled"`
+}
+
+type eurekaInstance struct {
+	InstanceID     string            `json:"instanceId"`
+	HostName       string            `json:"hostName"`
+	App            string            `json:"app"`
+	IPAddr         string            `json:"ipAddr"`
+	Status         string            `json:"status"`
+	Port           eurekaPort        `json:"port"`
+	SecurePort     eurekaPort        `json:"securePort"`
+	HomePageURL    string            `json:"homePageUrl,omitempty"`
+	StatusPageURL  string            `json:"statusPageUrl,omitempty"`
+	HealthCheckURL string            `json:"healthCheckUrl,omitempty"`
+	VIPAddress     string            `json:"vipAddress,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	LastUpdated    int64             `json:"lastUpdatedTimestamp,omitempty"`
+	DataCenterInfo map[string]string `json:"dataCenterInfo,omitempty"`
+}
+
+type eurekaApplication struct {
+	Name     string           `json:"name"`
+	Instance []eurekaInstance `json:"instance"`
+}
+
+// EurekaRegisterHandler registers or re-registers an instance of an application
+func (h *Handler) EurekaRegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Instance eurekaInstance `json:"instance"`
+	}
+	if !decodeJSON(w, r, &body) {
+		return
+	}
+	instance := body.Instance
+	app := mux.Vars(r)["app"]
+	if instance.InstanceID == "" {
+		instance.InstanceID = instance.HostName
+	}
+	if instance.InstanceID == "" {
+		errorResponse(w, "Instance ID or host name is required", http.StatusBadRequest)
+		return
+	}
+
+	request := types.ServiceRegistrationRequest{
+		Name:         strings.ToLower(app),
+		Description:  instance.VIPAddress,
+		URL:          eurekaURL(instance),
+		Capabilities: map[string]bool{},
+		Categories:   []string{},
+		Metadata:     map[string]string{eurekaInstanceKey: instance.InstanceID},
+		ApiDocs:      instance.StatusPageURL,
+	}
+	for key, value := range instance.Metadata {
+		request.Metadata[key] = value
+	}
+	if errs := h.normalizeRegistration(r, &request); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+
+	serviceID := eurekaServiceID(app, instance.InstanceID)
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		var service types.MCPService
+		err := tx.First(&service, "id = ?", serviceID).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := h.checkRegistration(tx, request, ""); err != nil {
+				return err
+			}
+			if err := db.CreateService(tx, serviceID, request, time.Now()); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			if err := h.checkRegistration(tx, request, serviceID); err != nil {
+				return err
+			}
+			if err := db.UpdateService(tx, &service, request, time.Now()); err != nil {
+				return err
+			}
+		}
+		return tx.Model(&types.MCPService{}).Where("id = ?", serviceID).
+			Update("status", eurekaStatus(instance.Status)).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+			conflictResponse(w, existingID)
+			return
+		}
+		if rejectionResponse(w, err) {
+			return
+		}
+		errorResponse(w, "Failed to register instance", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EurekaRenewHandler renews an instance's lease, answering 404 so the client
+// re-registers if the instance is unknown
+func (h *Handler) EurekaRenewHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := h.eurekaInstance(w, r)
+	if !ok {
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeOwner(w, r, service) || !h.authorizeHeartbeat(w, r, service) {
+		return
+	}
+	if err := h.renewLease(r, service.ID); err != nil {
+		errorResponse(w, "Failed to renew lease", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// EurekaStatusHandler overrides an instance's status, e.g. to take it out of service
+func (h *Handler) EurekaStatusHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := h.eurekaInstance(w, r)
+	if !ok {
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeEdit(w, r, service) {
+		return
+	}
+	status := eurekaStatus(r.URL.Query().Get("value"))
+	if err := h.conn(r).Model(&types.MCPService{}).Where("id = ?", service.ID).Update("status", status).Error; err != nil {
+		errorResponse(w, "Failed to update status", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// EurekaCancelHandler deregisters an instance
+func (h *Handler) EurekaCancelHandler(w http.ResponseWriter, r *http.Request) {
+	service, ok := h.eurekaInstance(w, r)
+	if !ok {
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeEdit(w, r, service) {
+		return
+	}
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		return db.DeleteService(tx, service.ID)
+	})
+	if err != nil {
+		errorResponse(w, "Failed to cancel instance", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// EurekaAppsHandler lists every application registered through the shim
+func (h *Handler) EurekaAppsHandler(w http.ResponseWriter, r *http.Request) {
+	apps, err := h.eurekaApps(r, "")
+	if err != nil {
+		errorResponse(w, "Error finding applications", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]any{"applications": map[string]any{
+		"versions__delta": "1",
+		"apps__hashcode":  "",
+		"application":     apps,
+	}}, http.StatusOK)
+}
+
+// EurekaAppHandler returns one application with its instances
+func (h *Handler) EurekaAppHandler(w http.ResponseWriter, r *http.Request) {
+	apps, err := h.eurekaApps(r, mux.Vars(r)["app"])
+	if err != nil {
+		errorResponse(w, "Error finding application", http.StatusInternalServerError)
+		return
+	}
+	if len(apps) == 0 {
+		errorResponse(w, "Application not found", http.StatusNotFound)
+		return
+	}
+
+	jsonResponse(w, map[string]any{"application": apps[0]}, http.StatusOK)
+}
+
+// EurekaInstanceHandler returns one instance of an application
+func (h *Handler) EurekaInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID, ok := h.eurekaInstanceID(w, r)
+	if !ok {
+		return
+	}
+	var service types.MCPService
+	if err := db.Preload(h.conn(r)).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Error finding instance", http.StatusInternalServerError)
+		return
+	}
+	if visible, err := h.canSee(r, service.TeamID, service.Visibility); err != nil || !visible {
+		errorResponse(w, "Instance not found", http.StatusNotFound)
+		return
+	}
+
+	jsonResponse(w, map[string]any{"instance": h.toEurekaInstance(service, h.audience(r))}, http.StatusOK)
+}
+
+// eurekaInstanceID resolves the app and instance in the path to an existing
+// service ID, writing a 404 if there isn't one
+func (h *Handler) eurekaInstanceID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	vars := mux.Vars(r)
+	serviceID := eurekaServiceID(vars["app"], vars["instance"])
+
+	var ids []string
+	if err := h.conn(r).Model(&types.MCPService{}).Where("id = ?", serviceID).Limit(1).Pluck("id", &ids).Error; err != nil {
+		errorResponse(w, "Error finding instance", http.StatusInternalServerError)
+		return "", false
+	}
+	if len(ids) == 0 {
+		errorResponse(w, "Instance not found", http.StatusNotFound)
+		return "", false
+	}
+	return serviceID, true
+}
+
+// eurekaInstance loads the service the app and instance in the path name,
+// writing a 404 if there isn't one
+func (h *Handler) eurekaInstance(w http.ResponseWriter, r *http.Request) (types.MCPService, bool) {
+	vars := mux.Vars(r)
+	var service types.MCPService
+	err := h.conn(r).First(&service, "id = ?", eurekaServiceID(vars["app"], vars["instance"])).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorResponse(w, "Instance not found", http.StatusNotFound)
+		return service, false
+	}
+	if err != nil {
+		errorResponse(w, "Error finding instance", http.StatusInternalServerError)
+		return service, false
+	}
+	return service, true
+}
+
+// eurekaApps groups the services registered through the shim that the
+// caller may see by application, optionally only the named one
+func (h *Handler) eurekaApps(r *http.Request, app string) ([]eurekaApplication, error) {
+	var filter serviceFilter
+	if err := h.scopeFilter(r, &filter); err != nil {
+		return nil, err
+	}
+	registered := h.conn(r).Model(&types.MetadataItem{}).Select("service_id").Where("key = ?", eurekaInstanceKey)
+	query := db.Preload(h.conn(r)).Where("id IN (?)", registered).Scopes(filter.scope).Order("name, id")
+	if app != "" {
+		query = query.Where("name = ?", strings.ToLower(app))
+	}
+	var services []types.MCPService
+	if err := query.Find(&services).Error; err != nil {
+		return nil, err
+	}
+
+	audience := h.audience(r)
+	apps := []eurekaApplication{}
+	for _, service := range services {
+		name := strings.ToUpper(service.Name)
+		if n := len(apps); n == 0 || apps[n-1].Name != name {
+			apps = append(apps, eurekaApplication{Name: name})
+		}
+		apps[len(apps)-1].Instance = append(apps[len(apps)-1].Instance, h.toEurekaInstance(service, audience))
+	}
+	return apps, nil
+}
+
+// toEurekaInstance converts a service for a caller in the given response
+// policy audience
+func (h *Handler) toEurekaInstance(service types.MCPService, audience string) eurekaInstance {
+	instance := eurekaInstance{
+		App:           strings.ToUpper(service.Name),
+		HomePageURL:   service.URL,
+		StatusPageURL: service.ApiDocs,
+		VIPAddress:    service.Description,
+		Metadata:      map[string]string{},
+		LastUpdated:   service.UpdatedAt.UnixMilli(),
+		Status:        eurekaUp,
+		DataCenterInfo: map[string]string{
+			"@class": "com.netflix.appinfo.InstanceInfo$DefaultDataCenterInfo",
+			"name":   "MyOwn",
+		},
+	}
+	for _, item := range service.Metadata {
+		if item.Key == eurekaInstanceKey {
+			instance.InstanceID = item.Value
+			continue
+		}
+		instance.Metadata[item.Key] = item.Value
+	}
+	redactSealed(instance.Metadata)
+	h.Policy.ApplyMetadata(audience, instance.Metadata)
+	instance.VIPAddress = h.Policy.Description(audience, instance.VIPAddress)
+	if service.Status != types.StatusHealthy || service.LastSeen.Before(time.Now().Add(-h.Config.ServiceTTL)) {
+		instance.Status = eurekaDown
+	}
+
+	if u, err := url.Parse(service.URL); err == nil {
+		instance.HostName, instance.IPAddr = u.Hostname(), u.Hostname()
+		port := u.Port()
+		if u.Scheme == "https" {
+			if port == "" {
+				port = "443"
+			}
+			instance.SecurePort = eurekaPort{Port: eurekaValue(port), Enabled: "true"}
+			instance.Port = eurekaPort{Port: "80", Enabled: "false"}
+		} else {
+			if port == "" {
+				port = "80"
+			}
+			instance.Port = eurekaPort{Port: eurekaValue(port), Enabled: "true"}
+			instance.SecurePort = eurekaPort{Port: "443", Enabled: "false"}
+		}
+	}
+	return instance
+}
+
+// eurekaURL picks the URL an instance is reached at
+func eurekaURL(instance eurekaInstance) string {
+	if instance.HomePageURL != "" {
+		return instance.HomePageURL
+	}
+	host := instance.HostName
+	if host == "" {
+		host = instance.IPAddr
+	}
+	if instance.SecurePort.Enabled == "true" {
+		return "https://" + net.JoinHostPort(host, string(instance.SecurePort.Port)) + "/"
+	}
+	port := string(instance.Port.Port)
+	if _, err := strconv.Atoi(port); err != nil {
+		port = "80"
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+func eurekaServiceID(app, instanceID string) string {
+	return uuid.NewSHA1(eurekaNamespace, []byte(strings.ToLower(app)+"/"+instanceID)).String()
+}
+
+// eurekaStatus maps a Eureka instance status onto a service status
+func eurekaStatus(status string) string {
+	if status == "" || status == eurekaUp {
+		return types.StatusHealthy
+	}
+	return types.StatusDegraded
+}
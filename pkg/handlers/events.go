@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ListEventsHandler returns the lifecycle events after ?since, oldest first,
+// optionally narrowed to one ?service or ?type. ?since=latest starts after
+// the newest event. With ?wait the request is held until an event arrives or
+// the wait runs out, so followers can long-poll the feed. Events about API
+// keys are only listed to admins.
+func (h *Handler) ListEventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var since uint
+	switch param := query.Get("since"); param {
+	case "":
+	case "latest":
+		latest, err := db.LatestEventID(h.reader(r))
+		if err != nil {
+			errorResponse(w, "Error finding events", http.StatusInternalServerError)
+			return
+		}
+		since = latest
+	default:
+		n, err := strconv.ParseUint(param, 10, 0)
+		if err != nil {
+			errorResponse(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = uint(n)
+	}
+
+	limit := defaultPageSize
+	if param := query.Get("limit"); param != "" {
+		n, err := strconv.Atoi(param)
+		if err != nil || n <= 0 || n > maxPageSize {
+			errorResponse(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var wait time.Duration
+	if param := query.Get("wait"); param != "" {
+		var err error
+		if wait, err = time.ParseDuration(param); err != nil || wait <= 0 {
+			errorResponse(w, "Invalid wait", http.StatusBadRequest)
+			return
+		}
+		wait = min(wait, maxBlockingWait)
+	}
+
+	principal, _ := h.authenticate(r)
+	if wait > 0 {
+		defer h.holdOpen()()
+	}
+	deadline := time.Now().Add(wait)
+	var events []types.Event
+	for {
+		var err error
+		events, err = db.ListEvents(h.reader(r), since, query.Get("service"), query.Get("type"), limit, principal.Admin)
+		if err != nil {
+			errorResponse(w, "Error finding events", http.StatusInternalServerError)
+			return
+		}
+		if len(events) > 0 || !time.Now().Before(deadline) {
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(blockingPollInterval):
+		}
+	}
+
+	response := types.EventsResponse{Events: make([]types.EventResponse, len(events)), Next: since}
+	for i, event := range events {
+		response.Events[i] = toEventResponse(event)
+		response.Next = event.ID
+	}
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// toEventResponse converts an event for the API, inlining its JSON data
+func toEventResponse(event types.Event) types.EventResponse {
+	data := json.RawMessage(event.Data)
+	if event.Data == "" {
+		data = json.RawMessage("null")
+	}
+	return types.EventResponse{
+		ID:        event.ID,
+		Type:      event.Type,
+		ServiceID: event.ServiceID,
+		Data:      data,
+		CreatedAt: event.CreatedAt,
+	}
+}
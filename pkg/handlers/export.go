@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ExportHandler returns a snapshot of every service with its associations,
+// as JSON or as YAML with ?format=yaml
+func (h *Handler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := db.ExportSnapshot(h.conn(r))
+	if err != nil {
+		errorResponse(w, "Error exporting services", http.StatusInternalServerError)
+		return
+	}
+
+	// Team services are only exported to those who can see them
+	var filter serviceFilter
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error exporting services", http.StatusInternalServerError)
+		return
+	}
+	snapshot.Services = slices.DeleteFunc(snapshot.Services, func(service types.ServiceResponse) bool {
+		return !filter.visible(service.TeamID, service.Visibility)
+	})
+	for _, service := range snapshot.Services {
+		redactSealed(service.Metadata)
+	}
+	if err := h.presentServices(r, snapshot.Services); err != nil {
+		errorResponse(w, "Error exporting services", http.StatusInternalServerError)
+		return
+	}
+
+	exportResponse(w, r, snapshot)
+}
+
+// ExportServiceHandler returns the fields of a single service that are set
+// through registration, in the shape PUT /services/{id} accepts, for
+// infrastructure-as-code tools to read back and diff. {id} may also be the
+// service's external ID within ?namespace. JSON, or YAML with ?format=yaml.
+func (h *Handler) ExportServiceHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var service types.MCPService
+	err := db.Preload(h.reader(r)).First(&service, "id = ?", serviceID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		namespace := r.URL.Query().Get("namespace")
+		if namespace == "" {
+			namespace = types.DefaultNamespace
+		}
+		service, err = db.FindByExternalID(db.Preload(h.reader(r)), namespace, serviceID)
+	}
+	if err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if visible, err := h.canSee(r, service.TeamID, service.Visibility); err != nil || !visible {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+
+	// Redacted values can be applied back unchanged, see db.UpdateService
+	responses := []types.ServiceResponse{types.ServiceModelToResponse(service)}
+	redactSealed(responses[0].Metadata)
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error exporting service", http.StatusInternalServerError)
+		return
+	}
+	exportResponse(w, r, types.ServiceExport{
+		ID:                         responses[0].ID,
+		ServiceRegistrationRequest: types.ServiceResponseToRegistration(responses[0]),
+	})
+}
+
+// exportResponse writes v as JSON, or as YAML with ?format=yaml
+func exportResponse(w http.ResponseWriter, r *http.Request, v any) {
+	if r.URL.Query().Get("format") != "yaml" {
+		jsonResponse(w, v, http.StatusOK)
+		return
+	}
+
+	// Go through JSON so the YAML keys match the API field names
+	data, err := json.Marshal(v)
+	if err == nil {
+		data, err = jsonToYAML(data)
+	}
+	if err != nil {
+		errorResponse(w, "Error encoding export", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// importSnapshot loads a snapshot posted to /import, merging by default.
+// Each service is checked like a registration and only its registration
+// fields are taken; moderation state, verification, ownership, provenance
+// and ratings stay as the registry has them, or start fresh for new services.
+func (h *Handler) importSnapshot(w http.ResponseWriter, r *http.Request, data []byte) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = db.ImportMerge
+	}
+	if mode != db.ImportMerge && mode != db.ImportReplace {
+		errorResponse(w, "Query parameter 'mode' must be merge or replace", http.StatusBadRequest)
+		return
+	}
+
+	var snapshot types.Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		errorResponse(w, "Invalid snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if snapshot.Version > types.SnapshotVersion {
+		errorResponse(w, fmt.Sprintf("Unsupported snapshot version %d", snapshot.Version), http.StatusBadRequest)
+		return
+	}
+
+	requests := make([]types.ServiceRegistrationRequest, len(snapshot.Services))
+	var errs []types.FieldError
+	for i, service := range snapshot.Services {
+		field := fmt.Sprintf("services[%d]", i)
+		if service.ID == "" {
+			errs = append(errs, types.FieldError{Field: field + ".id", Code: codeRequired, Message: "ID is required"})
+			continue
+		}
+		requests[i] = types.ServiceResponseToRegistration(service)
+		for _, err := range h.normalizeRegistration(r, &requests[i]) {
+			err.Field = field + "." + err.Field
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+
+	result := types.SnapshotImportResult{Mode: mode}
+	now := time.Now()
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if mode == db.ImportReplace {
+			deleted, err := db.DeleteAllServices(tx)
+			if err != nil {
+				return err
+			}
+			result.Deleted = deleted
+		}
+		for i, request := range requests {
+			serviceID := snapshot.Services[i].ID
+			if err := h.checkRegistration(tx, request, serviceID); err != nil {
+				var aliasErr *aliasError
+				if errors.As(err, &aliasErr) {
+					for j := range aliasErr.errors {
+						aliasErr.errors[j].Field = fmt.Sprintf("services[%d].%s", i, aliasErr.errors[j].Field)
+					}
+				}
+				return err
+			}
+			var existing types.MCPService
+			err := tx.First(&existing, "id = ?", serviceID).Error
+			switch {
+			case err == nil:
+				err = db.UpdateService(tx, &existing, request, now)
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				request.State = h.initialState()
+				err = db.CreateService(tx, serviceID, request, now)
+			}
+			if err != nil {
+				return fmt.Errorf("importing service %s: %w", serviceID, err)
+			}
+			result.Imported++
+		}
+		return nil
+	})
+	if err != nil {
+		if rejectionResponse(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorCodeResponse(w, CodeDuplicateService, "Failed to import snapshot: "+err.Error(), http.StatusConflict, nil)
+			return
+		}
+		errorResponse(w, "Failed to import snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, result, http.StatusOK)
+}
+
+func isYAML(contentType, format string) bool {
+	return format == "yaml" || strings.Contains(contentType, "yaml")
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func jsonToYAML(data []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
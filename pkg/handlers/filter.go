@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/base64"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// serviceFilter holds the query parameters shared by list and search, so they
+// can be applied either to a database query or to the in-memory snapshot
+type serviceFilter struct {
+	search            string
+	state             string
+	verified          string
+	excludeDeprecated bool
+	ids               []string
+	origin            string
+	category          string
+	protocolVersion   string    // Only services declaring this MCP revision
+	team              string    // Only services owned by this team
+	since             time.Time // Zero unless a delta token was given
+	sort              string
+
+	// Services with team visibility are left out unless they belong to one
+	// of teams, or allTeams is set for admins. See scopeFilter.
+	teams    []string
+	allTeams bool
+
+	// Keyset pagination over (created_at, id). limit is 0 when unpaginated.
+	after *pageCursor
+	limit int
+}
+
+// Page sizes for keyset pagination of the service list
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// pageCursor is the position of the last service on a page
+type pageCursor struct {
+	createdAt time.Time
+	id        string
+}
+
+func (c pageCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.createdAt.UTC().Format(time.RFC3339Nano) + "," + c.id))
+}
+
+func parseCursor(value string) (*pageCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	createdAt, id, ok := strings.Cut(string(data), ",")
+	if !ok || id == "" {
+		return nil, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, false
+	}
+	return &pageCursor{createdAt: t, id: id}, true
+}
+
+// parseServiceFilter reads the filters from the request, returning a message
+// describing the first invalid one
+func parseServiceFilter(r *http.Request) (serviceFilter, string) {
+	query := r.URL.Query()
+	filter := serviceFilter{
+		search:            query.Get("q"),
+		verified:          query.Get("verified"),
+		excludeDeprecated: excludeDeprecated(r),
+		origin:            query.Get("origin"),
+		category:          query.Get("category"),
+		protocolVersion:   query.Get("protocol_version"),
+		team:              query.Get("team_id"),
+		sort:              query.Get("sort"),
+	}
+	if filter.protocolVersion != "" && !validProtocolVersion(filter.protocolVersion) {
+		return filter, "Invalid protocol version"
+	}
+
+	if _, ok := orderBy(filter.sort); !ok {
+		return filter, "Invalid sort field"
+	}
+	state, ok := stateFilter(r)
+	if !ok {
+		return filter, "Invalid state"
+	}
+	filter.state = state
+	if ids := query.Get("ids"); ids != "" {
+		filter.ids = strings.Split(ids, ",")
+	}
+
+	// A delta token is the time of a previous listing; only services that
+	// changed or heartbeated since then are returned
+	if token := query.Get("delta_token"); token != "" {
+		since, err := time.Parse(time.RFC3339Nano, token)
+		if err != nil {
+			return filter, "Invalid delta token"
+		}
+		filter.since = since
+	}
+
+	// Pages are only stable in the default (created_at, id) order, which
+	// registrations can only append to
+	after, limit := query.Get("after"), query.Get("limit")
+	if after != "" || limit != "" {
+		if filter.sort != "" {
+			return filter, "Pagination can't be combined with sort"
+		}
+		if wantsNDJSON(r) {
+			return filter, "Pagination can't be combined with NDJSON streaming"
+		}
+		filter.limit = defaultPageSize
+		if limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 || n > maxPageSize {
+				return filter, "Invalid limit"
+			}
+			filter.limit = n
+		}
+		if after != "" {
+			cursor, ok := parseCursor(after)
+			if !ok {
+				return filter, "Invalid cursor"
+			}
+			filter.after = cursor
+		}
+	}
+	return filter, ""
+}
+
+// nextCursor returns the cursor for the page after services, or an empty
+// string if this was the last page
+func (f serviceFilter) nextCursor(services []types.MCPService) string {
+	if f.limit == 0 || len(services) < f.limit {
+		return ""
+	}
+	last := services[len(services)-1]
+	return pageCursor{createdAt: last.CreatedAt, id: last.ID}.String()
+}
+
+// findServices returns the services matching the filter, from the snapshot
+// when it's available at the catalog version modified and from the database
+// otherwise
+func (h *Handler) findServices(r *http.Request, filter serviceFilter, modified time.Time) ([]types.MCPService, error) {
+	if err := h.scopeFilter(r, &filter); err != nil {
+		return nil, err
+	}
+	if services, ok := h.snapshotServices(filter, modified); ok {
+		return services, nil
+	}
+
+	var services []types.MCPService
+	err := filter.query(db.Preload(h.reader(r)), h.reader(r)).Find(&services).Error
+	return services, err
+}
+
+// snapshotServices filters and sorts the in-memory snapshot, reporting false
+// if it isn't available
+func (h *Handler) snapshotServices(filter serviceFilter, modified time.Time) ([]types.MCPService, bool) {
+	snapshot, ok := h.Catalog.Services(modified)
+	if !ok {
+		return nil, false
+	}
+
+	var services []types.MCPService
+	for _, service := range snapshot {
+		if filter.match(service) {
+			services = append(services, service)
+		}
+	}
+	sortServices(services, filter.sort)
+	if filter.limit > 0 && len(services) > filter.limit {
+		services = services[:filter.limit]
+	}
+	return services, true
+}
+
+// query applies the filter, order and page size to a services query
+func (f serviceFilter) query(query, conn *gorm.DB) *gorm.DB {
+	order, _ := orderBy(f.sort)
+	query = f.apply(query, conn).Order(order)
+	if f.limit > 0 {
+		query = query.Limit(f.limit)
+	}
+	return query
+}
+
+// apply adds the filter's conditions to a query
+func (f serviceFilter) apply(query, conn *gorm.DB) *gorm.DB {
+	if f.search != "" {
+		// Escaped so the search matches literally, as matchesSearch does
+		pattern := "%" + db.EscapeLike(f.search) + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ? OR id IN (?)", pattern, pattern,
+			conn.Model(&types.ServiceAlias{}).Select("service_id").Where("name ILIKE ?", pattern))
+	}
+	if f.state != "" {
+		query = query.Where("state = ?", f.state)
+	}
+	if f.verified != "" {
+		query = query.Where("verified = ?", f.verified == "true")
+	}
+	if f.excludeDeprecated {
+		query = query.Where("deprecated = ?", false)
+	}
+	if f.ids != nil {
+		query = query.Where("id IN ?", f.ids)
+	}
+
+	switch f.origin {
+	case "":
+	case "local":
+		query = query.Where("origin = ''")
+	default:
+		query = query.Where("origin = ?", f.origin)
+	}
+
+	if !f.since.IsZero() {
+		query = query.Where("updated_at >= ? OR last_seen >= ?", f.since, f.since)
+	}
+	if f.after != nil {
+		query = query.Where("(created_at, id) > (?, ?)", f.after.createdAt, f.after.id)
+	}
+	if f.category != "" {
+		query = query.Where("id IN (?)", conn.Model(&types.Category{}).Select("service_id").Where("name = ?", f.category))
+	}
+	if f.protocolVersion != "" {
+		query = query.Where("protocol_versions @> ?::jsonb", types.StringList{f.protocolVersion})
+	}
+	if f.team != "" {
+		query = query.Where("team_id = ?", f.team)
+	}
+	return f.scope(query)
+}
+
+// scope leaves the team services the filter's caller may not see out of a
+// services query
+func (f serviceFilter) scope(query *gorm.DB) *gorm.DB {
+	switch {
+	case f.allTeams:
+	case len(f.teams) == 0:
+		query = query.Where("visibility <> ?", types.VisibilityTeam)
+	default:
+		query = query.Where("visibility <> ? OR team_id IN ?", types.VisibilityTeam, f.teams)
+	}
+	return query
+}
+
+// scopeKey identifies the services the filter's caller may see, for caching
+// results that depend on it
+func (f serviceFilter) scopeKey() string {
+	switch {
+	case f.allTeams:
+		return "all"
+	case len(f.teams) == 0:
+		return "public"
+	}
+	teams := slices.Clone(f.teams)
+	slices.Sort(teams)
+	return "teams=" + strings.Join(teams, ",")
+}
+
+// match reports whether a service passes the filter, mirroring apply
+func (f serviceFilter) match(service types.MCPService) bool {
+	if f.search != "" && !matchesSearch(service, strings.ToLower(f.search)) {
+		return false
+	}
+	if f.state != "" && service.State != f.state {
+		return false
+	}
+	if f.verified != "" && service.Verified != (f.verified == "true") {
+		return false
+	}
+	if f.excludeDeprecated && service.Deprecated {
+		return false
+	}
+	if f.ids != nil && !slices.Contains(f.ids, service.ID) {
+		return false
+	}
+
+	switch f.origin {
+	case "":
+	case "local":
+		if service.Origin != "" {
+			return false
+		}
+	default:
+		if service.Origin != f.origin {
+			return false
+		}
+	}
+
+	if !f.since.IsZero() && service.UpdatedAt.Before(f.since) && service.LastSeen.Before(f.since) {
+		return false
+	}
+	if f.after != nil {
+		if c := service.CreatedAt.Compare(f.after.createdAt); c < 0 || c == 0 && service.ID <= f.after.id {
+			return false
+		}
+	}
+	if f.category != "" && !slices.ContainsFunc(service.Categories, func(c types.Category) bool { return c.Name == f.category }) {
+		return false
+	}
+	if f.protocolVersion != "" && !slices.Contains(service.ProtocolVersions, f.protocolVersion) {
+		return false
+	}
+	if f.team != "" && service.TeamID != f.team {
+		return false
+	}
+	return f.visible(service.TeamID, service.Visibility)
+}
+
+// visible reports whether the filter's caller may see a service owned by
+// teamID with the given visibility
+func (f serviceFilter) visible(teamID, visibility string) bool {
+	return f.allTeams || visibility != types.VisibilityTeam || slices.Contains(f.teams, teamID)
+}
+
+func matchesSearch(service types.MCPService, search string) bool {
+	if strings.Contains(strings.ToLower(service.Name), search) ||
+		strings.Contains(strings.ToLower(service.Description), search) {
+		return true
+	}
+	return slices.ContainsFunc(service.Aliases, func(a types.ServiceAlias) bool {
+		return strings.Contains(strings.ToLower(a.Name), search)
+	})
+}
+
+// sortServices orders services in memory the way orderBy orders them in SQL
+func sortServices(services []types.MCPService, sort string) {
+	field, descending := strings.CutPrefix(sort, "-")
+	slices.SortStableFunc(services, func(a, b types.MCPService) int {
+		var c int
+		switch field {
+		case "":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "name":
+			c = cmp.Compare(a.Name, b.Name)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "last_seen":
+			c = a.LastSeen.Compare(b.LastSeen)
+		case "latency_p50":
+			c = compareNullable(a.LatencyP50Ms, b.LatencyP50Ms, descending)
+		case "latency_p95":
+			c = compareNullable(a.LatencyP95Ms, b.LatencyP95Ms, descending)
+		case "rating":
+			c = compareNullable(a.RatingAvg, b.RatingAvg, descending)
+		}
+		if descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+}
+
+// compareNullable compares two optional values with nulls last regardless of
+// direction. The result is pre-negated for nulls when descending so the
+// caller's reversal leaves them last.
+func compareNullable(a, b *float64, descending bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil || b == nil:
+		last := 1
+		if b == nil {
+			last = -1
+		}
+		if descending {
+			last = -last
+		}
+		return last
+	}
+	return cmp.Compare(*a, *b)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// fleetThresholds are the optional limits that flip the fleet to degraded
+type fleetThresholds struct {
+	maxStaleRatio    float64 // Fraction of live services that may be stale, negative if unset
+	maxDegradedRatio float64 // Fraction of live services that may be degraded, negative if unset
+	minHealthy       int64   // Fewest healthy services allowed
+}
+
+// HealthSummaryHandler reports how many published services are healthy,
+// degraded, stale and archived, in total and by namespace and category.
+// With ?max_stale_ratio, ?max_degraded_ratio or ?min_healthy the overall
+// status becomes degraded, and the response a 503, when one is breached, so
+// alerting integrations can poll it directly.
+func (h *Handler) HealthSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	thresholds, msg := parseFleetThresholds(r)
+	if msg != "" {
+		errorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var summary types.HealthSummaryResponse
+	err := h.Cache.Fetch("health-summary", &summary, func() (err error) {
+		summary, err = h.healthSummary(h.reader(r), time.Now())
+		return err
+	})
+	if err != nil {
+		errorResponse(w, "Error summarizing fleet health", http.StatusInternalServerError)
+		return
+	}
+
+	summary.Status, summary.Breaches = types.FleetStatusOK, thresholds.breaches(summary.Totals)
+	if len(summary.Breaches) > 0 {
+		summary.Status = types.FleetStatusDegraded
+		jsonResponse(w, summary, http.StatusServiceUnavailable)
+		return
+	}
+	jsonResponse(w, summary, http.StatusOK)
+}
+
+func (h *Handler) healthSummary(conn *gorm.DB, now time.Time) (types.HealthSummaryResponse, error) {
+	summary := types.HealthSummaryResponse{
+		ByNamespace: map[string]types.FleetCounts{},
+		ByCategory:  map[string]types.FleetCounts{},
+	}
+	staleBefore := now.Add(-h.Config.ServiceTTL)
+
+	namespaces, err := db.FleetByNamespace(conn, staleBefore)
+	if err != nil {
+		return summary, err
+	}
+	for _, row := range namespaces {
+		summary.Totals.Add(row.Class, row.Count)
+		addFleetCount(summary.ByNamespace, row.Key, row.Class, row.Count)
+	}
+	categories, err := db.FleetByCategory(conn, staleBefore)
+	if err != nil {
+		return summary, err
+	}
+	for _, row := range categories {
+		addFleetCount(summary.ByCategory, row.Key, row.Class, row.Count)
+	}
+
+	archived, err := db.ArchivedByNamespace(conn)
+	if err != nil {
+		return summary, err
+	}
+	for namespace, count := range archived {
+		summary.Totals.Add(types.FleetArchived, count)
+		addFleetCount(summary.ByNamespace, namespace, types.FleetArchived, count)
+	}
+	if archived, err = db.ArchivedByCategory(conn); err != nil {
+		return summary, err
+	}
+	for category, count := range archived {
+		addFleetCount(summary.ByCategory, category, types.FleetArchived, count)
+	}
+	return summary, nil
+}
+
+func addFleetCount(groups map[string]types.FleetCounts, key, class string, n int64) {
+	counts := groups[key]
+	counts.Add(class, n)
+	groups[key] = counts
+}
+
+func parseFleetThresholds(r *http.Request) (fleetThresholds, string) {
+	thresholds := fleetThresholds{maxStaleRatio: -1, maxDegradedRatio: -1}
+	query := r.URL.Query()
+	for name, ratio := range map[string]*float64{
+		"max_stale_ratio":    &thresholds.maxStaleRatio,
+		"max_degraded_ratio": &thresholds.maxDegradedRatio,
+	} {
+		param := query.Get(name)
+		if param == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(param, 64)
+		if err != nil || value < 0 || value > 1 {
+			return thresholds, "Invalid " + name + ", must be between 0 and 1"
+		}
+		*ratio = value
+	}
+	if param := query.Get("min_healthy"); param != "" {
+		value, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || value < 0 {
+			return thresholds, "Invalid min_healthy"
+		}
+		thresholds.minHealthy = value
+	}
+	return thresholds, ""
+}
+
+// breaches describes every threshold the totals are past
+func (t fleetThresholds) breaches(totals types.FleetCounts) []string {
+	breaches := []string{}
+	live := totals.Healthy + totals.Degraded + totals.Stale
+	if live > 0 {
+		if ratio := float64(totals.Stale) / float64(live); t.maxStaleRatio >= 0 && ratio > t.maxStaleRatio {
+			breaches = append(breaches, fmt.Sprintf("stale ratio %.2f exceeds %.2f", ratio, t.maxStaleRatio))
+		}
+		if ratio := float64(totals.Degraded) / float64(live); t.maxDegradedRatio >= 0 && ratio > t.maxDegradedRatio {
+			breaches = append(breaches, fmt.Sprintf("degraded ratio %.2f exceeds %.2f", ratio, t.maxDegradedRatio))
+		}
+	}
+	if totals.Healthy < t.minHealthy {
+		breaches = append(breaches, fmt.Sprintf("%d healthy services is below %d", totals.Healthy, t.minHealthy))
+	}
+	return breaches
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+func (h *Handler) ListGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	var groups []types.ServiceGroup
+	if err := h.conn(r).Preload("Members").Preload("Metadata").Order("name").Find(&groups).Error; err != nil {
+		errorResponse(w, "Error finding groups", http.StatusInternalServerError)
+		return
+	}
+
+	var members []string
+	for _, group := range groups {
+		for _, member := range group.Members {
+			members = append(members, member.ServiceID)
+		}
+	}
+	hidden, err := h.hiddenServices(r, members)
+	if err != nil {
+		errorResponse(w, "Error finding groups", http.StatusInternalServerError)
+		return
+	}
+
+	responses := []types.ServiceGroupResponse{}
+	for _, group := range groups {
+		response := types.GroupModelToResponse(group)
+		response.ServiceIDs = slices.DeleteFunc(response.ServiceIDs, func(id string) bool { return hidden[id] })
+		responses = append(responses, response)
+	}
+
+	jsonResponse(w, responses, http.StatusOK)
+}
+
+// GetGroupHandler returns a group with its member services expanded. Members
+// that are currently not registered are left out, as are team services the
+// caller may not see.
+func (h *Handler) GetGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var group types.ServiceGroup
+	if err := h.conn(r).Preload("Members").Preload("Metadata").First(&group, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeGroupNotFound, "Group not found", http.StatusNotFound, nil)
+		return
+	}
+
+	response := types.GroupModelToResponse(group)
+	hidden, err := h.hiddenServices(r, response.ServiceIDs)
+	if err != nil {
+		errorResponse(w, "Error finding group services", http.StatusInternalServerError)
+		return
+	}
+	response.ServiceIDs = slices.DeleteFunc(response.ServiceIDs, func(id string) bool { return hidden[id] })
+	response.Services = []types.ServiceResponse{}
+	if len(response.ServiceIDs) > 0 {
+		var services []types.MCPService
+		if err := db.Preload(h.conn(r)).
+			Where("id IN ?", response.ServiceIDs).Order("name").Find(&services).Error; err != nil {
+			errorResponse(w, "Error finding group services", http.StatusInternalServerError)
+			return
+		}
+		for _, service := range services {
+			response.Services = append(response.Services, h.toResponse(service))
+		}
+		if err := h.presentServices(r, response.Services); err != nil {
+			errorResponse(w, "Error finding group services", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+func (h *Handler) CreateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var request types.ServiceGroupRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if msg := h.validateGroup(r, request); msg != "" {
+		errorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	group := types.ServiceGroup{ID: uuid.New().String(), Owner: h.groupOwner(r)}
+	if err := h.saveGroup(r, &group, request); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorResponse(w, "A group with this name already exists", http.StatusConflict)
+			return
+		}
+		errorResponse(w, "Failed to create group", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondWithGroup(w, r, group.ID, http.StatusCreated)
+}
+
+func (h *Handler) UpdateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	var group types.ServiceGroup
+	if err := h.conn(r).First(&group, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeGroupNotFound, "Group not found", http.StatusNotFound, nil)
+		return
+	}
+	if !h.authorizeGroup(w, r, group) {
+		return
+	}
+
+	var request types.ServiceGroupRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if msg := h.validateGroup(r, request); msg != "" {
+		errorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.saveGroup(r, &group, request); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorResponse(w, "A group with this name already exists", http.StatusConflict)
+			return
+		}
+		errorResponse(w, "Failed to update group", http.StatusInternalServerError)
+		return
+	}
+
+	h.respondWithGroup(w, r, group.ID, http.StatusOK)
+}
+
+func (h *Handler) DeleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	groupID := mux.Vars(r)["id"]
+
+	var group types.ServiceGroup
+	if err := h.conn(r).First(&group, "id = ?", groupID).Error; err != nil {
+		errorCodeResponse(w, CodeGroupNotFound, "Group not found", http.StatusNotFound, nil)
+		return
+	}
+	if !h.authorizeGroup(w, r, group) {
+		return
+	}
+
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", groupID).Delete(&types.ServiceGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", groupID).Delete(&types.GroupMetadataItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+	if err != nil {
+		errorResponse(w, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"message": "Group deleted"}, http.StatusOK)
+}
+
+// groupOwner is who a group created by the request belongs to: its client
+// certificate identity, or else the principal it authenticated as, empty for
+// anonymous requests
+func (h *Handler) groupOwner(r *http.Request) string {
+	if identity, ok := auth.ClientIdentity(r); ok {
+		return identity
+	}
+	if principal, ok := h.authenticate(r); ok {
+		return principal.Name
+	}
+	return ""
+}
+
+// authorizeGroup is authorizeOwner for groups: it writes a 403 and returns
+// false unless the group has no owner, the request comes from its owner, or
+// the caller is an admin
+func (h *Handler) authorizeGroup(w http.ResponseWriter, r *http.Request, group types.ServiceGroup) bool {
+	if group.Owner == "" {
+		return true
+	}
+	if identity, ok := auth.ClientIdentity(r); ok && identity == group.Owner {
+		return true
+	}
+	if principal, ok := h.authenticate(r); ok && (principal.Admin || principal.Name == group.Owner) {
+		return true
+	}
+	errorResponse(w, "Group is owned by another client", http.StatusForbidden)
+	return false
+}
+
+// validateGroup returns a message describing what's wrong with a group request
+func (h *Handler) validateGroup(r *http.Request, request types.ServiceGroupRequest) string {
+	if request.Name == "" {
+		return "Missing required fields"
+	}
+	if len(request.ServiceIDs) == 0 {
+		return ""
+	}
+
+	var found []string
+	if err := h.conn(r).Model(&types.MCPService{}).Where("id IN ?", request.ServiceIDs).Pluck("id", &found).Error; err != nil {
+		return "Failed to look up services"
+	}
+	known := make(map[string]bool, len(found))
+	for _, id := range found {
+		known[id] = true
+	}
+	var unknown []string
+	for _, id := range request.ServiceIDs {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return "Unknown services: " + strings.Join(unknown, ", ")
+	}
+	return ""
+}
+
+// saveGroup writes a group's fields and replaces its members and metadata
+func (h *Handler) saveGroup(r *http.Request, group *types.ServiceGroup, request types.ServiceGroupRequest) error {
+	return h.conn(r).Transaction(func(tx *gorm.DB) error {
+		group.Name = request.Name
+		group.Description = request.Description
+		if err := tx.Save(group).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("group_id = ?", group.ID).Delete(&types.ServiceGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", group.ID).Delete(&types.GroupMetadataItem{}).Error; err != nil {
+			return err
+		}
+
+		seen := make(map[string]bool)
+		for _, serviceID := range request.ServiceIDs {
+			if seen[serviceID] {
+				continue
+			}
+			seen[serviceID] = true
+			if err := tx.Create(&types.ServiceGroupMember{GroupID: group.ID, ServiceID: serviceID}).Error; err != nil {
+				return err
+			}
+		}
+		for key, value := range request.Metadata {
+			if err := tx.Create(&types.GroupMetadataItem{GroupID: group.ID, Key: key, Value: value}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (h *Handler) respondWithGroup(w http.ResponseWriter, r *http.Request, groupID string, code int) {
+	var group types.ServiceGroup
+	if err := h.conn(r).Preload("Members").Preload("Metadata").First(&group, "id = ?", groupID).Error; err != nil {
+		errorResponse(w, "Group saved but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, types.GroupModelToResponse(group), code)
+}
@@ -1,31 +1,236 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/backup"
+	"github.com/arnavsurve/gateway-registry/pkg/cache"
+	"github.com/arnavsurve/gateway-registry/pkg/catalog"
+	"github.com/arnavsurve/gateway-registry/pkg/config"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/extensions"
+	"github.com/arnavsurve/gateway-registry/pkg/netguard"
+	"github.com/arnavsurve/gateway-registry/pkg/oidc"
+	"github.com/arnavsurve/gateway-registry/pkg/provenance"
+	"github.com/arnavsurve/gateway-registry/pkg/redact"
+	"github.com/arnavsurve/gateway-registry/pkg/secrets"
+	"github.com/arnavsurve/gateway-registry/pkg/telemetry"
 	"github.com/arnavsurve/gateway-registry/pkg/types"
+	"github.com/arnavsurve/gateway-registry/pkg/usage"
 	"gorm.io/gorm"
 )
 
 // TODO: refactor handlers into individual files
 
 type Handler struct {
-	DB *gorm.DB
+	DB      *gorm.DB
+	ReadDB  *gorm.DB // Optional read replica for list, search and get, see reader
+	Config  *config.Config
+	Backups backup.Store
+	Usage   *usage.Counter
+	Meter   *usage.Meter       // Meters API calls per caller, optional
+	Guard   *netguard.Guard    // Restricts outbound requests to registered URLs
+	Cache   *cache.Cache       // Optional cache for hot reads
+	Catalog *catalog.Snapshot  // Optional in-memory copy of the services for list and search
+	Metrics *telemetry.Metrics // Optional, exported to an OpenTelemetry collector
+
+	Publishers *provenance.Verifier // Trusted keys for manifest signatures, optional
+	OIDC       *oidc.Provider       // Where people log in, optional
+	Secrets    *secrets.Box         // Opens sensitive metadata for callers allowed to read it, optional
+	Policy     redact.Policy        // What anonymous and read-only callers don't see, optional
+	Extensions extensions.Registry  // Schemas for the extensions registrations may carry, optional
+
+	// Client networks allowed to use the admin API and write routes, anyone
+	// when empty
+	AdminNetworks netguard.Networks
+	WriteNetworks netguard.Networks
+
+	readOnly atomic.Bool
+
+	// Load shedding state, see Shed
+	inFlight     atomic.Int64
+	databaseDown atomic.Bool
+	databaseUp   atomic.Int64 // Unix nanoseconds the database was last found reachable after being down
 }
 
-// Helper functions
-func errorResponse(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	response := map[string]string{"error": message}
-	json.NewEncoder(w).Encode(response)
+// SetReadOnly toggles whether write routes are refused
+func (h *Handler) SetReadOnly(readOnly bool) {
+	h.readOnly.Store(readOnly)
+}
+
+// Writable wraps a handler that modifies the registry so it's refused while
+// the instance is read-only, or from clients outside the write allowlist, or
+// without a client certificate when one is required. Admins are held to the
+// admin allowlist instead and needn't present a certificate.
+//
+// Once API keys are issued, anonymous callers are refused too unless they
+// present a client certificate, or a key without the write scope would
+// restrict nothing: its holder could leave it off.
+func (h *Handler) Writable(next http.HandlerFunc) http.HandlerFunc {
+	return h.writable(next, false)
+}
+
+// Renewal is Writable for routes that only renew a service's lease, which
+// anonymous callers may still use once API keys are issued. Renewals change
+// no registration, and heartbeats are authorized per service by ownership
+// and heartbeat tokens instead.
+func (h *Handler) Renewal(next http.HandlerFunc) http.HandlerFunc {
+	return h.writable(next, true)
+}
+
+func (h *Handler) writable(next http.HandlerFunc, renewal bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.readOnly.Load() {
+			errorCodeResponse(w, CodeReadOnly, "Registry is read-only", http.StatusForbidden, nil)
+			return
+		}
+		principal, authenticated := h.authenticate(r)
+		if !principal.Can(auth.ScopeWrite) {
+			errorResponse(w, "API key lacks the "+auth.ScopeWrite+" scope", http.StatusForbidden)
+			return
+		}
+		if _, identified := auth.ClientIdentity(r); !authenticated && !identified && !renewal {
+			keys, err := db.HasAPIKeys(h.conn(r), time.Now())
+			if err != nil {
+				errorResponse(w, "Error checking API keys", http.StatusInternalServerError)
+				return
+			}
+			if keys {
+				errorResponse(w, "An API key is required to modify the registry", http.StatusUnauthorized)
+				return
+			}
+		}
+		networks := h.WriteNetworks
+		if principal.Admin {
+			networks = h.AdminNetworks
+		}
+		if !allowedClient(r, networks) {
+			errorResponse(w, "Client address not allowed to modify the registry", http.StatusForbidden)
+			return
+		}
+		if _, ok := auth.ClientIdentity(r); h.Config.RequireClientCert && !ok && !principal.Admin {
+			errorResponse(w, "Client certificate required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Admin wraps a handler so it requires the configured admin bearer token,
+// from a client on the admin allowlist
+func (h *Handler) Admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.Config.AdminToken == "" && h.OIDC == nil {
+			errorResponse(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+		// Checked before the token so outsiders can't probe for it
+		if !allowedClient(r, h.AdminNetworks) {
+			errorResponse(w, "Client address not allowed to use the admin API", http.StatusForbidden)
+			return
+		}
+		principal, ok := h.authenticate(r)
+		if !ok || !principal.Admin {
+			errorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// Authenticated wraps a handler so it requires an API key or the admin token.
+// The caller is available to the handler through auth.FromContext.
+func (h *Handler) Authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := h.authenticate(r)
+		if !ok {
+			errorResponse(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// authorizeOwner writes a 403 and returns false unless the request may modify
+// the service: it has no owner, the request's client certificate is the
+// owner's, or the caller is an admin
+func (h *Handler) authorizeOwner(w http.ResponseWriter, r *http.Request, service types.MCPService) bool {
+	if service.Owner == "" {
+		return true
+	}
+	if identity, ok := auth.ClientIdentity(r); ok && identity == service.Owner {
+		return true
+	}
+	if principal, ok := h.authenticate(r); ok && principal.Admin {
+		return true
+	}
+	errorResponse(w, "Service is owned by another client", http.StatusForbidden)
+	return false
+}
+
+// allowedClient reports whether the request comes from one of networks, or
+// whether networks is empty and so allows everyone
+func allowedClient(r *http.Request, networks netguard.Networks) bool {
+	if len(networks) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP(r))
+	return ip != nil && networks.Contains(ip)
+}
+
+// authenticate resolves the request's bearer token, or session cookie, to a
+// principal
+func (h *Handler) authenticate(r *http.Request) (auth.Principal, bool) {
+	token, ok := auth.BearerToken(r)
+	if !ok {
+		if session, ok := h.session(r); ok {
+			return auth.Principal{Name: session.Name, Admin: session.Admin}, true
+		}
+		return auth.Principal{}, false
+	}
+	if h.Config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.Config.AdminToken)) == 1 {
+		return auth.Principal{Name: adminActor, Admin: true}, true
+	}
+	if name, ok := h.Config.APIKeys[token]; ok {
+		return auth.Principal{Name: name}, true
+	}
+	if key, err := db.FindAPIKey(h.conn(r), auth.HashToken(token), time.Now()); err == nil {
+		scopes := append([]string{}, key.Scopes...)
+		return auth.Principal{Name: key.Name, Admin: slices.Contains(scopes, auth.ScopeAdmin), KeyID: key.ID, Scopes: scopes}, true
+	}
+	if session, ok := h.session(r); ok {
+		return auth.Principal{Name: session.Name, Admin: session.Admin}, true
+	}
+	return auth.Principal{}, false
+}
+
+// reader returns the connection for read-only discovery queries, the replica
+// when one is configured
+func (h *Handler) reader(r *http.Request) *gorm.DB {
+	if h.ReadDB != nil {
+		return h.ReadDB.WithContext(r.Context())
+	}
+	return h.conn(r)
 }
 
+// conn returns the primary connection bound to the request's context, so
+// queries are abandoned when the client goes away
+func (h *Handler) conn(r *http.Request) *gorm.DB {
+	return h.DB.WithContext(r.Context())
+}
+
+// Helper functions
 func jsonResponse(w http.ResponseWriter, data any, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -37,58 +242,134 @@ func getServiceID(r *http.Request) string {
 	return vars["id"]
 }
 
+// toResponse converts a service for the API, adding when its lease expires
+func (h *Handler) toResponse(service types.MCPService) types.ServiceResponse {
+	response := types.ServiceModelToResponse(service)
+	redactSealed(response.Metadata)
+	expiresAt := service.LastSeen.Add(h.Config.ServiceTTL)
+	response.ExpiresAt = &expiresAt
+	response.TTLSeconds = int(h.Config.ServiceTTL / time.Second)
+	return response
+}
+
+// rejectFederated writes a 403 and returns true if the service is a read-only
+// copy federated from a peer registry
+func rejectFederated(w http.ResponseWriter, service types.MCPService) bool {
+	if service.Origin == "" {
+		return false
+	}
+	errorCodeResponse(w, CodeServiceReadOnly, "Service is federated from "+service.Origin+" and is read-only", http.StatusForbidden, nil)
+	return true
+}
+
+// sortColumns maps the values accepted by ?sort= to columns. Prefix a value
+// with "-" to sort descending.
+var sortColumns = map[string]string{
+	"name":        "name",
+	"created_at":  "created_at",
+	"last_seen":   "last_seen",
+	"latency_p50": "latency_p50_ms",
+	"latency_p95": "latency_p95_ms",
+	"rating":      "rating_avg",
+}
+
+// orderBy translates a ?sort= value into an ORDER BY clause. Services without
+// a value, such as unprobed latencies, always sort last.
+func orderBy(sort string) (string, bool) {
+	if sort == "" {
+		return "created_at, id", true
+	}
+	direction := "ASC"
+	if field, ok := strings.CutPrefix(sort, "-"); ok {
+		sort, direction = field, "DESC"
+	}
+	column, ok := sortColumns[sort]
+	if !ok {
+		return "", false
+	}
+	return column + " " + direction + " NULLS LAST, id", true
+}
+
 func (h *Handler) ListServicesHandler(w http.ResponseWriter, r *http.Request) {
-	category := r.URL.Query().Get("category")
+	filter, msg := parseServiceFilter(r)
+	if msg != "" {
+		errorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
 
-	var services []types.MCPService
-	query := h.DB.Preload("Capabilities").Preload("Categories").Preload("Metadata")
+	state, ok := h.registryState(w, r)
+	if !ok {
+		return
+	}
+	modified := state.UpdatedAt
+	if notModified(w, r, modified) {
+		return
+	}
 
-	if category != "" {
-		var serviceIDs []string
-		h.DB.Model(&types.Category{}).Where("name = ?", category).Pluck("service_id", &serviceIDs)
+	// The next delta token is always sent back so callers can chain
+	// incremental syncs
+	w.Header().Set("X-Delta-Token", time.Now().UTC().Format(time.RFC3339Nano))
 
-		if len(serviceIDs) > 0 {
-			query = query.Where("id IN ?", serviceIDs)
-		} else {
-			jsonResponse(w, []types.ServiceResponse{}, http.StatusOK)
-			return
-		}
+	if wantsNDJSON(r) {
+		h.streamServices(w, r, filter, modified)
+		return
 	}
 
-	result := query.Find(&services)
-	if result.Error != nil {
+	services, err := h.findServices(r, filter, modified)
+	if err != nil {
 		errorResponse(w, "Error finding services", http.StatusInternalServerError)
 		return
 	}
+	if cursor := filter.nextCursor(services); cursor != "" {
+		w.Header().Set("X-Next-Cursor", cursor)
+	}
 
 	// Convert to response format
 	var responses []types.ServiceResponse
 	for _, service := range services {
-		responses = append(responses, types.ServiceModelToResponse(service))
+		responses = append(responses, h.toResponse(service))
+		h.Usage.Record(service.ID)
+	}
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
 	}
 
 	jsonResponse(w, responses, http.StatusOK)
 }
 
 func (h *Handler) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("upsert") == "true" {
+		h.UpsertServiceHandler(w, r)
+		return
+	}
+
 	var request types.ServiceRegistrationRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		errorResponse(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &request) {
 		return
 	}
 
-	// Validate required fields
-	if request.Name == "" || request.URL == "" ||
-		request.Capabilities == nil || request.Categories == nil {
-		errorResponse(w, "Missing required fields", http.StatusBadRequest)
+	if errs := h.normalizeRegistration(r, &request); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+	if msg := h.checkReachable(r, request.URL); msg != "" {
+		validationResponse(w, []types.FieldError{{Field: "url", Code: codeUnreachable, Message: msg}})
 		return
 	}
 
-	serviceID := uuid.New().String()
-	now := time.Now()
+	existingID, err := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+	if err != nil {
+		errorResponse(w, "Failed to check for duplicates", http.StatusInternalServerError)
+		return
+	}
+	if existingID != "" {
+		conflictResponse(w, existingID)
+		return
+	}
 
 	// Start a transaction
-	tx := h.DB.Begin()
+	tx := h.conn(r).Begin()
 	if tx.Error != nil {
 		errorResponse(w, "Failed to start transaction", http.StatusInternalServerError)
 		return
@@ -101,61 +382,24 @@ func (h *Handler) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	service := types.MCPService{
-		ID:          serviceID,
-		Name:        request.Name,
-		Description: request.Description,
-		URL:         request.URL,
-		LastSeen:    now,
-		ApiDocs:     request.ApiDocs,
+	serviceID, err := h.registerService(r, tx, request)
+	var token string
+	if err == nil {
+		token, err = h.issueHeartbeatToken(tx, serviceID)
 	}
-
-	// Create service in the database
-	if err := tx.Create(&service).Error; err != nil {
+	if err != nil {
 		tx.Rollback()
-		errorResponse(w, "Failed to register service", http.StatusInternalServerError)
-		return
-	}
-
-	// Add capabilities
-	for name, enabled := range request.Capabilities {
-		capability := types.Capability{
-			ServiceID: serviceID,
-			Name:      name,
-			Enabled:   enabled,
-		}
-		if err := tx.Create(&capability).Error; err != nil {
-			tx.Rollback()
-			errorResponse(w, "Failed to add capability", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Add categories
-	for _, name := range request.Categories {
-		category := types.Category{
-			ServiceID: serviceID,
-			Name:      name,
-		}
-		if err := tx.Create(&category).Error; err != nil {
-			tx.Rollback()
-			errorResponse(w, "Failed to add category", http.StatusInternalServerError)
+		if rejectionResponse(w, err) {
 			return
 		}
-	}
-
-	// Add metadata
-	for key, value := range request.Metadata {
-		metadata := types.MetadataItem{
-			ServiceID: serviceID,
-			Key:       key,
-			Value:     value,
-		}
-		if err := tx.Create(&metadata).Error; err != nil {
-			tx.Rollback()
-			errorResponse(w, "Failed to add metadata", http.StatusInternalServerError)
+		// Lost a race with a concurrent registration of the same service
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+			conflictResponse(w, existingID)
 			return
 		}
+		errorResponse(w, "Failed to register service", http.StatusInternalServerError)
+		return
 	}
 
 	// Commit transaction
@@ -166,55 +410,130 @@ func (h *Handler) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Retrieve the full service to return
 	var createdService types.MCPService
-	err := h.DB.Preload("Capabilities").Preload("Categories").Preload("Metadata").First(&createdService, "id = ?", serviceID).Error
+	err = db.Preload(h.conn(r)).First(&createdService, "id = ?", serviceID).Error
 	if err != nil {
 		errorResponse(w, "Service created but failed to retrieve details", http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, types.ServiceModelToResponse(createdService), http.StatusCreated)
+	response := h.toResponse(createdService)
+	response.HeartbeatToken = token
+	jsonResponse(w, response, http.StatusCreated)
 }
 
 func (h *Handler) GetServiceHandler(w http.ResponseWriter, r *http.Request) {
 	serviceID := getServiceID(r)
 	if serviceID == "" {
-		errorResponse(w, "Invalid service ID", http.StatusBadRequest)
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
 		return
 	}
 
-	var service types.MCPService
-	result := h.DB.Preload("Capabilities").Preload("Categories").Preload("Metadata").First(&service, "id = ?", serviceID)
-	if result.Error != nil {
-		errorResponse(w, "Service not found", http.StatusNotFound)
+	if _, ok := h.registryState(w, r); !ok {
 		return
 	}
 
-	jsonResponse(w, types.ServiceModelToResponse(service), http.StatusOK)
+	var service types.ServiceResponse
+	err := h.Cache.Fetch("service:"+serviceID, &service, func() error {
+		var model types.MCPService
+		if err := db.Preload(h.reader(r)).First(&model, "id = ?", serviceID).Error; err != nil {
+			return err
+		}
+		service = h.toResponse(model)
+		return nil
+	})
+	if err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	// Hidden team services look like missing ones
+	visible, err := h.canSee(r, service.TeamID, service.Visibility)
+	if err != nil {
+		errorResponse(w, "Error finding service", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+
+	h.Usage.Record(service.ID)
+	modified := service.UpdatedAt
+	if service.LastSeen.After(modified) {
+		modified = service.LastSeen
+	}
+	if notModified(w, r, modified) {
+		return
+	}
+	responses := []types.ServiceResponse{service}
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error finding service", http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, responses[0], http.StatusOK)
+}
+
+// HeadServiceHandler reports whether a service exists without loading it or writing a body
+func (h *Handler) HeadServiceHandler(w http.ResponseWriter, r *http.Request) {
+	var filter serviceFilter
+	if err := h.scopeFilter(r, &filter); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var ids []string
+	if err := filter.apply(h.conn(r).Model(&types.MCPService{}).Where("id = ?", getServiceID(r)), h.conn(r)).
+		Limit(1).Pluck("id", &ids).Error; err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(ids) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
 	serviceID := getServiceID(r)
 	if serviceID == "" {
-		errorResponse(w, "Invalid service ID", http.StatusBadRequest)
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
 		return
 	}
 
 	// Check if service exists before starting transaction
 	var existingService types.MCPService
-	result := h.DB.First(&existingService, "id = ?", serviceID)
+	result := h.conn(r).First(&existingService, "id = ?", serviceID)
 	if result.Error != nil {
-		errorResponse(w, "Service not found", http.StatusNotFound)
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, existingService) || !h.authorizeEdit(w, r, existingService) {
 		return
 	}
 
 	var request types.ServiceRegistrationRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		errorResponse(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &request) {
 		return
 	}
 
+	// Sending back the current state is a no-op rather than a transition
+	if request.State == existingService.State {
+		request.State = ""
+	}
+
+	if errs := h.normalizeRegistration(r, &request); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+	// Only a new URL needs to prove it's reachable
+	if request.URL != existingService.URL {
+		if msg := h.checkReachable(r, request.URL); msg != "" {
+			validationResponse(w, []types.FieldError{{Field: "url", Code: codeUnreachable, Message: msg}})
+			return
+		}
+	}
+
 	// Start transaction
-	tx := h.DB.Begin()
+	tx := h.conn(r).Begin()
 	if tx.Error != nil {
 		errorResponse(w, "Failed to start transaction", http.StatusInternalServerError)
 		return
@@ -227,76 +546,25 @@ func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// Update service details
-	existingService.Name = request.Name
-	existingService.Description = request.Description
-	existingService.URL = request.URL
-	existingService.LastSeen = time.Now()
-	existingService.ApiDocs = request.ApiDocs
-
-	if err := tx.Save(&existingService).Error; err != nil {
-		tx.Rollback()
-		errorResponse(w, "Failed to update service", http.StatusInternalServerError)
-		return
+	err := h.checkRegistration(tx, request, existingService.ID)
+	if err == nil {
+		err = db.UpdateService(tx, &existingService, request, time.Now())
 	}
-
-	// Update capabilities: remove old ones and add new ones
-	if err := tx.Where("service_id = ?", serviceID).Delete(&types.Capability{}).Error; err != nil {
-		tx.Rollback()
-		errorResponse(w, "Failed to remove old capabilities", http.StatusInternalServerError)
-		return
-	}
-
-	for name, enabled := range request.Capabilities {
-		capability := types.Capability{
-			ServiceID: serviceID,
-			Name:      name,
-			Enabled:   enabled,
-		}
-		if err := tx.Create(&capability).Error; err != nil {
-			tx.Rollback()
-			errorResponse(w, "Failed to add capability", http.StatusInternalServerError)
-			return
-		}
+	if err == nil {
+		err = h.recordProvenance(r, tx, existingService.ID)
 	}
-
-	// Update categories: remove old ones and add new ones
-	if err := tx.Where("service_id = ?", serviceID).Delete(&types.Category{}).Error; err != nil {
+	if err != nil {
 		tx.Rollback()
-		errorResponse(w, "Failed to remove old categories", http.StatusInternalServerError)
-		return
-	}
-
-	for _, name := range request.Categories {
-		category := types.Category{
-			ServiceID: serviceID,
-			Name:      name,
-		}
-		if err := tx.Create(&category).Error; err != nil {
-			tx.Rollback()
-			errorResponse(w, "Failed to add category", http.StatusInternalServerError)
+		if rejectionResponse(w, err) {
 			return
 		}
-	}
-
-	// Update metadata: remove old ones and add new ones
-	if err := tx.Where("service_id = ?", serviceID).Delete(&types.MetadataItem{}).Error; err != nil {
-		tx.Rollback()
-		errorResponse(w, "Failed to remove old metadata", http.StatusInternalServerError)
-		return
-	}
-
-	for key, value := range request.Metadata {
-		metadata := types.MetadataItem{
-			ServiceID: serviceID,
-			Key:       key,
-			Value:     value,
-		}
-		if err := tx.Create(&metadata).Error; err != nil {
-			tx.Rollback()
-			errorResponse(w, "Failed to add metadata", http.StatusInternalServerError)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+			conflictResponse(w, existingID)
 			return
 		}
+		errorResponse(w, "Failed to update service", http.StatusInternalServerError)
+		return
 	}
 
 	// Commit the transaction
@@ -307,32 +575,39 @@ func (h *Handler) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Retrieve the updated service to return (outside transaction)
 	var updatedService types.MCPService
-	if err := h.DB.Preload("Capabilities").Preload("Categories").Preload("Metadata").
+	if err := db.Preload(h.conn(r)).
 		First(&updatedService, "id = ?", serviceID).Error; err != nil {
 		errorResponse(w, "Service updated but failed to retrieve details", http.StatusInternalServerError)
 		return
 	}
 
-	jsonResponse(w, types.ServiceModelToResponse(updatedService), http.StatusOK)
+	jsonResponse(w, h.toResponse(updatedService), http.StatusOK)
 }
 
 func (h *Handler) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
 	serviceID := getServiceID(r)
 	if serviceID == "" {
-		errorResponse(w, "Invalid service ID", http.StatusBadRequest)
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+	if r.URL.Query().Get("purge") == "true" {
+		h.Admin(h.purgeService)(w, r)
 		return
 	}
 
 	// Check if service exists before starting transaction
 	var service types.MCPService
-	result := h.DB.First(&service, "id = ?", serviceID)
+	result := h.conn(r).First(&service, "id = ?", serviceID)
 	if result.Error != nil {
-		errorResponse(w, "Service not found", http.StatusNotFound)
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeEdit(w, r, service) {
 		return
 	}
 
 	// Start transaction
-	tx := h.DB.Begin()
+	tx := h.conn(r).Begin()
 	if tx.Error != nil {
 		errorResponse(w, "Failed to start transaction", http.StatusInternalServerError)
 		return
@@ -346,26 +621,19 @@ func (h *Handler) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	// Delete related records first
-	if err := tx.Where("service_id = ?", serviceID).Delete(&types.Capability{}).Error; err != nil {
-		tx.Rollback()
-		errorResponse(w, "Failed to delete capabilities", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Where("service_id = ?", serviceID).Delete(&types.Category{}).Error; err != nil {
+	if err := db.DeleteAssociations(tx, serviceID); err != nil {
 		tx.Rollback()
-		errorResponse(w, "Failed to delete categories", http.StatusInternalServerError)
+		errorResponse(w, "Failed to delete associations", http.StatusInternalServerError)
 		return
 	}
 
-	if err := tx.Where("service_id = ?", serviceID).Delete(&types.MetadataItem{}).Error; err != nil {
+	// Delete the service
+	if err := tx.Delete(&service).Error; err != nil {
 		tx.Rollback()
-		errorResponse(w, "Failed to delete metadata", http.StatusInternalServerError)
+		errorResponse(w, "Failed to delete service", http.StatusInternalServerError)
 		return
 	}
-
-	// Delete the service
-	if err := tx.Delete(&service).Error; err != nil {
+	if err := db.RecordChange(tx, types.ChangeDeleted, serviceID); err != nil {
 		tx.Rollback()
 		errorResponse(w, "Failed to delete service", http.StatusInternalServerError)
 		return
@@ -383,37 +651,64 @@ func (h *Handler) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
 	serviceID := getServiceID(r)
 	if serviceID == "" {
-		errorResponse(w, "Invalid service ID", http.StatusBadRequest)
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
 		return
 	}
 
 	var service types.MCPService
-	result := h.DB.First(&service, "id = ?", serviceID)
+	result := h.conn(r).First(&service, "id = ?", serviceID)
 	if result.Error != nil {
-		errorResponse(w, "Service not found", http.StatusNotFound)
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
 		return
 	}
+	if rejectFederated(w, service) || !h.authorizeOwner(w, r, service) || !h.authorizeHeartbeat(w, r, service) {
+		return
+	}
+
+	// POSTed heartbeats may report runtime metrics and the running build
+	// along with the renewal
+	var request types.HeartbeatRequest
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if !decodeJSON(w, r, &request) {
+			return
+		}
+		if errs := validateHeartbeat(request); len(errs) > 0 {
+			validationResponse(w, errs)
+			return
+		}
+	}
 
-	// Update last seen time
-	service.LastSeen = time.Now()
-	h.DB.Save(&service)
+	if err := h.renewLease(r, serviceID); err != nil {
+		errorResponse(w, "Failed to record heartbeat", http.StatusInternalServerError)
+		return
+	}
+	h.applyHeartbeat(r, serviceID, request)
 
 	jsonResponse(w, map[string]string{"message": "Heartbeat received"}, http.StatusOK)
 }
 
 func (h *Handler) SearchServicesHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
+	if r.URL.Query().Get("q") == "" {
 		errorResponse(w, "Query parameter 'q' is required", http.StatusBadRequest)
 		return
 	}
 
-	var services []types.MCPService
-	result := h.DB.Preload("Capabilities").Preload("Categories").Preload("Metadata").
-		Where("name ILIKE ? OR description ILIKE ?", "%"+query+"%", "%"+query+"%").
-		Find(&services)
+	filter, msg := parseServiceFilter(r)
+	if msg != "" {
+		errorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+	// Search has never supported the list-only filters
+	filter.ids, filter.origin, filter.category, filter.since = nil, "", "", time.Time{}
+	filter.after, filter.limit = nil, 0
 
-	if result.Error != nil {
+	state, ok := h.registryState(w, r)
+	if !ok {
+		return
+	}
+
+	services, err := h.findServices(r, filter, state.UpdatedAt)
+	if err != nil {
 		errorResponse(w, "Error searching for services", http.StatusInternalServerError)
 		return
 	}
@@ -421,7 +716,12 @@ func (h *Handler) SearchServicesHandler(w http.ResponseWriter, r *http.Request)
 	// Convert to response format
 	var responses []types.ServiceResponse
 	for _, service := range services {
-		responses = append(responses, types.ServiceModelToResponse(service))
+		responses = append(responses, h.toResponse(service))
+		h.Usage.Record(service.ID)
+	}
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error searching for services", http.StatusInternalServerError)
+		return
 	}
 
 	jsonResponse(w, responses, http.StatusOK)
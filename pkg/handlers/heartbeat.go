@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+var upgrader = websocket.Upgrader{
+	// Browsers are already allowed in by the CORS policy
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// renewLease marks a service seen now and counts the heartbeat towards its
+// recovery if it's degraded. Neither moves the change index unless the
+// service's status changes.
+func (h *Handler) renewLease(r *http.Request, serviceID string) error {
+	now := time.Now()
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&types.MCPService{}).Where("id = ?", serviceID).UpdateColumn("last_seen", now).Error; err != nil {
+			return err
+		}
+		_, err := db.RecordOutcome(tx, serviceID, true, h.StreakPolicy(), now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := db.RecordAvailability(h.conn(r), serviceID, true, now); err != nil {
+		logf(r, "Failed to record availability for %s: %v", serviceID, err)
+	}
+	return nil
+}
+
+// applyHeartbeat records the metrics and build reported with a heartbeat.
+// Failures are only logged since the lease has already been renewed.
+func (h *Handler) applyHeartbeat(r *http.Request, serviceID string, request types.HeartbeatRequest) {
+	if request.Metrics != nil {
+		if err := db.RecordMetrics(h.conn(r), serviceID, *request.Metrics, time.Now()); err != nil {
+			logf(r, "Failed to record metrics for %s: %v", serviceID, err)
+		}
+	}
+
+	if request.Version == "" && request.GitSHA == "" {
+		return
+	}
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		changed, err := db.RecordBuild(tx, serviceID, request.Version, request.GitSHA)
+		if changed {
+			logf(r, "Service %s now running %s (%s)", serviceID, request.Version, request.GitSHA)
+		}
+		return err
+	})
+	if err != nil {
+		logf(r, "Failed to record build for %s: %v", serviceID, err)
+	}
+}
+
+// validateHeartbeat rejects heartbeat payloads that can't be right
+func validateHeartbeat(request types.HeartbeatRequest) []types.FieldError {
+	var v validator
+	if request.Metrics != nil {
+		v.errors = validateMetrics(*request.Metrics)
+	}
+	v.maxLength("version", request.Version, maxNameLength)
+	if request.GitSHA != "" && !gitSHAPattern.MatchString(request.GitSHA) {
+		v.add("git_sha", codeInvalid, "Must be a hexadecimal commit SHA")
+	}
+	return v.errors
+}
+
+// gitSHAPattern matches abbreviated and full SHA-1 or SHA-256 commit IDs
+var gitSHAPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// issueHeartbeatToken gives a service a new heartbeat token when they are
+// enabled, replacing any previous one. Only the hash is kept, the token is
+// returned so it can be shown to the caller once.
+func (h *Handler) issueHeartbeatToken(tx *gorm.DB, serviceID string) (string, error) {
+	if !h.Config.HeartbeatTokens {
+		return "", nil
+	}
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Model(&types.MCPService{}).Where("id = ?", serviceID).UpdateColumn("heartbeat_token_hash", hash).Error
+	return token, err
+}
+
+// authorizeHeartbeat writes a 401 and returns false unless the request may
+// renew the service's lease. Services that were issued a heartbeat token need
+// it, the admin token, or an API key that may edit the service; older
+// services are left open. Callers check the owner with authorizeOwner first.
+func (h *Handler) authorizeHeartbeat(w http.ResponseWriter, r *http.Request, service types.MCPService) bool {
+	if !h.Config.HeartbeatTokens || service.HeartbeatTokenHash == "" {
+		return true
+	}
+	if token, ok := auth.BearerToken(r); ok && auth.MatchesHash(token, service.HeartbeatTokenHash) {
+		return true
+	}
+	if _, ok := h.authenticate(r); ok && h.inTeam(r, service) {
+		return true
+	}
+	errorResponse(w, "Unauthorized", http.StatusUnauthorized)
+	return false
+}
+
+// ResetHeartbeatTokenHandler issues a service a new heartbeat token, revoking
+// the old one, for when it was lost or leaked. A heartbeat token can't be used
+// to call it.
+func (h *Handler) ResetHeartbeatTokenHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeOwner(w, r, service) {
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+	var token string
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if token, err = h.issueHeartbeatToken(tx, serviceID); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, serviceID, types.AuditTokenReset, principal.Name, "")
+	})
+	if err != nil {
+		errorResponse(w, "Failed to reset heartbeat token", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"service_id": serviceID, "heartbeat_token": token}, http.StatusOK)
+}
+
+// HeartbeatStreamHandler upgrades to a WebSocket that keeps a service's lease
+// alive for as long as the connection is. The registry pings at a third of
+// the TTL and every pong or client message renews the lease. When the
+// connection drops the service is marked degraded straight away instead of
+// waiting for the TTL to run out.
+func (h *Handler) HeartbeatStreamHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeOwner(w, r, service) || !h.authorizeHeartbeat(w, r, service) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer conn.Close()
+	defer h.holdOpen()()
+
+	// Every pong or message keeps the stream open, but the lease is written at
+	// most every ttl/4 so a chatty client doesn't write on each message. The
+	// pings going out every ttl/3 still renew it each time.
+	ttl := h.Config.ServiceTTL
+	var renewed time.Time
+	renew := func() error {
+		conn.SetReadDeadline(time.Now().Add(ttl))
+		if time.Since(renewed) < ttl/4 {
+			return nil
+		}
+		renewed = time.Now()
+		if err := h.renewLease(r, serviceID); err != nil {
+			logf(r, "Failed to renew lease for %s: %v", serviceID, err)
+		}
+		return nil
+	}
+	renew()
+	conn.SetPongHandler(func(string) error { return renew() })
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ttl/3)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Reading drives the pong handler; any message from the client also counts
+	// and may carry the same metrics as a POSTed heartbeat
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		renew()
+
+		var request types.HeartbeatRequest
+		if json.Unmarshal(data, &request) != nil || len(validateHeartbeat(request)) > 0 {
+			continue
+		}
+		h.applyHeartbeat(r, serviceID, request)
+	}
+	close(done)
+
+	var degraded bool
+	err = h.conn(r).Transaction(func(tx *gorm.DB) (err error) {
+		degraded, err = db.RecordOutcome(tx, serviceID, false, h.StreakPolicy(), time.Now())
+		return err
+	})
+	if err != nil {
+		logf(r, "Failed to record heartbeat failure for %s: %v", serviceID, err)
+	}
+	if err := db.RecordAvailability(h.conn(r), serviceID, false, time.Now()); err != nil {
+		logf(r, "Failed to record availability for %s: %v", serviceID, err)
+	}
+	if degraded {
+		logf(r, "Heartbeat stream for %s closed, marked degraded", serviceID)
+	} else {
+		logf(r, "Heartbeat stream for %s closed", serviceID)
+	}
+}
+
+// StreakPolicy is the configured policy for turning probe and heartbeat
+// outcomes into status changes
+func (h *Handler) StreakPolicy() db.StreakPolicy {
+	return db.StreakPolicy{
+		FailureThreshold:  int(h.Config.FailureThreshold),
+		RecoveryThreshold: int(h.Config.RecoveryThreshold),
+		FlapThreshold:     int(h.Config.FlapThreshold),
+		FlapWindow:        h.Config.FlapWindow,
+	}
+}
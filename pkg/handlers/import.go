@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// maxImportSize bounds how much of an import is read from a body or remote URL
+const maxImportSize = 32 << 20
+
+// ImportHandler accepts either a registry snapshot from /export or an MCP
+// manifest, posted as the body or fetched from the "url" query parameter, in
+// JSON or YAML.
+//
+// Snapshots are loaded with ?mode=merge (default) or ?mode=replace, by admins
+// only.
+//
+// Manifests and mcp.json files register each declared server into ?namespace,
+// with tools becoming enabled capabilities. Entries without a URL, such as stdio
+// servers, are skipped and reported back.
+func (h *Handler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	body := io.Reader(r.Body)
+	contentType := r.Header.Get("Content-Type")
+	if source := r.URL.Query().Get("url"); source != "" {
+		resp, err := h.fetchManifest(source)
+		if err != nil {
+			errorResponse(w, "Failed to fetch manifest: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		body = resp.Body
+		contentType = resp.Header.Get("Content-Type")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxImportSize))
+	if err != nil {
+		errorResponse(w, "Failed to read import: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if isYAML(contentType, r.URL.Query().Get("format")) {
+		if data, err = yamlToJSON(data); err != nil {
+			errorResponse(w, "Invalid YAML: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Snapshots are the only documents with a top level "services" list
+	var probe struct {
+		Services json.RawMessage `json:"services"`
+	}
+	json.Unmarshal(data, &probe)
+	if probe.Services != nil || r.URL.Query().Get("mode") != "" {
+		h.Admin(func(w http.ResponseWriter, r *http.Request) { h.importSnapshot(w, r, data) })(w, r)
+		return
+	}
+
+	var doc types.ImportDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		errorResponse(w, "Invalid manifest: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	manifests := manifestEntries(doc)
+	if len(manifests) == 0 {
+		errorResponse(w, "Manifest does not declare any servers", http.StatusBadRequest)
+		return
+	}
+
+	result := types.ImportResult{
+		Imported: []types.ServiceResponse{},
+		Skipped:  []types.ImportSkipped{},
+	}
+	var serviceIDs []string
+
+	tx := h.conn(r).Begin()
+	if tx.Error != nil {
+		errorResponse(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, manifest := range manifests {
+		if manifest.Name == "" {
+			result.Skipped = append(result.Skipped, types.ImportSkipped{Reason: "Missing name"})
+			continue
+		}
+		if manifest.URL == "" {
+			reason := "Missing URL"
+			if manifest.Command != "" {
+				reason = "Stdio servers can't be registered"
+			}
+			result.Skipped = append(result.Skipped, types.ImportSkipped{Name: manifest.Name, Reason: reason})
+			continue
+		}
+
+		request := manifestToRegistration(manifest)
+		request.Namespace = r.URL.Query().Get("namespace")
+		if errs := h.normalizeRegistration(r, &request); len(errs) > 0 {
+			result.Skipped = append(result.Skipped, types.ImportSkipped{Name: manifest.Name, Reason: errs[0].Field + ": " + errs[0].Message})
+			continue
+		}
+
+		existingID, err := db.FindDuplicate(tx, request.Namespace, request.Name, request.URL)
+		if err != nil {
+			tx.Rollback()
+			errorResponse(w, "Failed to check for duplicates", http.StatusInternalServerError)
+			return
+		}
+		if existingID != "" {
+			result.Skipped = append(result.Skipped, types.ImportSkipped{Name: manifest.Name, Reason: "Already registered as " + existingID})
+			continue
+		}
+
+		serviceID, err := h.registerService(r, tx, request)
+		var aliasErr *aliasError
+		if errors.As(err, &aliasErr) {
+			result.Skipped = append(result.Skipped, types.ImportSkipped{Name: manifest.Name, Reason: aliasErr.errors[0].Field + ": " + aliasErr.errors[0].Message})
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			if rejectionResponse(w, err) {
+				return
+			}
+			errorResponse(w, "Failed to register "+manifest.Name, http.StatusInternalServerError)
+			return
+		}
+		serviceIDs = append(serviceIDs, serviceID)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		errorResponse(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	if len(serviceIDs) > 0 {
+		var services []types.MCPService
+		if err := db.Preload(h.conn(r)).
+			Where("id IN ?", serviceIDs).Find(&services).Error; err != nil {
+			errorResponse(w, "Services imported but failed to retrieve details", http.StatusInternalServerError)
+			return
+		}
+		for _, service := range services {
+			result.Imported = append(result.Imported, h.toResponse(service))
+		}
+	}
+
+	code := http.StatusOK
+	if len(result.Imported) > 0 {
+		code = http.StatusCreated
+	}
+	jsonResponse(w, result, code)
+}
+
+func (h *Handler) fetchManifest(source string) (*http.Response, error) {
+	client := &http.Client{Timeout: 10 * time.Second, Transport: h.Guard.Transport()}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// manifestEntries flattens the supported manifest layouts into a list of servers
+func manifestEntries(doc types.ImportDocument) []types.MCPManifest {
+	var manifests []types.MCPManifest
+	if doc.Name != "" || doc.URL != "" {
+		manifests = append(manifests, doc.MCPManifest)
+	}
+	manifests = append(manifests, doc.Servers...)
+
+	// Map iteration order is random, keep imports deterministic
+	names := make([]string, 0, len(doc.MCPServers))
+	for name := range doc.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		manifest := doc.MCPServers[name]
+		if manifest.Name == "" {
+			manifest.Name = name
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests
+}
+
+func manifestToRegistration(manifest types.MCPManifest) types.ServiceRegistrationRequest {
+	capabilities := make(map[string]bool)
+	var tools []types.Tool
+	for _, tool := range manifest.Tools {
+		if tool.Name != "" {
+			capabilities[tool.Name] = true
+			tools = append(tools, types.Tool{Name: tool.Name, Description: tool.Description, InputSchema: tool.InputSchema})
+		}
+	}
+
+	categories := manifest.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+
+	return types.ServiceRegistrationRequest{
+		Name:         manifest.Name,
+		Description:  manifest.Description,
+		URL:          manifest.URL,
+		Capabilities: capabilities,
+		Categories:   categories,
+		Metadata:     manifest.Metadata,
+		Tools:        tools,
+	}
+}
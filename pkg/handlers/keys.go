@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// keyPrefixLength is how much of a token is kept to tell keys apart
+const keyPrefixLength = 8
+
+// CreateAPIKeyHandler issues an API key. The token is only returned in this
+// response; the registry keeps just its hash.
+func (h *Handler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var request types.APIKeyRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	var v validator
+	if request.Name == "" {
+		v.add("name", codeRequired, "Name is required")
+	}
+	v.maxLength("name", request.Name, maxNameLength)
+	v.maxLength("description", request.Description, maxDescriptionLength)
+	for _, scope := range request.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			v.add("scopes", codeInvalid, "Unknown scope "+scope)
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		v.add("expires_at", codeInvalid, "Expiry must be in the future")
+	}
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		errorResponse(w, "Failed to generate API key", http.StatusInternalServerError)
+		return
+	}
+	slices.Sort(request.Scopes)
+	key := types.APIKey{
+		ID:          uuid.New().String(),
+		Name:        request.Name,
+		Description: request.Description,
+		Prefix:      token[:keyPrefixLength],
+		TokenHash:   hash,
+		Scopes:      slices.Compact(request.Scopes),
+		CreatedBy:   principal.Name,
+		ExpiresAt:   request.ExpiresAt,
+	}
+	err = h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, "", types.AuditKeyCreated, principal.Name, key.ID+" ("+key.Name+")")
+	})
+	if err != nil {
+		errorResponse(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	response := types.APIKeyToResponse(key)
+	response.Token = token
+	jsonResponse(w, response, http.StatusCreated)
+}
+
+// ListAPIKeysHandler lists API keys, newest first. Revoked keys are left out
+// unless ?revoked=true.
+func (h *Handler) ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	query := h.conn(r).Order("created_at DESC, id")
+	if r.URL.Query().Get("revoked") != "true" {
+		query = query.Where("revoked_at IS NULL")
+	}
+	if name := r.URL.Query().Get("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+
+	var keys []types.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		errorResponse(w, "Error finding API keys", http.StatusInternalServerError)
+		return
+	}
+	responses := []types.APIKeyResponse{}
+	for _, key := range keys {
+		responses = append(responses, types.APIKeyToResponse(key))
+	}
+	jsonResponse(w, responses, http.StatusOK)
+}
+
+func (h *Handler) GetAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var key types.APIKey
+	if err := h.conn(r).First(&key, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeNotFound, "API key not found", http.StatusNotFound, nil)
+		return
+	}
+	jsonResponse(w, types.APIKeyToResponse(key), http.StatusOK)
+}
+
+// RevokeAPIKeyHandler stops a key from working. Keys are looked up on every
+// request, so this takes effect at once.
+func (h *Handler) RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var key types.APIKey
+	if err := h.conn(r).First(&key, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeNotFound, "API key not found", http.StatusNotFound, nil)
+		return
+	}
+
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		revoked, err := db.RevokeAPIKey(tx, key.ID, time.Now())
+		if err != nil || !revoked {
+			return err
+		}
+		return db.RecordAudit(tx, "", types.AuditKeyRevoked, principal.Name, key.ID+" ("+key.Name+")")
+	})
+	if err != nil {
+		errorResponse(w, "Failed to revoke API key", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"message": "API key revoked"}, http.StatusOK)
+}
+
+// RotateAPIKeyHandler replaces a key with a new one carrying the same name
+// and scopes. The old key keeps working for a grace period so whatever uses
+// it can switch over without downtime.
+func (h *Handler) RotateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	h.rotateKey(w, r, mux.Vars(r)["id"], false)
+}
+
+// RotateOwnKeyHandler rotates the API key the request is authenticated with,
+// so long-running servers can renew their own credentials. Keys without the
+// write scope may rotate themselves too. The replacement never outlives the
+// key it replaces; only admins can extend a key's expiry.
+func (h *Handler) RotateOwnKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if h.readOnly.Load() {
+		errorCodeResponse(w, CodeReadOnly, "Registry is read-only", http.StatusForbidden, nil)
+		return
+	}
+	principal, _ := auth.FromContext(r.Context())
+	if principal.KeyID == "" {
+		errorResponse(w, "Only API keys issued through /keys can be rotated", http.StatusBadRequest)
+		return
+	}
+	h.rotateKey(w, r, principal.KeyID, true)
+}
+
+// rotateKey replaces a key. When a key rotates itself the replacement expires
+// no later than the key would have.
+func (h *Handler) rotateKey(w http.ResponseWriter, r *http.Request, keyID string, self bool) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var request types.RotateKeyRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &request) {
+		return
+	}
+	now := time.Now()
+	var v validator
+	if request.GraceSeconds < 0 {
+		v.add("grace_seconds", codeInvalid, "Grace period must not be negative")
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		v.add("expires_at", codeInvalid, "Expiry must be in the future")
+	}
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+	grace := h.Config.KeyRotationGrace
+	if request.GraceSeconds > 0 {
+		grace = time.Duration(request.GraceSeconds) * time.Second
+	}
+
+	var old types.APIKey
+	if err := h.conn(r).First(&old, "id = ?", keyID).Error; err != nil {
+		errorCodeResponse(w, CodeNotFound, "API key not found", http.StatusNotFound, nil)
+		return
+	}
+	switch {
+	case old.RevokedAt != nil, old.ExpiresAt != nil && !old.ExpiresAt.After(now):
+		errorCodeResponse(w, CodeConflict, "API key is no longer valid", http.StatusConflict, nil)
+		return
+	case old.ReplacedBy != "":
+		errorCodeResponse(w, CodeConflict, "API key was already rotated", http.StatusConflict,
+			map[string]string{"replaced_by": old.ReplacedBy})
+		return
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		errorResponse(w, "Failed to generate API key", http.StatusInternalServerError)
+		return
+	}
+	replacement := types.APIKey{
+		ID:          uuid.New().String(),
+		Name:        old.Name,
+		Description: old.Description,
+		Prefix:      token[:keyPrefixLength],
+		TokenHash:   hash,
+		Scopes:      old.Scopes,
+		CreatedBy:   principal.Name,
+		ExpiresAt:   request.ExpiresAt,
+	}
+	// Without an explicit expiry the new key gets the old one's lifetime
+	if replacement.ExpiresAt == nil && old.ExpiresAt != nil {
+		expiresAt := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		replacement.ExpiresAt = &expiresAt
+	}
+	if self && old.ExpiresAt != nil && (replacement.ExpiresAt == nil || replacement.ExpiresAt.After(*old.ExpiresAt)) {
+		replacement.ExpiresAt = old.ExpiresAt
+	}
+
+	err = h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := db.RotateAPIKey(tx, &old, &replacement, now.Add(grace)); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, "", types.AuditKeyRotated, principal.Name, old.ID+" ("+old.Name+") replaced by "+replacement.ID)
+	})
+	if errors.Is(err, db.ErrKeyRotated) {
+		errorCodeResponse(w, CodeConflict, "API key was already rotated", http.StatusConflict, nil)
+		return
+	}
+	if err != nil {
+		errorResponse(w, "Failed to rotate API key", http.StatusInternalServerError)
+		return
+	}
+
+	response := types.RotateKeyResponse{Key: types.APIKeyToResponse(replacement), Previous: types.APIKeyToResponse(old)}
+	response.Key.Token = token
+	jsonResponse(w, response, http.StatusCreated)
+}
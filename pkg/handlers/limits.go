@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Per-field limits on registrations, so a single request can't bloat the database
+const (
+	maxNameLength          = 128
+	maxDescriptionLength   = 4096
+	maxURLLength           = 2048
+	maxCapabilities        = 256
+	maxCategories          = 64
+	maxMetadataEntries     = 64
+	maxMetadataKeyLength   = 128
+	maxMetadataValueLength = 1024
+	maxAliases             = 32
+	maxToolSchemaLength    = 16 << 10
+	maxAPIDocsLength       = 1 << 20 // Inline OpenAPI documents run larger than anything else
+	maxProtocolVersions    = 16
+	maxExtensions          = 32
+	maxExtensionLength     = 16 << 10
+)
+
+// LimitBody is middleware refusing request bodies larger than the configured
+// maximum. Imports are exempt since they enforce their own, larger limit, and
+// so is proxied traffic.
+func (h *Handler) LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exempt := r.URL.Path == "/import" || strings.HasPrefix(r.URL.Path, "/proxy/")
+		if !exempt && h.Config.MaxBodyBytes > 0 {
+			if r.ContentLength > h.Config.MaxBodyBytes {
+				bodyTooLargeResponse(w, h.Config.MaxBodyBytes)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, h.Config.MaxBodyBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes the request body into v, writing a 413 if the body was
+// cut off by LimitBody or a 400 if it isn't valid JSON
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &tooLarge):
+		bodyTooLargeResponse(w, tooLarge.Limit)
+	default:
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+func bodyTooLargeResponse(w http.ResponseWriter, limit int64) {
+	errorCodeResponse(w, CodePayloadTooLarge, "Request body too large", http.StatusRequestEntityTooLarge,
+		map[string]int64{"limit_bytes": limit})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/oidc"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Cookies set by the login flow
+const (
+	sessionCookie    = "registry_session"
+	loginStateCookie = "registry_login_state"
+)
+
+// loginTimeout is how long a user has to finish logging in with the provider
+const loginTimeout = 10 * time.Minute
+
+// LoginHandler starts an OIDC login, redirecting to the provider. ?redirect=
+// is where to go once logged in: a path on the registry, which gets a session
+// cookie, or a loopback URL, which gets the session token as ?token= so CLIs
+// can receive it.
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	redirect := r.URL.Query().Get("redirect")
+	if redirect != "" && !isLocalRedirect(redirect) && !isLoopbackRedirect(redirect) {
+		errorResponse(w, "redirect must be a path on the registry or a loopback URL", http.StatusBadRequest)
+		return
+	}
+
+	login := types.PendingLogin{Redirect: redirect, CreatedAt: time.Now().UTC()}
+	var err error
+	for _, value := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		if *value, err = oidc.RandomString(); err != nil {
+			errorResponse(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+	}
+	target, err := h.OIDC.AuthCodeURL(r.Context(), login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		logf(r, "Failed to reach OIDC provider: %v", err)
+		errorResponse(w, "Identity provider is unavailable", http.StatusBadGateway)
+		return
+	}
+	if err := db.CreateLogin(h.conn(r), login); err != nil {
+		errorResponse(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	// Ties the callback to this browser, so nobody can log a victim into
+	// their own account by sending them a callback link
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    login.State,
+		Path:     "/auth",
+		MaxAge:   int(loginTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// LoginCallbackHandler completes a login when the provider redirects back,
+// mapping the user's role claim to registry roles and starting a session
+func (h *Handler) LoginCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		errorResponse(w, "Login failed: "+providerErr+" "+query.Get("error_description"), http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(loginStateCookie)
+	if state == "" || err != nil || cookie.Value != state {
+		errorResponse(w, "Login state doesn't match this browser, start again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Path: "/auth", MaxAge: -1})
+
+	login, err := db.TakeLogin(h.conn(r), state)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && time.Since(login.CreatedAt) > loginTimeout) {
+		errorResponse(w, "Login expired, start again", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		errorResponse(w, "Failed to complete login", http.StatusInternalServerError)
+		return
+	}
+
+	claims, err := h.OIDC.Exchange(r.Context(), query.Get("code"), login.Verifier, login.Nonce)
+	if err != nil {
+		logf(r, "OIDC login failed: %v", err)
+		errorResponse(w, "Login failed", http.StatusUnauthorized)
+		return
+	}
+
+	roles := claims.Strings(h.Config.OIDCRoleClaim)
+	admin := anyRole(roles, h.Config.OIDCAdminRoles)
+	if len(h.Config.OIDCAllowedRoles) > 0 && !admin && !anyRole(roles, h.Config.OIDCAllowedRoles) {
+		errorResponse(w, "You don't have a role that may use the registry", http.StatusForbidden)
+		return
+	}
+
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		errorResponse(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	session := types.Session{
+		TokenHash: hash,
+		Subject:   claims.String("sub"),
+		Name:      sessionName(claims),
+		Admin:     admin,
+		ExpiresAt: time.Now().Add(h.Config.SessionTTL).UTC(),
+	}
+	if err := db.CreateSession(h.conn(r), session); err != nil {
+		errorResponse(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	logf(r, "%s logged in (admin: %t)", session.Name, session.Admin)
+
+	if isLoopbackRedirect(login.Redirect) {
+		target, _ := url.Parse(login.Redirect)
+		values := target.Query()
+		values.Set("token", token)
+		target.RawQuery = values.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if login.Redirect != "" {
+		http.Redirect(w, r, login.Redirect, http.StatusFound)
+		return
+	}
+	jsonResponse(w, types.SessionResponse{Name: session.Name, Admin: session.Admin, ExpiresAt: &session.ExpiresAt, Token: token}, http.StatusOK)
+}
+
+// LogoutHandler ends the caller's session
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if token, ok := sessionToken(r); ok {
+		if err := db.DeleteSession(h.conn(r), auth.HashToken(token)); err != nil {
+			errorResponse(w, "Failed to end session", http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SessionHandler describes the caller's session
+func (h *Handler) SessionHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := h.session(r)
+	if !ok {
+		errorResponse(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+	jsonResponse(w, types.SessionResponse{Name: session.Name, Admin: session.Admin, ExpiresAt: &session.ExpiresAt}, http.StatusOK)
+}
+
+// session returns the unexpired session the request's bearer token or
+// session cookie belongs to
+func (h *Handler) session(r *http.Request) (types.Session, bool) {
+	if h.OIDC == nil {
+		return types.Session{}, false
+	}
+	token, ok := sessionToken(r)
+	if !ok {
+		return types.Session{}, false
+	}
+	session, err := db.FindSession(h.conn(r), auth.HashToken(token), time.Now())
+	return session, err == nil
+}
+
+// sessionToken is the bearer token or, for browsers, the session cookie
+func sessionToken(r *http.Request) (string, bool) {
+	if token, ok := auth.BearerToken(r); ok {
+		return token, true
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value, true
+	}
+	return "", false
+}
+
+// sessionName is how a user is shown in audit logs and known to teams: their
+// email if the provider verified it, falling back to their user name and then
+// their subject
+func sessionName(claims oidc.Claims) string {
+	if claims.Bool("email_verified") {
+		if email := claims.String("email"); email != "" {
+			return email
+		}
+	}
+	for _, claim := range []string{"preferred_username", "sub"} {
+		if name := claims.String(claim); name != "" {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+func anyRole(roles, wanted []string) bool {
+	for _, role := range roles {
+		for _, w := range wanted {
+			if role == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isLocalRedirect reports whether redirect is a path on the registry, and not
+// a protocol relative URL to another host
+func isLocalRedirect(redirect string) bool {
+	return strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") && !strings.HasPrefix(redirect, "/\\")
+}
+
+// isLoopbackRedirect reports whether redirect is a plain HTTP URL on this
+// machine, where a CLI waits for its token
+func isLoopbackRedirect(redirect string) bool {
+	target, err := url.Parse(redirect)
+	if err != nil || target.Scheme != "http" || target.User != nil {
+		return false
+	}
+	switch target.Hostname() {
+	case "127.0.0.1", "::1", "localhost":
+		return true
+	}
+	return false
+}
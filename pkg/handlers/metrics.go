@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ServiceMetricsHandler returns the runtime metrics a service reported with its
+// heartbeats over ?window, which defaults to everything still retained, along
+// with the latest sample and per metric averages for load-aware routing.
+func (h *Handler) ServiceMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = h.Config.MetricsWindow.String()
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.findVisible(w, r, serviceID); !ok {
+		return
+	}
+
+	samples, err := db.MetricsSince(h.reader(r), serviceID, time.Now().Add(-window))
+	if err != nil {
+		errorResponse(w, "Error reading metrics", http.StatusInternalServerError)
+		return
+	}
+
+	response := types.ServiceMetricsResponse{
+		ServiceID: serviceID,
+		Window:    windowParam,
+		Average:   averageMetrics(samples),
+		Samples:   samples,
+	}
+	if response.Samples == nil {
+		response.Samples = []types.MetricSample{}
+	}
+	if len(samples) > 0 {
+		response.Latest = &samples[len(samples)-1]
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// averageMetrics averages each metric over the samples that reported it
+func averageMetrics(samples []types.MetricSample) types.MetricAverages {
+	var sessions, cpu, memory, queue mean
+	for _, sample := range samples {
+		if sample.ActiveSessions != nil {
+			sessions.add(float64(*sample.ActiveSessions))
+		}
+		if sample.CPUPercent != nil {
+			cpu.add(*sample.CPUPercent)
+		}
+		if sample.MemoryPercent != nil {
+			memory.add(*sample.MemoryPercent)
+		}
+		if sample.QueueDepth != nil {
+			queue.add(float64(*sample.QueueDepth))
+		}
+	}
+	return types.MetricAverages{
+		ActiveSessions: sessions.value(),
+		CPUPercent:     cpu.value(),
+		MemoryPercent:  memory.value(),
+		QueueDepth:     queue.value(),
+	}
+}
+
+type mean struct {
+	sum   float64
+	count int
+}
+
+func (m *mean) add(v float64) {
+	m.sum += v
+	m.count++
+}
+
+// value is nil when nothing was added
+func (m mean) value() *float64 {
+	if m.count == 0 {
+		return nil
+	}
+	v := m.sum / float64(m.count)
+	return &v
+}
+
+// validateMetrics rejects metrics that can't be right. CPU may exceed 100% on
+// multi-core hosts so only memory is capped.
+func validateMetrics(metrics types.HeartbeatMetrics) []types.FieldError {
+	var v validator
+	if metrics.ActiveSessions != nil && *metrics.ActiveSessions < 0 {
+		v.add("metrics.active_sessions", codeInvalid, "Must not be negative")
+	}
+	if metrics.CPUPercent != nil && *metrics.CPUPercent < 0 {
+		v.add("metrics.cpu_percent", codeInvalid, "Must not be negative")
+	}
+	if metrics.MemoryPercent != nil && (*metrics.MemoryPercent < 0 || *metrics.MemoryPercent > 100) {
+		v.add("metrics.memory_percent", codeInvalid, "Must be between 0 and 100")
+	}
+	if metrics.QueueDepth != nil && *metrics.QueueDepth < 0 {
+		v.add("metrics.queue_depth", codeInvalid, "Must not be negative")
+	}
+	return v.errors
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// protocolVersionLayout is the date format MCP protocol revisions are named by
+const protocolVersionLayout = "2006-01-02"
+
+func validProtocolVersion(version string) bool {
+	_, err := time.Parse(protocolVersionLayout, version)
+	return err == nil
+}
+
+// NegotiateHandler takes the capabilities and protocol version a gateway
+// supports and returns the live services it's compatible with: those speaking
+// its protocol version, sharing at least one capability with it and having
+// every required one. Each
+// service's capabilities and tools are narrowed to the shared ones, so the
+// gateway can use the response as is.
+func (h *Handler) NegotiateHandler(w http.ResponseWriter, r *http.Request) {
+	var request types.NegotiateRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	var v validator
+	if len(request.Capabilities) == 0 {
+		v.add("capabilities", codeRequired, "Capabilities are required")
+	}
+	v.maxCount("capabilities", len(request.Capabilities), maxCapabilities)
+	v.maxCount("required_capabilities", len(request.Required), maxCapabilities)
+	if request.ProtocolVersion != "" && !validProtocolVersion(request.ProtocolVersion) {
+		v.add("protocol_version", codeInvalid, "Protocol version must be a revision date like 2025-03-26")
+	}
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+
+	supported := make(map[string]bool, len(request.Capabilities))
+	for _, name := range request.Capabilities {
+		supported[name] = true
+	}
+
+	query := db.Preload(h.reader(r)).Where("state = ? AND status = ? AND last_seen >= ?",
+		types.StatePublished, types.StatusHealthy, time.Now().Add(-h.Config.ServiceTTL))
+	if request.Namespace != "" {
+		query = query.Where("namespace = ?", request.Namespace)
+	}
+	filter := serviceFilter{protocolVersion: request.ProtocolVersion}
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+	query = filter.apply(query, h.reader(r))
+	var services []types.MCPService
+	if err := query.Order("name, id").Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	response := types.NegotiateResponse{ProtocolVersion: request.ProtocolVersion, Services: []types.ServiceResponse{}}
+	for _, service := range services {
+		shared, ok := negotiate(service, supported, request.Required)
+		if !ok {
+			continue
+		}
+		service.Capabilities = shared
+		tools := make(types.Tools, 0, len(service.Tools))
+		for _, tool := range service.Tools {
+			if supported[tool.Name] {
+				tools = append(tools, tool)
+			}
+		}
+		service.Tools = tools
+		response.Services = append(response.Services, h.toResponse(service))
+	}
+	if err := h.presentServices(r, response.Services); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// negotiate returns the enabled capabilities of a service that the gateway
+// supports, reporting false if there are none or a required one is missing
+func negotiate(service types.MCPService, supported map[string]bool, required []string) ([]types.Capability, bool) {
+	enabled := make(map[string]bool, len(service.Capabilities))
+	var shared []types.Capability
+	for _, capability := range service.Capabilities {
+		if !capability.Enabled {
+			continue
+		}
+		enabled[capability.Name] = true
+		if supported[capability.Name] {
+			shared = append(shared, capability)
+		}
+	}
+	for _, name := range required {
+		if !enabled[name] {
+			return nil, false
+		}
+	}
+	return shared, len(shared) > 0
+}
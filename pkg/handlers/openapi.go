@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/openapi"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ImportOpenAPIHandler derives a service's tools and capabilities from an
+// OpenAPI document, posted as JSON or YAML or fetched from ?url=. Each
+// operation becomes a tool, replacing a declared tool of the same name, and
+// an enabled capability. With ?replace=true tools and capabilities the
+// document doesn't describe are dropped.
+func (h *Handler) ImportOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var service types.MCPService
+	if err := db.Preload(h.conn(r)).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeEdit(w, r, service) {
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if source := r.URL.Query().Get("url"); source != "" {
+		resp, err := h.fetchManifest(source)
+		if err != nil {
+			errorResponse(w, "Failed to fetch OpenAPI document: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		body = resp.Body
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxImportSize))
+	if err != nil {
+		errorResponse(w, "Failed to read OpenAPI document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	doc, err := parseOpenAPI(data)
+	if err != nil {
+		errorResponse(w, "Invalid OpenAPI document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	derived := doc.Tools()
+	if len(derived) == 0 {
+		errorResponse(w, "OpenAPI document has no operations", http.StatusBadRequest)
+		return
+	}
+
+	// Sealed metadata is carried over as it is stored
+	request := types.ServiceResponseToRegistration(types.ServiceModelToResponse(service))
+	request.Tools, request.Capabilities = mergeTools(request.Tools, request.Capabilities, derived, r.URL.Query().Get("replace") == "true")
+	if errs := h.normalizeRegistration(r, &request); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+
+	err = h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := h.checkRegistration(tx, request, serviceID); err != nil {
+			return err
+		}
+		if err := db.UpdateService(tx, &service, request, time.Now()); err != nil {
+			return err
+		}
+		// The derived tools weren't part of what was signed
+		return h.recordProvenance(r, tx, serviceID)
+	})
+	if err != nil {
+		if rejectionResponse(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+			conflictResponse(w, existingID)
+			return
+		}
+		errorResponse(w, "Failed to update service", http.StatusInternalServerError)
+		return
+	}
+
+	var updated types.MCPService
+	if err := db.Preload(h.conn(r)).First(&updated, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Service updated but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, h.toResponse(updated), http.StatusOK)
+}
+
+// mergeTools adds derived tools, and a capability for each, to a service's
+// own. Derived tools win over declared ones of the same name.
+func mergeTools(tools []types.Tool, capabilities map[string]bool, derived []types.Tool, replace bool) ([]types.Tool, map[string]bool) {
+	merged := []types.Tool{}
+	mergedCapabilities := map[string]bool{}
+	if !replace {
+		names := map[string]bool{}
+		for _, tool := range derived {
+			names[tool.Name] = true
+		}
+		for _, tool := range tools {
+			if !names[tool.Name] {
+				merged = append(merged, tool)
+			}
+		}
+		for name, enabled := range capabilities {
+			mergedCapabilities[name] = enabled
+		}
+	}
+	for _, tool := range derived {
+		merged = append(merged, tool)
+		mergedCapabilities[tool.Name] = true
+	}
+	return merged, mergedCapabilities
+}
+
+// ServiceOpenAPIHandler serves the OpenAPI document a service's api_docs hold
+// or link to, as JSON with sorted keys, for docs viewers such as Swagger UI.
+// Linked documents are fetched on each request.
+func (h *Handler) ServiceOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	var service types.MCPService
+	if err := h.reader(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	// Hidden team services look like missing ones
+	visible, err := h.canSee(r, service.TeamID, service.Visibility)
+	if err != nil {
+		errorResponse(w, "Error finding service", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+
+	var data []byte
+	switch {
+	case h.Policy.Strips(h.audience(r), "api_docs"):
+	case openapi.Inline(service.ApiDocs):
+		if notModified(w, r, service.UpdatedAt) {
+			return
+		}
+		data = []byte(service.ApiDocs)
+	case openapi.Linked(service.ApiDocs):
+		if data, err = h.fetchAPIDocs(r.Context(), service.ApiDocs); err != nil {
+			errorCodeResponse(w, CodeUpstreamUnavailable, "Failed to fetch OpenAPI document: "+err.Error(), http.StatusBadGateway, nil)
+			return
+		}
+	}
+	if data == nil {
+		errorCodeResponse(w, CodeNoOpenAPIDocument, "Service has no OpenAPI document", http.StatusNotFound, nil)
+		return
+	}
+
+	doc, err := parseOpenAPI(data)
+	if err != nil {
+		errorResponse(w, "Service's OpenAPI document is invalid: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	normalized, err := doc.Normalize()
+	if err != nil {
+		errorResponse(w, "Error encoding OpenAPI document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(normalized)
+}
+
+// checkLinkedAPIDocs fetches api_docs that link to an OpenAPI document and
+// checks it, unless the caller passed ?skip_probe=true. Links that can't be
+// fetched yet, or that turn out to serve something else, are let through.
+func (h *Handler) checkLinkedAPIDocs(r *http.Request, apiDocs string) []types.FieldError {
+	if !openapi.Linked(apiDocs) || r.URL.Query().Get("skip_probe") == "true" {
+		return nil
+	}
+	data, err := h.fetchAPIDocs(r.Context(), apiDocs)
+	if err != nil {
+		logf(r, "Not checking API docs at %s: %v", apiDocs, err)
+		return nil
+	}
+	var probe struct {
+		OpenAPI any `yaml:"openapi"`
+		Swagger any `yaml:"swagger"`
+	}
+	if yaml.Unmarshal(data, &probe) != nil || (probe.OpenAPI == nil && probe.Swagger == nil) {
+		return nil
+	}
+	if _, err := parseOpenAPI(data); err != nil {
+		return []types.FieldError{{Field: "api_docs", Code: codeInvalid, Message: "Linked OpenAPI document is invalid: " + err.Error()}}
+	}
+	return nil
+}
+
+// fetchAPIDocs downloads a linked OpenAPI document through the URL guard
+func (h *Handler) fetchAPIDocs(ctx context.Context, link string) ([]byte, error) {
+	client := &http.Client{Timeout: h.Config.ProbeTimeout, Transport: h.Guard.Transport()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, application/yaml")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxAPIDocsLength))
+}
+
+// parseOpenAPI parses and validates an OpenAPI document
+func parseOpenAPI(data []byte) (openapi.Document, error) {
+	doc, err := openapi.Parse(data)
+	if err == nil {
+		err = doc.Validate()
+	}
+	return doc, err
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/config"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/telemetry"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// redacted replaces secrets in the config view
+const redacted = types.RedactedValue
+
+// PruneHandler runs a prune immediately instead of waiting for the next interval
+func (h *Handler) PruneHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	cutoff := time.Now().Add(-h.Config.ServiceTTL)
+	pruned, err := db.PruneInactive(h.conn(r), cutoff, h.Config.ArchiveGracePeriod > 0)
+	h.Metrics.Add(telemetry.PrunedServices, int64(len(pruned)))
+
+	result := types.PruneResult{Pruned: []types.ServiceResponse{}}
+	for _, service := range pruned {
+		result.Pruned = append(result.Pruned, h.toResponse(service))
+	}
+	if err := db.RecordAudit(h.conn(r), "", types.AuditPruned, principal.Name, fmt.Sprintf("%d services", len(pruned))); err != nil {
+		logf(r, "Failed to record audit entry: %v", err)
+	}
+	if err != nil {
+		errorResponse(w, "Prune stopped early: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, result, http.StatusOK)
+}
+
+// StaleServicesHandler lists services that haven't been seen for ?older_than
+// (default the service TTL), i.e. that the next prune run would remove
+func (h *Handler) StaleServicesHandler(w http.ResponseWriter, r *http.Request) {
+	olderThan := h.Config.ServiceTTL
+	if param := r.URL.Query().Get("older_than"); param != "" {
+		var err error
+		if olderThan, err = parseWindow(param); err != nil {
+			errorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var services []types.MCPService
+	if err := db.Preload(h.conn(r)).Where("last_seen < ?", time.Now().Add(-olderThan)).
+		Order("last_seen").Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	responses := []types.ServiceResponse{}
+	for _, service := range services {
+		responses = append(responses, h.toResponse(service))
+	}
+
+	jsonResponse(w, responses, http.StatusOK)
+}
+
+// PurgeArchiveHandler permanently deletes archived services, or only those
+// archived more than ?older_than ago
+func (h *Handler) PurgeArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	cutoff := time.Now()
+	if param := r.URL.Query().Get("older_than"); param != "" {
+		olderThan, err := parseWindow(param)
+		if err != nil {
+			errorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cutoff = cutoff.Add(-olderThan)
+	}
+
+	purged, err := db.PurgeArchive(h.conn(r), cutoff)
+	if err != nil {
+		errorResponse(w, "Failed to purge archived services", http.StatusInternalServerError)
+		return
+	}
+	if err := db.RecordAudit(h.conn(r), "", types.AuditPurged, principal.Name, strconv.FormatInt(purged, 10)+" archived services"); err != nil {
+		logf(r, "Failed to record audit entry: %v", err)
+	}
+
+	jsonResponse(w, map[string]int64{"purged": purged}, http.StatusOK)
+}
+
+// ListArchiveHandler lists services that were pruned and can still be restored
+func (h *Handler) ListArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	archived, err := db.ListArchived(h.conn(r))
+	if err != nil {
+		errorResponse(w, "Error finding archived services", http.StatusInternalServerError)
+		return
+	}
+
+	responses := []types.ArchivedServiceResponse{}
+	for _, entry := range archived {
+		response := types.ArchivedServiceResponse{ArchivedAt: entry.ArchivedAt}
+		if err := json.Unmarshal([]byte(entry.Data), &response.ServiceResponse); err != nil {
+			logf(r, "Failed to decode archived service %s: %v", entry.ID, err)
+			response.ServiceResponse = types.ServiceResponse{ID: entry.ID, Namespace: entry.Namespace, Name: entry.Name, URL: entry.URL, CreatedAt: entry.CreatedAt}
+		}
+		redactSealed(response.Metadata)
+		responses = append(responses, response)
+	}
+
+	jsonResponse(w, responses, http.StatusOK)
+}
+
+// RestoreArchivedHandler re-registers an archived service as it was when
+// pruned, under its original ID and creation time
+func (h *Handler) RestoreArchivedHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	serviceID := mux.Vars(r)["id"]
+	var archived types.ArchivedService
+	if err := h.conn(r).First(&archived, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Archived service not found", http.StatusNotFound)
+		return
+	}
+
+	var previous types.ServiceResponse
+	if err := json.Unmarshal([]byte(archived.Data), &previous); err != nil {
+		errorResponse(w, "Archived service is corrupt", http.StatusInternalServerError)
+		return
+	}
+	request := types.ServiceResponseToRegistration(previous)
+
+	// The same service may have registered again since, under a new ID
+	existingID, err := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+	if err != nil {
+		errorResponse(w, "Failed to check for duplicates", http.StatusInternalServerError)
+		return
+	}
+	if existingID != "" {
+		conflictResponse(w, existingID)
+		return
+	}
+
+	err = h.conn(r).Transaction(func(tx *gorm.DB) error {
+		// Its aliases may have been taken, or its owner's quota used up,
+		// while it was archived
+		if err := h.checkRegistration(tx, request, ""); err != nil {
+			return err
+		}
+		if err := db.RestoreArchived(tx, archived, request, time.Now()); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, serviceID, types.AuditRestored, principal.Name, "")
+	})
+	if err != nil {
+		if rejectionResponse(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+			conflictResponse(w, existingID)
+			return
+		}
+		errorResponse(w, "Failed to restore service", http.StatusInternalServerError)
+		return
+	}
+
+	var restored types.MCPService
+	if err := db.Preload(h.conn(r)).First(&restored, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Service restored but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, h.toResponse(restored), http.StatusOK)
+}
+
+// purgeService serves DELETE /services/{id}?purge=true for data deletion
+// requests: it removes everything kept about the service, even once the
+// service itself was deleted or pruned. Only the audit entry recording the
+// purge remains. Backups taken before are left alone.
+func (h *Handler) purgeService(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	serviceID := getServiceID(r)
+
+	var result types.PurgeResult
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if result, err = db.PurgeService(tx, serviceID); err != nil {
+			return err
+		}
+		if !result.Service && result.Archives+result.Revisions+result.Audit+result.Events == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return db.RecordAudit(tx, "", types.AuditPurgedData, principal.Name, serviceID)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		errorResponse(w, "Failed to purge service", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, result, http.StatusOK)
+}
+
+// ConfigHandler shows the running configuration with secrets redacted
+func (h *Handler) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, struct {
+		*config.Config
+		ReadOnly bool
+	}{h.redactedConfig(), h.readOnly.Load()}, http.StatusOK)
+}
+
+// redactedConfig returns a copy of the configuration that is safe to show
+func (h *Handler) redactedConfig() *config.Config {
+	cfg := *h.Config
+	cfg.DatabaseDSN = redactDSN(cfg.DatabaseDSN)
+	cfg.ReplicaDSN = redactDSN(cfg.ReplicaDSN)
+	if cfg.AdminToken != "" {
+		cfg.AdminToken = redacted
+	}
+	if cfg.OIDCClientSecret != "" {
+		cfg.OIDCClientSecret = redacted
+	}
+	if cfg.ConsulToken != "" {
+		cfg.ConsulToken = redacted
+	}
+	if cfg.RedisPassword != "" {
+		cfg.RedisPassword = redacted
+	}
+	if cfg.BackupS3SecretKey != "" {
+		cfg.BackupS3SecretKey = redacted
+	}
+	// Webhook URLs are usually their own credential, like Slack's
+	if cfg.WebhookURL != "" {
+		cfg.WebhookURL = redacted
+	}
+	cfg.MirrorUpstream = redactURL(cfg.MirrorUpstream)
+	cfg.Peers = make([]string, len(h.Config.Peers))
+	for i, peer := range h.Config.Peers {
+		cfg.Peers[i] = redactURL(peer)
+	}
+	cfg.SigningSecrets = make(map[string]string, len(h.Config.SigningSecrets))
+	for namespace := range h.Config.SigningSecrets {
+		cfg.SigningSecrets[namespace] = redacted
+	}
+	// Collector headers usually carry credentials
+	cfg.OTLPHeaders = make(map[string]string, len(h.Config.OTLPHeaders))
+	for key := range h.Config.OTLPHeaders {
+		cfg.OTLPHeaders[key] = redacted
+	}
+	// Only the user names are shown, keyed by a placeholder instead of their token
+	cfg.APIKeys = make(map[string]string, len(h.Config.APIKeys))
+	i := 0
+	for _, name := range h.Config.APIKeys {
+		i++
+		cfg.APIKeys[fmt.Sprintf("%s-%d", redacted, i)] = name
+	}
+	return &cfg
+}
+
+// ReadOnlyHandler reports whether the registry currently refuses writes
+func (h *Handler) ReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	jsonResponse(w, types.ReadOnlyRequest{ReadOnly: h.readOnly.Load()}, http.StatusOK)
+}
+
+// SetReadOnlyHandler switches read-only mode on or off, e.g. during maintenance
+func (h *Handler) SetReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var request types.ReadOnlyRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	h.SetReadOnly(request.ReadOnly)
+	if err := db.RecordAudit(h.conn(r), "", types.AuditReadOnly, principal.Name, strconv.FormatBool(request.ReadOnly)); err != nil {
+		logf(r, "Failed to record audit entry: %v", err)
+	}
+
+	jsonResponse(w, request, http.StatusOK)
+}
+
+var dsnPassword = regexp.MustCompile(`password=\S+`)
+
+// redactDSN hides the password in a key/value or URL style database DSN
+func redactDSN(dsn string) string {
+	if parsed, err := url.Parse(dsn); err == nil && parsed.User != nil {
+		if _, ok := parsed.User.Password(); ok {
+			parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+		}
+		return parsed.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "password="+redacted)
+}
+
+// redactURL hides the user info and query parameters of a URL, which is
+// where upstream and peer URLs carry credentials
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if parsed.User != nil {
+		parsed.User = url.User(redacted)
+	}
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		for key := range query {
+			query[key] = []string{redacted}
+		}
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.String()
+}
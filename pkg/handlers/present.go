@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/redact"
+	"github.com/arnavsurve/gateway-registry/pkg/secrets"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// redactSealed replaces encrypted metadata values with types.RedactedValue
+func redactSealed(metadata map[string]string) {
+	for key, value := range metadata {
+		if secrets.Sealed(value) {
+			metadata[key] = types.RedactedValue
+		}
+	}
+}
+
+// presentServices prepares services for the caller: sensitive metadata is
+// decrypted for those allowed to read it, and the response policy for the
+// caller's audience is applied
+func (h *Handler) presentServices(r *http.Request, responses []types.ServiceResponse) error {
+	principal, ok := h.authenticate(r)
+	if ok && principal.CanReadSecrets() {
+		if err := h.revealSecrets(r, responses); err != nil {
+			return err
+		}
+	}
+	if audience := audienceOf(principal, ok); audience != "" {
+		for i := range responses {
+			h.Policy.Apply(audience, &responses[i])
+		}
+	}
+	return nil
+}
+
+// audience returns the response policy audience of the request's caller
+func (h *Handler) audience(r *http.Request) string {
+	principal, ok := h.authenticate(r)
+	return audienceOf(principal, ok)
+}
+
+// audienceOf returns the response policy audience of a caller, or an empty
+// string for those who see everything
+func audienceOf(principal auth.Principal, authenticated bool) string {
+	switch {
+	case !authenticated:
+		return redact.Anonymous
+	case !principal.Admin && !principal.Can(auth.ScopeWrite):
+		return redact.ReadOnly
+	}
+	return ""
+}
+
+// revealSecrets fills in the sensitive metadata redacted from responses.
+// Values that can't be decrypted stay redacted.
+func (h *Handler) revealSecrets(r *http.Request, responses []types.ServiceResponse) error {
+	if h.Secrets == nil {
+		return nil
+	}
+
+	var ids []string
+	keys := make(map[string]bool)
+	byID := make(map[string]int, len(responses))
+	for i, response := range responses {
+		for key, value := range response.Metadata {
+			if value != types.RedactedValue {
+				continue
+			}
+			if _, ok := byID[response.ID]; !ok {
+				ids = append(ids, response.ID)
+			}
+			byID[response.ID] = i
+			keys[key] = true
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var items []types.MetadataItem
+	if err := h.reader(r).Where("service_id IN ? AND key IN ?", ids, slices.Collect(maps.Keys(keys))).Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		response := responses[byID[item.ServiceID]]
+		if response.Metadata[item.Key] != types.RedactedValue || !secrets.Sealed(item.Value) {
+			continue
+		}
+		if value, err := h.Secrets.Open(item.Value); err == nil {
+			response.Metadata[item.Key] = value
+		} else {
+			logf(r, "Failed to decrypt metadata %s of service %s: %v", item.Key, item.ServiceID, err)
+		}
+	}
+	return nil
+}
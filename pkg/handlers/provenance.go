@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ManifestSignatureHeader carries a detached cosign or minisign signature
+// over the registration body
+const ManifestSignatureHeader = "X-Manifest-Signature"
+
+type provenanceKey struct{}
+
+// VerifyManifest wraps a registration handler so a manifest signature, when
+// one is sent, must verify against a trusted publisher's key. The publisher
+// is recorded as the service's provenance by recordProvenance.
+func (h *Handler) VerifyManifest(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get(ManifestSignatureHeader)
+		if signature == "" {
+			next(w, r)
+			return
+		}
+		if h.Publishers == nil {
+			errorResponse(w, "No publisher keys are configured to verify manifest signatures", http.StatusBadRequest)
+			return
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+		result, err := h.Publishers.Verify(body, signature)
+		if err != nil {
+			signatureResponse(w, "Manifest signature rejected: "+err.Error())
+			return
+		}
+
+		now := time.Now().UTC()
+		provenance := types.Provenance{
+			Publisher:  result.Publisher,
+			KeyID:      result.KeyID,
+			Format:     result.Format,
+			Digest:     result.Digest,
+			VerifiedAt: &now,
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), provenanceKey{}, provenance)))
+	}
+}
+
+// recordProvenance sets a service's provenance to the publisher whose
+// signature VerifyManifest checked, clearing it if the request wasn't signed
+// since the service no longer matches what was signed before
+func (h *Handler) recordProvenance(r *http.Request, tx *gorm.DB, serviceID string) error {
+	provenance, _ := r.Context().Value(provenanceKey{}).(types.Provenance)
+	return tx.Model(&types.MCPService{}).Where("id = ?", serviceID).Updates(map[string]any{
+		"provenance_publisher":   provenance.Publisher,
+		"provenance_key_id":      provenance.KeyID,
+		"provenance_format":      provenance.Format,
+		"provenance_digest":      provenance.Digest,
+		"provenance_verified_at": provenance.VerifiedAt,
+	}).Error
+}
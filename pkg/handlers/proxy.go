@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ProxyHandler forwards /proxy/{id}/... to the registered service URL. Only
+// healthy services the caller may see receive traffic, and each request is bounded by the
+// service's proxy timeout (or the registry default).
+func (h *Handler) ProxyHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	service, ok := h.findVisible(w, r, serviceID)
+	if !ok {
+		return
+	}
+
+	if service.Status != types.StatusHealthy || service.LastSeen.Before(time.Now().Add(-h.Config.ServiceTTL)) {
+		errorResponse(w, "Service is not healthy", http.StatusServiceUnavailable)
+		return
+	}
+
+	target, err := url.Parse(service.URL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		errorResponse(w, "Service has an invalid URL", http.StatusBadGateway)
+		return
+	}
+
+	timeout := h.Config.ProxyTimeout
+	if service.ProxyTimeout > 0 {
+		timeout = time.Duration(service.ProxyTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	// Everything after /proxy/{id} is relative to the service URL
+	rest := strings.TrimPrefix(r.URL.Path, "/proxy/"+serviceID)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(rest, "/")
+			pr.Out.URL.RawPath = ""
+			pr.SetXForwarded()
+			// The caller's registry credentials are for the registry, not
+			// for whoever registered the upstream
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Cookie")
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Nor may an upstream set cookies on the registry's origin
+			resp.Header.Del("Set-Cookie")
+			return nil
+		},
+		Transport: h.Guard.Transport(),
+		// Flush immediately so streamed MCP responses (SSE) aren't buffered
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, context.DeadlineExceeded) {
+				errorResponse(w, "Upstream service timed out", http.StatusGatewayTimeout)
+				return
+			}
+			errorResponse(w, "Upstream service unavailable", http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/config"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// quotaError is returned when a write would take a namespace over a quota
+type quotaError struct {
+	Quota     string `json:"quota"`
+	Namespace string `json:"namespace"`
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`      // Usage without the service being written
+	Requested int64  `json:"requested"` // What the service being written adds
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("namespace %s would exceed its %s quota of %d", e.Namespace, e.Quota, e.Limit)
+}
+
+// quotaLimit returns a namespace's limit from a quota map, falling back to
+// the default entry, and false if it's unlimited
+func quotaLimit(quotas map[string]int64, namespace string) (int64, bool) {
+	if limit, ok := quotas[namespace]; ok {
+		return limit, true
+	}
+	limit, ok := quotas[config.QuotaDefault]
+	return limit, ok
+}
+
+// checkQuota fails with a quotaError if writing the registration would take
+// its namespace over a quota. excludeID is the service being updated, empty
+// for new ones. The namespace stays locked until tx ends.
+func (h *Handler) checkQuota(tx *gorm.DB, request types.ServiceRegistrationRequest, excludeID string) error {
+	maxServices, limitServices := quotaLimit(h.Config.QuotaMaxServices, request.Namespace)
+	maxBytes, limitBytes := quotaLimit(h.Config.QuotaMaxMetadataBytes, request.Namespace)
+	if !limitServices && !limitBytes {
+		return nil
+	}
+
+	if err := db.LockNamespace(tx, request.Namespace); err != nil {
+		return err
+	}
+	usage, err := db.UsageOf(tx, request.Namespace, excludeID)
+	if err != nil {
+		return err
+	}
+
+	if limitServices && usage.Services+1 > maxServices {
+		return &quotaError{Quota: types.QuotaServices, Namespace: request.Namespace, Limit: maxServices, Used: usage.Services, Requested: 1}
+	}
+	var metadataBytes int64
+	for key, value := range request.Metadata {
+		metadataBytes += int64(len(key) + len(value))
+	}
+	if limitBytes && usage.MetadataBytes+metadataBytes > maxBytes {
+		return &quotaError{Quota: types.QuotaMetadataBytes, Namespace: request.Namespace, Limit: maxBytes, Used: usage.MetadataBytes, Requested: metadataBytes}
+	}
+	return nil
+}
+
+// quotaResponse writes a 403 if err is a quotaError, reporting whether it did
+func quotaResponse(w http.ResponseWriter, err error) bool {
+	var quotaErr *quotaError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+	errorCodeResponse(w, CodeQuotaExceeded, "Namespace "+quotaErr.Namespace+" is over its "+quotaErr.Quota+" quota",
+		http.StatusForbidden, quotaErr)
+	return true
+}
+
+// QuotasHandler lists every namespace's usage of its quotas, including
+// namespaces with a quota but no services yet
+func (h *Handler) QuotasHandler(w http.ResponseWriter, r *http.Request) {
+	usages, err := db.NamespaceUsages(h.reader(r))
+	if err != nil {
+		errorResponse(w, "Error computing quota usage", http.StatusInternalServerError)
+		return
+	}
+
+	byNamespace := make(map[string]db.NamespaceUsage, len(usages))
+	for _, usage := range usages {
+		byNamespace[usage.Namespace] = usage
+	}
+	for _, quotas := range []map[string]int64{h.Config.QuotaMaxServices, h.Config.QuotaMaxMetadataBytes} {
+		for namespace := range quotas {
+			if _, ok := byNamespace[namespace]; !ok && namespace != config.QuotaDefault {
+				byNamespace[namespace] = db.NamespaceUsage{Namespace: namespace}
+			}
+		}
+	}
+
+	response := make([]types.NamespaceQuotas, 0, len(byNamespace))
+	for namespace, usage := range byNamespace {
+		quotas := types.NamespaceQuotas{
+			Namespace:     namespace,
+			Services:      types.QuotaUsage{Used: usage.Services},
+			MetadataBytes: types.QuotaUsage{Used: usage.MetadataBytes},
+		}
+		if limit, ok := quotaLimit(h.Config.QuotaMaxServices, namespace); ok {
+			quotas.Services.Limit = &limit
+		}
+		if limit, ok := quotaLimit(h.Config.QuotaMaxMetadataBytes, namespace); ok {
+			quotas.MetadataBytes.Limit = &limit
+		}
+		response = append(response, quotas)
+	}
+	sort.Slice(response, func(i, j int) bool { return response[i].Namespace < response[j].Namespace })
+
+	jsonResponse(w, response, http.StatusOK)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/arnavsurve/gateway-registry/pkg/config"
+	"github.com/arnavsurve/gateway-registry/pkg/telemetry"
+)
+
+// RateLimit is middleware limiting how often each caller may hit each class
+// of route, per the configured requests per minute. Heartbeats can be given a
+// far higher allowance than registrations since healthy services send them
+// constantly. It needs the matched route, so it's installed with Router.Use.
+func (h *Handler) RateLimit(next http.Handler) http.Handler {
+	if len(h.Config.RateLimits) == 0 {
+		return next
+	}
+	limiter := newRateLimiter()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := routeClass(r)
+		limit, ok := h.Config.RateLimits[class]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		caller := "ip:" + clientIP(r)
+		if principal, ok := h.authenticate(r); ok {
+			caller = "key:" + principal.Name
+		}
+		if wait, ok := limiter.allow(class+"|"+caller, limit, time.Now()); !ok {
+			h.Metrics.Add(telemetry.HTTPRateLimited, 1, telemetry.Attribute{Key: "class", Value: class})
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			errorCodeResponse(w, CodeRateLimited, "Rate limit exceeded", http.StatusTooManyRequests,
+				map[string]any{"class": class, "limit_per_minute": limit})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeClass sorts a request into the class its rate limit is taken from
+func routeClass(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return config.RateLimitDefault
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return config.RateLimitDefault
+	}
+
+	switch {
+	case strings.HasSuffix(template, "/heartbeat"), strings.HasSuffix(template, "/heartbeat/stream"):
+		return config.RateLimitHeartbeat
+	case template == "/eureka/apps/{app}/{instance}" && r.Method == http.MethodPut:
+		// Eureka renewals are its heartbeats
+		return config.RateLimitHeartbeat
+	case template == "/services" && (r.Method == http.MethodPost || r.Method == http.MethodPut),
+		template == "/services/{id}" && r.Method == http.MethodPut,
+		template == "/eureka/apps/{app}" && r.Method == http.MethodPost,
+		template == "/import":
+		return config.RateLimitRegister
+	case template == "/services" && r.Method == http.MethodGet,
+		template == "/services/search",
+		template == "/resolve",
+		template == "/discover",
+		template == "/negotiate":
+		return config.RateLimitSearch
+	}
+	return config.RateLimitDefault
+}
+
+// rateLimiter keeps a token bucket per caller and route class. Each bucket
+// holds a minute's allowance and refills continuously, so short bursts are
+// allowed while the average stays under the limit.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// allow takes a token from key's bucket, reporting how long until one is
+// available if it's empty
+func (l *rateLimiter) allow(key string, perMinute int64, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Buckets untouched for a minute have refilled, so they can be dropped
+	if now.Sub(l.lastSweep) > time.Minute {
+		for k, b := range l.buckets {
+			if now.Sub(b.updated) > time.Minute {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	capacity := float64(perMinute)
+	rate := capacity / time.Minute.Seconds()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
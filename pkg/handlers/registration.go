@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/health"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// normalizeRegistration fills in defaults on a registration request and
+// returns every problem found with it
+func (h *Handler) normalizeRegistration(r *http.Request, request *types.ServiceRegistrationRequest) []types.FieldError {
+	if request.Weight == 0 {
+		request.Weight = types.DefaultWeight
+	}
+	if request.Namespace == "" {
+		request.Namespace = types.DefaultNamespace
+	}
+	errs := h.validateRegistration(*request)
+	categoryErrs, err := h.canonicalizeCategories(r, request)
+	if err != nil {
+		logf(r, "Failed to load canonical categories: %v", err)
+		categoryErrs = []types.FieldError{{Field: "categories", Code: codeInvalid, Message: "Categories could not be checked"}}
+	}
+	errs = append(errs, categoryErrs...)
+	schemaErrs, err := h.checkMetadataSchemas(r, *request)
+	if err != nil {
+		logf(r, "Failed to check metadata schemas: %v", err)
+		schemaErrs = []types.FieldError{{Field: "metadata", Code: codeInvalid, Message: "Metadata could not be checked"}}
+	}
+	errs = append(errs, schemaErrs...)
+	if request.TeamID != "" {
+		if teamErr, ok := h.checkTeam(r, request.TeamID); !ok {
+			errs = append(errs, teamErr)
+		}
+	}
+	if len(errs) == 0 {
+		errs = append(errs, h.checkLinkedAPIDocs(r, request.ApiDocs)...)
+	}
+	return errs
+}
+
+// checkReachable probes a registration's URL when registration probes are
+// enabled, unless the caller passed ?skip_probe=true. It returns a message
+// describing the failure, or an empty string if the URL answered.
+func (h *Handler) checkReachable(r *http.Request, serviceURL string) string {
+	if !h.Config.RegistrationProbe || r.URL.Query().Get("skip_probe") == "true" {
+		return ""
+	}
+
+	client := &http.Client{
+		Timeout:       h.Config.ProbeTimeout,
+		Transport:     h.Guard.Transport(),
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	if _, err := health.RoundTrip(r.Context(), client, serviceURL); err != nil {
+		return "Service URL is not reachable: " + err.Error()
+	}
+	return ""
+}
+
+// conflictResponse reports that a registration collides with an existing service
+func conflictResponse(w http.ResponseWriter, existingID string) {
+	errorCodeResponse(w, CodeDuplicateService, "A service with this name and URL is already registered in the namespace",
+		http.StatusConflict, map[string]string{"existing_id": existingID})
+}
+
+// aliasError is returned when a registration's name or aliases are already
+// used by a differently named service in its namespace
+type aliasError struct {
+	errors []types.FieldError
+}
+
+func (e *aliasError) Error() string {
+	return fmt.Sprintf("%d names are already used in the namespace", len(e.errors))
+}
+
+// checkAliases fails with an aliasError if the registration's name or
+// aliases are the name or an alias of a differently named service in its
+// namespace, since resolving them would pick either. Replicas share a name,
+// so they may share aliases too. excludeID is the service being updated.
+func checkAliases(tx *gorm.DB, request types.ServiceRegistrationRequest, excludeID string) error {
+	taken, err := db.TakenNames(tx, request.Namespace, request.Name, append([]string{request.Name}, request.Aliases...), excludeID)
+	if err != nil {
+		return err
+	}
+
+	var v validator
+	if taken[request.Name] {
+		v.add("name", codeTaken, "Already an alias of another service in the namespace")
+	}
+	for i, alias := range request.Aliases {
+		if taken[alias] {
+			v.add(fmt.Sprintf("aliases[%d]", i), codeTaken, "Already the name or an alias of another service in the namespace")
+		}
+	}
+	if len(v.errors) > 0 {
+		return &aliasError{errors: v.errors}
+	}
+	return nil
+}
+
+// checkRegistration fails with an aliasError or quotaError if the
+// registration can't be written as it is. excludeID is the service being
+// updated, empty for new ones.
+func (h *Handler) checkRegistration(tx *gorm.DB, request types.ServiceRegistrationRequest, excludeID string) error {
+	if err := checkAliases(tx, request, excludeID); err != nil {
+		return err
+	}
+	return h.checkQuota(tx, request, excludeID)
+}
+
+// rejectionResponse writes the response for an error from
+// checkRegistration, reporting whether it was one
+func rejectionResponse(w http.ResponseWriter, err error) bool {
+	var aliasErr *aliasError
+	if errors.As(err, &aliasErr) {
+		validationResponse(w, aliasErr.errors)
+		return true
+	}
+	return quotaResponse(w, err)
+}
+
+// registerService creates a new service and returns its ID. A service that
+// was pruned within the archive grace period is resurrected with its
+// original ID instead. Either way it is owned by the request's client
+// certificate, if it has one, and carries its verified provenance. The
+// caller owns the transaction.
+func (h *Handler) registerService(r *http.Request, tx *gorm.DB, request types.ServiceRegistrationRequest) (string, error) {
+	now := time.Now()
+
+	if request.State == "" {
+		request.State = h.initialState()
+	}
+
+	if err := h.checkRegistration(tx, request, ""); err != nil {
+		return "", err
+	}
+
+	if h.Config.ArchiveGracePeriod > 0 {
+		archived, err := db.FindArchived(tx, request.Namespace, request.Name, request.URL, now.Add(-h.Config.ArchiveGracePeriod))
+		if err != nil {
+			return "", err
+		}
+		if archived != nil {
+			if err := db.RestoreArchived(tx, *archived, request, now); err != nil {
+				return "", err
+			}
+			return archived.ID, h.stampService(r, tx, archived.ID)
+		}
+	}
+
+	serviceID := uuid.New().String()
+	if err := db.CreateService(tx, serviceID, request, now); err != nil {
+		return "", err
+	}
+	return serviceID, h.stampService(r, tx, serviceID)
+}
+
+// initialState is the lifecycle state new registrations start in
+func (h *Handler) initialState() string {
+	if h.Config.RequireApproval {
+		return types.StatePendingReview
+	}
+	return types.StatePublished
+}
+
+// stampService records who registered a new service: its owner and provenance
+func (h *Handler) stampService(r *http.Request, tx *gorm.DB, serviceID string) error {
+	if err := h.claimService(r, tx, serviceID); err != nil {
+		return err
+	}
+	return h.recordProvenance(r, tx, serviceID)
+}
+
+// claimService makes the request's client certificate identity the owner of
+// a service it registered
+func (h *Handler) claimService(r *http.Request, tx *gorm.DB, serviceID string) error {
+	identity, ok := auth.ClientIdentity(r)
+	if !ok {
+		return nil
+	}
+	return tx.Model(&types.MCPService{}).Where("id = ?", serviceID).Update("owner", identity).Error
+}
+
+// excludeDeprecated reports whether ?include_deprecated=false was passed
+func excludeDeprecated(r *http.Request) bool {
+	return r.URL.Query().Get("include_deprecated") == "false"
+}
+
+// stateFilter returns the lifecycle state requested with ?state=, defaulting
+// to published. An empty result means all states.
+func stateFilter(r *http.Request) (string, bool) {
+	switch state := r.URL.Query().Get("state"); state {
+	case "":
+		return types.StatePublished, true
+	case "all":
+		return "", true
+	case types.StateDraft, types.StatePendingReview, types.StatePublished, types.StateRejected:
+		return state, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ResolveHandler picks a single healthy endpoint for a service name or alias so clients
+// don't have to implement selection themselves. Candidates in the requested
+// region are preferred, then the lowest priority value, then a weighted random
+// choice among the remaining replicas.
+func (h *Handler) ResolveHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		errorResponse(w, "Query parameter 'name' is required", http.StatusBadRequest)
+		return
+	}
+	region := r.URL.Query().Get("region")
+
+	var filter serviceFilter
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error resolving service", http.StatusInternalServerError)
+		return
+	}
+
+	var candidates []types.MCPService
+	cutoff := time.Now().Add(-h.Config.ServiceTTL)
+	aliased := h.conn(r).Model(&types.ServiceAlias{}).Select("service_id").Where("name = ?", name)
+	result := filter.apply(h.conn(r), h.conn(r)).Where("(name = ? OR id IN (?)) AND state = ? AND status = ? AND last_seen >= ?",
+		name, aliased, types.StatePublished, types.StatusHealthy, cutoff).
+		Find(&candidates)
+	if result.Error != nil {
+		errorResponse(w, "Error resolving service", http.StatusInternalServerError)
+		return
+	}
+
+	selected, ok := selectEndpoint(candidates, region)
+	if !ok {
+		errorCodeResponse(w, CodeNoHealthyEndpoint, "No healthy endpoint found", http.StatusNotFound, nil)
+		return
+	}
+
+	var service types.MCPService
+	if err := db.Preload(h.conn(r)).
+		First(&service, "id = ?", selected.ID).Error; err != nil {
+		errorResponse(w, "Error resolving service", http.StatusInternalServerError)
+		return
+	}
+
+	h.Usage.Record(service.ID)
+	responses := []types.ServiceResponse{h.toResponse(service)}
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error resolving service", http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, responses[0], http.StatusOK)
+}
+
+// selectEndpoint chooses one service out of the candidates, or reports false if
+// none can take traffic
+func selectEndpoint(candidates []types.MCPService, region string) (types.MCPService, bool) {
+	if region != "" {
+		var local []types.MCPService
+		for _, service := range candidates {
+			if service.Region == region {
+				local = append(local, service)
+			}
+		}
+		// Fall back to other regions rather than failing outright
+		if len(local) > 0 {
+			candidates = local
+		}
+	}
+
+	// Keep only the best priority tier with a positive weight
+	var tier []types.MCPService
+	totalWeight := 0
+	for _, service := range candidates {
+		if service.Weight <= 0 {
+			continue
+		}
+		if len(tier) > 0 && service.Priority > tier[0].Priority {
+			continue
+		}
+		if len(tier) > 0 && service.Priority < tier[0].Priority {
+			tier = tier[:0]
+			totalWeight = 0
+		}
+		tier = append(tier, service)
+		totalWeight += service.Weight
+	}
+	if len(tier) == 0 {
+		return types.MCPService{}, false
+	}
+
+	pick := rand.IntN(totalWeight)
+	for _, service := range tier {
+		if pick < service.Weight {
+			return service, true
+		}
+		pick -= service.Weight
+	}
+	return tier[len(tier)-1], true
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// CreateReviewHandler rates a service on behalf of the authenticated user.
+// Reviewing the same service again replaces the user's previous review.
+func (h *Handler) CreateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	principal, _ := auth.FromContext(r.Context())
+
+	var submission types.ReviewSubmission
+	if !decodeJSON(w, r, &submission) {
+		return
+	}
+	if submission.Rating < 1 || submission.Rating > 5 {
+		errorResponse(w, "Rating must be between 1 and 5", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.findVisible(w, r, serviceID); !ok {
+		return
+	}
+
+	review := types.Review{
+		ServiceID: serviceID,
+		Author:    principal.Name,
+		Rating:    submission.Rating,
+		Comment:   submission.Comment,
+	}
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "service_id"}, {Name: "author"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rating", "comment", "updated_at"}),
+		}).Create(&review).Error
+		if err != nil {
+			return err
+		}
+		return updateRating(tx, serviceID)
+	})
+	if err != nil {
+		errorResponse(w, "Failed to save review", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, review, http.StatusCreated)
+}
+
+func (h *Handler) ListReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+	if _, ok := h.findVisible(w, r, serviceID); !ok {
+		return
+	}
+
+	reviews := []types.Review{}
+	if err := h.conn(r).Where("service_id = ?", serviceID).Order("updated_at DESC").Find(&reviews).Error; err != nil {
+		errorResponse(w, "Error finding reviews", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, reviews, http.StatusOK)
+}
+
+// updateRating recomputes the rating aggregates stored on a service
+func updateRating(tx *gorm.DB, serviceID string) error {
+	var aggregate struct {
+		Avg   *float64
+		Count int
+	}
+	if err := tx.Model(&types.Review{}).Select("AVG(rating) AS avg, COUNT(*) AS count").
+		Where("service_id = ?", serviceID).Scan(&aggregate).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&types.MCPService{}).Where("id = ?", serviceID).
+		UpdateColumns(map[string]any{"rating_avg": aggregate.Avg, "rating_count": aggregate.Count}).Error
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ListRevisionsHandler returns every recorded state of a service, newest first
+func (h *Handler) ListRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+	if _, ok := h.findVisible(w, r, serviceID); !ok {
+		return
+	}
+
+	revisions, err := db.Revisions(h.conn(r), serviceID)
+	if err != nil {
+		errorResponse(w, "Error reading revisions", http.StatusInternalServerError)
+		return
+	}
+	audience := h.audience(r)
+	for i := range revisions {
+		redactSealed(revisions[i].Service.Metadata)
+		h.Policy.Apply(audience, &revisions[i].Service)
+	}
+
+	jsonResponse(w, revisions, http.StatusOK)
+}
+
+// RollbackHandler restores a service to the state of an earlier revision. The
+// rollback is itself recorded as a new revision. Lifecycle state, admin
+// managed fields and the namespace, whose signing secret the request was
+// checked against, are left as they are.
+func (h *Handler) RollbackHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+	revision, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		errorResponse(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeEdit(w, r, service) {
+		return
+	}
+
+	target, err := db.FindRevision(h.conn(r), serviceID, revision)
+	if err != nil {
+		errorCodeResponse(w, CodeRevisionNotFound, "Revision not found", http.StatusNotFound, nil)
+		return
+	}
+
+	err = h.conn(r).Transaction(func(tx *gorm.DB) error {
+		// The old revision isn't what was last signed, so its provenance is cleared
+		request := types.ServiceResponseToRegistration(target.Service)
+		request.Namespace = service.Namespace
+		if err := h.checkRegistration(tx, request, serviceID); err != nil {
+			return err
+		}
+		if err := db.UpdateService(tx, &service, request, time.Now()); err != nil {
+			return err
+		}
+		return h.recordProvenance(r, tx, serviceID)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), service.Namespace, target.Service.Name, target.Service.URL)
+			conflictResponse(w, existingID)
+			return
+		}
+		if rejectionResponse(w, err) {
+			return
+		}
+		errorResponse(w, "Failed to roll back service", http.StatusInternalServerError)
+		return
+	}
+
+	var restored types.MCPService
+	if err := db.Preload(h.conn(r)).First(&restored, "id = ?", serviceID).Error; err != nil {
+		errorResponse(w, "Service rolled back but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, h.toResponse(restored), http.StatusOK)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/telemetry"
+)
+
+// overloadRetryAfter is how long callers refused for load are asked to wait
+const overloadRetryAfter = time.Second
+
+// Shed is middleware refusing requests with a 503 and a Retry-After while the
+// database is unreachable or too many requests are already being served, so
+// clients back off instead of piling on. Retry-After is jittered to spread
+// out the retries of fleets that were refused together. Requests held open
+// on purpose stop counting towards MaxInFlight while they wait; see holdOpen.
+func (h *Handler) Shed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.databaseDown.Load() {
+			h.shedResponse(w, CodeDatabaseUnavailable, "Registry database is unavailable", h.Config.DBCheckInterval)
+			return
+		}
+
+		inFlight := h.inFlight.Add(1)
+		defer h.inFlight.Add(-1)
+		if h.Config.MaxInFlight > 0 && inFlight > h.Config.MaxInFlight {
+			h.shedResponse(w, CodeOverloaded, "Registry is overloaded", overloadRetryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// holdOpen stops counting the request towards MaxInFlight until the returned
+// func is called. Blocking queries, long-polls and heartbeat streams wait
+// with it, since otherwise a fleet of idle watchers would fill the limit and
+// get every other request shed.
+func (h *Handler) holdOpen() func() {
+	h.inFlight.Add(-1)
+	return func() { h.inFlight.Add(1) }
+}
+
+func (h *Handler) shedResponse(w http.ResponseWriter, code, message string, retryAfter time.Duration) {
+	h.Metrics.Add(telemetry.HTTPShed, 1, telemetry.Attribute{Key: "reason", Value: code})
+	seconds := int(retryAfter/time.Second) + 1 + rand.IntN(int(retryAfter/time.Second)+1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	errorCodeResponse(w, code, message, http.StatusServiceUnavailable, map[string]any{"retry_after_seconds": seconds})
+}
+
+// MonitorDatabase pings the database every DBCheckInterval until ctx is
+// cancelled, so Shed can refuse requests while it's unreachable
+func (h *Handler) MonitorDatabase(ctx context.Context) {
+	sqlDB, err := h.DB.DB()
+	if err != nil {
+		log.Printf("Database monitoring disabled: %v", err)
+		return
+	}
+	ticker := time.NewTicker(h.Config.DBCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, h.Config.DBCheckInterval)
+		err := sqlDB.PingContext(pingCtx)
+		cancel()
+		switch {
+		case err != nil && !h.databaseDown.Swap(true):
+			log.Printf("Database unreachable, refusing requests: %v", err)
+		case err == nil && h.databaseDown.Swap(false):
+			h.databaseUp.Store(time.Now().UnixNano())
+			log.Printf("Database reachable again")
+		}
+	}
+}
+
+// RecoveringSince reports when the database last came back after being
+// unreachable. Services couldn't heartbeat during the outage, so pruning
+// should give them a TTL to catch up after it.
+func (h *Handler) RecoveringSince() time.Time {
+	if h.databaseDown.Load() {
+		return time.Now()
+	}
+	return time.Unix(0, h.databaseUp.Load())
+}
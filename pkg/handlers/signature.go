@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// maxNonceLength keeps stored nonces small
+const maxNonceLength = 128
+
+// Signed wraps a registration handler so writes to a namespace with a signing
+// secret must carry a fresh HMAC signature over the body, see auth.Sign. Each
+// nonce is accepted once, so a captured request can't be replayed.
+func (h *Handler) Signed(next http.HandlerFunc) http.HandlerFunc {
+	return h.signed(h.signingNamespaces, next)
+}
+
+// SignedImport is Signed for /import, which registers manifests into
+// ?namespace rather than a namespace given in the body
+func (h *Handler) SignedImport(next http.HandlerFunc) http.HandlerFunc {
+	return h.signed(func(r *http.Request, _ []byte) []string {
+		if namespace := r.URL.Query().Get("namespace"); namespace != "" {
+			return []string{namespace}
+		}
+		return []string{types.DefaultNamespace}
+	}, next)
+}
+
+// SignedService is Signed for writes to an existing service that don't carry
+// a registration, like rollbacks, so only its own namespace counts
+func (h *Handler) SignedService(next http.HandlerFunc) http.HandlerFunc {
+	return h.signed(func(r *http.Request, _ []byte) []string {
+		var service types.MCPService
+		if err := h.conn(r).Select("namespace").First(&service, "id = ?", getServiceID(r)).Error; err != nil {
+			// Left for the handler to report
+			return nil
+		}
+		return []string{service.Namespace}
+	}, next)
+}
+
+// signed requires writes to carry a signature made with the secret of every
+// namespace the namespaces func finds for them. A write can only be signed
+// for several when they share a secret.
+func (h *Handler) signed(namespaces func(*http.Request, []byte) []string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(h.Config.SigningSecrets) == 0 {
+			next(w, r)
+			return
+		}
+
+		body, ok := readBody(w, r)
+		if !ok {
+			return
+		}
+
+		var secret string
+		for _, namespace := range namespaces(r, body) {
+			required, ok := h.Config.SigningSecrets[namespace]
+			if !ok {
+				continue
+			}
+			if secret != "" && required != secret {
+				signatureResponse(w, "Services can't be moved between namespaces with different signing secrets, transfer them instead")
+				return
+			}
+			secret = required
+		}
+		if secret == "" {
+			next(w, r)
+			return
+		}
+
+		signature := r.Header.Get(auth.SignatureHeader)
+		nonce := r.Header.Get(auth.SignatureNonceHeader)
+		timestamp, err := strconv.ParseInt(r.Header.Get(auth.SignatureTimestampHeader), 10, 64)
+		if signature == "" || nonce == "" || err != nil {
+			signatureResponse(w, "Request must be signed with "+auth.SignatureHeader+", "+
+				auth.SignatureTimestampHeader+" and "+auth.SignatureNonceHeader)
+			return
+		}
+		if len(nonce) > maxNonceLength {
+			signatureResponse(w, "Signature nonce is too long")
+			return
+		}
+		if age := time.Since(time.Unix(timestamp, 0)); age > h.Config.SignatureTolerance || age < -h.Config.SignatureTolerance {
+			signatureResponse(w, "Signature timestamp is outside the allowed clock skew")
+			return
+		}
+		if !auth.VerifySignature(secret, timestamp, nonce, body, signature) {
+			signatureResponse(w, "Invalid request signature")
+			return
+		}
+
+		if err := db.ClaimNonce(h.conn(r), nonce, time.Now()); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				signatureResponse(w, "Signature nonce was already used")
+				return
+			}
+			errorResponse(w, "Failed to record signature nonce", http.StatusInternalServerError)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// signingNamespaces are the namespaces whose secrets a registration must be
+// signed with: the one being registered into, and the existing service's too
+// when updating one, since the update may move it out of there
+func (h *Handler) signingNamespaces(r *http.Request, body []byte) []string {
+	// Malformed bodies are left for the handler to reject
+	var request struct {
+		Namespace string `json:"namespace"`
+	}
+	json.Unmarshal(body, &request)
+	if request.Namespace == "" {
+		request.Namespace = types.DefaultNamespace
+	}
+	namespaces := []string{request.Namespace}
+
+	if serviceID := getServiceID(r); serviceID != "" {
+		var service types.MCPService
+		if err := h.conn(r).Select("namespace").First(&service, "id = ?", serviceID).Error; err == nil && service.Namespace != request.Namespace {
+			namespaces = append(namespaces, service.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// readBody reads the whole request body and puts it back so the handler can
+// decode it, writing a 413 or 400 and returning false if it can't be read
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			bodyTooLargeResponse(w, tooLarge.Limit)
+			return nil, false
+		}
+		errorResponse(w, "Failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+func signatureResponse(w http.ResponseWriter, message string) {
+	errorCodeResponse(w, CodeInvalidSignature, message, http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+const (
+	defaultTopLimit = 10
+	maxTopLimit     = 100
+)
+
+// ServiceStatsHandler reports how often a service was returned by the read
+// endpoints per day over ?window (default 30d)
+func (h *Handler) ServiceStatsHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "30d"
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.findVisible(w, r, serviceID); !ok {
+		return
+	}
+
+	days, err := db.UsageSince(h.conn(r), serviceID, time.Now().Add(-window))
+	if err != nil {
+		errorResponse(w, "Error reading usage", http.StatusInternalServerError)
+		return
+	}
+
+	response := types.ServiceStatsResponse{
+		ServiceID: serviceID,
+		Window:    windowParam,
+		Days:      days,
+	}
+	if response.Days == nil {
+		response.Days = []types.UsageDay{}
+	}
+	for _, day := range days {
+		response.Total += day.Count
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// TopServicesHandler lists the ?limit (default 10) most used services over ?window (default 7d)
+func (h *Handler) TopServicesHandler(w http.ResponseWriter, r *http.Request) {
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "7d"
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, ok := parseLimit(r)
+	if !ok {
+		errorResponse(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	top, err := db.TopServices(h.conn(r), time.Now().Add(-window), limit)
+	if err != nil {
+		errorResponse(w, "Error reading usage", http.StatusInternalServerError)
+		return
+	}
+	if top == nil {
+		top = []types.ServiceUsage{}
+	}
+
+	jsonResponse(w, top, http.StatusOK)
+}
+
+// TrendingServicesHandler lists the ?limit published services whose usage grew
+// the most over ?window (default 7d) compared to the window before it
+func (h *Handler) TrendingServicesHandler(w http.ResponseWriter, r *http.Request) {
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "7d"
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, ok := parseLimit(r)
+	if !ok {
+		errorResponse(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	ids, err := db.TrendingServices(h.conn(r), time.Now().Add(-window), window, limit)
+	if err != nil {
+		errorResponse(w, "Error reading usage", http.StatusInternalServerError)
+		return
+	}
+
+	filter := serviceFilter{ids: ids}
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+	var services []types.MCPService
+	if err := filter.apply(db.Preload(h.conn(r)), h.conn(r)).Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	// Keep the ranking order
+	byID := make(map[string]types.MCPService, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+	responses := []types.ServiceResponse{}
+	for _, id := range ids {
+		if service, ok := byID[id]; ok {
+			responses = append(responses, h.toResponse(service))
+		}
+	}
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, responses, http.StatusOK)
+}
+
+// RecentServicesHandler lists the ?limit most recently registered published services
+func (h *Handler) RecentServicesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseLimit(r)
+	if !ok {
+		errorResponse(w, "Invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	filter := serviceFilter{state: types.StatePublished}
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+	var services []types.MCPService
+	if err := filter.apply(db.Preload(h.conn(r)), h.conn(r)).
+		Order("created_at DESC, id").Limit(limit).Find(&services).Error; err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	responses := []types.ServiceResponse{}
+	for _, service := range services {
+		responses = append(responses, h.toResponse(service))
+	}
+	if err := h.presentServices(r, responses); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, responses, http.StatusOK)
+}
+
+// parseLimit reads ?limit, defaulting to defaultTopLimit
+func parseLimit(r *http.Request) (int, bool) {
+	param := r.URL.Query().Get("limit")
+	if param == "" {
+		return defaultTopLimit, true
+	}
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit <= 0 || limit > maxTopLimit {
+		return 0, false
+	}
+	return limit, true
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// streamBatchSize is how many services are loaded with their associations at
+// a time while streaming
+const streamBatchSize = 500
+
+// wantsNDJSON reports whether the list was requested as newline-delimited JSON
+func wantsNDJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "ndjson"
+}
+
+// streamServices writes the services matching the filter as newline-delimited
+// JSON, one object per line. Only IDs are read up front and services are
+// loaded in batches, so neither end has to hold the whole catalog. Errors
+// after the first line can't change the status, so they end the stream early.
+func (h *Handler) streamServices(w http.ResponseWriter, r *http.Request, filter serviceFilter, modified time.Time) {
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	write := func(services []types.MCPService) error {
+		responses := make([]types.ServiceResponse, len(services))
+		for i, service := range services {
+			responses[i] = h.toResponse(service)
+		}
+		if err := h.presentServices(r, responses); err != nil {
+			return err
+		}
+		for _, response := range responses {
+			h.Usage.Record(response.ID)
+			if err := encoder.Encode(response); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if err := h.scopeFilter(r, &filter); err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+	if services, ok := h.snapshotServices(filter, modified); ok {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		write(services)
+		return
+	}
+
+	rows, err := filter.query(h.reader(r).Model(&types.MCPService{}).Select("id"), h.reader(r)).Rows()
+	if err != nil {
+		errorResponse(w, "Error finding services", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	batch := make([]string, 0, streamBatchSize)
+	flush := func() error {
+		var services []types.MCPService
+		if err := db.Preload(h.reader(r)).Where("id IN ?", batch).Find(&services).Error; err != nil {
+			return err
+		}
+		// Restore the order the IDs were listed in
+		byID := make(map[string]types.MCPService, len(services))
+		for _, service := range services {
+			byID[service.ID] = service
+		}
+		services = services[:0]
+		for _, id := range batch {
+			if service, ok := byID[id]; ok {
+				services = append(services, service)
+			}
+		}
+		batch = batch[:0]
+		return write(services)
+	}
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			logf(r, "Failed to stream services: %v", err)
+			return
+		}
+		batch = append(batch, id)
+		if len(batch) == streamBatchSize {
+			if err := flush(); err != nil {
+				logf(r, "Failed to stream services: %v", err)
+				return
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logf(r, "Failed to stream services: %v", err)
+		return
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			logf(r, "Failed to stream services: %v", err)
+		}
+	}
+}
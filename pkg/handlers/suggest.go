@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Suggestion limits, kept small since they're fetched on every keystroke
+const (
+	defaultSuggestLimit = 8
+	maxSuggestLimit     = 20
+	maxSuggestQuery     = 64
+)
+
+// suggestMaxAge is how long clients and proxies may reuse suggestions
+const suggestMaxAge = time.Minute
+
+// SuggestHandler completes a partially typed search, ?q=, with published
+// service names and categories starting with it, most used first. Responses
+// are small and cacheable so UIs can call it on every keystroke.
+func (h *Handler) SuggestHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	if prefix == "" {
+		errorResponse(w, "Query parameter 'q' is required", http.StatusBadRequest)
+		return
+	}
+	if len(prefix) > maxSuggestQuery {
+		errorResponse(w, "Query parameter 'q' is too long", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSuggestLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		var err error
+		if limit, err = strconv.Atoi(param); err != nil || limit <= 0 || limit > maxSuggestLimit {
+			errorResponse(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	state, ok := h.registryState(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(suggestMaxAge/time.Second)))
+	if notModified(w, r, state.UpdatedAt) {
+		return
+	}
+
+	suggestions, ok := h.snapshotSuggestions(prefix, state.UpdatedAt)
+	if !ok {
+		err := h.Cache.Fetch("suggest:"+strconv.Itoa(limit)+":"+prefix, &suggestions, func() error {
+			names, err := db.SuggestNames(h.reader(r), prefix, limit)
+			if err != nil {
+				return err
+			}
+			categories, err := db.SuggestCategories(h.reader(r), prefix, limit)
+			suggestions = append(names, categories...)
+			return err
+		})
+		if err != nil {
+			errorResponse(w, "Error finding suggestions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rankSuggestions(suggestions, prefix)
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	jsonResponse(w, map[string]any{"query": prefix, "suggestions": suggestions}, http.StatusOK)
+}
+
+// snapshotSuggestions finds completions in the in-memory snapshot, reporting
+// false if it isn't available
+func (h *Handler) snapshotSuggestions(prefix string, modified time.Time) ([]types.Suggestion, bool) {
+	snapshot, ok := h.Catalog.Services(modified)
+	if !ok {
+		return nil, false
+	}
+
+	names := make(map[string]int64)
+	categories := make(map[string]int64)
+	for _, service := range snapshot {
+		// Suggestions are cached publicly, so team services are left out
+		if service.State != types.StatePublished || service.Visibility == types.VisibilityTeam {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(service.Name), prefix) {
+			names[service.Name]++
+		}
+		for _, category := range service.Categories {
+			if strings.HasPrefix(strings.ToLower(category.Name), prefix) {
+				categories[category.Name]++
+			}
+		}
+	}
+
+	suggestions := []types.Suggestion{}
+	for name, count := range names {
+		suggestions = append(suggestions, types.Suggestion{Text: name, Type: types.SuggestionName, Count: count})
+	}
+	for name, count := range categories {
+		suggestions = append(suggestions, types.Suggestion{Text: name, Type: types.SuggestionCategory, Count: count})
+	}
+	return suggestions, true
+}
+
+// rankSuggestions puts exact matches first, then the completions matching
+// the most services, then the shortest
+func rankSuggestions(suggestions []types.Suggestion, prefix string) {
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if exactA, exactB := strings.EqualFold(a.Text, prefix), strings.EqualFold(b.Text, prefix); exactA != exactB {
+			return exactA
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		if a.Text != b.Text {
+			return a.Text < b.Text
+		}
+		return a.Type < b.Type
+	})
+}
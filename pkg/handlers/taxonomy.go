@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/jsonschema"
+	"github.com/arnavsurve/gateway-registry/pkg/secrets"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// ListCategoriesHandler lists every known category with its service count
+func (h *Handler) ListCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	var categories []types.CategorySummary
+	err := h.Cache.Fetch("categories", &categories, func() (err error) {
+		categories, err = db.CategorySummaries(h.conn(r))
+		return err
+	})
+	if err != nil {
+		errorResponse(w, "Error finding categories", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, categories, http.StatusOK)
+}
+
+// CreateCategoryHandler adds a category to the canonical list. A category
+// may carry a JSON Schema that the metadata of services in it must match;
+// metadata values are always strings, so constrain them with enum, pattern
+// and length rather than type.
+func (h *Handler) CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var category types.CanonicalCategory
+	if !decodeJSON(w, r, &category) {
+		return
+	}
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" || len(category.Name) > maxNameLength {
+		validationResponse(w, []types.FieldError{{Field: "name", Code: codeInvalid, Message: "Name must be non-empty and at most 128 bytes"}})
+		return
+	}
+	if errs := validateMetadataSchema(category.MetadataSchema); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+
+	// Canonical names may only differ from each other by more than case
+	canonical, err := db.CanonicalCategories(h.conn(r))
+	if err != nil {
+		errorResponse(w, "Error finding categories", http.StatusInternalServerError)
+		return
+	}
+	if existing, ok := canonical[strings.ToLower(category.Name)]; ok {
+		errorResponse(w, "Category already exists as "+existing, http.StatusConflict)
+		return
+	}
+
+	if err := h.conn(r).Create(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorResponse(w, "Category already exists", http.StatusConflict)
+			return
+		}
+		errorResponse(w, "Failed to create category", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, category, http.StatusCreated)
+}
+
+// UpdateCategoryHandler replaces a canonical category's description and
+// metadata schema. Services already in the category are checked against a
+// new schema the next time they are written.
+func (h *Handler) UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var request types.CanonicalCategory
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if errs := validateMetadataSchema(request.MetadataSchema); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+
+	var category types.CanonicalCategory
+	if err := h.conn(r).First(&category, "name = ?", mux.Vars(r)["name"]).Error; err != nil {
+		errorResponse(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	category.Description = request.Description
+	category.MetadataSchema = request.MetadataSchema
+	err := h.conn(r).Model(&category).Select("description", "metadata_schema").Updates(&category).Error
+	if err != nil {
+		errorResponse(w, "Failed to update category", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, category, http.StatusOK)
+}
+
+// DeleteCategoryHandler removes a category from the canonical list. Services
+// already using it keep it.
+func (h *Handler) DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	result := h.conn(r).Delete(&types.CanonicalCategory{}, "name = ?", mux.Vars(r)["name"])
+	if result.Error != nil {
+		errorResponse(w, "Failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		errorResponse(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"message": "Category deleted"}, http.StatusOK)
+}
+
+// canonicalizeCategories rewrites a registration's categories to their
+// canonical spelling when categories are enforced, reporting unknown ones
+func (h *Handler) canonicalizeCategories(r *http.Request, request *types.ServiceRegistrationRequest) ([]types.FieldError, error) {
+	if !h.Config.EnforceCategories {
+		return nil, nil
+	}
+	canonical, err := db.CanonicalCategories(h.conn(r))
+	if err != nil || len(canonical) == 0 {
+		return nil, err
+	}
+
+	var v validator
+	for i, name := range request.Categories {
+		if spelled, ok := canonical[strings.ToLower(strings.TrimSpace(name))]; ok {
+			request.Categories[i] = spelled
+			continue
+		}
+		v.add(fmt.Sprintf("categories[%d]", i), codeUnknownCategory, "Category "+name+" is not in the canonical list")
+	}
+	return v.errors, nil
+}
+
+// checkMetadataSchemas validates a registration's metadata against the
+// schemas of its canonical categories. Sealed values, and values sent back
+// redacted, can't be read here, so only their presence is checked.
+func (h *Handler) checkMetadataSchemas(r *http.Request, request types.ServiceRegistrationRequest) ([]types.FieldError, error) {
+	if len(request.Categories) == 0 {
+		return nil, nil
+	}
+	schemas, err := db.CategorySchemas(h.conn(r), request.Categories)
+	if err != nil || len(schemas) == 0 {
+		return nil, err
+	}
+
+	metadata := make(map[string]any, len(request.Metadata))
+	for key, value := range request.Metadata {
+		metadata[key] = value
+	}
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var v validator
+	for _, name := range names {
+		schema, err := jsonschema.Compile(schemas[name])
+		if err != nil {
+			return nil, fmt.Errorf("category %s: %w", name, err)
+		}
+		for _, violation := range schema.Validate(metadata) {
+			key := pointerToken.Replace(strings.TrimPrefix(violation.Path, "/"))
+			if value := request.Metadata[key]; secrets.Sealed(value) || value == types.RedactedValue {
+				continue
+			}
+			field := "metadata"
+			if key != "" {
+				field += "." + key
+			}
+			v.add(field, codeSchemaViolation, "Category "+name+": "+violation.Message)
+		}
+	}
+	return v.errors, nil
+}
+
+// pointerToken unescapes a JSON pointer token back into a metadata key
+var pointerToken = strings.NewReplacer("~1", "/", "~0", "~")
+
+// validateMetadataSchema checks that a category's metadata schema compiles
+func validateMetadataSchema(schema types.JSONDocument) []types.FieldError {
+	if len(schema) == 0 {
+		return nil
+	}
+	if _, err := jsonschema.Compile(schema); err != nil {
+		return []types.FieldError{{Field: "metadata_schema", Code: codeInvalid, Message: "Invalid schema: " + err.Error()}}
+	}
+	return nil
+}
+
+// ListCapabilitiesHandler lists every capability exposed across the fleet, by
+// the services the caller may see
+func (h *Handler) ListCapabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	var filter serviceFilter
+	err := h.scopeFilter(r, &filter)
+	var capabilities []types.CapabilitySummary
+	if err == nil {
+		err = h.Cache.Fetch("capabilities:"+filter.scopeKey(), &capabilities, func() (err error) {
+			capabilities, err = db.CapabilitySummaries(h.conn(r), filter.scope)
+			return err
+		})
+	}
+	if err != nil {
+		errorResponse(w, "Error finding capabilities", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, capabilities, http.StatusOK)
+}
@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+func (h *Handler) ListOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	var organizations []types.Organization
+	if err := h.conn(r).Preload("Teams", func(tx *gorm.DB) *gorm.DB { return tx.Order("name") }).
+		Order("name").Find(&organizations).Error; err != nil {
+		errorResponse(w, "Error finding organizations", http.StatusInternalServerError)
+		return
+	}
+	if organizations == nil {
+		organizations = []types.Organization{}
+	}
+	jsonResponse(w, organizations, http.StatusOK)
+}
+
+// GetOrganizationHandler returns an organization with its teams
+func (h *Handler) GetOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var organization types.Organization
+	if err := h.conn(r).Preload("Teams", func(tx *gorm.DB) *gorm.DB { return tx.Order("name") }).
+		First(&organization, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeOrganizationNotFound, "Organization not found", http.StatusNotFound, nil)
+		return
+	}
+	if organization.Teams == nil {
+		organization.Teams = []types.Team{}
+	}
+	jsonResponse(w, organization, http.StatusOK)
+}
+
+func (h *Handler) CreateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var request types.OrganizationRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	var v validator
+	if request.Name == "" {
+		v.add("name", codeRequired, "Name is required")
+	}
+	v.maxLength("name", request.Name, maxNameLength)
+	v.maxLength("description", request.Description, maxDescriptionLength)
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+
+	organization := types.Organization{ID: uuid.New().String(), Name: request.Name, Description: request.Description}
+	if err := h.conn(r).Create(&organization).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorResponse(w, "An organization with this name already exists", http.StatusConflict)
+			return
+		}
+		errorResponse(w, "Failed to create organization", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, organization, http.StatusCreated)
+}
+
+// DeleteOrganizationHandler deletes an organization once its teams are gone
+func (h *Handler) DeleteOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var organization types.Organization
+	if err := h.conn(r).First(&organization, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeOrganizationNotFound, "Organization not found", http.StatusNotFound, nil)
+		return
+	}
+
+	var teams int64
+	if err := h.conn(r).Model(&types.Team{}).Where("organization_id = ?", organization.ID).Count(&teams).Error; err != nil {
+		errorResponse(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+	if teams > 0 {
+		errorCodeResponse(w, CodeConflict, "Organization still has teams", http.StatusConflict,
+			map[string]int64{"teams": teams})
+		return
+	}
+
+	if err := h.conn(r).Delete(&organization).Error; err != nil {
+		errorResponse(w, "Failed to delete organization", http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, map[string]string{"message": "Organization deleted"}, http.StatusOK)
+}
+
+func (h *Handler) CreateTeamHandler(w http.ResponseWriter, r *http.Request) {
+	var organization types.Organization
+	if err := h.conn(r).First(&organization, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeOrganizationNotFound, "Organization not found", http.StatusNotFound, nil)
+		return
+	}
+
+	var request types.TeamRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	var v validator
+	if request.Name == "" {
+		v.add("name", codeRequired, "Name is required")
+	}
+	v.maxLength("name", request.Name, maxNameLength)
+	v.maxLength("description", request.Description, maxDescriptionLength)
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+
+	team := types.Team{ID: uuid.New().String(), OrganizationID: organization.ID, Name: request.Name, Description: request.Description}
+	if err := h.conn(r).Create(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorResponse(w, "A team with this name already exists in the organization", http.StatusConflict)
+			return
+		}
+		errorResponse(w, "Failed to create team", http.StatusInternalServerError)
+		return
+	}
+
+	team.Members = []types.TeamMember{}
+	jsonResponse(w, team, http.StatusCreated)
+}
+
+// GetTeamHandler returns a team with its members. The team's services are
+// listed by GET /services?team_id=.
+func (h *Handler) GetTeamHandler(w http.ResponseWriter, r *http.Request) {
+	var team types.Team
+	if err := h.conn(r).Preload("Members", func(tx *gorm.DB) *gorm.DB { return tx.Order("principal") }).
+		First(&team, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeTeamNotFound, "Team not found", http.StatusNotFound, nil)
+		return
+	}
+	if team.Members == nil {
+		team.Members = []types.TeamMember{}
+	}
+	jsonResponse(w, team, http.StatusOK)
+}
+
+// DeleteTeamHandler deletes a team and its memberships once it no longer owns
+// any services
+func (h *Handler) DeleteTeamHandler(w http.ResponseWriter, r *http.Request) {
+	var team types.Team
+	if err := h.conn(r).First(&team, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeTeamNotFound, "Team not found", http.StatusNotFound, nil)
+		return
+	}
+
+	services, err := db.TeamServiceCount(h.conn(r), team.ID)
+	if err != nil {
+		errorResponse(w, "Failed to delete team", http.StatusInternalServerError)
+		return
+	}
+	if services > 0 {
+		errorCodeResponse(w, CodeConflict, "Team still owns services", http.StatusConflict,
+			map[string]int64{"services": services})
+		return
+	}
+
+	err = h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", team.ID).Delete(&types.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&team).Error
+	})
+	if err != nil {
+		errorResponse(w, "Failed to delete team", http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, map[string]string{"message": "Team deleted"}, http.StatusOK)
+}
+
+// SetTeamMemberHandler adds a principal to a team or changes their role.
+// Admins and the team's maintainers may manage its members.
+func (h *Handler) SetTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	team, principal, ok := h.manageTeam(w, r)
+	if !ok {
+		return
+	}
+
+	var request types.TeamMemberRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	if request.Role == "" {
+		request.Role = types.TeamRoleMember
+	}
+	var v validator
+	if request.Principal == "" {
+		v.add("principal", codeRequired, "Principal is required")
+	}
+	v.maxLength("principal", request.Principal, maxNameLength)
+	if request.Role != types.TeamRoleMember && request.Role != types.TeamRoleMaintainer {
+		v.add("role", codeInvalid, "Role must be member or maintainer")
+	}
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+
+	member := types.TeamMember{TeamID: team.ID, Principal: request.Principal, Role: request.Role}
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "team_id"}, {Name: "principal"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role"}),
+		}).Create(&member).Error; err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, "", types.AuditTeamMember, principal.Name,
+			team.Name+" ("+team.ID+"): "+request.Principal+" as "+request.Role)
+	})
+	if err != nil {
+		errorResponse(w, "Failed to save team member", http.StatusInternalServerError)
+		return
+	}
+	jsonResponse(w, member, http.StatusOK)
+}
+
+// RemoveTeamMemberHandler takes a principal out of a team. Besides admins and
+// maintainers, members may remove themselves.
+func (h *Handler) RemoveTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := mux.Vars(r)["principal"]
+	var (
+		team      types.Team
+		principal auth.Principal
+	)
+	if caller, _ := auth.FromContext(r.Context()); caller.Name == member {
+		if err := h.conn(r).First(&team, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+			errorCodeResponse(w, CodeTeamNotFound, "Team not found", http.StatusNotFound, nil)
+			return
+		}
+		principal = caller
+	} else {
+		var ok bool
+		if team, principal, ok = h.manageTeam(w, r); !ok {
+			return
+		}
+	}
+
+	removed := false
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("team_id = ? AND principal = ?", team.ID, member).Delete(&types.TeamMember{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		removed = true
+		return db.RecordAudit(tx, "", types.AuditTeamLeft, principal.Name, team.Name+" ("+team.ID+"): "+member)
+	})
+	if err != nil {
+		errorResponse(w, "Failed to remove team member", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		errorCodeResponse(w, CodeNotFound, "Not a member of the team", http.StatusNotFound, nil)
+		return
+	}
+	jsonResponse(w, map[string]string{"message": "Team member removed"}, http.StatusOK)
+}
+
+// manageTeam loads the team in the path and checks the caller may manage its
+// membership, writing an error and returning false if not
+func (h *Handler) manageTeam(w http.ResponseWriter, r *http.Request) (types.Team, auth.Principal, bool) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var team types.Team
+	if err := h.conn(r).First(&team, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		errorCodeResponse(w, CodeTeamNotFound, "Team not found", http.StatusNotFound, nil)
+		return team, principal, false
+	}
+	if principal.Admin {
+		return team, principal, true
+	}
+
+	role, err := db.TeamRole(h.conn(r), team.ID, principal.Name)
+	if err != nil {
+		errorResponse(w, "Failed to look up team membership", http.StatusInternalServerError)
+		return team, principal, false
+	}
+	if role != types.TeamRoleMaintainer {
+		errorResponse(w, "Only the team's maintainers may manage its members", http.StatusForbidden)
+		return team, principal, false
+	}
+	return team, principal, true
+}
+
+// visibleTeams returns the teams whose services the caller may see beyond
+// the public ones, or all as true for admins
+func (h *Handler) visibleTeams(r *http.Request) (teams []string, all bool, err error) {
+	principal, ok := h.authenticate(r)
+	if !ok {
+		return nil, false, nil
+	}
+	if principal.Admin {
+		return nil, true, nil
+	}
+	teams, err = db.TeamsOf(h.reader(r), principal.Name)
+	return teams, false, err
+}
+
+// scopeFilter narrows a filter to the services the caller may see
+func (h *Handler) scopeFilter(r *http.Request, filter *serviceFilter) error {
+	teams, all, err := h.visibleTeams(r)
+	filter.teams, filter.allTeams = teams, all
+	return err
+}
+
+// canSee reports whether the caller may see a service owned by teamID with
+// the given visibility
+func (h *Handler) canSee(r *http.Request, teamID, visibility string) (bool, error) {
+	if visibility != types.VisibilityTeam {
+		return true, nil
+	}
+	var filter serviceFilter
+	if err := h.scopeFilter(r, &filter); err != nil {
+		return false, err
+	}
+	return filter.visible(teamID, visibility), nil
+}
+
+// findVisible loads a service for a request about it, writing a 404 unless
+// it exists and the caller may see it. Hidden team services look like
+// missing ones.
+func (h *Handler) findVisible(w http.ResponseWriter, r *http.Request, serviceID string) (types.MCPService, bool) {
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", serviceID).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return service, false
+	}
+	visible, err := h.canSee(r, service.TeamID, service.Visibility)
+	if err != nil {
+		errorResponse(w, "Error finding service", http.StatusInternalServerError)
+		return service, false
+	}
+	if !visible {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return service, false
+	}
+	return service, true
+}
+
+// hiddenServices returns which of the given services are team services the
+// caller may not see
+func (h *Handler) hiddenServices(r *http.Request, ids []string) (map[string]bool, error) {
+	var filter serviceFilter
+	if err := h.scopeFilter(r, &filter); err != nil || filter.allTeams || len(ids) == 0 {
+		return nil, err
+	}
+	var services []types.MCPService
+	if err := h.conn(r).Select("id", "team_id", "visibility").
+		Where("id IN ? AND visibility = ?", ids, types.VisibilityTeam).Find(&services).Error; err != nil {
+		return nil, err
+	}
+	hidden := map[string]bool{}
+	for _, service := range services {
+		if !filter.visible(service.TeamID, service.Visibility) {
+			hidden[service.ID] = true
+		}
+	}
+	return hidden, nil
+}
+
+// checkTeam returns a field error unless the team exists and the caller may
+// give it services: they're a member or an admin
+func (h *Handler) checkTeam(r *http.Request, teamID string) (types.FieldError, bool) {
+	var team types.Team
+	if err := h.conn(r).First(&team, "id = ?", teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return types.FieldError{Field: "team_id", Code: codeInvalid, Message: "Team does not exist"}, false
+		}
+		return types.FieldError{Field: "team_id", Code: codeInvalid, Message: "Team could not be checked"}, false
+	}
+
+	principal, ok := h.authenticate(r)
+	if ok && principal.Admin {
+		return types.FieldError{}, true
+	}
+	if ok {
+		if role, err := db.TeamRole(h.conn(r), teamID, principal.Name); err == nil && role != "" {
+			return types.FieldError{}, true
+		}
+	}
+	return types.FieldError{Field: "team_id", Code: codeNotAllowed, Message: "Only members of the team may give it services"}, false
+}
+
+// authorizeEdit is authorizeOwner for changes to a service's registration,
+// which services owned by a team also restrict to the team's members
+func (h *Handler) authorizeEdit(w http.ResponseWriter, r *http.Request, service types.MCPService) bool {
+	if !h.authorizeOwner(w, r, service) {
+		return false
+	}
+	if h.inTeam(r, service) {
+		return true
+	}
+	errorResponse(w, "Service is owned by another team", http.StatusForbidden)
+	return false
+}
+
+// inTeam reports whether the caller passes the team half of authorizeEdit:
+// the service has no team, or the caller is an admin or one of its members
+func (h *Handler) inTeam(r *http.Request, service types.MCPService) bool {
+	if service.TeamID == "" {
+		return true
+	}
+
+	principal, ok := h.authenticate(r)
+	if ok && principal.Admin {
+		return true
+	}
+	if ok {
+		if role, err := db.TeamRole(h.conn(r), service.TeamID, principal.Name); err == nil && role != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/auth"
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// errTransferResolved is returned when a transfer is no longer pending
+var errTransferResolved = errors.New("transfer already resolved")
+
+// errOwnerChanged is returned when a service changed hands after a transfer
+// of it was requested
+var errOwnerChanged = errors.New("service changed owners")
+
+// errNotReceiver is returned when the caller may not resolve a transfer
+var errNotReceiver = errors.New("not allowed to resolve transfer")
+
+// TransferServiceHandler asks for a service to be handed to another namespace
+// or team. Anyone who may change the service can ask; the move happens once
+// the receiving party accepts it through AcceptTransferHandler.
+func (h *Handler) TransferServiceHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var service types.MCPService
+	if err := h.conn(r).First(&service, "id = ?", getServiceID(r)).Error; err != nil {
+		errorCodeResponse(w, CodeServiceNotFound, "Service not found", http.StatusNotFound, nil)
+		return
+	}
+	if rejectFederated(w, service) || !h.authorizeEdit(w, r, service) {
+		return
+	}
+
+	var request types.TransferRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+	transfer := types.ServiceTransfer{
+		ID:            uuid.New().String(),
+		ServiceID:     service.ID,
+		FromNamespace: service.Namespace,
+		FromTeamID:    service.TeamID,
+		ToNamespace:   service.Namespace,
+		ToTeamID:      service.TeamID,
+		Note:          request.Note,
+		State:         types.TransferPending,
+		RequestedBy:   principal.Name,
+	}
+	if request.Namespace != "" {
+		transfer.ToNamespace = request.Namespace
+	}
+	if request.TeamID != "" {
+		transfer.ToTeamID = request.TeamID
+	}
+
+	var v validator
+	v.maxLength("namespace", request.Namespace, maxNameLength)
+	v.maxLength("note", request.Note, maxDescriptionLength)
+	if transfer.ToNamespace == transfer.FromNamespace && transfer.ToTeamID == transfer.FromTeamID {
+		v.add("namespace", codeInvalid, "Transfer must change the service's namespace or team")
+	}
+	if request.TeamID != "" {
+		if err := h.conn(r).First(&types.Team{}, "id = ?", request.TeamID).Error; err != nil {
+			v.add("team_id", codeInvalid, "Team does not exist")
+		}
+	}
+	if len(v.errors) > 0 {
+		validationResponse(w, v.errors)
+		return
+	}
+
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, service.ID, types.AuditTransferRequested, principal.Name, transferDetails(transfer))
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorCodeResponse(w, CodeConflict, "Service already has a pending transfer", http.StatusConflict, nil)
+			return
+		}
+		errorResponse(w, "Failed to request transfer", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, transfer, http.StatusAccepted)
+}
+
+// ListTransfersHandler lists transfers by ?state, pending by default. Admins
+// see all of them, others the ones they asked for or that their teams may
+// accept.
+func (h *Handler) ListTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = types.TransferPending
+	}
+
+	query := h.conn(r).Where("state = ?", state)
+	if !principal.Admin {
+		teams, err := db.TeamsOf(h.conn(r), principal.Name)
+		if err != nil {
+			errorResponse(w, "Error finding transfers", http.StatusInternalServerError)
+			return
+		}
+		if len(teams) == 0 {
+			query = query.Where("requested_by = ?", principal.Name)
+		} else {
+			query = query.Where("requested_by = ? OR to_team_id IN ?", principal.Name, teams)
+		}
+	}
+
+	var transfers []types.ServiceTransfer
+	if err := query.Order("created_at DESC, id").Find(&transfers).Error; err != nil {
+		errorResponse(w, "Error finding transfers", http.StatusInternalServerError)
+		return
+	}
+	if transfers == nil {
+		transfers = []types.ServiceTransfer{}
+	}
+	jsonResponse(w, transfers, http.StatusOK)
+}
+
+// AcceptTransferHandler moves a service as a pending transfer asked. Quotas
+// of the receiving namespace apply, and the move fails with a 409 if the
+// service's name and URL are taken there.
+func (h *Handler) AcceptTransferHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var transfer types.ServiceTransfer
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transfer, err = db.LockTransfer(tx, mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+		if transfer.State != types.TransferPending {
+			return errTransferResolved
+		}
+		if err := h.authorizeReceiver(tx, principal, transfer); err != nil {
+			return err
+		}
+
+		var service types.MCPService
+		if err := db.Preload(tx).First(&service, "id = ?", transfer.ServiceID).Error; err != nil {
+			return err
+		}
+		if service.Namespace != transfer.FromNamespace || service.TeamID != transfer.FromTeamID {
+			return errOwnerChanged
+		}
+		if transfer.ToNamespace != transfer.FromNamespace {
+			request := types.ServiceResponseToRegistration(types.ServiceModelToResponse(service))
+			request.Namespace = transfer.ToNamespace
+			if err := h.checkRegistration(tx, request, ""); err != nil {
+				return err
+			}
+		}
+
+		if err := db.CompleteTransfer(tx, &transfer, principal.Name, time.Now()); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, transfer.ServiceID, types.AuditTransferred, principal.Name, transferDetails(transfer))
+	})
+	if err != nil {
+		if rejectionResponse(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			errorCodeResponse(w, CodeDuplicateService, "A service with this name and URL or external ID is already registered in the namespace",
+				http.StatusConflict, nil)
+			return
+		}
+		transferErrorResponse(w, err, "Failed to accept transfer")
+		return
+	}
+
+	jsonResponse(w, transfer, http.StatusOK)
+}
+
+// DeclineTransferHandler turns a pending transfer down on behalf of the
+// receiving party
+func (h *Handler) DeclineTransferHandler(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, types.TransferDeclined, types.AuditTransferDeclined, h.authorizeReceiver)
+}
+
+// CancelTransferHandler withdraws a pending transfer. Whoever asked for it,
+// members of the team giving the service away and admins may cancel it.
+func (h *Handler) CancelTransferHandler(w http.ResponseWriter, r *http.Request) {
+	h.resolveTransfer(w, r, types.TransferCancelled, types.AuditTransferCancelled,
+		func(tx *gorm.DB, principal auth.Principal, transfer types.ServiceTransfer) error {
+			if principal.Admin || principal.Name == transfer.RequestedBy {
+				return nil
+			}
+			if transfer.FromTeamID == "" {
+				return errNotReceiver
+			}
+			role, err := db.TeamRole(tx, transfer.FromTeamID, principal.Name)
+			if err != nil {
+				return err
+			}
+			if role == "" {
+				return errNotReceiver
+			}
+			return nil
+		})
+}
+
+// resolveTransfer ends a pending transfer in state without moving the
+// service, once authorize allows the caller to
+func (h *Handler) resolveTransfer(w http.ResponseWriter, r *http.Request, state, action string,
+	authorize func(*gorm.DB, auth.Principal, types.ServiceTransfer) error) {
+	principal, _ := auth.FromContext(r.Context())
+
+	var transfer types.ServiceTransfer
+	err := h.conn(r).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transfer, err = db.LockTransfer(tx, mux.Vars(r)["id"]); err != nil {
+			return err
+		}
+		if transfer.State != types.TransferPending {
+			return errTransferResolved
+		}
+		if err := authorize(tx, principal, transfer); err != nil {
+			return err
+		}
+		if err := db.ResolveTransfer(tx, &transfer, state, principal.Name, time.Now()); err != nil {
+			return err
+		}
+		return db.RecordAudit(tx, transfer.ServiceID, action, principal.Name, transferDetails(transfer))
+	})
+	if err != nil {
+		transferErrorResponse(w, err, "Failed to resolve transfer")
+		return
+	}
+
+	jsonResponse(w, transfer, http.StatusOK)
+}
+
+// authorizeReceiver returns errNotReceiver unless the caller may accept or
+// decline the transfer: a maintainer of the receiving team, or an admin
+func (h *Handler) authorizeReceiver(tx *gorm.DB, principal auth.Principal, transfer types.ServiceTransfer) error {
+	if principal.Admin {
+		return nil
+	}
+	if transfer.ToTeamID == "" || transfer.ToTeamID == transfer.FromTeamID {
+		// Nobody owns a namespace, so only admins may move services between them
+		return errNotReceiver
+	}
+	role, err := db.TeamRole(tx, transfer.ToTeamID, principal.Name)
+	if err != nil {
+		return err
+	}
+	if role != types.TeamRoleMaintainer {
+		return errNotReceiver
+	}
+	return nil
+}
+
+// transferErrorResponse writes the response for an error resolving a
+// transfer
+func transferErrorResponse(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		errorCodeResponse(w, CodeNotFound, "Transfer or its service not found", http.StatusNotFound, nil)
+	case errors.Is(err, errTransferResolved):
+		errorCodeResponse(w, CodeConflict, "Transfer is no longer pending", http.StatusConflict, nil)
+	case errors.Is(err, errOwnerChanged):
+		errorCodeResponse(w, CodeConflict, "Service changed owners after the transfer was requested", http.StatusConflict, nil)
+	case errors.Is(err, errNotReceiver):
+		errorResponse(w, "Not allowed to resolve this transfer", http.StatusForbidden)
+	default:
+		errorResponse(w, message, http.StatusInternalServerError)
+	}
+}
+
+// transferDetails describes a transfer for the audit log
+func transferDetails(transfer types.ServiceTransfer) string {
+	from, to := transfer.FromNamespace, transfer.ToNamespace
+	if transfer.FromTeamID != "" {
+		from += " (team " + transfer.FromTeamID + ")"
+	}
+	if transfer.ToTeamID != "" {
+		to += " (team " + transfer.ToTeamID + ")"
+	}
+	return "transfer " + transfer.ID + ": " + from + " to " + to
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// UpsertServiceHandler registers a service using its external ID, or else its
+// URL, as the identity within a namespace. If a matching service exists it is
+// updated and its last_seen refreshed, so restarting MCP servers don't
+// accumulate duplicates.
+// Served as PUT /services and POST /services?upsert=true.
+func (h *Handler) UpsertServiceHandler(w http.ResponseWriter, r *http.Request) {
+	var request types.ServiceRegistrationRequest
+	if !decodeJSON(w, r, &request) {
+		return
+	}
+
+	if errs := h.normalizeRegistration(r, &request); len(errs) > 0 {
+		validationResponse(w, errs)
+		return
+	}
+	if msg := h.checkReachable(r, request.URL); msg != "" {
+		validationResponse(w, []types.FieldError{{Field: "url", Code: codeUnreachable, Message: msg}})
+		return
+	}
+
+	tx := h.conn(r).Begin()
+	if tx.Error != nil {
+		errorResponse(w, "Failed to start transaction", http.StatusInternalServerError)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	code := http.StatusOK
+	var token string
+	// An external ID identifies the service even if its URL changed
+	service, err := db.FindByURL(tx, request.Namespace, request.URL)
+	if request.ExternalID != "" {
+		if byExternalID, findErr := db.FindByExternalID(tx, request.Namespace, request.ExternalID); !errors.Is(findErr, gorm.ErrRecordNotFound) {
+			service, err = byExternalID, findErr
+		}
+	}
+	if err == nil && !h.authorizeEdit(w, r, service) {
+		tx.Rollback()
+		return
+	}
+	switch {
+	case err == nil:
+		// Re-registering is as good as a heartbeat
+		service.Status = types.StatusHealthy
+		if err = h.checkRegistration(tx, request, service.ID); err == nil {
+			err = db.UpdateService(tx, &service, request, time.Now())
+		}
+		if err == nil {
+			err = h.recordProvenance(r, tx, service.ID)
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// Only new services get a token, re-registering mustn't hand out a
+		// working one for somebody else's service
+		code = http.StatusCreated
+		if service.ID, err = h.registerService(r, tx, request); err == nil {
+			token, err = h.issueHeartbeatToken(tx, service.ID)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		if rejectionResponse(w, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			existingID, _ := db.FindDuplicate(h.conn(r), request.Namespace, request.Name, request.URL)
+			conflictResponse(w, existingID)
+			return
+		}
+		errorResponse(w, "Failed to register service", http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		errorResponse(w, "Failed to commit transaction", http.StatusInternalServerError)
+		return
+	}
+
+	var saved types.MCPService
+	if err := db.Preload(h.conn(r)).
+		First(&saved, "id = ?", service.ID).Error; err != nil {
+		errorResponse(w, "Service registered but failed to retrieve details", http.StatusInternalServerError)
+		return
+	}
+
+	response := h.toResponse(saved)
+	response.HeartbeatToken = token
+	jsonResponse(w, response, code)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// UptimeHandler reports the percentage of availability buckets a service was
+// up in over ?window (default 7d), and the downtime incidents in between.
+// Buckets without any heartbeat or probe count as down, except the current
+// one which may simply not have been reported yet.
+func (h *Handler) UptimeHandler(w http.ResponseWriter, r *http.Request) {
+	serviceID := getServiceID(r)
+	if serviceID == "" {
+		errorCodeResponse(w, CodeInvalidServiceID, "Invalid service ID", http.StatusBadRequest, nil)
+		return
+	}
+
+	windowParam := r.URL.Query().Get("window")
+	if windowParam == "" {
+		windowParam = "7d"
+	}
+	window, err := parseWindow(windowParam)
+	if err != nil {
+		errorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	service, ok := h.findVisible(w, r, serviceID)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	size := types.AvailabilityBucketSize
+	from := now.Add(-window)
+	if service.CreatedAt.After(from) {
+		from = service.CreatedAt.UTC()
+	}
+	from = from.Truncate(size)
+
+	buckets, err := db.AvailabilitySince(h.conn(r), serviceID, from)
+	if err != nil {
+		errorResponse(w, "Error reading availability", http.StatusInternalServerError)
+		return
+	}
+	byStart := make(map[time.Time]types.AvailabilityBucket, len(buckets))
+	for _, bucket := range buckets {
+		byStart[bucket.BucketStart.UTC()] = bucket
+	}
+
+	response := types.UptimeResponse{
+		ServiceID: serviceID,
+		Window:    windowParam,
+		From:      from,
+		To:        now,
+		Incidents: []types.DowntimeIncident{},
+	}
+
+	current := now.Truncate(size)
+	total, up := 0, 0
+	var incident *types.DowntimeIncident
+	for start := from; !start.After(current); start = start.Add(size) {
+		bucket, ok := byStart[start]
+		if start.Equal(current) && !ok {
+			break
+		}
+
+		total++
+		if ok && bucket.Up() {
+			up++
+			if incident != nil {
+				response.Incidents = append(response.Incidents, *incident)
+				incident = nil
+			}
+			continue
+		}
+
+		if incident == nil {
+			incident = &types.DowntimeIncident{Start: start}
+		}
+		incident.End = start.Add(size)
+		incident.DurationSeconds = int64(incident.End.Sub(incident.Start).Seconds())
+	}
+	if incident != nil {
+		response.Incidents = append(response.Incidents, *incident)
+	}
+
+	if total > 0 {
+		response.UptimePercent = float64(up) / float64(total) * 100
+	} else {
+		response.UptimePercent = 100
+	}
+
+	jsonResponse(w, response, http.StatusOK)
+}
+
+// parseWindow accepts Go durations ("36h") as well as a number of days ("7d")
+func parseWindow(window string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q", window)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q", window)
+	}
+	return d, nil
+}
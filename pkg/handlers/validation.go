@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/arnavsurve/gateway-registry/pkg/openapi"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Validation error codes
+const (
+	codeRequired        = "required"
+	codeInvalid         = "invalid"
+	codeTooLong         = "too_long"
+	codeTooMany         = "too_many"
+	codeNotAllowed      = "not_allowed"
+	codeUnreachable     = "unreachable"
+	codeUnknownCategory = "unknown_category"
+	codeSchemaViolation = "schema_violation"
+	codeTaken           = "taken"
+)
+
+// validator collects field errors
+type validator struct {
+	errors []types.FieldError
+}
+
+func (v *validator) add(field, code, message string) {
+	v.errors = append(v.errors, types.FieldError{Field: field, Code: code, Message: message})
+}
+
+func (v *validator) maxLength(field, value string, limit int) {
+	if len(value) > limit {
+		v.add(field, codeTooLong, fmt.Sprintf("Must be at most %d bytes", limit))
+	}
+}
+
+func (v *validator) maxCount(field string, count, limit int) {
+	if count > limit {
+		v.add(field, codeTooMany, fmt.Sprintf("Must have at most %d entries", limit))
+	}
+}
+
+// validateRegistration checks a registration request with defaults already applied
+func (h *Handler) validateRegistration(request types.ServiceRegistrationRequest) []types.FieldError {
+	var v validator
+
+	if request.Name == "" {
+		v.add("name", codeRequired, "Name is required")
+	}
+	v.maxLength("name", request.Name, maxNameLength)
+	v.maxLength("namespace", request.Namespace, maxNameLength)
+	v.maxLength("description", request.Description, maxDescriptionLength)
+	v.maxLength("external_id", request.ExternalID, maxNameLength)
+	v.maxLength("api_docs", request.ApiDocs, maxAPIDocsLength)
+	if openapi.Inline(request.ApiDocs) && len(request.ApiDocs) <= maxAPIDocsLength {
+		if _, err := parseOpenAPI([]byte(request.ApiDocs)); err != nil {
+			v.add("api_docs", codeInvalid, "API docs must be a link or a valid OpenAPI 3 document: "+err.Error())
+		}
+	}
+
+	switch parsed, err := url.Parse(request.URL); {
+	case request.URL == "":
+		v.add("url", codeRequired, "URL is required")
+	case len(request.URL) > maxURLLength:
+		v.maxLength("url", request.URL, maxURLLength)
+	case err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "":
+		v.add("url", codeInvalid, "URL must be an absolute http or https URL")
+	default:
+		if err := h.Guard.CheckURL(request.URL); err != nil {
+			v.add("url", codeNotAllowed, "URL is not allowed: "+err.Error())
+		}
+	}
+
+	if request.Capabilities == nil {
+		v.add("capabilities", codeRequired, "Capabilities are required")
+	}
+	v.maxCount("capabilities", len(request.Capabilities), maxCapabilities)
+	for name := range request.Capabilities {
+		v.maxLength("capabilities."+name, name, maxNameLength)
+	}
+
+	if request.Categories == nil {
+		v.add("categories", codeRequired, "Categories are required")
+	}
+	v.maxCount("categories", len(request.Categories), maxCategories)
+	for i, name := range request.Categories {
+		v.maxLength(fmt.Sprintf("categories[%d]", i), name, maxNameLength)
+	}
+
+	v.maxCount("metadata", len(request.Metadata), maxMetadataEntries)
+	for key, value := range request.Metadata {
+		if len(key) > maxMetadataKeyLength {
+			v.maxLength("metadata", key, maxMetadataKeyLength)
+			continue
+		}
+		v.maxLength("metadata."+key, value, maxMetadataValueLength)
+	}
+
+	v.maxCount("aliases", len(request.Aliases), maxAliases)
+	for i, alias := range request.Aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		if alias == "" || alias == request.Name {
+			v.add(field, codeInvalid, "Aliases must be non-empty and differ from the service name")
+		} else if slices.Contains(request.Aliases[:i], alias) {
+			v.add(field, codeInvalid, "Aliases must be unique")
+		}
+		v.maxLength(field, alias, maxNameLength)
+	}
+
+	v.maxCount("tools", len(request.Tools), maxCapabilities)
+	for i, tool := range request.Tools {
+		field := fmt.Sprintf("tools[%d]", i)
+		if tool.Name == "" {
+			v.add(field+".name", codeRequired, "Tool name is required")
+		}
+		v.maxLength(field+".name", tool.Name, maxNameLength)
+		v.maxLength(field+".description", tool.Description, maxDescriptionLength)
+		if len(tool.InputSchema) > 0 {
+			var schema map[string]any
+			if json.Unmarshal(tool.InputSchema, &schema) != nil {
+				v.add(field+".input_schema", codeInvalid, "Input schema must be a JSON Schema object")
+			}
+			v.maxLength(field+".input_schema", string(tool.InputSchema), maxToolSchemaLength)
+		}
+	}
+
+	v.maxCount("extensions", len(request.Extensions), maxExtensions)
+	for name, value := range request.Extensions {
+		v.maxLength("extensions."+name, string(value), maxExtensionLength)
+	}
+	for _, violation := range h.Extensions.Validate(request.Extensions) {
+		field := "extensions." + violation.Extension + strings.ReplaceAll(violation.Path, "/", ".")
+		separator := " "
+		if violation.Path != "" {
+			separator = ": "
+		}
+		v.add(field, codeSchemaViolation, "Extension "+violation.Extension+separator+violation.Message)
+	}
+
+	v.maxCount("protocol_versions", len(request.ProtocolVersions), maxProtocolVersions)
+	for i, version := range request.ProtocolVersions {
+		if !validProtocolVersion(version) {
+			v.add(fmt.Sprintf("protocol_versions[%d]", i), codeInvalid, "Protocol version must be a revision date like 2025-03-26")
+		}
+	}
+
+	switch request.Visibility {
+	case "", types.VisibilityPublic:
+	case types.VisibilityTeam:
+		if request.TeamID == "" {
+			v.add("team_id", codeRequired, "Team visibility requires a team")
+		}
+	default:
+		v.add("visibility", codeInvalid, "Visibility must be public or team")
+	}
+
+	if request.Weight < 0 {
+		v.add("weight", codeInvalid, "Weight must not be negative")
+	}
+	if request.Priority < 0 {
+		v.add("priority", codeInvalid, "Priority must not be negative")
+	}
+	if request.ProxyTimeout < 0 {
+		v.add("proxy_timeout_seconds", codeInvalid, "Proxy timeout must not be negative")
+	}
+
+	switch request.State {
+	case "", types.StateDraft, types.StatePendingReview:
+	case types.StatePublished:
+		if h.Config.RequireApproval {
+			v.add("state", codeNotAllowed, "Services must be approved before they are published")
+		}
+	default:
+		v.add("state", codeInvalid, "State must be draft, pending_review or published")
+	}
+
+	if !request.Deprecated {
+		if request.SunsetAt != nil {
+			v.add("sunset_at", codeNotAllowed, "Sunset date requires the service to be deprecated")
+		}
+		if request.Replacement != "" {
+			v.add("replacement_service_id", codeNotAllowed, "Replacement service requires the service to be deprecated")
+		}
+	}
+
+	return v.errors
+}
+
+// validationResponse writes a 422 listing every invalid field
+func validationResponse(w http.ResponseWriter, errors []types.FieldError) {
+	errorCodeResponse(w, CodeValidationFailed, "Validation failed", http.StatusUnprocessableEntity, errors)
+}
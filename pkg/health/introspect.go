@@ -0,0 +1,107 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// LatestProtocolVersion is the newest MCP revision offered when introspecting
+const LatestProtocolVersion = "2025-06-18"
+
+// maxInitializeResponse bounds how much of an initialize response is read
+const maxInitializeResponse = 1 << 20
+
+// ProtocolVersion sends an MCP initialize request to a service over the
+// streamable HTTP transport and returns the protocol revision it answers
+// with, which is the offered one if it supports it and its own latest
+// otherwise
+func ProtocolVersion(ctx context.Context, client *http.Client, url string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "initialize",
+		"params": map[string]any{
+			"protocolVersion": LatestProtocolVersion,
+			"capabilities":    map[string]any{},
+			"clientInfo":      map[string]string{"name": "gateway-registry", "version": "1.0"},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{resp.StatusCode}
+	}
+
+	// Servers may answer with plain JSON or a single-event SSE stream
+	payload := io.LimitReader(resp.Body, maxInitializeResponse)
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/event-stream" {
+		data, err := firstEventData(payload)
+		if err != nil {
+			return "", err
+		}
+		payload = strings.NewReader(data)
+	}
+
+	var result struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(payload).Decode(&result); err != nil {
+		return "", fmt.Errorf("decoding initialize response: %w", err)
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("initialize failed: %s", result.Error.Message)
+	}
+	if result.Result.ProtocolVersion == "" {
+		return "", errors.New("initialize response has no protocol version")
+	}
+	return result.Result.ProtocolVersion, nil
+}
+
+// firstEventData returns the data of the first event in an SSE stream
+func firstEventData(r io.Reader) (string, error) {
+	var data []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxInitializeResponse)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" && len(data) > 0 {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	if len(data) == 0 {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", errors.New("event stream ended without an event")
+	}
+	return strings.Join(data, "\n"), nil
+}
@@ -0,0 +1,228 @@
+package health
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/leader"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+const (
+	// latencyWindow is how many recent probes latency percentiles are computed over
+	latencyWindow = 20
+	// probeConcurrency bounds how many services are probed at once
+	probeConcurrency = 16
+)
+
+// Prober periodically sends a request to every local service, recording
+// whether it answered in the availability history and its streaks, and
+// keeping rolling p50/p95 round-trip latencies on the service record. Services that didn't declare
+// their MCP protocol versions have them learned from an initialize handshake.
+type Prober struct {
+	DB       *gorm.DB
+	Interval time.Duration
+	Client   *http.Client
+	Leader   *leader.Elector // Only probe while this instance is the leader, if set
+	Policy   db.StreakPolicy // When probe outcomes change a service's status
+
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+}
+
+// NewProber creates a Prober whose requests give up after timeout and connect through transport
+func NewProber(db *gorm.DB, interval, timeout time.Duration, transport http.RoundTripper) *Prober {
+	return &Prober{
+		DB:       db,
+		Interval: interval,
+		Client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// A redirect still proves the server is up
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		samples: make(map[string][]time.Duration),
+	}
+}
+
+// Run probes every interval until the context is cancelled
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !p.Leader.IsLeader() {
+			continue
+		}
+		if err := p.ProbeAll(ctx); err != nil {
+			log.Printf("Health probe run failed: %v", err)
+		}
+	}
+}
+
+// ProbeAll probes every local service once
+func (p *Prober) ProbeAll(ctx context.Context) error {
+	var services []types.MCPService
+	if err := p.DB.Select("id", "url", "protocol_versions").Where("origin = ''").Find(&services).Error; err != nil {
+		return err
+	}
+
+	p.forgetMissing(services)
+
+	sem := make(chan struct{}, probeConcurrency)
+	var wg sync.WaitGroup
+	for _, service := range services {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(service types.MCPService) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			p.probe(ctx, service)
+		}(service)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (p *Prober) probe(ctx context.Context, service types.MCPService) {
+	latency, err := p.roundTrip(ctx, service.URL)
+	now := time.Now()
+
+	if err := db.RecordAvailability(p.DB, service.ID, err == nil, now); err != nil {
+		log.Printf("Failed to record availability for %s: %v", service.ID, err)
+	}
+	if p.Policy.FailureThreshold > 0 {
+		success := err == nil
+		err := p.DB.Transaction(func(tx *gorm.DB) error {
+			changed, err := db.RecordOutcome(tx, service.ID, success, p.Policy, now)
+			if changed {
+				log.Printf("Service %s changed status after probe streak", service.ID)
+			}
+			return err
+		})
+		if err != nil {
+			log.Printf("Failed to record probe outcome for %s: %v", service.ID, err)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	p50, p95 := p.observe(service.ID, latency)
+	err = p.DB.Model(&types.MCPService{}).Where("id = ?", service.ID).
+		UpdateColumns(map[string]any{"latency_p50_ms": p50, "latency_p95_ms": p95}).Error
+	if err != nil {
+		log.Printf("Failed to store latency for %s: %v", service.ID, err)
+	}
+
+	// Handshakes open sessions on the service, so versions are only learned once
+	if len(service.ProtocolVersions) == 0 {
+		p.learnProtocolVersion(ctx, service)
+	}
+}
+
+// learnProtocolVersion records the protocol version a service negotiates.
+// Services that can't be introspected are left without one.
+func (p *Prober) learnProtocolVersion(ctx context.Context, service types.MCPService) {
+	version, err := ProtocolVersion(ctx, p.Client, service.URL)
+	if err != nil {
+		return
+	}
+	// Only fill it in if registration hasn't declared versions meanwhile
+	err = p.DB.Model(&types.MCPService{}).Where("id = ? AND protocol_versions IS NULL", service.ID).
+		UpdateColumn("protocol_versions", types.StringList{version}).Error
+	if err != nil {
+		log.Printf("Failed to store protocol version for %s: %v", service.ID, err)
+	}
+}
+
+func (p *Prober) roundTrip(ctx context.Context, url string) (time.Duration, error) {
+	return RoundTrip(ctx, p.Client, url)
+}
+
+// RoundTrip times a GET to a service. Any response below 500 counts as up,
+// since MCP endpoints commonly reject plain GETs with 4xx.
+func RoundTrip(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	latency := time.Since(start)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return 0, &statusError{resp.StatusCode}
+	}
+	return latency, nil
+}
+
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return "unhealthy status " + http.StatusText(e.code)
+}
+
+// observe adds a sample and returns the current p50 and p95 in milliseconds
+func (p *Prober) observe(serviceID string, latency time.Duration) (float64, float64) {
+	p.mu.Lock()
+	samples := append(p.samples[serviceID], latency)
+	if len(samples) > latencyWindow {
+		samples = samples[len(samples)-latencyWindow:]
+	}
+	p.samples[serviceID] = samples
+	sorted := append([]time.Duration(nil), samples...)
+	p.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return percentileMs(sorted, 0.50), percentileMs(sorted, 0.95)
+}
+
+// forgetMissing drops samples for services that no longer exist
+func (p *Prober) forgetMissing(services []types.MCPService) {
+	live := make(map[string]bool, len(services))
+	for _, service := range services {
+		live[service.ID] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.samples {
+		if !live[id] {
+			delete(p.samples, id)
+		}
+	}
+}
+
+// percentileMs uses the nearest-rank method on sorted samples
+func percentileMs(sorted []time.Duration, q float64) float64 {
+	rank := int(q*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return float64(sorted[rank-1].Microseconds()) / 1000
+}
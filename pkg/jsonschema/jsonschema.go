@@ -0,0 +1,419 @@
+// Package jsonschema validates JSON values against the part of JSON Schema
+// operators need to describe registration data: types, required and
+// additional properties, enums, string and number bounds, arrays and the
+// allOf, anyOf, oneOf and not combinators. Schemas using other keywords are
+// refused when compiled rather than half enforced.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// annotations are keywords that describe a schema without constraining it
+var annotations = map[string]bool{
+	"$schema": true, "$id": true, "$comment": true, "title": true,
+	"description": true, "default": true, "examples": true, "deprecated": true,
+}
+
+// types are the values the type keyword accepts
+var types = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// formats are the string formats checked, others are refused
+var formats = map[string]func(string) bool{
+	"email":     func(s string) bool { a, err := mail.ParseAddress(s); return err == nil && a.Address == s },
+	"uri":       func(s string) bool { u, err := url.Parse(s); return err == nil && u.Scheme != "" },
+	"date-time": func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil },
+	"date":      func(s string) bool { _, err := time.Parse(time.DateOnly, s); return err == nil },
+}
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	never bool // The false schema, which nothing matches
+
+	types                []string
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	items                *Schema
+	enum                 []any
+	constant             any
+	hasConst             bool
+	pattern              *regexp.Regexp
+	format               string
+	minLength, maxLength *int
+	minItems, maxItems   *int
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+	allOf, anyOf, oneOf  []*Schema
+	not                  *Schema
+}
+
+// Violation is a way a value doesn't match a schema
+type Violation struct {
+	Path    string `json:"path"` // JSON pointer to the offending value, empty for the value itself
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Compile parses a schema, refusing keywords this package doesn't enforce
+func Compile(data []byte) (*Schema, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("schema is not JSON: %w", err)
+	}
+	return compile(v, "")
+}
+
+func compile(v any, at string) (*Schema, error) {
+	switch v := v.(type) {
+	case bool:
+		return &Schema{never: !v}, nil
+	case map[string]any:
+		s := &Schema{}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := s.keyword(key, v[key], at+"/"+key); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	if at == "" {
+		return nil, fmt.Errorf("schema must be an object or a boolean")
+	}
+	return nil, fmt.Errorf("%s: schema must be an object or a boolean", at)
+}
+
+// keyword compiles one keyword of a schema. at is the keyword's location,
+// which errors start with.
+func (s *Schema) keyword(key string, value any, at string) error {
+	fail := func(format string, args ...any) error {
+		return fmt.Errorf("%s: %s", at, fmt.Sprintf(format, args...))
+	}
+	var err error
+	switch key {
+	case "type":
+		switch value := value.(type) {
+		case string:
+			s.types = []string{value}
+		case []any:
+			for _, t := range value {
+				name, _ := t.(string)
+				s.types = append(s.types, name)
+			}
+		}
+		if len(s.types) == 0 {
+			return fail("must be a type name or a list of them")
+		}
+		for _, t := range s.types {
+			if !types[t] {
+				return fail("unknown type %q", t)
+			}
+		}
+	case "properties":
+		properties, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		s.properties = make(map[string]*Schema, len(properties))
+		for name, property := range properties {
+			if s.properties[name], err = compile(property, at+"/"+name); err != nil {
+				return err
+			}
+		}
+	case "required":
+		list, ok := value.([]any)
+		if !ok {
+			return fail("must be a list of property names")
+		}
+		for _, name := range list {
+			name, ok := name.(string)
+			if !ok {
+				return fail("must be a list of property names")
+			}
+			s.required = append(s.required, name)
+		}
+	case "additionalProperties":
+		s.additionalProperties, err = compile(value, at)
+	case "items":
+		s.items, err = compile(value, at)
+	case "enum":
+		list, ok := value.([]any)
+		if !ok || len(list) == 0 {
+			return fail("must be a non-empty list")
+		}
+		s.enum = list
+	case "const":
+		s.constant, s.hasConst = value, true
+	case "pattern":
+		pattern, ok := value.(string)
+		if !ok {
+			return fail("must be a regular expression")
+		}
+		if s.pattern, err = regexp.Compile(pattern); err != nil {
+			return fail("invalid regular expression: %v", err)
+		}
+	case "format":
+		s.format, _ = value.(string)
+		if formats[s.format] == nil {
+			return fail("unsupported format %q", value)
+		}
+	case "minLength":
+		s.minLength, err = count(value, at)
+	case "maxLength":
+		s.maxLength, err = count(value, at)
+	case "minItems":
+		s.minItems, err = count(value, at)
+	case "maxItems":
+		s.maxItems, err = count(value, at)
+	case "minimum":
+		s.minimum, err = number(value, at)
+	case "maximum":
+		s.maximum, err = number(value, at)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = number(value, at)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = number(value, at)
+	case "allOf":
+		s.allOf, err = compileList(value, at)
+	case "anyOf":
+		s.anyOf, err = compileList(value, at)
+	case "oneOf":
+		s.oneOf, err = compileList(value, at)
+	case "not":
+		s.not, err = compile(value, at)
+	default:
+		if !annotations[key] {
+			return fail("unsupported keyword")
+		}
+	}
+	return err
+}
+
+func compileList(value any, at string) ([]*Schema, error) {
+	list, ok := value.([]any)
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty list of schemas", at)
+	}
+	schemas := make([]*Schema, len(list))
+	for i, item := range list {
+		var err error
+		if schemas[i], err = compile(item, fmt.Sprintf("%s/%d", at, i)); err != nil {
+			return nil, err
+		}
+	}
+	return schemas, nil
+}
+
+func count(value any, at string) (*int, error) {
+	n, ok := value.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", at)
+	}
+	c := int(n)
+	return &c, nil
+}
+
+func number(value any, at string) (*float64, error) {
+	n, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", at)
+	}
+	return &n, nil
+}
+
+// Validate reports every way v, a value decoded by encoding/json, doesn't
+// match the schema
+func (s *Schema) Validate(v any) []Violation {
+	var violations []Violation
+	s.validate(v, "", &violations)
+	return violations
+}
+
+func (s *Schema) validate(v any, at string, violations *[]Violation) {
+	report := func(format string, args ...any) {
+		*violations = append(*violations, Violation{Path: at, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.never {
+		report("no value is allowed")
+		return
+	}
+	if len(s.types) > 0 && !s.matchesType(v) {
+		report("must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.enum != nil && !contains(s.enum, v) {
+		report("must be one of %s", describe(s.enum))
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		report("must be %s", describe([]any{s.constant}))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				*violations = append(*violations, Violation{Path: at + "/" + escape(name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := at + "/" + escape(name)
+			if property, ok := s.properties[name]; ok {
+				property.validate(v[name], path, violations)
+			} else if s.additionalProperties != nil {
+				if s.additionalProperties.never {
+					*violations = append(*violations, Violation{Path: path, Message: "is not allowed"})
+					continue
+				}
+				s.additionalProperties.validate(v[name], path, violations)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			report("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			report("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s/%d", at, i), violations)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.minLength != nil && length < *s.minLength {
+			report("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report("must match %s", s.pattern)
+		}
+		if s.format != "" && !formats[s.format](v) {
+			report("must be a valid %s", s.format)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			report("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			report("must be at most %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			report("must be greater than %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			report("must be less than %v", *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, at, violations)
+	}
+	if s.anyOf != nil && s.matching(s.anyOf, v) == 0 {
+		report("must match at least one of the anyOf schemas")
+	}
+	if s.oneOf != nil {
+		if n := s.matching(s.oneOf, v); n != 1 {
+			report("must match exactly one of the oneOf schemas, matches %d", n)
+		}
+	}
+	if s.not != nil && len(s.not.Validate(v)) == 0 {
+		report("must not match the not schema")
+	}
+}
+
+func (s *Schema) matching(schemas []*Schema, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.Validate(v)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Schema) matchesType(v any) bool {
+	for _, t := range s.types {
+		switch v := v.(type) {
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(values []any, v any) bool {
+	for _, value := range values {
+		if reflect.DeepEqual(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(values []any) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		data, _ := json.Marshal(value)
+		parts[i] = string(data)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// escape encodes a property name as a JSON pointer token
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,207 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// CRD coordinates of MCPService resources, see deploy/kubernetes/crd.yaml
+const (
+	crdGroup    = "registry.mcp.dev"
+	crdVersion  = "v1alpha1"
+	crdResource = "mcpservices"
+)
+
+// OriginCRD is the origin of services registered from MCPService resources
+const OriginCRD = "kubernetes:mcpservice"
+
+// kubeNamespace derives stable registry IDs from Kubernetes object UIDs
+var kubeNamespace = uuid.MustParse("9d2f6c1e-3b7a-4f58-8e0d-5a4c1b2e7f90")
+
+// MCPServiceResource is an MCPService custom resource
+type MCPServiceResource struct {
+	Metadata ObjectMeta        `json:"metadata"`
+	Spec     MCPServiceSpec    `json:"spec"`
+	Status   *MCPServiceStatus `json:"status,omitempty"`
+}
+
+// MCPServiceSpec declares the registration of an in-cluster MCP server
+type MCPServiceSpec struct {
+	Name         string            `json:"name"` // Defaults to the resource name
+	Namespace    string            `json:"namespace"`
+	Description  string            `json:"description"`
+	URL          string            `json:"url"`
+	Capabilities map[string]bool   `json:"capabilities"`
+	Categories   []string          `json:"categories"`
+	Metadata     map[string]string `json:"metadata"`
+	Weight       int               `json:"weight"`
+	Priority     int               `json:"priority"`
+	Region       string            `json:"region"`
+}
+
+// MCPServiceStatus is reported back to the resource after each reconcile
+type MCPServiceStatus struct {
+	ServiceID          string    `json:"serviceId,omitempty"`
+	Registered         bool      `json:"registered"`
+	Message            string    `json:"message,omitempty"`
+	ObservedGeneration int64     `json:"observedGeneration"`
+	LastSyncTime       time.Time `json:"lastSyncTime"`
+}
+
+// Controller keeps registry entries in sync with MCPService resources. Each
+// pass registers or refreshes an entry for every resource, deletes entries
+// whose resource is gone and writes the outcome to the resource's status.
+// Entries are read-only through the API since the resource owns them.
+type Controller struct {
+	DB        *gorm.DB
+	Client    *Client
+	Namespace string // Kubernetes namespace to watch, empty for all
+	Interval  time.Duration
+}
+
+// Run reconciles on each interval until the context is cancelled
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Reconcile(ctx); err != nil {
+			log.Printf("Failed to reconcile MCPService resources: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile runs a single pass over every MCPService resource
+func (c *Controller) Reconcile(ctx context.Context) error {
+	var list struct {
+		Items []MCPServiceResource `json:"items"`
+	}
+	if err := c.Client.Get(ctx, resourcePath(c.Namespace, ""), &list); err != nil {
+		return err
+	}
+
+	current := make([]string, 0, len(list.Items))
+	for _, resource := range list.Items {
+		serviceID := uuid.NewSHA1(kubeNamespace, []byte(resource.Metadata.UID)).String()
+		current = append(current, serviceID)
+
+		status := MCPServiceStatus{
+			ServiceID:          serviceID,
+			Registered:         true,
+			ObservedGeneration: resource.Metadata.Generation,
+			LastSyncTime:       time.Now().UTC(),
+		}
+		if err := c.register(serviceID, resource); err != nil {
+			status.Registered = false
+			status.Message = err.Error()
+		}
+
+		path := resourcePath(resource.Metadata.Namespace, resource.Metadata.Name) + "/status"
+		if err := c.Client.MergePatch(ctx, path, map[string]any{"status": status}); err != nil {
+			log.Printf("Failed to update status of %s/%s: %v", resource.Metadata.Namespace, resource.Metadata.Name, err)
+		}
+	}
+
+	return removeStale(c.DB, OriginCRD, current)
+}
+
+// register creates or refreshes the registry entry for a resource
+func (c *Controller) register(serviceID string, resource MCPServiceResource) error {
+	spec := resource.Spec
+	if spec.Name == "" {
+		spec.Name = resource.Metadata.Name
+	}
+	return saveSpec(c.DB, serviceID, OriginCRD, spec, resource.Metadata.CreationTimestamp)
+}
+
+// saveSpec creates or refreshes a registry entry owned by the cluster
+func saveSpec(database *gorm.DB, serviceID, origin string, spec MCPServiceSpec, created time.Time) error {
+	if spec.Namespace == "" {
+		spec.Namespace = types.DefaultNamespace
+	}
+	if spec.Weight == 0 {
+		spec.Weight = types.DefaultWeight
+	}
+	if parsed, err := url.Parse(spec.URL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+
+	service := types.MCPService{
+		ID:          serviceID,
+		Namespace:   spec.Namespace,
+		Name:        spec.Name,
+		Description: spec.Description,
+		URL:         spec.URL,
+		CreatedAt:   created,
+		LastSeen:    time.Now(),
+		Weight:      spec.Weight,
+		Priority:    spec.Priority,
+		Region:      spec.Region,
+		Status:      types.StatusHealthy,
+		State:       types.StatePublished,
+		Origin:      origin,
+	}
+	for name, enabled := range spec.Capabilities {
+		service.Capabilities = append(service.Capabilities, types.Capability{ServiceID: serviceID, Name: name, Enabled: enabled})
+	}
+	for _, name := range spec.Categories {
+		service.Categories = append(service.Categories, types.Category{ServiceID: serviceID, Name: name})
+	}
+	for key, value := range spec.Metadata {
+		service.Metadata = append(service.Metadata, types.MetadataItem{ServiceID: serviceID, Key: key, Value: value})
+	}
+
+	return database.Transaction(func(tx *gorm.DB) error {
+		return db.SaveService(tx, service)
+	})
+}
+
+// removeStale deletes the services of an origin that aren't in current
+func removeStale(database *gorm.DB, origin string, current []string) error {
+	query := database.Model(&types.MCPService{}).Where("origin = ?", origin)
+	if len(current) > 0 {
+		query = query.Where("id NOT IN ?", current)
+	}
+	var stale []string
+	if err := query.Pluck("id", &stale).Error; err != nil {
+		return err
+	}
+
+	for _, id := range stale {
+		err := database.Transaction(func(tx *gorm.DB) error {
+			return db.DeleteService(tx, id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resourcePath is the API path of MCPService resources in a namespace, or of one resource
+func resourcePath(namespace, name string) string {
+	path := "/apis/" + crdGroup + "/" + crdVersion
+	if namespace != "" {
+		path += "/namespaces/" + url.PathEscape(namespace)
+	}
+	path += "/" + crdResource
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
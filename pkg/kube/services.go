@@ -0,0 +1,167 @@
+package kube
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OriginService is the origin of services registered from labeled Kubernetes Services
+const OriginService = "kubernetes:service"
+
+// Label selecting Services to register, and annotations refining their registration
+const (
+	enabledLabel          = "mcp.enabled"
+	annotationURL         = "mcp.registry/url" // Full URL, e.g. of an ingress, instead of the cluster URL
+	annotationPort        = "mcp.registry/port"
+	annotationPath        = "mcp.registry/path"
+	annotationScheme      = "mcp.registry/scheme"
+	annotationName        = "mcp.registry/name"
+	annotationNamespace   = "mcp.registry/namespace"
+	annotationDescription = "mcp.registry/description"
+	annotationCategories  = "mcp.registry/categories" // Comma separated
+)
+
+// serviceResource is the subset of a core/v1 Service the watcher reads
+type serviceResource struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"spec"`
+}
+
+// ServiceWatcher registers every Kubernetes Service labeled mcp.enabled=true
+// under its cluster DNS URL, or the URL in its mcp.registry/url annotation,
+// so servers don't need to embed a registration client. Entries are removed
+// once their Service is deleted or unlabeled.
+type ServiceWatcher struct {
+	DB            *gorm.DB
+	Client        *Client
+	Namespace     string // Kubernetes namespace to watch, empty for all
+	ClusterDomain string
+	Interval      time.Duration
+}
+
+// Run syncs on each interval until the context is cancelled
+func (sw *ServiceWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(sw.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := sw.Sync(ctx); err != nil {
+			log.Printf("Failed to sync Kubernetes services: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync runs a single pass over every labeled Service
+func (sw *ServiceWatcher) Sync(ctx context.Context) error {
+	path := "/api/v1"
+	if sw.Namespace != "" {
+		path += "/namespaces/" + url.PathEscape(sw.Namespace)
+	}
+	path += "/services?labelSelector=" + url.QueryEscape(enabledLabel+"=true")
+
+	var list struct {
+		Items []serviceResource `json:"items"`
+	}
+	if err := sw.Client.Get(ctx, path, &list); err != nil {
+		return err
+	}
+
+	current := make([]string, 0, len(list.Items))
+	for _, resource := range list.Items {
+		serviceID := uuid.NewSHA1(kubeNamespace, []byte(resource.Metadata.UID)).String()
+		current = append(current, serviceID)
+
+		spec, ok := sw.spec(resource)
+		if !ok {
+			log.Printf("Kubernetes service %s/%s has no usable port", resource.Metadata.Namespace, resource.Metadata.Name)
+			continue
+		}
+		if err := saveSpec(sw.DB, serviceID, OriginService, spec, resource.Metadata.CreationTimestamp); err != nil {
+			log.Printf("Failed to register Kubernetes service %s/%s: %v", resource.Metadata.Namespace, resource.Metadata.Name, err)
+		}
+	}
+
+	return removeStale(sw.DB, OriginService, current)
+}
+
+// spec derives a registration from a Service's ports and annotations
+func (sw *ServiceWatcher) spec(resource serviceResource) (MCPServiceSpec, bool) {
+	annotations := resource.Metadata.Annotations
+	spec := MCPServiceSpec{
+		Name:         annotations[annotationName],
+		Namespace:    annotations[annotationNamespace],
+		Description:  annotations[annotationDescription],
+		URL:          annotations[annotationURL],
+		Capabilities: map[string]bool{},
+		Metadata: map[string]string{
+			"kubernetes_namespace": resource.Metadata.Namespace,
+			"kubernetes_service":   resource.Metadata.Name,
+		},
+	}
+	if spec.Name == "" {
+		spec.Name = resource.Metadata.Name
+	}
+	for _, category := range strings.Split(annotations[annotationCategories], ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			spec.Categories = append(spec.Categories, category)
+		}
+	}
+	if spec.URL != "" {
+		return spec, true
+	}
+
+	port, ok := servicePort(resource, annotations[annotationPort])
+	if !ok {
+		return spec, false
+	}
+	scheme := annotations[annotationScheme]
+	if scheme == "" {
+		scheme = "http"
+		if port == 443 {
+			scheme = "https"
+		}
+	}
+	host := resource.Metadata.Name + "." + resource.Metadata.Namespace + ".svc." + sw.ClusterDomain
+	spec.URL = scheme + "://" + host + ":" + strconv.Itoa(port) + "/" + strings.TrimPrefix(annotations[annotationPath], "/")
+	return spec, true
+}
+
+// servicePort picks the port named or numbered by the annotation, then one
+// named "mcp" or "http", then the first port
+func servicePort(resource serviceResource, annotation string) (int, bool) {
+	ports := resource.Spec.Ports
+	if len(ports) == 0 {
+		return 0, false
+	}
+	for _, port := range ports {
+		if annotation != "" && (port.Name == annotation || strconv.Itoa(port.Port) == annotation) {
+			return port.Port, true
+		}
+	}
+	for _, name := range []string{"mcp", "http"} {
+		for _, port := range ports {
+			if port.Name == name {
+				return port.Port, true
+			}
+		}
+	}
+	return ports[0].Port, true
+}
@@ -0,0 +1,89 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Elector holds a lease in the leader_leases table so that only one of
+// several registry instances sharing a database runs the background jobs.
+// The lease is renewed at a third of its TTL; if the holder stops renewing,
+// another instance takes over once it expires. Expiry is judged by the
+// database's clock so instances don't need synchronized clocks.
+type Elector struct {
+	DB   *gorm.DB
+	Name string
+	TTL  time.Duration
+
+	id     string
+	leader atomic.Bool
+}
+
+// NewElector creates an Elector for the named lease with a unique holder ID
+func NewElector(db *gorm.DB, name string, ttl time.Duration) *Elector {
+	host, _ := os.Hostname()
+	return &Elector{DB: db, Name: name, TTL: ttl, id: fmt.Sprintf("%s/%s", host, uuid.NewString())}
+}
+
+// IsLeader reports whether this instance currently holds the lease. A nil
+// Elector is always the leader, for single instance deployments.
+func (e *Elector) IsLeader() bool {
+	return e == nil || e.leader.Load()
+}
+
+// Run acquires and renews the lease until the context is cancelled, then
+// releases it so another instance can take over straight away
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	result := e.DB.WithContext(ctx).Exec(`
+		INSERT INTO leader_leases (name, holder, expires_at)
+		VALUES (?, ?, now() + ? * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+		WHERE leader_leases.holder = EXCLUDED.holder OR leader_leases.expires_at < now()`,
+		e.Name, e.id, e.TTL.Milliseconds())
+	if result.Error != nil {
+		// Can't tell whether the lease is still ours, so stop acting on it
+		log.Printf("Failed to renew %s lease: %v", e.Name, result.Error)
+		e.setLeader(false)
+		return
+	}
+	e.setLeader(result.RowsAffected > 0)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) != leader {
+		if leader {
+			log.Printf("Acquired %s lease as %s", e.Name, e.id)
+		} else {
+			log.Printf("Lost %s lease", e.Name)
+		}
+	}
+}
+
+func (e *Elector) release() {
+	e.leader.Store(false)
+	if err := e.DB.Exec("DELETE FROM leader_leases WHERE name = ? AND holder = ?", e.Name, e.id).Error; err != nil {
+		log.Printf("Failed to release %s lease: %v", e.Name, err)
+	}
+}
@@ -0,0 +1,238 @@
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Guard decides which hosts the registry may connect to when it fetches or
+// probes registered URLs. The checks run when connecting, after DNS
+// resolution, so a public name pointing at a private address is refused too.
+//
+// Denied hosts and networks are always refused. When an allowlist is set,
+// only hosts and networks on it are permitted, and they may be private.
+// Otherwise loopback, private, link-local (including cloud metadata
+// endpoints) and unspecified addresses are refused unless AllowPrivate is set.
+type Guard struct {
+	AllowPrivate bool
+
+	allow rules
+	deny  rules
+
+	transportOnce sync.Once
+	transport     *http.Transport
+}
+
+// ErrBlocked is wrapped by every error returned for a refused target
+var ErrBlocked = errors.New("target is not allowed")
+
+// New creates a Guard from allow and deny entries, each a host name
+// ("example.com", or ".example.com" for subdomains), an IP or a CIDR
+func New(allowPrivate bool, allow, deny []string) (*Guard, error) {
+	g := &Guard{AllowPrivate: allowPrivate}
+	var err error
+	if g.allow, err = parseRules(allow); err != nil {
+		return nil, err
+	}
+	if g.deny, err = parseRules(deny); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// CheckURL refuses URLs whose host is explicitly denied or, with an
+// allowlist, not explicitly allowed. Host names aren't resolved, so private
+// addresses are only caught when connecting.
+func (g *Guard) CheckURL(rawURL string) error {
+	if g == nil {
+		return nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := parsed.Hostname()
+	ip := net.ParseIP(host)
+	if g.deny.match(host, ip) {
+		return fmt.Errorf("%w: %s is denied", ErrBlocked, host)
+	}
+	if len(g.allow) > 0 && !g.allow.match(host, ip) && (ip != nil || !g.allow.hasNetworks()) {
+		return fmt.Errorf("%w: %s is not on the allowlist", ErrBlocked, host)
+	}
+	return nil
+}
+
+// check decides whether a resolved address of host may be connected to
+func (g *Guard) check(host string, ip net.IP) error {
+	if g.deny.match(host, ip) {
+		return fmt.Errorf("%w: %s (%s) is denied", ErrBlocked, host, ip)
+	}
+	if len(g.allow) > 0 {
+		if g.allow.match(host, ip) {
+			return nil
+		}
+		return fmt.Errorf("%w: %s (%s) is not on the allowlist", ErrBlocked, host, ip)
+	}
+	if !g.AllowPrivate && isPrivate(ip) {
+		return fmt.Errorf("%w: %s resolves to private address %s", ErrBlocked, host, ip)
+	}
+	return nil
+}
+
+// DialContext resolves the address, refuses it if any of its IPs are
+// blocked and otherwise connects to the first IP that answers
+func (g *Guard) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if err := g.check(host, addr.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Transport returns a shared HTTP transport whose connections go through the
+// guard. A nil Guard returns the default transport.
+func (g *Guard) Transport() http.RoundTripper {
+	if g == nil {
+		return http.DefaultTransport
+	}
+
+	g.transportOnce.Do(func() {
+		g.transport = http.DefaultTransport.(*http.Transport).Clone()
+		g.transport.DialContext = g.DialContext
+		// Proxies would connect on our behalf, bypassing the checks
+		g.transport.Proxy = nil
+	})
+	return g.transport
+}
+
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+type rule struct {
+	host    string // Exact host, or a suffix when it starts with a dot
+	network *net.IPNet
+}
+
+type rules []rule
+
+func parseRules(entries []string) (rules, error) {
+	var parsed rules
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			parsed = append(parsed, rule{network: network})
+		case net.ParseIP(entry) != nil:
+			ip := net.ParseIP(entry)
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			parsed = append(parsed, rule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}})
+		default:
+			parsed = append(parsed, rule{host: entry})
+		}
+	}
+	return parsed, nil
+}
+
+func (rs rules) match(host string, ip net.IP) bool {
+	host = strings.ToLower(host)
+	for _, r := range rs {
+		switch {
+		case r.network != nil:
+			if ip != nil && r.network.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(r.host, "."):
+			if strings.HasSuffix(host, r.host) || host == r.host[1:] {
+				return true
+			}
+		case host == r.host:
+			return true
+		}
+	}
+	return false
+}
+
+func (rs rules) hasNetworks() bool {
+	for _, r := range rs {
+		if r.network != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Networks is a set of CIDRs, such as the client addresses allowed to use
+// part of the API
+type Networks []*net.IPNet
+
+// ParseNetworks parses CIDRs, treating a bare IP as a single address network
+func ParseNetworks(entries []string) (Networks, error) {
+	var networks Networks
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Contains reports whether ip is in any of the networks
+func (n Networks) Contains(ip net.IP) bool {
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,377 @@
+// Package openapi reads OpenAPI 3 documents, so services that bridge a REST
+// API to MCP can have their tools derived from the API's operations rather
+// than listed by hand, and the API docs services register can be checked and
+// served to docs viewers in one shape.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// maxToolName is the longest tool name MCP clients accept
+const maxToolName = 64
+
+// methods are the operations a path item may have, in the order tools are listed
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// unsafeName matches the characters MCP tool names may not contain
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Document is a parsed OpenAPI document
+type Document map[string]any
+
+// Parse reads an OpenAPI 3 document written as JSON or YAML
+func Parse(data []byte) (Document, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("parsing document: %w", err)
+	}
+	doc, ok := stringKeys(v).(map[string]any)
+	if !ok {
+		return nil, errors.New("document must be an object")
+	}
+	version, _ := doc["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, errors.New("only OpenAPI 3 documents are supported")
+	}
+	if _, ok := doc["paths"].(map[string]any); !ok {
+		return nil, errors.New("document has no paths")
+	}
+	return doc, nil
+}
+
+// Tools returns a tool for each operation the document describes. Tools are
+// named after operationIds, or the method and path when an operation has
+// none. Deprecated operations are left out.
+func (d Document) Tools() []types.Tool {
+	paths, _ := d["paths"].(map[string]any)
+	templates := make([]string, 0, len(paths))
+	for template := range paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	tools := []types.Tool{}
+	seen := map[string]bool{}
+	for _, template := range templates {
+		item, _ := d.resolve(paths[template]).(map[string]any)
+		for _, method := range methods {
+			operation, ok := item[method].(map[string]any)
+			if !ok || operation["deprecated"] == true {
+				continue
+			}
+			tool := d.tool(method, template, item, operation)
+			if seen[tool.Name] {
+				continue
+			}
+			seen[tool.Name] = true
+			tools = append(tools, tool)
+		}
+	}
+	return tools
+}
+
+func (d Document) tool(method, template string, item, operation map[string]any) types.Tool {
+	name, _ := operation["operationId"].(string)
+	if name == "" {
+		name = method + template
+	}
+	name = strings.Trim(unsafeName.ReplaceAllString(name, "_"), "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+
+	description, _ := operation["summary"].(string)
+	if description == "" {
+		description, _ = operation["description"].(string)
+	}
+
+	schema, _ := json.Marshal(d.inputSchema(item, operation))
+	return types.Tool{Name: name, Description: strings.TrimSpace(description), InputSchema: schema}
+}
+
+// inputSchema collects an operation's parameters, and its JSON request body
+// under "body", into one JSON Schema object
+func (d Document) inputSchema(item, operation map[string]any) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+
+	// Operation parameters override path item parameters of the same name
+	var parameters []any
+	itemParameters, _ := item["parameters"].([]any)
+	operationParameters, _ := operation["parameters"].([]any)
+	parameters = append(parameters, itemParameters...)
+	parameters = append(parameters, operationParameters...)
+	for _, p := range parameters {
+		parameter, _ := d.resolve(p).(map[string]any)
+		name, _ := parameter["name"].(string)
+		if name == "" {
+			continue
+		}
+		schema, ok := d.resolve(parameter["schema"]).(map[string]any)
+		if !ok {
+			schema = map[string]any{"type": "string"}
+		}
+		// resolve copies what it returns, so the schema can be changed
+		if _, ok := schema["description"]; !ok && parameter["description"] != nil {
+			schema["description"] = parameter["description"]
+		}
+		properties[name] = schema
+		if parameter["required"] == true || parameter["in"] == "path" {
+			required = append(required, name)
+		}
+	}
+
+	if body, ok := d.resolve(operation["requestBody"]).(map[string]any); ok {
+		content, _ := body["content"].(map[string]any)
+		if media, ok := content["application/json"].(map[string]any); ok {
+			if schema, ok := d.resolve(media["schema"]).(map[string]any); ok {
+				properties["body"] = schema
+				if body["required"] == true {
+					required = append(required, "body")
+				}
+			}
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = slices.Compact(required)
+	}
+	return schema
+}
+
+// resolve returns a copy of v with its local $refs inlined. References that
+// can't be followed, or that would recurse into a schema being inlined
+// already, become empty schemas, which accept anything.
+func (d Document) resolve(v any, expanding ...string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"].(string); ok {
+			if slices.Contains(expanding, ref) {
+				return map[string]any{}
+			}
+			target, ok := d.pointer(ref)
+			if !ok {
+				return map[string]any{}
+			}
+			return d.resolve(target, append(expanding, ref)...)
+		}
+		resolved := make(map[string]any, len(v))
+		for key, value := range v {
+			resolved[key] = d.resolve(value, expanding...)
+		}
+		return resolved
+	case []any:
+		resolved := make([]any, len(v))
+		for i, value := range v {
+			resolved[i] = d.resolve(value, expanding...)
+		}
+		return resolved
+	}
+	return v
+}
+
+// pointer follows a local JSON pointer such as #/components/schemas/Pet
+func (d Document) pointer(ref string) (any, bool) {
+	path, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, false
+	}
+	var current any = map[string]any(d)
+	for _, token := range strings.Split(path, "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[token]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// stringKeys converts the maps YAML decodes with non-string keys, such as
+// response codes, into maps JSON can encode
+func stringKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = stringKeys(value)
+		}
+		return v
+	case map[any]any:
+		converted := make(map[string]any, len(v))
+		for key, value := range v {
+			converted[fmt.Sprint(key)] = stringKeys(value)
+		}
+		return converted
+	case []any:
+		for i, value := range v {
+			v[i] = stringKeys(value)
+		}
+		return v
+	}
+	return v
+}
+
+// Inline reports whether a service's api_docs hold a document themselves
+// rather than a link or a note
+func Inline(apiDocs string) bool {
+	apiDocs = strings.TrimSpace(apiDocs)
+	return strings.HasPrefix(apiDocs, "{") || strings.HasPrefix(apiDocs, "openapi:") || strings.Contains(apiDocs, "\n")
+}
+
+// Linked reports whether a service's api_docs link to what looks like an
+// OpenAPI document, by the link's path, rather than to a docs page
+func Linked(apiDocs string) bool {
+	u, err := url.Parse(apiDocs)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	p := strings.ToLower(u.Path)
+	switch path.Ext(p) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return strings.Contains(p, "openapi") || strings.Contains(p, "swagger")
+}
+
+// maxProblems caps how many problems Validate reports
+const maxProblems = 10
+
+// Validate checks the parts of the document that viewers and Tools rely on:
+// its info, that paths are templates starting with /, that path parameters
+// are declared, that operationIds are unique and that every $ref points
+// somewhere in the document. It reports up to maxProblems problems.
+func (d Document) Validate() error {
+	var problems []error
+	report := func(format string, args ...any) {
+		if len(problems) < maxProblems {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	info, _ := d["info"].(map[string]any)
+	if title, _ := info["title"].(string); title == "" {
+		report("info.title is required")
+	}
+	if _, ok := info["version"].(string); !ok {
+		report("info.version is required")
+	}
+
+	paths, _ := d["paths"].(map[string]any)
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	operationIDs := map[string]string{}
+	for _, name := range names {
+		if !strings.HasPrefix(name, "/") {
+			report("path %q must start with /", name)
+		}
+		item, ok := d.resolve(paths[name]).(map[string]any)
+		if !ok {
+			report("path %q must be an object", name)
+			continue
+		}
+		for _, method := range methods {
+			if item[method] == nil {
+				continue
+			}
+			operation, ok := item[method].(map[string]any)
+			if !ok {
+				report("%s %s must be an object", method, name)
+				continue
+			}
+			where := strings.ToUpper(method) + " " + name
+			if id, _ := operation["operationId"].(string); id != "" {
+				if other, ok := operationIDs[id]; ok {
+					report("%s reuses operationId %q from %s", where, id, other)
+				}
+				operationIDs[id] = where
+			}
+			declared := d.pathParameters(item, operation)
+			for _, param := range templateParameters(name) {
+				if !declared[param] {
+					report("%s does not declare path parameter %q", where, param)
+				}
+			}
+		}
+	}
+
+	d.checkRefs(map[string]any(d), "#", report)
+	return errors.Join(problems...)
+}
+
+// pathParameters returns the names of the path parameters an operation or
+// its path item declares
+func (d Document) pathParameters(item, operation map[string]any) map[string]bool {
+	declared := map[string]bool{}
+	itemParameters, _ := item["parameters"].([]any)
+	operationParameters, _ := operation["parameters"].([]any)
+	for _, p := range slices.Concat(itemParameters, operationParameters) {
+		parameter, _ := d.resolve(p).(map[string]any)
+		if name, _ := parameter["name"].(string); name != "" && parameter["in"] == "path" {
+			declared[name] = true
+		}
+	}
+	return declared
+}
+
+// templateParameters returns the {names} in a path template
+func templateParameters(template string) []string {
+	var names []string
+	for _, match := range pathParameter.FindAllStringSubmatch(template, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// pathParameter matches a parameter in a path template
+var pathParameter = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// checkRefs reports $refs that aren't local or don't resolve
+func (d Document) checkRefs(v any, at string, report func(string, ...any)) {
+	switch v := v.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"].(string); ok {
+			if _, found := d.pointer(ref); !found {
+				report("%s: $ref %q does not point into the document", at, ref)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			d.checkRefs(v[key], at+"/"+key, report)
+		}
+	case []any:
+		for i, value := range v {
+			d.checkRefs(value, fmt.Sprintf("%s/%d", at, i), report)
+		}
+	}
+}
+
+// Normalize returns the document as JSON with its keys sorted, however it
+// was written
+func (d Document) Normalize() ([]byte, error) {
+	return json.Marshal(d)
+}
@@ -0,0 +1,164 @@
+// Package provenance verifies detached signatures over service manifests
+// against the public keys of trusted publishers, so a registration can be
+// shown to come from who it claims to.
+package provenance
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+	"gopkg.in/yaml.v3"
+)
+
+// Signature formats
+const (
+	FormatCosign   = "cosign"   // sign-blob signature, base64 ASN.1 ECDSA or raw Ed25519
+	FormatMinisign = "minisign" // The base64 signature line of a .minisig file
+)
+
+// ErrNoMatch is returned when no trusted key verifies a signature
+var ErrNoMatch = errors.New("signature doesn't match any trusted publisher key")
+
+// Result identifies the key that verified a manifest
+type Result struct {
+	Publisher string
+	KeyID     string
+	Format    string
+	Digest    string // Hex SHA-256 of the signed manifest
+}
+
+// Verifier holds the trusted publishers' public keys
+type Verifier struct {
+	keys []publisherKey
+}
+
+type publisherKey struct {
+	publisher string
+	id        string
+	format    string
+	ecdsa     *ecdsa.PublicKey
+	ed25519   ed25519.PublicKey
+	minisign  [8]byte // Key ID minisign signatures name their key by
+}
+
+// Load reads a YAML file mapping publisher names to lists of public keys,
+// each a PEM public key as used by cosign or a minisign public key
+func Load(path string) (*Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var publishers map[string][]string
+	if err := yaml.Unmarshal(data, &publishers); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	// Sorted so the same key always reports the same publisher
+	names := make([]string, 0, len(publishers))
+	for name := range publishers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	v := &Verifier{}
+	for _, name := range names {
+		for i, encoded := range publishers[name] {
+			key, err := parseKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("publisher %s key %d: %w", name, i+1, err)
+			}
+			key.publisher = name
+			v.keys = append(v.keys, key)
+		}
+	}
+	return v, nil
+}
+
+// Verify checks a detached signature over manifest against every trusted key
+func (v *Verifier) Verify(manifest []byte, signature string) (Result, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return Result{}, fmt.Errorf("signature isn't valid base64: %w", err)
+	}
+
+	digest := sha256.Sum256(manifest)
+	for _, key := range v.keys {
+		if key.verify(manifest, digest[:], sig) {
+			return Result{Publisher: key.publisher, KeyID: key.id, Format: key.format, Digest: hex.EncodeToString(digest[:])}, nil
+		}
+	}
+	return Result{}, ErrNoMatch
+}
+
+func (k publisherKey) verify(manifest, digest, sig []byte) bool {
+	switch {
+	case k.format == FormatMinisign:
+		// Signature algorithm (2 bytes), key ID (8 bytes), Ed25519 signature
+		if len(sig) != 2+8+ed25519.SignatureSize || string(sig[2:10]) != string(k.minisign[:]) {
+			return false
+		}
+		switch string(sig[:2]) {
+		case "Ed":
+			return ed25519.Verify(k.ed25519, manifest, sig[10:])
+		case "ED":
+			// Prehashed, the default since minisign 0.10
+			hashed := blake2b.Sum512(manifest)
+			return ed25519.Verify(k.ed25519, hashed[:], sig[10:])
+		}
+		return false
+	case k.ecdsa != nil:
+		return ecdsa.VerifyASN1(k.ecdsa, digest, sig)
+	default:
+		return ed25519.Verify(k.ed25519, manifest, sig)
+	}
+}
+
+// parseKey reads a PEM public key or a minisign public key, optionally with
+// its "untrusted comment:" line
+func parseKey(encoded string) (publisherKey, error) {
+	encoded = strings.TrimSpace(encoded)
+	if block, _ := pem.Decode([]byte(encoded)); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return publisherKey{}, err
+		}
+		fingerprint := sha256.Sum256(block.Bytes)
+		key := publisherKey{format: FormatCosign, id: hex.EncodeToString(fingerprint[:8])}
+		switch parsed := parsed.(type) {
+		case *ecdsa.PublicKey:
+			key.ecdsa = parsed
+		case ed25519.PublicKey:
+			key.ed25519 = parsed
+		default:
+			return publisherKey{}, fmt.Errorf("unsupported key type %T", parsed)
+		}
+		return key, nil
+	}
+
+	lines := strings.Split(encoded, "\n")
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil {
+		return publisherKey{}, fmt.Errorf("not a PEM or minisign public key")
+	}
+	// Algorithm "Ed" (2 bytes), key ID (8 bytes), Ed25519 public key
+	if len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return publisherKey{}, fmt.Errorf("not a minisign Ed25519 public key")
+	}
+	key := publisherKey{format: FormatMinisign, ed25519: ed25519.PublicKey(raw[10:])}
+	copy(key.minisign[:], raw[2:10])
+	// minisign shows key IDs as the little endian number in hex
+	key.id = fmt.Sprintf("%016X", binary.LittleEndian.Uint64(raw[2:10]))
+	return key, nil
+}
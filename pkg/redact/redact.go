@@ -0,0 +1,179 @@
+// Package redact strips and masks parts of service responses for callers who
+// shouldn't see all of them, so one registry can serve a public audience and
+// an internal one.
+package redact
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arnavsurve/gateway-registry/pkg/types"
+)
+
+// Audiences a policy can have rules for. Admins and callers who may write
+// always see everything.
+const (
+	Anonymous = "anonymous" // Callers without credentials
+	ReadOnly  = "read_only" // Authenticated callers without the write scope
+)
+
+// Rule says what an audience doesn't get to see
+type Rule struct {
+	StripFields   []string `yaml:"strip_fields"`   // Response fields left empty, see Fields
+	StripMetadata []string `yaml:"strip_metadata"` // Globs of metadata keys left out
+	MaskMetadata  []string `yaml:"mask_metadata"`  // Globs of metadata keys whose values are replaced
+	MaskEmails    bool     `yaml:"mask_emails"`    // Hide the local part of emails in descriptions and metadata
+}
+
+// Policy holds the rule for each audience. Audiences without one see
+// everything.
+type Policy map[string]Rule
+
+// fields empties each response field a rule may strip, by its JSON name
+var fields = map[string]func(*types.ServiceResponse){
+	"description": func(s *types.ServiceResponse) { s.Description = "" },
+	"api_docs":    func(s *types.ServiceResponse) { s.ApiDocs = "" },
+	"owner":       func(s *types.ServiceResponse) { s.Owner = "" },
+	"team_id":     func(s *types.ServiceResponse) { s.TeamID = "" },
+	"external_id": func(s *types.ServiceResponse) { s.ExternalID = "" },
+	"region":      func(s *types.ServiceResponse) { s.Region = "" },
+	"version":     func(s *types.ServiceResponse) { s.Version = "" },
+	"git_sha":     func(s *types.ServiceResponse) { s.GitSHA = "" },
+	"review_note": func(s *types.ServiceResponse) { s.ReviewNote = "" },
+	"provenance":  func(s *types.ServiceResponse) { s.Provenance = nil },
+	"tools":       func(s *types.ServiceResponse) { s.Tools = nil },
+	"aliases":     func(s *types.ServiceResponse) { s.Aliases = []string{} },
+	"extensions":  func(s *types.ServiceResponse) { s.Extensions = nil },
+}
+
+// Fields lists the response fields a rule may strip
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// emailPattern finds email addresses in free text
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// Load reads a YAML file mapping audiences to rules, such as
+//
+//	anonymous:
+//	  strip_fields: [owner, team_id]
+//	  strip_metadata: ["internal_*"]
+//	  mask_metadata: ["contact_*"]
+//	  mask_emails: true
+func Load(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return policy, policy.validate()
+}
+
+func (p Policy) validate() error {
+	for audience, rule := range p {
+		if audience != Anonymous && audience != ReadOnly {
+			return fmt.Errorf("unknown audience %q, must be %s or %s", audience, Anonymous, ReadOnly)
+		}
+		for _, field := range rule.StripFields {
+			if _, ok := fields[field]; !ok {
+				return fmt.Errorf("%s: unknown field %q, must be one of %s", audience, field, strings.Join(Fields(), ", "))
+			}
+		}
+		for _, pattern := range slices.Concat(rule.StripMetadata, rule.MaskMetadata) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: invalid metadata pattern %q", audience, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply strips and masks a response as the audience's rule says
+func (p Policy) Apply(audience string, response *types.ServiceResponse) {
+	rule, ok := p[audience]
+	if !ok {
+		return
+	}
+	for _, field := range rule.StripFields {
+		fields[field](response)
+	}
+	if rule.MaskEmails {
+		response.Description = maskEmails(response.Description)
+	}
+	rule.apply(response.Metadata)
+}
+
+// Description returns a service description as the audience may see it, for
+// responses in other shapes
+func (p Policy) Description(audience, description string) string {
+	rule, ok := p[audience]
+	switch {
+	case !ok:
+		return description
+	case p.Strips(audience, "description"):
+		return ""
+	case rule.MaskEmails:
+		return maskEmails(description)
+	}
+	return description
+}
+
+// Strips reports whether the audience's rule strips a response field
+func (p Policy) Strips(audience, field string) bool {
+	rule, ok := p[audience]
+	return ok && slices.Contains(rule.StripFields, field)
+}
+
+// ApplyMetadata strips and masks only metadata, for responses in other shapes
+func (p Policy) ApplyMetadata(audience string, metadata map[string]string) {
+	if rule, ok := p[audience]; ok {
+		rule.apply(metadata)
+	}
+}
+
+func (r Rule) apply(metadata map[string]string) {
+	for key, value := range metadata {
+		switch {
+		case matchAny(r.StripMetadata, key):
+			delete(metadata, key)
+		case matchAny(r.MaskMetadata, key):
+			metadata[key] = types.RedactedValue
+		case r.MaskEmails:
+			metadata[key] = maskEmails(value)
+		}
+	}
+}
+
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// maskEmails keeps the first character of each email's local part, so
+// j.doe@example.com becomes j***@example.com
+func maskEmails(text string) string {
+	return emailPattern.ReplaceAllStringFunc(text, func(email string) string {
+		at := strings.IndexByte(email, '@')
+		return email[:1] + "***" + email[at:]
+	})
+}
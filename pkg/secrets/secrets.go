@@ -0,0 +1,99 @@
+// Package secrets encrypts sensitive values before they are stored, with
+// AES-256-GCM under a master key the registry reads at startup. The key file
+// can be written by a KMS or mounted by a secrets manager; it never reaches
+// the database.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// prefix marks sealed values, and the version of the format they use
+const prefix = "enc:v1:"
+
+// keySize is the master key length AES-256 needs
+const keySize = 32
+
+// ErrNotSealed is returned when opening a value that wasn't sealed
+var ErrNotSealed = errors.New("value is not sealed")
+
+// Box seals and opens values under a master key
+type Box struct {
+	aead cipher.AEAD
+}
+
+// Load reads a base64 encoded 32 byte master key from path
+func Load(path string) (*Box, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("master key must be base64: %w", err)
+	}
+	return New(key)
+}
+
+// New creates a Box for a 32 byte master key
+func New(key []byte) (*Box, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext under a random nonce. Values that are already
+// sealed are returned as they are, so sealing twice is harmless.
+func (b *Box) Seal(plaintext string) (string, error) {
+	if Sealed(plaintext) {
+		return plaintext, nil
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal
+func (b *Box) Open(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return "", ErrNotSealed
+	}
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < b.aead.NonceSize() {
+		return "", errors.New("sealed value is truncated")
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Sealed reports whether value was produced by Seal
+func Sealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
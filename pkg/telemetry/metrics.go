@@ -0,0 +1,144 @@
+// Package telemetry collects operational metrics and pushes them to an
+// OpenTelemetry collector.
+package telemetry
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Metric names
+const (
+	HTTPRequests     = "registry.http.requests"
+	HTTPDuration     = "registry.http.request.duration" // Milliseconds, summed
+	HTTPRateLimited  = "registry.http.rate_limited"
+	HTTPShed         = "registry.http.shed"
+	PrunedServices   = "registry.prune.services"
+	WebhookDelivered = "registry.webhook.delivered"
+	WebhookFailures  = "registry.webhook.failures"
+)
+
+// Attribute is a key/value pair qualifying a data point
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Metrics holds cumulative counters and gauges read on demand. A nil
+// Metrics discards everything, so callers needn't check whether telemetry is
+// enabled.
+type Metrics struct {
+	start time.Time
+
+	mu       sync.Mutex
+	counters map[string]*counter
+	gauges   map[string]func() int64
+}
+
+type counter struct {
+	name  string
+	attrs []Attribute
+	value int64
+}
+
+// New creates an empty Metrics
+func New() *Metrics {
+	return &Metrics{
+		start:    time.Now(),
+		counters: make(map[string]*counter),
+		gauges:   make(map[string]func() int64),
+	}
+}
+
+// Add increments a counter
+func (m *Metrics) Add(name string, n int64, attrs ...Attribute) {
+	if m == nil {
+		return
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
+	key := name
+	for _, attr := range attrs {
+		key += "\x00" + attr.Key + "=" + attr.Value
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if !ok {
+		c = &counter{name: name, attrs: attrs}
+		m.counters[key] = c
+	}
+	c.value += n
+}
+
+// Gauge registers a value that is read each time metrics are exported
+func (m *Metrics) Gauge(name string, read func() int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = read
+}
+
+// Middleware counts requests and their duration by route, method and status.
+// Routes are the mux path templates so IDs don't blow up cardinality.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		attrs := []Attribute{
+			{Key: "http.route", Value: route},
+			{Key: "http.request.method", Value: strings.ToUpper(r.Method)},
+			{Key: "http.response.status_code", Value: strconv.Itoa(recorder.status)},
+		}
+		m.Add(HTTPRequests, 1, attrs...)
+		m.Add(HTTPDuration, time.Since(start).Milliseconds(), attrs...)
+	})
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streamed responses working through the recorder
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack keeps WebSocket upgrades working through the recorder
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
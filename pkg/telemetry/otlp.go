@@ -0,0 +1,186 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aggregationCumulative is OTLP's AGGREGATION_TEMPORALITY_CUMULATIVE
+const aggregationCumulative = 2
+
+// Exporter pushes metrics to an OpenTelemetry collector using OTLP/HTTP with
+// JSON encoding, so no protobuf or SDK dependency is needed
+type Exporter struct {
+	Metrics  *Metrics
+	Endpoint string            // Collector base URL, /v1/metrics is appended
+	Headers  map[string]string // Sent with every export, e.g. for collector auth
+	Interval time.Duration
+	Client   *http.Client
+}
+
+// NewExporter creates an Exporter pushing to the collector at endpoint
+func NewExporter(metrics *Metrics, endpoint string, headers map[string]string, interval time.Duration) *Exporter {
+	return &Exporter{
+		Metrics:  metrics,
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Headers:  headers,
+		Interval: interval,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run exports every interval until the context is cancelled
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Export(ctx); err != nil {
+				log.Printf("Failed to export metrics: %v", err)
+			}
+		}
+	}
+}
+
+// Export sends the current value of every metric
+func (e *Exporter) Export(ctx context.Context) error {
+	body, err := json.Marshal(e.Metrics.snapshot(time.Now()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint+"/v1/metrics", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON payload, see opentelemetry-proto's metrics_service.proto. 64 bit
+// integers are encoded as strings per the protobuf JSON mapping.
+type (
+	otlpRequest struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpResource       `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpScope    `json:"scope"`
+		Metrics []otlpMetric `json:"metrics"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name  string     `json:"name"`
+		Sum   *otlpSum   `json:"sum,omitempty"`
+		Gauge *otlpGauge `json:"gauge,omitempty"`
+	}
+	otlpSum struct {
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpDataPoint `json:"dataPoints"`
+	}
+	otlpDataPoint struct {
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string          `json:"timeUnixNano"`
+		AsInt             string          `json:"asInt"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+// snapshot reads every counter and gauge into an export request
+func (m *Metrics) snapshot(now time.Time) otlpRequest {
+	start := strconv.FormatInt(m.start.UnixNano(), 10)
+	timestamp := strconv.FormatInt(now.UnixNano(), 10)
+
+	m.mu.Lock()
+	sums := make(map[string]*otlpSum)
+	var names []string
+	for _, c := range m.counters {
+		sum, ok := sums[c.name]
+		if !ok {
+			sum = &otlpSum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+			sums[c.name] = sum
+			names = append(names, c.name)
+		}
+		sum.DataPoints = append(sum.DataPoints, otlpDataPoint{
+			Attributes:        otlpAttributes(c.attrs),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      timestamp,
+			AsInt:             strconv.FormatInt(c.value, 10),
+		})
+	}
+	gauges := make(map[string]func() int64, len(m.gauges))
+	for name, read := range m.gauges {
+		gauges[name] = read
+	}
+	m.mu.Unlock()
+
+	var metrics []otlpMetric
+	for _, name := range names {
+		metrics = append(metrics, otlpMetric{Name: name, Sum: sums[name]})
+	}
+	// Gauges are read outside the lock, they may query the database pool
+	for name, read := range gauges {
+		metrics = append(metrics, otlpMetric{Name: name, Gauge: &otlpGauge{DataPoints: []otlpDataPoint{{
+			TimeUnixNano: timestamp,
+			AsInt:        strconv.FormatInt(read(), 10),
+		}}}})
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: otlpAttributes([]Attribute{{Key: "service.name", Value: "gateway-registry"}})},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "github.com/arnavsurve/gateway-registry"},
+			Metrics: metrics,
+		}},
+	}}}
+}
+
+func otlpAttributes(attrs []Attribute) []otlpAttribute {
+	converted := make([]otlpAttribute, len(attrs))
+	for i, attr := range attrs {
+		converted[i] = otlpAttribute{Key: attr.Key, Value: otlpValue{StringValue: attr.Value}}
+	}
+	return converted
+}
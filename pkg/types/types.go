@@ -1,27 +1,709 @@
 package types
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
 // MCPService represents a registered MCP service
 type MCPService struct {
 	ID           string         `json:"id" gorm:"primaryKey"`
-	Name         string         `json:"name" gorm:"not null"`
+	Namespace    string         `json:"namespace" gorm:"not null;default:default;uniqueIndex:idx_service_identity;uniqueIndex:idx_service_external_id"`
+	Name         string         `json:"name" gorm:"not null;uniqueIndex:idx_service_identity;index:idx_services_lower_name,expression:lower(name)"`
 	Description  string         `json:"description"`
-	URL          string         `json:"url" gorm:"not null"`
+	URL          string         `json:"url" gorm:"not null;uniqueIndex:idx_service_identity"`
 	Capabilities []Capability   `json:"capabilities" gorm:"foreignKey:ServiceID"`
 	Categories   []Category     `json:"categories" gorm:"foreignKey:ServiceID"`
 	CreatedAt    time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	LastSeen     time.Time      `json:"last_seen"`
+	UpdatedAt    time.Time      `json:"updated_at" gorm:"autoUpdateTime;index"`
+	LastSeen     time.Time      `json:"last_seen" gorm:"index"` // Scanned by the prune loop
 	Metadata     []MetadataItem `json:"metadata" gorm:"foreignKey:ServiceID"`
+	Aliases      []ServiceAlias `json:"aliases" gorm:"foreignKey:ServiceID"`
 	ApiDocs      string         `json:"api_docs"`
+	Weight       int            `json:"weight" gorm:"not null;default:1"`
+	Priority     int            `json:"priority" gorm:"not null;default:0"`
+	Region       string         `json:"region" gorm:"index"`
+	Status       string         `json:"status" gorm:"not null;default:healthy"`
+	State        string         `json:"state" gorm:"not null;default:published;index"`
+	ReviewNote   string         `json:"review_note"`                                  // Reason given when the service was last approved or rejected
+	Verified     bool           `json:"verified" gorm:"not null;default:false;index"` // Only settable by admins
+	RatingAvg    *float64       `json:"rating_average"`                               // Kept in sync with the service's reviews
+	RatingCount  int            `json:"rating_count" gorm:"not null;default:0"`
+	Deprecated   bool           `json:"deprecated" gorm:"not null;default:false;index"`
+	SunsetAt     *time.Time     `json:"sunset_at"` // When a deprecated service is expected to go away
+	Replacement  string         `json:"replacement_service_id"`
+	ProxyTimeout int            `json:"proxy_timeout_seconds"`
+	Origin       string         `json:"origin" gorm:"index;uniqueIndex:idx_service_identity;uniqueIndex:idx_service_external_id"` // Peer registry the service was federated from, empty if local
+	LatencyP50Ms *float64       `json:"latency_p50_ms"`                                                                           // Null until the service has been probed
+	LatencyP95Ms *float64       `json:"latency_p95_ms"`
+	Version      string         `json:"version"` // Build the service last reported with a heartbeat
+	GitSHA       string         `json:"git_sha"`
+
+	// Caller chosen ID that is stable across re-creation, for
+	// infrastructure-as-code tools. Unique within a namespace when set.
+	ExternalID string `json:"external_id" gorm:"uniqueIndex:idx_service_external_id,where:external_id <> ''"`
+
+	// Consecutive failed and successful probes or heartbeats, one of which is
+	// always 0. Status only changes once a streak reaches its threshold.
+	FailureStreak int `json:"failure_streak" gorm:"not null;default:0"`
+	SuccessStreak int `json:"success_streak" gorm:"not null;default:0"`
+
+	// Status changes within the current flap window, which started at
+	// FlapWindowStart. Flapping services are kept out of routing decisions
+	// by careful gateways.
+	StatusChanges   int        `json:"-" gorm:"not null;default:0"`
+	FlapWindowStart *time.Time `json:"-"`
+	Flapping        bool       `json:"flapping" gorm:"not null;default:false;index"`
+
+	// Identity (SPIFFE ID or DNS name) of the client certificate the service
+	// was registered with. Only that identity or an admin may change it.
+	Owner string `json:"owner" gorm:"index"`
+
+	// Team that owns the service, whose members may change it. Services with
+	// team visibility are only listed and returned to the team's members.
+	TeamID     string `json:"team_id" gorm:"index"`
+	Visibility string `json:"visibility" gorm:"not null;default:public;index"`
+
+	// The publisher whose key signed the last registration or update, empty
+	// if it wasn't signed
+	Provenance Provenance `json:"provenance" gorm:"embedded;embeddedPrefix:provenance_"`
+
+	// Tool definitions the service declared, for discovery by agents
+	Tools Tools `json:"tools" gorm:"type:jsonb"`
+
+	// Structured custom data, by extension name, each matching the schema
+	// the operator registered for it
+	Extensions Extensions `json:"extensions" gorm:"type:jsonb"`
+
+	// MCP protocol revisions the service speaks, as declared at registration
+	// or learned from its initialize response by the health prober
+	ProtocolVersions StringList `json:"protocol_versions" gorm:"type:jsonb;index:idx_services_protocol_versions,type:gin"`
+
+	// SHA-256 of the token that may only renew this service's lease, empty if
+	// none was issued
+	HeartbeatTokenHash string `json:"-"`
+
+	// When the service.sunset event went out for the service
+	SunsetNotifiedAt *time.Time `json:"-"`
+
+	// Denormalized copy of the associations, read instead of the child tables
+	// when the registry runs with the JSONB schema
+	Associations *ServiceAssociations `json:"-" gorm:"type:jsonb;index:idx_service_associations,type:gin"`
+}
+
+// Provenance records which trusted publisher signed a service's manifest
+type Provenance struct {
+	Publisher  string     `json:"publisher"`
+	KeyID      string     `json:"key_id"`
+	Format     string     `json:"format"` // cosign or minisign
+	Digest     string     `json:"digest"` // Hex SHA-256 of the signed manifest
+	VerifiedAt *time.Time `json:"verified_at"`
+}
+
+// Tool describes a tool an MCP service exposes, as listed by its tools/list
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema,omitempty"` // JSON Schema of the tool's arguments
+}
+
+// Tools is a service's tool definitions, stored as a JSONB column
+type Tools []Tool
+
+func (t Tools) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(t)
+	return string(data), err
+}
+
+func (t *Tools) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, t)
+	case string:
+		return json.Unmarshal([]byte(value), t)
+	}
+	return errors.New("unsupported type for tools")
+}
+
+// StringList is a list of strings stored as a JSONB array
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(l)
+	return string(data), err
+}
+
+func (l *StringList) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, l)
+	case string:
+		return json.Unmarshal([]byte(value), l)
+	}
+	return errors.New("unsupported type for string list")
+}
+
+// Extensions is a service's extension data by extension name, stored as a
+// JSONB column
+type Extensions map[string]json.RawMessage
+
+func (e Extensions) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(e)
+	return string(data), err
+}
+
+func (e *Extensions) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*e = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, e)
+	case string:
+		return json.Unmarshal([]byte(value), e)
+	}
+	return errors.New("unsupported type for extensions")
+}
+
+// JSONDocument is a JSON value stored as a JSONB column, kept as written
+type JSONDocument json.RawMessage
+
+func (d JSONDocument) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+func (d *JSONDocument) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = nil
+		return nil
+	}
+	*d = append((*d)[:0], data...)
+	return nil
+}
+
+func (d JSONDocument) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	return string(d), nil
+}
+
+func (d *JSONDocument) Scan(value any) error {
+	switch value := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		*d = append((*d)[:0], value...)
+		return nil
+	case string:
+		*d = JSONDocument(value)
+		return nil
+	}
+	return errors.New("unsupported type for JSON document")
+}
+
+// ServiceAssociations holds a service's capabilities, categories, metadata
+// and aliases as a single JSONB document
+type ServiceAssociations struct {
+	Capabilities map[string]bool   `json:"capabilities"`
+	Categories   []string          `json:"categories"`
+	Metadata     map[string]string `json:"metadata"`
+	Aliases      []string          `json:"aliases"`
+}
+
+func (a ServiceAssociations) Value() (driver.Value, error) {
+	data, err := json.Marshal(a)
+	return string(data), err
+}
+
+func (a *ServiceAssociations) Scan(value any) error {
+	switch value := value.(type) {
+	case []byte:
+		return json.Unmarshal(value, a)
+	case string:
+		return json.Unmarshal([]byte(value), a)
+	}
+	return errors.New("unsupported type for service associations")
+}
+
+// Service statuses
+const (
+	StatusHealthy  = "healthy"
+	StatusDegraded = "degraded"
+)
+
+// Service lifecycle states. Only published services are listed, searched
+// and resolved by default.
+const (
+	StateDraft         = "draft"
+	StatePendingReview = "pending_review"
+	StatePublished     = "published"
+	StateRejected      = "rejected"
+)
+
+// Service visibilities
+const (
+	VisibilityPublic = "public"
+	VisibilityTeam   = "team" // Only the owning team's members and admins
+)
+
+// RedactedValue stands in for values callers may not see, such as sensitive
+// metadata. Sending it back in an update keeps the stored value.
+const RedactedValue = "[redacted]"
+
+// DefaultNamespace is used for registrations that don't specify a namespace
+const DefaultNamespace = "default"
+
+// DefaultWeight is the load balancing weight assigned to services that don't declare one
+const DefaultWeight = 1
+
+// ArchivedService is a pruned service kept so it can be restored if it comes back
+type ArchivedService struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	Namespace  string    `json:"namespace" gorm:"index:idx_archived_identity"`
+	Name       string    `json:"name" gorm:"index:idx_archived_identity"`
+	URL        string    `json:"url" gorm:"index:idx_archived_identity"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime:false"`
+	ArchivedAt time.Time `json:"archived_at" gorm:"index"`
+	Data       string    `json:"-" gorm:"type:jsonb"` // The service as a ServiceResponse
+}
+
+// ArchivedServiceResponse is an archived service as it was when pruned
+type ArchivedServiceResponse struct {
+	ServiceResponse
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// AvailabilityBucket counts heartbeat and probe outcomes for a service over
+// one AvailabilityBucketSize interval
+type AvailabilityBucket struct {
+	ServiceID   string    `json:"-" gorm:"primaryKey"`
+	BucketStart time.Time `json:"bucket_start" gorm:"primaryKey"`
+	Successes   int       `json:"successes" gorm:"not null;default:0"`
+	Failures    int       `json:"failures" gorm:"not null;default:0"`
+}
+
+func (AvailabilityBucket) TableName() string {
+	return "availability"
+}
+
+// RegistryState is a single row tracking when the catalog last changed
+type RegistryState struct {
+	ID        uint      `gorm:"primaryKey"`
+	UpdatedAt time.Time `gorm:"not null"`
+	Index     int64     `gorm:"column:change_index;not null;default:0"` // Incremented by every catalog write
+}
+
+func (RegistryState) TableName() string {
+	return "registry_state"
+}
+
+// UsageDay counts how often a service was returned by read endpoints on one UTC day
+type UsageDay struct {
+	ServiceID string    `json:"-" gorm:"primaryKey"`
+	Day       time.Time `json:"day" gorm:"primaryKey;type:date"`
+	Count     int64     `json:"count" gorm:"not null;default:0"`
+}
+
+func (UsageDay) TableName() string {
+	return "usage_daily"
+}
+
+// API usage classes
+const (
+	APIUsageRead   = "read"
+	APIUsageWrite  = "write"
+	APIUsageSearch = "search"
+)
+
+// APIUsageDay counts one caller's API calls of one class on one UTC day
+type APIUsageDay struct {
+	Principal string    `json:"principal" gorm:"primaryKey"` // API key user or session name
+	Day       time.Time `json:"day" gorm:"primaryKey;type:date"`
+	Class     string    `json:"class" gorm:"primaryKey"` // read, write or search
+	Count     int64     `json:"count" gorm:"not null;default:0"`
+}
+
+func (APIUsageDay) TableName() string {
+	return "api_usage_daily"
+}
+
+// APIUsageResponse is a caller's metered API calls over a window
+type APIUsageResponse struct {
+	Principal string           `json:"principal"`
+	Window    string           `json:"window"`
+	Totals    map[string]int64 `json:"totals"` // By class
+	Days      []APIUsageDay    `json:"days"`
+}
+
+// ServiceUsage is a service's total usage over a window
+type ServiceUsage struct {
+	ServiceID string `json:"service_id"`
+	Name      string `json:"name"`
+	Count     int64  `json:"count"`
+}
+
+// ServiceStatsResponse reports a service's usage over a window
+type ServiceStatsResponse struct {
+	ServiceID string     `json:"service_id"`
+	Window    string     `json:"window"`
+	Total     int64      `json:"total"`
+	Days      []UsageDay `json:"days"`
+}
+
+// HeartbeatMetrics are the optional runtime figures a service reports with a
+// heartbeat. Fields left out weren't measured.
+type HeartbeatMetrics struct {
+	ActiveSessions *int     `json:"active_sessions,omitempty"`
+	CPUPercent     *float64 `json:"cpu_percent,omitempty"`
+	MemoryPercent  *float64 `json:"memory_percent,omitempty"`
+	QueueDepth     *int     `json:"queue_depth,omitempty"`
+}
+
+// MetricSample is one heartbeat's metrics, kept for the metrics window
+type MetricSample struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	ServiceID  string    `json:"-" gorm:"not null;index:idx_metric_samples_service_recorded,priority:1"`
+	RecordedAt time.Time `json:"recorded_at" gorm:"not null;index;index:idx_metric_samples_service_recorded,priority:2"`
+	HeartbeatMetrics
+}
+
+// MetricAverages are the mean of each metric over the samples that reported it
+type MetricAverages struct {
+	ActiveSessions *float64 `json:"active_sessions,omitempty"`
+	CPUPercent     *float64 `json:"cpu_percent,omitempty"`
+	MemoryPercent  *float64 `json:"memory_percent,omitempty"`
+	QueueDepth     *float64 `json:"queue_depth,omitempty"`
+}
+
+// ServiceMetricsResponse summarises the metrics a service reported within the window
+type ServiceMetricsResponse struct {
+	ServiceID string         `json:"service_id"`
+	Window    string         `json:"window"`
+	Latest    *MetricSample  `json:"latest"`
+	Average   MetricAverages `json:"average"`
+	Samples   []MetricSample `json:"samples"`
+}
+
+// AvailabilityBucketSize is the granularity availability is recorded at
+const AvailabilityBucketSize = 5 * time.Minute
+
+// Up reports whether the service was considered available during the bucket
+func (b AvailabilityBucket) Up() bool {
+	return b.Successes > 0 && b.Successes >= b.Failures
+}
+
+// Review is a user's rating of a service. Each user has at most one review per service.
+type Review struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	ServiceID string    `json:"service_id" gorm:"not null;uniqueIndex:idx_review_author"`
+	Author    string    `json:"author" gorm:"not null;uniqueIndex:idx_review_author"`
+	Rating    int       `json:"rating" gorm:"not null"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ReviewSubmission represents an incoming review
+type ReviewSubmission struct {
+	Rating  int    `json:"rating"`
+	Comment string `json:"comment"`
+}
+
+// ServiceRevision is the full state of a service after one of its updates
+type ServiceRevision struct {
+	ServiceID string    `json:"service_id" gorm:"primaryKey"`
+	Revision  int       `json:"revision" gorm:"primaryKey;autoIncrement:false"`
+	Data      string    `json:"-"` // JSON encoded ServiceResponse
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RevisionResponse represents an outgoing service revision
+type RevisionResponse struct {
+	Revision  int             `json:"revision"`
+	CreatedAt time.Time       `json:"created_at"`
+	Service   ServiceResponse `json:"service"`
+}
+
+// Event records something that happened to a service, for delivery to webhooks
+type Event struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"not null;index"`
+	ServiceID string    `json:"service_id" gorm:"index"`
+	Data      string    `json:"data"` // JSON encoded details, depending on the type
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+
+	// The events table doubles as the webhook outbox: events are written in
+	// the transaction that caused them and delivered afterwards
+	DeliveredAt   *time.Time `json:"-" gorm:"index"`
+	Attempts      int        `json:"-" gorm:"not null;default:0"`
+	NextAttemptAt *time.Time `json:"-"` // Failed deliveries back off until then
+}
+
+// EventResponse is an event as returned by the API, with its data decoded
+type EventResponse struct {
+	ID        uint            `json:"id"`
+	Type      string          `json:"type"`
+	ServiceID string          `json:"service_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// EventsResponse is a page of events. Next is the ID to pass as ?since for
+// the following page.
+type EventsResponse struct {
+	Events []EventResponse `json:"events"`
+	Next   uint            `json:"next"`
+}
+
+// LeaderLease records which registry instance runs the background jobs
+type LeaderLease struct {
+	Name      string    `gorm:"primaryKey"`
+	Holder    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// Session is a user's registry session after logging in with OIDC, looked up
+// by the hash of its token
+type Session struct {
+	TokenHash string    `gorm:"primaryKey"`
+	Subject   string    `gorm:"not null;index"` // The provider's stable user ID
+	Name      string    `gorm:"not null"`
+	Admin     bool      `gorm:"not null;default:false"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// APIKey is a bearer token for the API, looked up by the hash of the token.
+// Keys stop working once they expire or are revoked.
+type APIKey struct {
+	ID          string `gorm:"primaryKey"`
+	Name        string `gorm:"not null;index"` // Principal the key authenticates as
+	Description string
+	Prefix      string     // Start of the token, so people can tell their keys apart
+	TokenHash   string     `gorm:"not null;uniqueIndex"`
+	Scopes      StringList `gorm:"type:jsonb"`
+	CreatedBy   string
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+
+	// Key that replaced this one when it was rotated. It keeps working until
+	// its grace period, its ExpiresAt, runs out.
+	ReplacedBy string `gorm:"not null;default:''"`
+
+	// When the api_key.expiring event went out for the key
+	ExpiryNotifiedAt *time.Time
+}
+
+// APIKeyRequest represents a request to create an API key
+type APIKeyRequest struct {
+	Name        string     `json:"name"` // Principal the key authenticates as
+	Description string     `json:"description"`
+	Scopes      []string   `json:"scopes"`     // write and admin; keys without scopes may only read
+	ExpiresAt   *time.Time `json:"expires_at"` // Never expires when left out
+}
+
+// APIKeyResponse represents an API key. The token is only returned when the
+// key is created.
+type APIKeyResponse struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Prefix      string     `json:"prefix"`
+	Scopes      []string   `json:"scopes"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy  string     `json:"replaced_by,omitempty"`
+	Token       string     `json:"token,omitempty"`
+}
+
+// RotateKeyRequest asks for an API key to be replaced. Both fields are
+// optional: the grace period defaults to the registry's, and the new key
+// lasts as long as the old one did.
+type RotateKeyRequest struct {
+	GraceSeconds int        `json:"grace_seconds"` // How long the old key keeps working
+	ExpiresAt    *time.Time `json:"expires_at"`
+}
+
+// RotateKeyResponse is the new key, with its token, and the key it replaces
+type RotateKeyResponse struct {
+	Key      APIKeyResponse `json:"key"`
+	Previous APIKeyResponse `json:"previous"`
+}
+
+// APIKeyToResponse converts an API key into its response format
+func APIKeyToResponse(key APIKey) APIKeyResponse {
+	scopes := []string(key.Scopes)
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return APIKeyResponse{
+		ID:          key.ID,
+		Name:        key.Name,
+		Description: key.Description,
+		Prefix:      key.Prefix,
+		Scopes:      scopes,
+		CreatedBy:   key.CreatedBy,
+		CreatedAt:   key.CreatedAt,
+		ExpiresAt:   key.ExpiresAt,
+		LastUsedAt:  key.LastUsedAt,
+		RevokedAt:   key.RevokedAt,
+		ReplacedBy:  key.ReplacedBy,
+	}
+}
+
+// PendingLogin is an OIDC login waiting for the provider to redirect back
+type PendingLogin struct {
+	State     string    `gorm:"primaryKey"`
+	Nonce     string    `gorm:"not null"`
+	Verifier  string    `gorm:"not null"` // PKCE code verifier
+	Redirect  string    // Where to send the user afterwards
+	CreatedAt time.Time `gorm:"not null;index"`
+}
+
+// SessionResponse describes a session. The token is only returned when the
+// session is created.
+type SessionResponse struct {
+	Name      string     `json:"name"`
+	Admin     bool       `json:"admin"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Token     string     `json:"token,omitempty"`
+}
+
+// SignatureNonce is a nonce seen on a signed registration, kept while its
+// timestamp is within tolerance so the request can't be replayed
+type SignatureNonce struct {
+	Nonce  string    `gorm:"primaryKey"`
+	SeenAt time.Time `gorm:"not null;index"`
+}
+
+// Change is an entry in the service change feed. Sequence numbers only ever
+// increase in commit order, so a consumer that remembers the last one it saw
+// never misses a change.
+type Change struct {
+	Seq       uint64    `json:"seq" gorm:"primaryKey;autoIncrement"`
+	Type      string    `json:"type" gorm:"not null"`
+	ServiceID string    `json:"service_id" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Change types
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// ChangesResponse is a page of the change feed. Next is the sequence number
+// to pass as ?since for the following page.
+type ChangesResponse struct {
+	Changes []Change `json:"changes"`
+	Next    uint64   `json:"next"`
+}
+
+// Event types
+const (
+	EventServiceSunset         = "service.sunset"
+	EventServiceVersionChanged = "service.version_changed"
+	EventServiceStatusChanged  = "service.status_changed"
+
+	// Lifecycle events mirror the change feed
+	EventServiceCreated = "service.created"
+	EventServiceUpdated = "service.updated"
+	EventServiceDeleted = "service.deleted"
+
+	// API key lifecycle, with the key's ID but never its token in the data
+	EventAPIKeyRotated  = "api_key.rotated"
+	EventAPIKeyExpiring = "api_key.expiring"
+)
+
+// Quota names
+const (
+	QuotaServices      = "services"
+	QuotaMetadataBytes = "metadata_bytes"
+)
+
+// QuotaUsage is how much of one quota a namespace uses. Limit is null for
+// unlimited quotas.
+type QuotaUsage struct {
+	Used  int64  `json:"used"`
+	Limit *int64 `json:"limit"`
+}
+
+// NamespaceQuotas is a namespace's usage of each quota
+type NamespaceQuotas struct {
+	Namespace     string     `json:"namespace"`
+	Services      QuotaUsage `json:"services"`
+	MetadataBytes QuotaUsage `json:"metadata_bytes"`
 }
 
+// AuditEntry records an administrative action taken on a service
+type AuditEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ServiceID string    `json:"service_id" gorm:"index"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// Audit actions
+const (
+	AuditApproved   = "approved"
+	AuditRejected   = "rejected"
+	AuditVerified   = "verified"
+	AuditUnverified = "unverified"
+	AuditPruned     = "pruned"
+	AuditPurged     = "purged_archive"
+	AuditRestored   = "restored"
+	AuditReadOnly   = "read_only"
+	AuditTokenReset = "heartbeat_token_reset"
+	AuditPurgedData = "service_purged"      // Details name the purged service, whose own entries are gone
+	AuditTeamMember = "team_member_set"     // Details name the team, principal and role
+	AuditTeamLeft   = "team_member_removed" // Details name the team and principal
+
+	// Ownership transfers, with the transfer ID in the details
+	AuditTransferRequested = "transfer_requested"
+	AuditTransferred       = "transferred"
+	AuditTransferDeclined  = "transfer_declined"
+	AuditTransferCancelled = "transfer_cancelled"
+
+	// API keys, with the key's ID and name in the details
+	AuditKeyCreated = "api_key_created"
+	AuditKeyRevoked = "api_key_revoked"
+	AuditKeyRotated = "api_key_rotated"
+)
+
 // Capability represents a service capability
 type Capability struct {
 	ID        uint   `json:"-" gorm:"primaryKey"`
-	ServiceID string `json:"-"`
+	ServiceID string `json:"-" gorm:"index"`
 	Name      string `json:"name"`
 	Enabled   bool   `json:"enabled"`
 }
@@ -29,46 +711,581 @@ type Capability struct {
 // Category represents a service category
 type Category struct {
 	ID        uint   `json:"-" gorm:"primaryKey"`
-	ServiceID string `json:"-"`
-	Name      string `json:"name"`
+	ServiceID string `json:"-" gorm:"index:idx_categories_name_service,priority:2"`
+	Name      string `json:"name" gorm:"index:idx_categories_name_service,priority:1"`
+}
+
+// CanonicalCategory is an admin-managed category that registrations may be
+// restricted to
+type CanonicalCategory struct {
+	Name        string `json:"name" gorm:"primaryKey"`
+	Description string `json:"description"`
+
+	// JSON Schema the metadata of services in the category must match
+	MetadataSchema JSONDocument `json:"metadata_schema,omitempty" gorm:"type:jsonb"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// CategorySummary is a category with the number of services in it
+type CategorySummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Canonical   bool   `json:"canonical"`
+	Count       int64  `json:"count"`
+
+	MetadataSchema JSONDocument `json:"metadata_schema,omitempty"`
+}
+
+// Suggestion kinds
+const (
+	SuggestionName     = "name"
+	SuggestionCategory = "category"
+)
+
+// Suggestion is a completion for a partially typed search
+type Suggestion struct {
+	Text  string `json:"text"`
+	Type  string `json:"type"`  // name or category
+	Count int64  `json:"count"` // Published services it would match
+}
+
+// DiscoveredService is a service and its tools as returned by /discover,
+// trimmed to what an agent needs to pick and call a tool
+type DiscoveredService struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	Tools       []Tool `json:"tools"`
+}
+
+// DashboardResponse is everything a UI home page shows, in one response
+type DashboardResponse struct {
+	Total         int64             `json:"total"`
+	ByStatus      map[string]int64  `json:"by_status"`
+	ByState       map[string]int64  `json:"by_state"`
+	Recent        []ServiceResponse `json:"recent"`         // Newest published registrations
+	Stale         []ServiceResponse `json:"stale"`          // Services that most recently missed their heartbeat
+	TopCategories []CategorySummary `json:"top_categories"` // Most used categories
+	Events        DashboardEvents   `json:"events"`
+}
+
+// DashboardEvents summarizes recent lifecycle events
+type DashboardEvents struct {
+	Window string           `json:"window"`
+	ByType map[string]int64 `json:"by_type"` // Events within the window by type
+	Recent []EventResponse  `json:"recent"`  // Newest first
+}
+
+// Fleet health classes
+const (
+	FleetHealthy  = "healthy"
+	FleetDegraded = "degraded"
+	FleetStale    = "stale" // Missed its heartbeat but not yet pruned
+	FleetArchived = "archived"
+)
+
+// Overall fleet statuses
+const (
+	FleetStatusOK       = "ok"
+	FleetStatusDegraded = "degraded" // A threshold was breached
+)
+
+// FleetCounts counts services in each health class
+type FleetCounts struct {
+	Healthy  int64 `json:"healthy"`
+	Degraded int64 `json:"degraded"`
+	Stale    int64 `json:"stale"`
+	Archived int64 `json:"archived"`
+}
+
+// Add counts n services of a health class
+func (c *FleetCounts) Add(class string, n int64) {
+	switch class {
+	case FleetHealthy:
+		c.Healthy += n
+	case FleetDegraded:
+		c.Degraded += n
+	case FleetStale:
+		c.Stale += n
+	case FleetArchived:
+		c.Archived += n
+	}
+}
+
+// HealthSummaryResponse is the health of every published service, overall
+// and broken down by namespace and category
+type HealthSummaryResponse struct {
+	Status      string                 `json:"status"`   // ok, or degraded if a threshold was breached
+	Breaches    []string               `json:"breaches"` // Thresholds that were breached
+	Totals      FleetCounts            `json:"totals"`
+	ByNamespace map[string]FleetCounts `json:"by_namespace"`
+	ByCategory  map[string]FleetCounts `json:"by_category"`
+}
+
+// CapabilitySummary is a capability with the published services exposing it
+type CapabilitySummary struct {
+	Name       string   `json:"name"`
+	Count      int      `json:"count"`
+	ServiceIDs []string `json:"service_ids"`
+}
+
+// ServiceAlias is an alternate name a service can be found and resolved by
+type ServiceAlias struct {
+	ID        uint   `json:"-" gorm:"primaryKey"`
+	ServiceID string `json:"-" gorm:"index"`
+	Name      string `json:"name" gorm:"index"`
 }
 
 // MetadataItem represents a service metadata item
 type MetadataItem struct {
 	ID        uint   `json:"-" gorm:"primaryKey"`
-	ServiceID string `json:"-"`
+	ServiceID string `json:"-" gorm:"index"`
 	Key       string `json:"key"`
 	Value     string `json:"value"`
 }
 
+// ServiceGroup bundles services that gateways enable together, such as a
+// "data-tools suite"
+type ServiceGroup struct {
+	ID          string               `json:"id" gorm:"primaryKey"`
+	Name        string               `json:"name" gorm:"not null;uniqueIndex"`
+	Description string               `json:"description"`
+	Members     []ServiceGroupMember `json:"-" gorm:"foreignKey:GroupID"`
+	Metadata    []GroupMetadataItem  `json:"metadata" gorm:"foreignKey:GroupID"`
+	CreatedAt   time.Time            `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time            `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Client certificate identity, or else the API key or user name, that
+	// created the group. Only it or an admin may change the group.
+	Owner string `json:"owner" gorm:"index"`
+}
+
+// ServiceGroupMember links a service into a group. Memberships outlive their
+// services so a resurrected service rejoins its groups.
+type ServiceGroupMember struct {
+	GroupID   string `gorm:"primaryKey"`
+	ServiceID string `gorm:"primaryKey;index"`
+}
+
+// GroupMetadataItem represents a service group metadata item
+type GroupMetadataItem struct {
+	ID      uint   `json:"-" gorm:"primaryKey"`
+	GroupID string `json:"-"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+}
+
+// Organization owns teams, and through them services
+type Organization struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"not null;uniqueIndex"`
+	Description string    `json:"description"`
+	Teams       []Team    `json:"teams,omitempty" gorm:"foreignKey:OrganizationID"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Team is a group of principals within an organization that owns services.
+// Names are unique within the organization.
+type Team struct {
+	ID             string       `json:"id" gorm:"primaryKey"`
+	OrganizationID string       `json:"organization_id" gorm:"not null;uniqueIndex:idx_team_name"`
+	Name           string       `json:"name" gorm:"not null;uniqueIndex:idx_team_name"`
+	Description    string       `json:"description"`
+	Members        []TeamMember `json:"members,omitempty" gorm:"foreignKey:TeamID"`
+	CreatedAt      time.Time    `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Team roles. Maintainers may manage the team's membership as well as its
+// services.
+const (
+	TeamRoleMember     = "member"
+	TeamRoleMaintainer = "maintainer"
+)
+
+// TeamMember puts a principal, the name of an API key or login, in a team
+type TeamMember struct {
+	TeamID    string    `json:"-" gorm:"primaryKey"`
+	Principal string    `json:"principal" gorm:"primaryKey;index"`
+	Role      string    `json:"role" gorm:"not null;default:member"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// OrganizationRequest represents a request to create an organization
+type OrganizationRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TeamRequest represents a request to create a team in an organization
+type TeamRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// TeamMemberRequest adds a principal to a team or changes their role
+type TeamMemberRequest struct {
+	Principal string `json:"principal"`
+	Role      string `json:"role"` // member or maintainer, defaults to member
+}
+
+// Transfer states
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+)
+
+// ServiceTransfer hands a service to another namespace or team. It only
+// takes effect once the receiving party accepts it: a maintainer of the
+// receiving team, or an admin for transfers between namespaces alone. A
+// service has at most one pending transfer.
+type ServiceTransfer struct {
+	ID            string     `json:"id" gorm:"primaryKey"`
+	ServiceID     string     `json:"service_id" gorm:"not null;index;uniqueIndex:idx_transfer_pending,where:state = 'pending'"`
+	FromNamespace string     `json:"from_namespace"`
+	FromTeamID    string     `json:"from_team_id,omitempty"`
+	ToNamespace   string     `json:"to_namespace"`
+	ToTeamID      string     `json:"to_team_id,omitempty" gorm:"index"`
+	Note          string     `json:"note,omitempty"`
+	State         string     `json:"state" gorm:"not null;default:pending;index"`
+	RequestedBy   string     `json:"requested_by"`
+	ResolvedBy    string     `json:"resolved_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+// TransferRequest asks for a service to be moved. Fields left out keep the
+// service's current namespace or team.
+type TransferRequest struct {
+	Namespace string `json:"namespace"`
+	TeamID    string `json:"team_id"`
+	Note      string `json:"note"`
+}
+
+// ServiceGroupRequest represents a request to create or replace a service group
+type ServiceGroupRequest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	ServiceIDs  []string          `json:"service_ids"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// ServiceGroupResponse represents the outgoing service group response.
+// Services is only filled in when fetching a single group.
+type ServiceGroupResponse struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	ServiceIDs  []string          `json:"service_ids"`
+	Services    []ServiceResponse `json:"services,omitempty"`
+	Metadata    map[string]string `json:"metadata"`
+	Owner       string            `json:"owner"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
 // ServiceRegistrationRequest represents the incoming registration request
 type ServiceRegistrationRequest struct {
+	Namespace    string            `json:"namespace"`
 	Name         string            `json:"name" binding:"required"`
 	Description  string            `json:"description"`
 	URL          string            `json:"url" binding:"required"`
 	Capabilities map[string]bool   `json:"capabilities" binding:"required"`
 	Categories   []string          `json:"categories" binding:"required"`
 	Metadata     map[string]string `json:"metadata"`
+	Aliases      []string          `json:"aliases"` // Alternate names, e.g. previous names after a rename
 	ApiDocs      string            `json:"api_docs"`
+	Weight       int               `json:"weight"`   // Relative weight for weighted round robin, defaults to 1
+	Priority     int               `json:"priority"` // Lower values are preferred over higher ones
+	Region       string            `json:"region"`
+	ProxyTimeout int               `json:"proxy_timeout_seconds"` // Upstream timeout when proxied, 0 uses the registry default
+	State        string            `json:"state"`                 // draft or pending_review, or published when approval isn't required
+	Deprecated   bool              `json:"deprecated"`
+	SunsetAt     *time.Time        `json:"sunset_at"` // Only allowed on deprecated services
+	Replacement  string            `json:"replacement_service_id"`
+	ExternalID   string            `json:"external_id,omitempty"` // Stable caller chosen ID, unique within the namespace
+	Tools        []Tool            `json:"tools,omitempty"`       // Definitions of the tools behind the capabilities
+	TeamID       string            `json:"team_id,omitempty"`     // Owning team, which the caller must belong to
+	Visibility   string            `json:"visibility,omitempty"`  // public or team, defaults to public
+
+	// Custom data by extension name, checked against the schemas the
+	// operator registered. Left out, a service keeps the extensions it has.
+	Extensions Extensions `json:"extensions,omitempty"`
+
+	// MCP protocol revisions the service speaks, e.g. 2025-03-26. Learned by
+	// the health prober when left out.
+	ProtocolVersions []string `json:"protocol_versions,omitempty"`
+}
+
+// ServiceExport is the registration of a single service as returned by
+// /services/{id}/export, without anything the registry maintains itself
+type ServiceExport struct {
+	ID string `json:"id"`
+	ServiceRegistrationRequest
+}
+
+// ErrorResponse is the envelope every error is returned in
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes an error. Code is stable and meant for programs,
+// Message for people.
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// FieldError describes why one field of a request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 // ServiceResponse represents the outgoing service response
 type ServiceResponse struct {
 	ID           string            `json:"id"`
+	Namespace    string            `json:"namespace"`
 	Name         string            `json:"name"`
 	Description  string            `json:"description"`
 	URL          string            `json:"url"`
 	Capabilities map[string]bool   `json:"capabilities"`
 	Categories   []string          `json:"categories"`
 	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
 	LastSeen     time.Time         `json:"last_seen"`
 	Metadata     map[string]string `json:"metadata"`
+	Aliases      []string          `json:"aliases"`
 	ApiDocs      string            `json:"api_docs"`
+	Weight       int               `json:"weight"`
+	Priority     int               `json:"priority"`
+	Region       string            `json:"region"`
+	Status       string            `json:"status"`
+	Flapping     bool              `json:"flapping"`
+	State        string            `json:"state"`
+	ReviewNote   string            `json:"review_note,omitempty"`
+	Verified     bool              `json:"verified"`
+	RatingAvg    *float64          `json:"rating_average"`
+	RatingCount  int               `json:"rating_count"`
+	Deprecated   bool              `json:"deprecated"`
+	SunsetAt     *time.Time        `json:"sunset_at,omitempty"`
+	Replacement  string            `json:"replacement_service_id,omitempty"`
+	ProxyTimeout int               `json:"proxy_timeout_seconds"`
+	Origin       string            `json:"origin,omitempty"`
+	ReadOnly     bool              `json:"read_only"`
+	LatencyP50Ms *float64          `json:"latency_p50_ms"`
+	LatencyP95Ms *float64          `json:"latency_p95_ms"`
+	Version      string            `json:"version,omitempty"`
+	GitSHA       string            `json:"git_sha,omitempty"`
+	ExternalID   string            `json:"external_id,omitempty"`
+	Owner        string            `json:"owner,omitempty"`
+	TeamID       string            `json:"team_id,omitempty"`
+	Visibility   string            `json:"visibility"`
+	Provenance   *Provenance       `json:"provenance,omitempty"` // Only set for signed manifests
+	Tools        []Tool            `json:"tools,omitempty"`
+	Extensions   Extensions        `json:"extensions,omitempty"`
+
+	ProtocolVersions []string `json:"protocol_versions"`
+
+	// When the service will be considered stale without another heartbeat,
+	// filled in by the API but not kept in snapshots or archives
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int        `json:"ttl_seconds,omitempty"`
+
+	// Only returned when a heartbeat token is issued, it is never shown again
+	HeartbeatToken string `json:"heartbeat_token,omitempty"`
+}
+
+// BatchGetRequest lists the services to fetch in one call
+type BatchGetRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// BatchGetResponse returns the found services in the requested order and the
+// IDs that don't exist
+type BatchGetResponse struct {
+	Services []ServiceResponse `json:"services"`
+	Missing  []string          `json:"missing"`
+}
+
+// NegotiateRequest describes what a gateway supports, so the registry can
+// return only the services it can use
+type NegotiateRequest struct {
+	ProtocolVersion string   `json:"protocol_version"` // MCP revision the gateway speaks, e.g. 2025-03-26
+	Capabilities    []string `json:"capabilities"`     // Capabilities the gateway can route
+	Required        []string `json:"required_capabilities"`
+	Namespace       string   `json:"namespace,omitempty"`
+}
+
+// NegotiateResponse lists the compatible services, each with its
+// capabilities narrowed to those the gateway supports
+type NegotiateResponse struct {
+	ProtocolVersion string            `json:"protocol_version,omitempty"`
+	Services        []ServiceResponse `json:"services"`
+}
+
+// Snapshot is a complete export of the registry
+type Snapshot struct {
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Services   []ServiceResponse `json:"services"`
+}
+
+// SnapshotVersion is the snapshot format written by this version of the registry
+const SnapshotVersion = 1
+
+// SnapshotImportResult summarizes a snapshot import
+type SnapshotImportResult struct {
+	Mode     string `json:"mode"`
+	Imported int    `json:"imported"`
+	Deleted  int64  `json:"deleted"`
+}
+
+// DuplicateGroup is a set of services that probably describe the same server
+type DuplicateGroup struct {
+	Reason   string            `json:"reason"`
+	Services []ServiceResponse `json:"services"`
+}
+
+// DuplicateReport lists groups of services that are probably the same
+// server, found by similarity rather than exact matches
+type DuplicateReport struct {
+	GeneratedAt time.Time            `json:"generated_at"`
+	MinScore    float64              `json:"min_score"`
+	Groups      []NearDuplicateGroup `json:"groups"`
+}
+
+// NearDuplicateGroup is a set of services linked by matching pairs, with the
+// service suggested to keep listed first
+type NearDuplicateGroup struct {
+	Services   []ServiceResponse `json:"services"`
+	Matches    []DuplicateMatch  `json:"matches"`
+	Suggestion MergeSuggestion   `json:"suggestion"`
+}
+
+// DuplicateMatch explains why two services look alike. Score is between 0
+// and 1.
+type DuplicateMatch struct {
+	ServiceIDs     [2]string `json:"service_ids"`
+	Score          float64   `json:"score"`
+	Signals        []string  `json:"signals"` // same_host, similar_name, overlapping_tools
+	NameSimilarity float64   `json:"name_similarity"`
+	ToolOverlap    float64   `json:"tool_overlap"`
+}
+
+// MergeSuggestion says which service of a group to keep and what to carry
+// over to it before the others are deprecated in its favour
+type MergeSuggestion struct {
+	Keep       string   `json:"keep"`
+	Merge      []string `json:"merge"`
+	Reason     string   `json:"reason"`
+	AddAliases []string `json:"add_aliases,omitempty"` // Names of merged services, so lookups by them still resolve
+	AddTools   []string `json:"add_tools,omitempty"`   // Tools merged services declare that the kept one doesn't
+}
+
+// UptimeResponse summarizes a service's availability over a window
+type UptimeResponse struct {
+	ServiceID     string             `json:"service_id"`
+	Window        string             `json:"window"`
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	UptimePercent float64            `json:"uptime_percent"`
+	Incidents     []DowntimeIncident `json:"incidents"`
+}
+
+// DowntimeIncident is a continuous stretch of unavailability
+type DowntimeIncident struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+// ReviewRequest carries an optional note when approving or rejecting a service
+type ReviewRequest struct {
+	Note string `json:"note"`
+}
+
+// VerifyRequest sets or clears a service's verified badge. Method records how
+// the publisher was verified, e.g. "dns_txt" or "manual_review".
+type VerifyRequest struct {
+	Verified bool   `json:"verified"`
+	Method   string `json:"method"`
 }
 
-// HeartbeatRequest represents a heartbeat request
+// ReadOnlyRequest toggles whether the registry refuses writes
+type ReadOnlyRequest struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// PruneResult lists the services removed by a prune run
+type PruneResult struct {
+	Pruned []ServiceResponse `json:"pruned"`
+}
+
+// PurgeResult counts what purging a service removed
+type PurgeResult struct {
+	ServiceID string `json:"service_id"`
+	Service   bool   `json:"service"` // Whether the service itself still existed
+	Archives  int64  `json:"archives"`
+	Revisions int64  `json:"revisions"`
+	Audit     int64  `json:"audit_entries"`
+	Events    int64  `json:"events"`
+}
+
+// HeartbeatRequest represents a heartbeat request. The body is optional when
+// POSTing a heartbeat.
 type HeartbeatRequest struct {
-	ServiceID string `json:"service_id" binding:"required"`
+	ServiceID string            `json:"service_id" binding:"required"`
+	Metrics   *HeartbeatMetrics `json:"metrics,omitempty"`
+	Version   string            `json:"version,omitempty"` // Semantic version of the running build
+	GitSHA    string            `json:"git_sha,omitempty"`
+}
+
+// MCPManifest describes an MCP server as published in a server manifest or an
+// mcp.json entry
+type MCPManifest struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	URL         string            `json:"url"`
+	Command     string            `json:"command"` // Set for stdio servers, which can't be registered
+	Tools       []MCPManifestTool `json:"tools"`
+	Categories  []string          `json:"categories"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+// MCPManifestTool is a tool declared by an MCP server manifest
+type MCPManifestTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// ImportDocument accepts a single manifest, a list of manifests under
+// "servers", or an mcp.json file keyed by server name under "mcpServers"
+type ImportDocument struct {
+	MCPManifest
+	Servers    []MCPManifest          `json:"servers"`
+	MCPServers map[string]MCPManifest `json:"mcpServers"`
+}
+
+// ImportResult reports which manifest entries were registered
+type ImportResult struct {
+	Imported []ServiceResponse `json:"imported"`
+	Skipped  []ImportSkipped   `json:"skipped"`
+}
+
+// ImportSkipped is a manifest entry that couldn't be registered
+type ImportSkipped struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
 }
 
 // Helper functions
@@ -88,16 +1305,178 @@ func ServiceModelToResponse(service MCPService) ServiceResponse {
 		metadata[item.Key] = item.Value
 	}
 
+	aliases := make([]string, len(service.Aliases))
+	for i, alias := range service.Aliases {
+		aliases[i] = alias.Name
+	}
+
+	var provenance *Provenance
+	if service.Provenance.Publisher != "" {
+		provenance = &service.Provenance
+	}
+
+	protocolVersions := []string(service.ProtocolVersions)
+	if protocolVersions == nil {
+		protocolVersions = []string{}
+	}
+
+	visibility := service.Visibility
+	if visibility == "" {
+		visibility = VisibilityPublic
+	}
+
 	return ServiceResponse{
 		ID:           service.ID,
+		Namespace:    service.Namespace,
 		Name:         service.Name,
 		Description:  service.Description,
 		URL:          service.URL,
 		Capabilities: capabilities,
 		Categories:   categories,
 		CreatedAt:    service.CreatedAt,
+		UpdatedAt:    service.UpdatedAt,
 		LastSeen:     service.LastSeen,
 		Metadata:     metadata,
+		Aliases:      aliases,
 		ApiDocs:      service.ApiDocs,
+		Weight:       service.Weight,
+		Priority:     service.Priority,
+		Region:       service.Region,
+		Status:       service.Status,
+		Flapping:     service.Flapping,
+		State:        service.State,
+		ReviewNote:   service.ReviewNote,
+		Verified:     service.Verified,
+		RatingAvg:    service.RatingAvg,
+		RatingCount:  service.RatingCount,
+		Deprecated:   service.Deprecated,
+		SunsetAt:     service.SunsetAt,
+		Replacement:  service.Replacement,
+		ProxyTimeout: service.ProxyTimeout,
+		Origin:       service.Origin,
+		ReadOnly:     service.Origin != "",
+		LatencyP50Ms: service.LatencyP50Ms,
+		LatencyP95Ms: service.LatencyP95Ms,
+		Version:      service.Version,
+		GitSHA:       service.GitSHA,
+		ExternalID:   service.ExternalID,
+		Owner:        service.Owner,
+		TeamID:       service.TeamID,
+		Visibility:   visibility,
+		Provenance:   provenance,
+		Tools:        service.Tools,
+		Extensions:   service.Extensions,
+
+		ProtocolVersions: protocolVersions,
+	}
+}
+
+// GroupModelToResponse converts a service group into its response format
+func GroupModelToResponse(group ServiceGroup) ServiceGroupResponse {
+	serviceIDs := make([]string, len(group.Members))
+	for i, member := range group.Members {
+		serviceIDs[i] = member.ServiceID
+	}
+
+	metadata := make(map[string]string)
+	for _, item := range group.Metadata {
+		metadata[item.Key] = item.Value
+	}
+
+	return ServiceGroupResponse{
+		ID:          group.ID,
+		Name:        group.Name,
+		Description: group.Description,
+		ServiceIDs:  serviceIDs,
+		Metadata:    metadata,
+		Owner:       group.Owner,
+		CreatedAt:   group.CreatedAt,
+		UpdatedAt:   group.UpdatedAt,
+	}
+}
+
+// ServiceResponseToModel converts a service as returned by the API back into its database model
+func ServiceResponseToModel(response ServiceResponse) MCPService {
+	service := MCPService{
+		ID:           response.ID,
+		Namespace:    response.Namespace,
+		Name:         response.Name,
+		Description:  response.Description,
+		URL:          response.URL,
+		CreatedAt:    response.CreatedAt,
+		LastSeen:     response.LastSeen,
+		ApiDocs:      response.ApiDocs,
+		Weight:       response.Weight,
+		Priority:     response.Priority,
+		Region:       response.Region,
+		Status:       response.Status,
+		Flapping:     response.Flapping,
+		State:        response.State,
+		ReviewNote:   response.ReviewNote,
+		Verified:     response.Verified,
+		RatingAvg:    response.RatingAvg,
+		RatingCount:  response.RatingCount,
+		Deprecated:   response.Deprecated,
+		SunsetAt:     response.SunsetAt,
+		Replacement:  response.Replacement,
+		ProxyTimeout: response.ProxyTimeout,
+		Origin:       response.Origin,
+		LatencyP50Ms: response.LatencyP50Ms,
+		LatencyP95Ms: response.LatencyP95Ms,
+		Version:      response.Version,
+		GitSHA:       response.GitSHA,
+		ExternalID:   response.ExternalID,
+		Owner:        response.Owner,
+		TeamID:       response.TeamID,
+		Visibility:   response.Visibility,
+		Tools:        response.Tools,
+		Extensions:   response.Extensions,
+
+		ProtocolVersions: response.ProtocolVersions,
+	}
+	if response.Provenance != nil {
+		service.Provenance = *response.Provenance
+	}
+	for name, enabled := range response.Capabilities {
+		service.Capabilities = append(service.Capabilities, Capability{ServiceID: response.ID, Name: name, Enabled: enabled})
+	}
+	for _, name := range response.Categories {
+		service.Categories = append(service.Categories, Category{ServiceID: response.ID, Name: name})
+	}
+	for key, value := range response.Metadata {
+		service.Metadata = append(service.Metadata, MetadataItem{ServiceID: response.ID, Key: key, Value: value})
+	}
+	for _, name := range response.Aliases {
+		service.Aliases = append(service.Aliases, ServiceAlias{ServiceID: response.ID, Name: name})
+	}
+	return service
+}
+
+// ServiceResponseToRegistration turns a service as returned by the API back into the request that would produce it
+func ServiceResponseToRegistration(service ServiceResponse) ServiceRegistrationRequest {
+	return ServiceRegistrationRequest{
+		Namespace:    service.Namespace,
+		Name:         service.Name,
+		Description:  service.Description,
+		URL:          service.URL,
+		Capabilities: service.Capabilities,
+		Categories:   service.Categories,
+		Metadata:     service.Metadata,
+		Aliases:      service.Aliases,
+		ApiDocs:      service.ApiDocs,
+		Weight:       service.Weight,
+		Priority:     service.Priority,
+		Region:       service.Region,
+		ProxyTimeout: service.ProxyTimeout,
+		Deprecated:   service.Deprecated,
+		SunsetAt:     service.SunsetAt,
+		Replacement:  service.Replacement,
+		ExternalID:   service.ExternalID,
+		Tools:        service.Tools,
+		TeamID:       service.TeamID,
+		Visibility:   service.Visibility,
+		Extensions:   service.Extensions,
+
+		ProtocolVersions: service.ProtocolVersions,
 	}
 }
@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+)
+
+// Meter tallies API calls per caller and class of call for chargeback.
+// Like Counter it keeps counts in memory and flushes them into a daily
+// rollup table.
+type Meter struct {
+	DB       *gorm.DB
+	Interval time.Duration
+
+	mu     sync.Mutex
+	counts map[[2]string]int64 // Keyed by principal and class
+}
+
+// NewMeter creates a Meter flushed every interval
+func NewMeter(db *gorm.DB, interval time.Duration) *Meter {
+	return &Meter{
+		DB:       db,
+		Interval: interval,
+		counts:   make(map[[2]string]int64),
+	}
+}
+
+// Record counts one call of a class by principal. A nil Meter ignores it.
+func (m *Meter) Record(principal, class string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[[2]string{principal, class}]++
+}
+
+// Run flushes the counts every interval until the context is cancelled,
+// flushing a final time before returning
+func (m *Meter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.flush()
+			return
+		case <-ticker.C:
+			m.flush()
+		}
+	}
+}
+
+func (m *Meter) flush() {
+	m.mu.Lock()
+	counts := m.counts
+	m.counts = make(map[[2]string]int64)
+	m.mu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+	if err := db.RecordAPIUsage(m.DB, counts, time.Now()); err != nil {
+		log.Printf("Failed to record API usage: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/arnavsurve/gateway-registry/pkg/db"
+)
+
+// Counter tallies how often services are returned by read endpoints. Counts
+// are kept in memory and periodically flushed into the daily rollup table so
+// lookups don't each cost a write.
+type Counter struct {
+	DB       *gorm.DB
+	Interval time.Duration
+
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewCounter creates a Counter flushed every interval
+func NewCounter(db *gorm.DB, interval time.Duration) *Counter {
+	return &Counter{
+		DB:       db,
+		Interval: interval,
+		counts:   make(map[string]int64),
+	}
+}
+
+// Record counts one appearance of each service ID. A nil Counter ignores it.
+func (c *Counter) Record(serviceIDs ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range serviceIDs {
+		c.counts[id]++
+	}
+}
+
+// Run flushes the counts every interval until the context is cancelled,
+// flushing a final time before returning
+func (c *Counter) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.flush()
+			return
+		case <-ticker.C:
+			c.flush()
+		}
+	}
+}
+
+func (c *Counter) flush() {
+	c.mu.Lock()
+	counts := c.counts
+	c.counts = make(map[string]int64)
+	c.mu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+	if err := db.RecordUsage(c.DB, counts, time.Now()); err != nil {
+		log.Printf("Failed to record usage: %v", err)
+	}
+}